@@ -0,0 +1,445 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// BackingStore persists the objects of a cache outside of it, such as in a
+// database, for a BackedStore.
+type BackingStore[T comparable] interface {
+	// Load returns the object stored under key, or ErrNotFound.
+	Load(ctx context.Context, key T) (interface{}, error)
+
+	// Put stores obj under key.
+	Put(ctx context.Context, key T, obj interface{}) error
+
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key T) error
+}
+
+// BackedStore is a ContextStore whose changes are written to a BackingStore,
+// and whose missing objects are loaded from it like a loading store does.
+type BackedStore[T comparable] interface {
+	ContextStore[T]
+
+	// Flush writes the pending changes to the backing store.
+	Flush(ctx context.Context) error
+
+	// Close stops writing changes in the background and flushes the pending
+	// ones.
+	Close() error
+}
+
+// WriteBehindOption configures optional behaviour of a BackedStore created
+// by NewWriteBehindStore.
+type WriteBehindOption[T comparable] func(*writeBehindOptions[T])
+
+// writeBehindOptions holds the optional settings applied by WriteBehindOption.
+type writeBehindOptions[T comparable] struct {
+	batchSize int
+	onError   func(key T, err error)
+}
+
+// WithWriteBatchSize makes the store flush as soon as n changes are pending,
+// without waiting for the flush interval.
+func WithWriteBatchSize[T comparable](n int) WriteBehindOption[T] {
+	return func(o *writeBehindOptions[T]) {
+		o.batchSize = n
+	}
+}
+
+// WithWriteErrorHandler sets a function invoked with every change that
+// failed to be written in the background. Failed changes are retried by the
+// next flush unless the key changed again meanwhile.
+func WithWriteErrorHandler[T comparable](fn func(key T, err error)) WriteBehindOption[T] {
+	return func(o *writeBehindOptions[T]) {
+		o.onError = fn
+	}
+}
+
+// NewWriteThroughStore creates a BackedStore writing every change to backing
+// before applying it to store, so that changes backing refuses fail without
+// reaching store. Objects missing from store are loaded from backing.
+// Transactions, compare-and-swap operations and replacements are applied to
+// store first and then written.
+func NewWriteThroughStore[T comparable](store Store[T], backing BackingStore[T]) BackedStore[T] {
+	return newBackedStore(store, backing, false)
+}
+
+// NewWriteBehindStore creates a BackedStore applying every change to store
+// and queuing it to be written to backing every interval. Several changes of
+// a key made between flushes are written once. Reads of a key whose change is
+// pending return the changed object, even if store evicted it. If store is
+// an EvictionStore, evicted objects with pending changes are written right
+// away, before the eviction returns. An interval of zero or less flushes every
+// second.
+func NewWriteBehindStore[T comparable](store Store[T], backing BackingStore[T], interval time.Duration, opts ...WriteBehindOption[T]) BackedStore[T] {
+	var o writeBehindOptions[T]
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if interval <= 0 {
+		interval = defaultWriteBehindInterval
+	}
+	s := newBackedStore(store, backing, true)
+	s.batchSize = o.batchSize
+	s.onError = o.onError
+	s.flushNow = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	if observable, ok := store.(interface{ AddObserver(Observer[T]) }); ok {
+		observable.AddObserver(ObserverFunc[T](s.observe))
+	}
+	go s.run(interval)
+	return s
+}
+
+// defaultWriteBehindInterval is the interval between the flushes of a
+// write-behind store created with an interval of zero or less.
+const defaultWriteBehindInterval = time.Second
+
+// pendingWrite is a change waiting to be written to the backing store.
+type pendingWrite struct {
+	obj     interface{}
+	deleted bool
+	// seq orders the changes, so that a flush only drops the ones it wrote
+	seq uint64
+}
+
+// backedStore implements BackedStore on top of a loading store reading
+// missing objects from the pending changes and then the backing store.
+type backedStore[T comparable] struct {
+	*loadingStore[T]
+	backing BackingStore[T]
+	// behind queues changes in pending instead of writing them right away
+	behind    bool
+	batchSize int
+	onError   func(key T, err error)
+	// mu guards pending, the changes waiting to be written by key, which
+	// stay there until written so that reads meanwhile still find them
+	mu      sync.Mutex
+	pending map[T]pendingWrite
+	seq     uint64
+	// flushMu serializes flushes, so that changes of a key are written in order
+	flushMu  sync.Mutex
+	flushNow chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// newBackedStore creates a backedStore writing changes right away unless behind.
+func newBackedStore[T comparable](store Store[T], backing BackingStore[T], behind bool) *backedStore[T] {
+	s := &backedStore[T]{
+		backing: backing,
+		behind:  behind,
+		pending: make(map[T]pendingWrite),
+	}
+	s.loadingStore = NewLoadingStore(store, s.load).(*loadingStore[T])
+	return s
+}
+
+// load returns the pending object of key, or else loads it from the backing store.
+func (s *backedStore[T]) load(ctx context.Context, key T) (interface{}, error) {
+	s.mu.Lock()
+	write, ok := s.pending[key]
+	s.mu.Unlock()
+	if ok {
+		if write.deleted {
+			return nil, ErrNotFound
+		}
+		return write.obj, nil
+	}
+	return s.backing.Load(ctx, key)
+}
+
+// Add inserts an object.
+func (s *backedStore[T]) Add(obj interface{}) error {
+	return s.AddCtx(context.Background(), obj)
+}
+
+// Update modifies an object.
+func (s *backedStore[T]) Update(obj interface{}) error {
+	return s.UpdateCtx(context.Background(), obj)
+}
+
+// Delete removes an object.
+func (s *backedStore[T]) Delete(obj interface{}) error {
+	return s.DeleteCtx(context.Background(), obj)
+}
+
+// AddCtx writes obj and inserts it.
+func (s *backedStore[T]) AddCtx(ctx context.Context, obj interface{}) error {
+	return s.put(ctx, obj, s.Store.Add)
+}
+
+// UpdateCtx writes obj and modifies it.
+func (s *backedStore[T]) UpdateCtx(ctx context.Context, obj interface{}) error {
+	return s.put(ctx, obj, s.Store.Update)
+}
+
+// DeleteCtx writes the removal of obj and removes it.
+func (s *backedStore[T]) DeleteCtx(ctx context.Context, obj interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key, err := s.KeyOf(obj)
+	if err != nil {
+		return err
+	}
+	if !s.behind {
+		if err := s.backing.Delete(ctx, key); err != nil {
+			return err
+		}
+		return s.Store.Delete(obj)
+	}
+	if err := s.Store.Delete(obj); err != nil {
+		return err
+	}
+	s.enqueue(key, pendingWrite{deleted: true})
+	return nil
+}
+
+// put writes obj and stores it with op.
+func (s *backedStore[T]) put(ctx context.Context, obj interface{}, op func(obj interface{}) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key, err := s.KeyOf(obj)
+	if err != nil {
+		return err
+	}
+	if !s.behind {
+		if err := s.backing.Put(ctx, key, obj); err != nil {
+			return err
+		}
+		return op(obj)
+	}
+	if err := op(obj); err != nil {
+		return err
+	}
+	s.enqueue(key, pendingWrite{obj: obj})
+	return nil
+}
+
+// AddAll inserts objects one by one.
+func (s *backedStore[T]) AddAll(objs []interface{}) []error {
+	return s.each(objs, s.Add)
+}
+
+// UpdateAll modifies objects one by one.
+func (s *backedStore[T]) UpdateAll(objs []interface{}) []error {
+	return s.each(objs, s.Update)
+}
+
+// DeleteAll removes objects one by one.
+func (s *backedStore[T]) DeleteAll(objs []interface{}) []error {
+	return s.each(objs, s.Delete)
+}
+
+// each applies op to every object, since every object is written separately.
+func (s *backedStore[T]) each(objs []interface{}, op func(obj interface{}) error) []error {
+	errs := make([]error, len(objs))
+	for i, obj := range objs {
+		errs[i] = op(obj)
+	}
+	return batchErrors(errs)
+}
+
+// CompareAndSwap replaces old by new if old is the stored object, and then
+// writes new.
+func (s *backedStore[T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	swapped, err := s.Store.CompareAndSwap(old, new)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	return true, s.sync(context.Background(), new)
+}
+
+// CompareAndDelete removes old if it is the stored object, and then writes
+// the removal.
+func (s *backedStore[T]) CompareAndDelete(old interface{}) (bool, error) {
+	deleted, err := s.Store.CompareAndDelete(old)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	return true, s.sync(context.Background(), old)
+}
+
+// Replace replaces all objects and writes the changes.
+func (s *backedStore[T]) Replace(list []interface{}) error {
+	_, _, _, err := s.ReplaceWithDiff(list)
+	return err
+}
+
+// ReplaceWithDiff replaces all objects, writes the changes and reports them.
+func (s *backedStore[T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	added, updated, removed, err := s.Store.ReplaceWithDiff(list)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ctx := context.Background()
+	var errs []error
+	for _, keys := range [][]T{added, updated} {
+		for _, key := range keys {
+			if obj, exists, err := s.Store.GetByKey(key); err == nil && exists {
+				errs = append(errs, s.write(ctx, key, pendingWrite{obj: obj}))
+			}
+		}
+	}
+	for _, key := range removed {
+		errs = append(errs, s.write(ctx, key, pendingWrite{deleted: true}))
+	}
+	return added, updated, removed, errors.Join(errs...)
+}
+
+// Txn applies the changes buffered by fn to the store and then writes them.
+func (s *backedStore[T]) Txn(fn func(tx Txn[T]) error) error {
+	var changed []interface{}
+	err := s.Store.Txn(func(tx Txn[T]) error {
+		return fn(recordingTxn[T]{tx, &changed})
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, obj := range changed {
+		errs = append(errs, s.sync(context.Background(), obj))
+	}
+	return errors.Join(errs...)
+}
+
+// sync writes the object stored under the key of obj, or its removal.
+func (s *backedStore[T]) sync(ctx context.Context, obj interface{}) error {
+	key, err := s.KeyOf(obj)
+	if err != nil {
+		return err
+	}
+	stored, exists, err := s.Store.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, key, pendingWrite{obj: stored, deleted: !exists})
+}
+
+// write writes a change right away, or queues it for a write-behind store.
+func (s *backedStore[T]) write(ctx context.Context, key T, write pendingWrite) error {
+	if s.behind {
+		s.enqueue(key, write)
+		return nil
+	}
+	return s.writeBacking(ctx, key, write)
+}
+
+// writeBacking applies a change to the backing store.
+func (s *backedStore[T]) writeBacking(ctx context.Context, key T, write pendingWrite) error {
+	if write.deleted {
+		return s.backing.Delete(ctx, key)
+	}
+	return s.backing.Put(ctx, key, write.obj)
+}
+
+// enqueue records a change to be written by the next flush.
+func (s *backedStore[T]) enqueue(key T, write pendingWrite) {
+	s.mu.Lock()
+	s.seq++
+	write.seq = s.seq
+	s.pending[key] = write
+	full := s.batchSize > 0 && len(s.pending) >= s.batchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes the pending changes, returning the errors of those that
+// failed, which stay pending.
+func (s *backedStore[T]) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending := make(map[T]pendingWrite, len(s.pending))
+	for key, write := range s.pending {
+		pending[key] = write
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for key, write := range pending {
+		if err := s.flushWrite(ctx, key, write); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushKey writes the pending change of key, if any.
+func (s *backedStore[T]) flushKey(key T) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	write, ok := s.pending[key]
+	s.mu.Unlock()
+	if ok {
+		_ = s.flushWrite(context.Background(), key, write)
+	}
+}
+
+// flushWrite writes a pending change and drops it unless the key changed
+// again meanwhile. The caller must hold s.flushMu.
+func (s *backedStore[T]) flushWrite(ctx context.Context, key T, write pendingWrite) error {
+	if err := s.writeBacking(ctx, key, write); err != nil {
+		if s.onError != nil {
+			s.onError(key, err)
+		}
+		return err
+	}
+	s.mu.Lock()
+	if s.pending[key].seq == write.seq {
+		delete(s.pending, key)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// observe writes the pending change of an object evicted from the store.
+func (s *backedStore[T]) observe(event Event[T]) {
+	if event.Type == EventEvict {
+		s.flushKey(event.Key)
+	}
+}
+
+// run flushes every interval, or when the batch size is reached, until Close.
+func (s *backedStore[T]) run(interval time.Duration) {
+	defer close(s.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.flushNow:
+		case <-s.stop:
+			return
+		}
+		_ = s.Flush(context.Background())
+	}
+}
+
+// Close stops the background flushes of a write-behind store and flushes the
+// pending changes.
+func (s *backedStore[T]) Close() error {
+	if s.stop == nil {
+		return nil
+	}
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.stopped
+	return s.Flush(context.Background())
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuxinbot/cache/eviction"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryBacking is a BackingStore keeping objects in a map.
+type memoryBacking struct {
+	mu      sync.Mutex
+	objs    map[string]interface{}
+	puts    int
+	failPut error
+}
+
+func newMemoryBacking() *memoryBacking {
+	return &memoryBacking{objs: make(map[string]interface{})}
+}
+
+func (b *memoryBacking) Load(ctx context.Context, key string) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	obj, ok := b.objs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return obj, nil
+}
+
+func (b *memoryBacking) Put(ctx context.Context, key string, obj interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failPut != nil {
+		return b.failPut
+	}
+	b.puts++
+	b.objs[key] = obj
+	return nil
+}
+
+func (b *memoryBacking) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.objs, key)
+	return nil
+}
+
+func (b *memoryBacking) keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var keys []string
+	for key := range b.objs {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestWriteThroughStore(t *testing.T) {
+	backing := newMemoryBacking()
+	backing.objs["stored"] = "stored"
+	store := NewWriteThroughStore(NewStore(testKeyFunc), backing)
+
+	assert.NoError(t, store.Add("a"))
+	assert.Nil(t, store.AddAll([]interface{}{"b", "c"}))
+	assert.NoError(t, store.Delete("b"))
+	assert.ElementsMatch(t, []string{"stored", "a", "c"}, backing.keys())
+
+	// Objects missing from the store are loaded from the backing store
+	item, exists, err := store.GetByKey("stored")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "stored", item)
+
+	// Changes refused by the backing store do not reach the store
+	backing.failPut = errors.New("read-only database")
+	assert.ErrorIs(t, store.Add("d"), backing.failPut)
+	assert.ElementsMatch(t, []string{"stored", "a", "c"}, store.ListKeys())
+	assert.NoError(t, store.Close())
+}
+
+func TestWriteThroughStoreReplaceAndTxn(t *testing.T) {
+	backing := newMemoryBacking()
+	store := NewWriteThroughStore(NewStore(testKeyFunc), backing)
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+
+	assert.NoError(t, store.Replace([]interface{}{"b", "c"}))
+	assert.ElementsMatch(t, []string{"b", "c"}, backing.keys())
+
+	assert.NoError(t, store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Delete("b"))
+		return tx.Add("d")
+	}))
+	assert.ElementsMatch(t, []string{"c", "d"}, backing.keys())
+}
+
+func TestWriteBehindStore(t *testing.T) {
+	backing := newMemoryBacking()
+	store := NewWriteBehindStore[string](NewStore(testKeyFunc), backing, time.Hour)
+	defer store.Close()
+
+	assert.NoError(t, store.Add("a"))
+	assert.NoError(t, store.Update("a"))
+	assert.NoError(t, store.Add("b"))
+	assert.NoError(t, store.Delete("b"))
+	assert.Empty(t, backing.keys())
+
+	assert.NoError(t, store.Flush(context.Background()))
+	assert.Equal(t, []string{"a"}, backing.keys())
+	// Both changes of a are written once
+	assert.Equal(t, 1, backing.puts)
+}
+
+func TestWriteBehindStoreDefaultInterval(t *testing.T) {
+	backing := newMemoryBacking()
+	// An interval of zero falls back to the default rather than panicking
+	store := NewWriteBehindStore[string](NewStore(testKeyFunc), backing, 0)
+	assert.NoError(t, store.Add("a"))
+	assert.NoError(t, store.Close())
+	assert.Equal(t, []string{"a"}, backing.keys())
+}
+
+func TestWriteBehindStoreRetry(t *testing.T) {
+	backing := newMemoryBacking()
+	backing.failPut = errors.New("database is down")
+	var failed []string
+	store := NewWriteBehindStore(NewStore(testKeyFunc), backing, time.Hour,
+		WithWriteErrorHandler(func(key string, err error) {
+			failed = append(failed, key)
+		}))
+	assert.NoError(t, store.Add("a"))
+
+	assert.ErrorIs(t, store.Flush(context.Background()), backing.failPut)
+	assert.Equal(t, []string{"a"}, failed)
+
+	backing.failPut = nil
+	assert.NoError(t, store.Close())
+	assert.Equal(t, []string{"a"}, backing.keys())
+}
+
+func TestWriteBehindStoreBatchSize(t *testing.T) {
+	backing := newMemoryBacking()
+	store := NewWriteBehindStore(NewStore(testKeyFunc), backing, time.Hour, WithWriteBatchSize[string](2))
+	defer store.Close()
+
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+
+	assert.Eventually(t, func() bool {
+		return len(backing.keys()) == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestWriteBehindStoreEviction(t *testing.T) {
+	backing := newMemoryBacking()
+	cache := NewEvictionCache[any](testKeyFunc, eviction.NewFIFO[string](1), Indexers[any]{})
+	store := NewWriteBehindStore[string](cache, backing, time.Hour)
+	defer store.Close()
+
+	assert.NoError(t, store.Add("a"))
+	assert.NoError(t, store.Add("b"))
+
+	// a was written when it was evicted to make room for b
+	assert.Equal(t, []string{"a"}, backing.keys())
+	assert.Equal(t, []string{"b"}, cache.ListKeys())
+	item, exists, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "a", item)
+}
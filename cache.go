@@ -1,30 +1,60 @@
 package cache
 
+import (
+	"iter"
+	"sync/atomic"
+)
+
 // NewStore creates a new Store.
-func NewStore[T comparable](keyFunc KeyFunc[T]) Store[T] {
-	return &cache[any, T]{
-		store:   NewThreadSafeStore(Indexers[any]{}, Indexes[any, T]{}),
-		keyFunc: keyFunc,
-	}
+func NewStore[T comparable](keyFunc KeyFunc[T], opts ...StoreOption) Store[T] {
+	return newCache[any](keyFunc, opts)
 }
 
 // NewIndexer creates a new IndexedStore.
-func NewIndexer[K, T comparable](keyFunc KeyFunc[T]) IndexedStore[K, T] {
+func NewIndexer[K, T comparable](keyFunc KeyFunc[T], opts ...StoreOption) IndexedStore[K, T] {
+	return newCache[K](keyFunc, opts)
+}
+
+// newCache creates a cache configured by opts.
+func newCache[K, T comparable](keyFunc KeyFunc[T], opts []StoreOption) *cache[K, T] {
+	var o storeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var meta *metaTracker[T]
+	if o.meta {
+		meta = newMetaTracker[T](o.metaClock)
+	}
 	return &cache[K, T]{
-		store:   NewThreadSafeStore(Indexers[K]{}, Indexes[K, T]{}),
+		store:   NewThreadSafeStore(Indexers[K]{}, Indexes[K, T]{}, o.storeOpts...),
 		keyFunc: keyFunc,
+		limits:  newStoreLimits[T](o),
+		copier:  o.copier,
+		meta:    meta,
+		version: o.version,
 	}
 }
 
 // cache implements Store and IndexedStore.
 type cache[K, T comparable] struct {
-	store ThreadSafeStore[K, T]
+	store ThreadSafeStore[K, T, interface{}]
 	// keyFunc is used to make the key for objects stored in and retrieved from items
 	keyFunc KeyFunc[T]
+	// limits bounds the growth of the store, nil if it is unbounded
+	limits *storeLimits[T]
+	// copier copies the items returned to callers, nil if they share them
+	copier func(obj interface{}) interface{}
+	// meta records the history of the items, nil if it is not tracked
+	meta *metaTracker[T]
+	// version returns the version of items, nil if stale writes are not rejected
+	version VersionFunc
+	// counters are reported by Stats
+	counters storeCounters
 }
 
 var _ Store[any] = &cache[any, any]{}
 var _ IndexedStore[any, any] = &cache[any, any]{}
+var _ StatsProvider = &cache[any, any]{}
 
 // Add inserts an item into the cache.
 func (c *cache[K, T]) Add(obj interface{}) error {
@@ -32,8 +62,7 @@ func (c *cache[K, T]) Add(obj interface{}) error {
 	if err != nil {
 		return KeyError{obj, err}
 	}
-	c.store.Add(key, obj)
-	return nil
+	return c.put(key, obj, &c.counters.adds)
 }
 
 // Update sets an item in the cache to its updated state.
@@ -42,23 +71,284 @@ func (c *cache[K, T]) Update(obj interface{}) error {
 	if err != nil {
 		return KeyError{obj, err}
 	}
-	c.store.Update(key, obj)
+	return c.put(key, obj, &c.counters.updates)
+}
+
+// put stores obj under key, enforcing the configured limits, and increments
+// counter if it did.
+func (c *cache[K, T]) put(key T, obj interface{}, counter *atomic.Uint64) error {
+	var version uint64
+	if c.version != nil {
+		var err error
+		if version, err = c.versionOf(obj); err != nil {
+			return err
+		}
+	}
+	if c.limits == nil {
+		if c.version != nil {
+			return c.putVersioned(key, obj, version, counter)
+		}
+		if err := c.store.Update(key, obj); err != nil {
+			return err
+		}
+		c.meta.stored(key)
+		counter.Add(1)
+		return nil
+	}
+	c.limits.mu.Lock()
+	defer c.limits.mu.Unlock()
+	current, exists := c.store.Get(key)
+	if c.version != nil {
+		if err := c.checkVersion(key, version, current, exists); err != nil {
+			return err
+		}
+	}
+	if !c.limits.reserve(key, obj, exists, c.store.Size()) {
+		return c.limits.overflow(obj)
+	}
+	if err := c.store.Update(key, obj); err != nil {
+		c.limits.record(key, current, exists)
+		return err
+	}
+	c.meta.stored(key)
+	counter.Add(1)
+	return nil
+}
+
+// putVersioned stores obj, whose version is version, under key unless the
+// stored object is newer, comparing and storing them atomically.
+func (c *cache[K, T]) putVersioned(key T, obj interface{}, version uint64, counter *atomic.Uint64) error {
+	var err error
+	computeErr := c.store.Compute(key, func(current interface{}, exists bool) (interface{}, bool) {
+		if err = c.checkVersion(key, version, current, exists); err != nil {
+			return current, false
+		}
+		return obj, false
+	})
+	if err != nil {
+		return err
+	}
+	if computeErr != nil {
+		return computeErr
+	}
+	c.meta.stored(key)
+	counter.Add(1)
 	return nil
 }
 
+// AddAll inserts items into the cache in a single batch.
+func (c *cache[K, T]) AddAll(objs []interface{}) []error {
+	return c.putAll(objs, &c.counters.adds)
+}
+
+// UpdateAll sets items in the cache to their updated state in a single batch.
+func (c *cache[K, T]) UpdateAll(objs []interface{}) []error {
+	return c.putAll(objs, &c.counters.updates)
+}
+
+// putAll stores objs, enforcing the configured limits on each of them, and
+// adds the number of stored objects to counter.
+func (c *cache[K, T]) putAll(objs []interface{}, counter *atomic.Uint64) []error {
+	errs := make([]error, len(objs))
+	if c.version != nil {
+		// Versions are checked object by object
+		for i, obj := range objs {
+			key, err := c.keyFunc(obj)
+			if err != nil {
+				errs[i] = KeyError{obj, err}
+				continue
+			}
+			errs[i] = c.put(key, obj, counter)
+		}
+		return batchErrors(errs)
+	}
+	keys := make([]T, 0, len(objs))
+	stored := make([]interface{}, 0, len(objs))
+	// positions holds the position in objs of each stored object
+	positions := make([]int, 0, len(objs))
+	// count and added track the number of objects as the batch is checked
+	var count int
+	added := make(map[T]struct{})
+	if c.limits != nil {
+		c.limits.mu.Lock()
+		defer c.limits.mu.Unlock()
+		count = c.store.Size()
+	}
+	for i, obj := range objs {
+		key, err := c.keyFunc(obj)
+		if err != nil {
+			errs[i] = KeyError{obj, err}
+			continue
+		}
+		if c.limits != nil {
+			_, exists := added[key]
+			if !exists {
+				_, exists = c.store.Get(key)
+			}
+			if !c.limits.reserve(key, obj, exists, count) {
+				errs[i] = c.limits.overflow(obj)
+				continue
+			}
+			if !exists {
+				added[key] = struct{}{}
+				count++
+			}
+		}
+		keys = append(keys, key)
+		stored = append(stored, obj)
+		positions = append(positions, i)
+	}
+	storeErrs := c.store.UpdateAll(keys, stored)
+	for j, key := range keys {
+		if storeErrs != nil && storeErrs[j] != nil {
+			errs[positions[j]] = storeErrs[j]
+			if c.limits != nil {
+				current, exists := c.store.Get(key)
+				c.limits.record(key, current, exists)
+			}
+			continue
+		}
+		c.meta.stored(key)
+		counter.Add(1)
+	}
+	return batchErrors(errs)
+}
+
+// DeleteAll removes items from the cache in a single batch.
+func (c *cache[K, T]) DeleteAll(objs []interface{}) []error {
+	errs := make([]error, len(objs))
+	keys := make([]T, 0, len(objs))
+	for i, obj := range objs {
+		key, err := c.keyFunc(obj)
+		if err != nil {
+			errs[i] = KeyError{obj, err}
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if c.limits != nil {
+		c.limits.mu.Lock()
+		defer c.limits.mu.Unlock()
+		for _, key := range keys {
+			c.limits.release(key)
+		}
+	}
+	c.store.DeleteAll(keys)
+	for _, key := range keys {
+		c.meta.forget(key)
+	}
+	c.counters.deletes.Add(uint64(len(keys)))
+	return batchErrors(errs)
+}
+
 // Delete removes an item from the cache.
 func (c *cache[K, T]) Delete(obj interface{}) error {
 	key, err := c.keyFunc(obj)
 	if err != nil {
 		return KeyError{obj, err}
 	}
+	if c.limits != nil {
+		c.limits.mu.Lock()
+		defer c.limits.mu.Unlock()
+		c.limits.release(key)
+	}
 	c.store.Delete(key)
+	c.meta.forget(key)
+	c.counters.deletes.Add(1)
 	return nil
 }
 
+// CompareAndSwap replaces old by new if old is the stored item.
+func (c *cache[K, T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	key, err := c.keyFunc(new)
+	if err != nil {
+		return false, KeyError{new, err}
+	}
+	if c.limits == nil {
+		swapped, err := c.store.CompareAndSwap(key, old, new)
+		if swapped {
+			c.meta.stored(key)
+			c.counters.updates.Add(1)
+		}
+		return swapped, err
+	}
+	c.limits.mu.Lock()
+	defer c.limits.mu.Unlock()
+	if current, exists := c.store.Get(key); !exists || !sameObject(current, old) {
+		return false, nil
+	}
+	if !c.limits.reserve(key, new, true, c.store.Size()) {
+		return false, c.limits.overflow(new)
+	}
+	if err := c.store.Update(key, new); err != nil {
+		c.limits.record(key, old, true)
+		return false, err
+	}
+	c.meta.stored(key)
+	c.counters.updates.Add(1)
+	return true, nil
+}
+
+// CompareAndDelete removes old if it is the stored item.
+func (c *cache[K, T]) CompareAndDelete(old interface{}) (bool, error) {
+	key, err := c.keyFunc(old)
+	if err != nil {
+		return false, KeyError{old, err}
+	}
+	if c.limits == nil {
+		deleted := c.store.CompareAndDelete(key, old)
+		if deleted {
+			c.meta.forget(key)
+			c.counters.deletes.Add(1)
+		}
+		return deleted, nil
+	}
+	c.limits.mu.Lock()
+	defer c.limits.mu.Unlock()
+	if !c.store.CompareAndDelete(key, old) {
+		return false, nil
+	}
+	c.limits.release(key)
+	c.meta.forget(key)
+	c.counters.deletes.Add(1)
+	return true, nil
+}
+
 // List returns a list of all the items.
 func (c *cache[K, T]) List() []interface{} {
-	return c.store.List()
+	return c.copyList(c.store.List())
+}
+
+// ListFiltered returns the items for which pred returns true.
+func (c *cache[K, T]) ListFiltered(pred func(obj interface{}) bool) []interface{} {
+	return c.copyList(c.store.ListFiltered(pred))
+}
+
+// ListKeysFiltered returns the keys of the items for which pred returns true.
+func (c *cache[K, T]) ListKeysFiltered(pred func(obj interface{}) bool) []T {
+	return c.store.ListKeysFiltered(pred)
+}
+
+// Range calls fn for every item until fn returns false.
+func (c *cache[K, T]) Range(fn func(key T, obj interface{}) bool) {
+	if c.copier == nil {
+		c.store.Range(fn)
+		return
+	}
+	c.store.Range(func(key T, obj interface{}) bool {
+		return fn(key, c.copier(obj))
+	})
+}
+
+// All returns an iterator over the keys and items.
+func (c *cache[K, T]) All() iter.Seq2[T, interface{}] {
+	return c.Range
+}
+
+// ListPage returns a page of items and the continue token of the next one.
+func (c *cache[K, T]) ListPage(limit int, continueToken string) ([]interface{}, string, error) {
+	items, next, err := c.store.ListPage(limit, continueToken)
+	return c.copyList(items), next, err
 }
 
 // ListKeys returns a list of all the keys of the objects currently
@@ -70,13 +360,71 @@ func (c *cache[K, T]) ListKeys() []T {
 // ListKeysByIndex returns the storage keys of the stored objects whose set of
 // indexed values for the named index includes the given indexed value.
 func (c *cache[K, T]) ListKeysByIndex(indexName string, indexedValue K) ([]T, error) {
+	c.counters.indexQueries.Add(1)
 	return c.store.IndexKeys(indexName, indexedValue, nil)
 }
 
+// CountByIndex returns the number of stored objects whose set of indexed
+// values for the named index includes the given indexed value.
+func (c *cache[K, T]) CountByIndex(indexName string, indexedValue K) (int, error) {
+	c.counters.indexQueries.Add(1)
+	return c.store.CountByIndex(indexName, indexedValue)
+}
+
 // ListByIndex returns the stored objects whose set of indexed values
 // for the named index includes the given indexed value.
 func (c *cache[K, T]) ListByIndex(indexName string, indexedValue K) ([]interface{}, error) {
-	return c.store.ByIndex(indexName, indexedValue, nil)
+	c.counters.indexQueries.Add(1)
+	items, err := c.store.ByIndex(indexName, indexedValue, nil)
+	return c.copyList(items), err
+}
+
+// ListByIndexValues returns the stored objects whose set of indexed values
+// for the named index includes any of the given indexed values.
+func (c *cache[K, T]) ListByIndexValues(indexName string, indexedValues ...K) ([]interface{}, error) {
+	c.counters.indexQueries.Add(1)
+	items, err := c.store.ByIndexValues(indexName, indexedValues...)
+	return c.copyList(items), err
+}
+
+// Query returns a Query over the stored objects.
+func (c *cache[K, T]) Query() *Query[K, T, interface{}] {
+	return newQuery(c.ListByIndexes, c.Range)
+}
+
+// ListByIndexes returns the stored objects whose set of indexed values
+// includes the queried value for every index in queries.
+func (c *cache[K, T]) ListByIndexes(queries map[string]K) ([]interface{}, error) {
+	c.counters.indexQueries.Add(1)
+	items, err := c.store.ByIndexes(queries)
+	return c.copyList(items), err
+}
+
+// ListIndexValues returns the distinct indexed values of the stored objects
+// for the named index.
+func (c *cache[K, T]) ListIndexValues(indexName string) ([]K, error) {
+	c.counters.indexQueries.Add(1)
+	return c.store.IndexValues(indexName)
+}
+
+// IndexStats returns the number of stored objects held by each value of the
+// named index.
+func (c *cache[K, T]) IndexStats(indexName string) (IndexStats[K], error) {
+	return c.store.IndexStats(indexName)
+}
+
+// GroupByIndex returns the storage keys of the stored objects held by each
+// value of the named index.
+func (c *cache[K, T]) GroupByIndex(indexName string) (map[K][]T, error) {
+	c.counters.indexQueries.Add(1)
+	return c.store.GroupByIndex(indexName)
+}
+
+// GroupCountsByIndex returns the number of stored objects held by each value
+// of the named index.
+func (c *cache[K, T]) GroupCountsByIndex(indexName string) (map[K]int, error) {
+	c.counters.indexQueries.Add(1)
+	return c.store.GroupCountsByIndex(indexName)
 }
 
 // AddIndexer add new indexer.
@@ -89,6 +437,98 @@ func (c *cache[K, T]) AddIndexers(newIndexers Indexers[K]) error {
 	return c.store.AddIndexers(newIndexers)
 }
 
+// RemoveIndexer removes an indexer and its index from this store.
+func (c *cache[K, T]) RemoveIndexer(indexName string) error {
+	return c.store.RemoveIndexer(indexName)
+}
+
+// Reindex rebuilds an index from the stored objects.
+func (c *cache[K, T]) Reindex(indexName string) error {
+	return c.store.Reindex(indexName)
+}
+
+// OnIndexChange registers hook for the changes of an index.
+func (c *cache[K, T]) OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error {
+	return c.store.OnIndexChange(indexName, hook)
+}
+
+// SetIndexNormalizer makes normalize map the values of an index.
+func (c *cache[K, T]) SetIndexNormalizer(indexName string, normalize func(K) K) error {
+	return c.store.SetIndexNormalizer(indexName, normalize)
+}
+
+// AddUniqueIndexer adds new unique indexer.
+func (c *cache[K, T]) AddUniqueIndexer(indexName string, indexFunc IndexFunc[K]) error {
+	return c.store.AddUniqueIndexer(indexName, indexFunc)
+}
+
+// GetByUniqueIndex returns the stored item holding indexedValue in the named
+// unique index.
+func (c *cache[K, T]) GetByUniqueIndex(indexName string, indexedValue K) (interface{}, bool, error) {
+	c.counters.indexQueries.Add(1)
+	item, exists, err := c.store.GetByUniqueIndex(indexName, indexedValue)
+	if exists {
+		item = c.copy(item)
+	}
+	return item, exists, err
+}
+
+// AddOrderedIndexer adds new ordered indexer.
+func (c *cache[K, T]) AddOrderedIndexer(indexName string, indexFunc IndexFunc[K], compare func(a, b K) int) error {
+	return c.store.AddOrderedIndexer(indexName, indexFunc, compare)
+}
+
+// ListByIndexRange returns the stored objects holding a value in [from, to)
+// in the named ordered index.
+func (c *cache[K, T]) ListByIndexRange(indexName string, from, to K) ([]interface{}, error) {
+	return c.byRange(indexName, indexRange[K]{from: &from, fromInclusive: true, to: &to})
+}
+
+// ListByIndexGreaterThan returns the stored objects holding a value greater
+// than indexedValue in the named ordered index.
+func (c *cache[K, T]) ListByIndexGreaterThan(indexName string, indexedValue K) ([]interface{}, error) {
+	return c.byRange(indexName, indexRange[K]{from: &indexedValue})
+}
+
+// ListByIndexLessThan returns the stored objects holding a value less than
+// indexedValue in the named ordered index.
+func (c *cache[K, T]) ListByIndexLessThan(indexName string, indexedValue K) ([]interface{}, error) {
+	return c.byRange(indexName, indexRange[K]{to: &indexedValue})
+}
+
+// matchValues returns the stored objects holding any of indexedValues in the
+// named index, along with how many of them each holds.
+func (c *cache[K, T]) matchValues(indexName string, indexedValues []K) ([]TokenMatch[interface{}], error) {
+	c.counters.indexQueries.Add(1)
+	matches, err := c.store.(valueMatcher[K, interface{}]).matchValues(indexName, indexedValues)
+	for i := range matches {
+		matches[i].Object = c.copy(matches[i].Object)
+	}
+	return matches, err
+}
+
+// byRange returns the stored objects holding a value within r in the named
+// ordered index.
+func (c *cache[K, T]) byRange(indexName string, r indexRange[K]) ([]interface{}, error) {
+	c.counters.indexQueries.Add(1)
+	items, err := c.store.(indexRanger[K, interface{}]).byRange(indexName, r)
+	return c.copyList(items), err
+}
+
+// GetIndexers returns a copy of the indexers of this store.
+func (c *cache[K, T]) GetIndexers() Indexers[K] {
+	return c.store.GetIndexers()
+}
+
+// KeyOf returns the key of obj in the cache.
+func (c *cache[K, T]) KeyOf(obj interface{}) (T, error) {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return key, KeyError{obj, err}
+	}
+	return key, nil
+}
+
 // Get returns the requested item。
 func (c *cache[K, T]) Get(obj interface{}) (item interface{}, exists bool, err error) {
 	key, err := c.keyFunc(obj)
@@ -101,24 +541,198 @@ func (c *cache[K, T]) Get(obj interface{}) (item interface{}, exists bool, err e
 // GetByKey returns the requested item。
 func (c *cache[K, T]) GetByKey(key T) (interface{}, bool, error) {
 	item, exists := c.store.Get(key)
+	c.counters.get(exists)
+	if exists {
+		item = c.copy(item)
+		c.meta.accessed(key)
+	}
 	return item, exists, nil
 }
 
+// GetMany returns the requested items and the keys that were not found.
+func (c *cache[K, T]) GetMany(keys []T) (map[T]interface{}, []T) {
+	found := c.store.GetMany(keys)
+	items := make(map[T]interface{}, len(found))
+	var missing []T
+	for _, key := range keys {
+		item, exists := found[key]
+		c.counters.get(exists)
+		if exists {
+			items[key] = c.copy(item)
+			c.meta.accessed(key)
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return items, missing
+}
+
+// Contains reports whether an item is stored under key, without copying it or
+// recording an access.
+func (c *cache[K, T]) Contains(key T) bool {
+	return c.store.Contains(key)
+}
+
+// GetMeta returns the history of the item stored under key, if the cache was
+// created WithMetadata.
+func (c *cache[K, T]) GetMeta(key T) (EntryMeta, bool) {
+	return c.meta.get(key)
+}
+
 // Replace will delete the contents of 'c', using instead the given list.
+// Only the indices of the objects that changed are updated.
 func (c *cache[K, T]) Replace(list []interface{}) error {
+	keys, items, err := c.keyItems(list)
+	if err != nil {
+		return err
+	}
+	if c.limits != nil {
+		c.limits.mu.Lock()
+		defer c.limits.mu.Unlock()
+		if err := c.limits.reset(keys, items); err != nil {
+			return err
+		}
+	}
+	err = c.store.ReplaceIncremental(items)
+	c.dropUnstored(items, err)
+	return err
+}
+
+// ReplaceWithDiff replaces the contents of the cache like Replace and reports
+// what changed.
+func (c *cache[K, T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	keys, items, err := c.keyItems(list)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if c.limits != nil {
+		c.limits.mu.Lock()
+		defer c.limits.mu.Unlock()
+		if err := c.limits.reset(keys, items); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	old, err := c.store.Swap(items)
+	c.dropUnstored(items, err)
+	oldKeys := make([]T, 0, len(old))
+	for key := range old {
+		oldKeys = append(oldKeys, key)
+	}
+	added, updated, removed := replaceDiff(oldKeys, keys, items)
+	return added, updated, removed, err
+}
+
+// dropUnstored removes from items the objects that the replacement failing
+// with err left out of the store, along with their recorded sizes, then
+// records the replacement in the metadata. The caller must hold c.limits.mu
+// if any.
+func (c *cache[K, T]) dropUnstored(items map[T]interface{}, err error) {
+	for key := range indexErrorKeys[T](err) {
+		delete(items, key)
+		if c.limits != nil {
+			c.limits.release(key)
+		}
+	}
+	c.meta.replaced(items)
+}
+
+// keyItems returns the objects of list by key, along with their distinct
+// keys in list order.
+func (c *cache[K, T]) keyItems(list []interface{}) ([]T, map[T]interface{}, error) {
+	keys := make([]T, 0, len(list))
 	items := make(map[T]interface{}, len(list))
 	for _, item := range list {
 		key, err := c.keyFunc(item)
 		if err != nil {
-			return KeyError{item, err}
+			return nil, nil, KeyError{item, err}
+		}
+		if _, exists := items[key]; !exists {
+			keys = append(keys, key)
 		}
 		items[key] = item
 	}
-	c.store.Replace(items)
+	return keys, items, nil
+}
+
+// Txn applies the changes buffered by fn atomically. If they would exceed the
+// configured limits, none of them is applied and Txn returns ErrStoreFull, and
+// if any object fails like Add, none of them is applied either.
+func (c *cache[K, T]) Txn(fn func(tx Txn[T]) error) error {
+	tx := newTxn(c.keyFunc)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	updates, deletes := tx.split()
+	if c.limits != nil {
+		c.limits.mu.Lock()
+		defer c.limits.mu.Unlock()
+		exists := func(key T) bool {
+			_, exists := c.store.Get(key)
+			return exists
+		}
+		if !c.limits.reserveAll(updates, deletes, exists, c.store.Size()) {
+			return ErrStoreFull
+		}
+	}
+	if err := c.store.Apply(updates, deletes); err != nil {
+		if c.limits != nil {
+			// Nothing was applied, so the reserved sizes are restored
+			for key := range updates {
+				current, exists := c.store.Get(key)
+				c.limits.record(key, current, exists)
+			}
+			for _, key := range deletes {
+				current, exists := c.store.Get(key)
+				c.limits.record(key, current, exists)
+			}
+		}
+		return err
+	}
+	for _, key := range deletes {
+		c.meta.forget(key)
+	}
+	for key := range updates {
+		c.meta.stored(key)
+	}
+	c.counters.updates.Add(uint64(len(updates)))
+	c.counters.deletes.Add(uint64(len(deletes)))
 	return nil
 }
 
+// Snapshot returns a read-only copy of the cache and its indices.
+func (c *cache[K, T]) Snapshot() Store[T] {
+	snap := newSnapshot(c.keyFunc, c.store.Snapshot())
+	snap.copier = c.copier
+	return snap
+}
+
 // Size returns count of object in the cache.
 func (c *cache[K, T]) Size() int {
 	return c.store.Size()
 }
+
+// Stats returns the counters of the cache.
+func (c *cache[K, T]) Stats() Stats {
+	return c.counters.stats(c.store.Size())
+}
+
+// copy returns obj as returned to callers, copied if the cache was created
+// WithCopyOnRead.
+func (c *cache[K, T]) copy(obj interface{}) interface{} {
+	if c.copier == nil {
+		return obj
+	}
+	return c.copier(obj)
+}
+
+// copyList replaces the items of list by their copies if the cache was
+// created WithCopyOnRead, and returns it.
+func (c *cache[K, T]) copyList(list []interface{}) []interface{} {
+	if c.copier == nil {
+		return list
+	}
+	for i, item := range list {
+		list[i] = c.copier(item)
+	}
+	return list
+}
@@ -1,9 +1,12 @@
 package cache
 
 import (
+	"cmp"
 	"fmt"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -74,6 +77,336 @@ func TestIndexer(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(items))
 	assert.Equal(t, "test1", items[0])
+
+	// Test GetIndexers
+	assert.Len(t, store.GetIndexers(), 2)
+
+	// Test RemoveIndexer
+	assert.Nil(t, store.RemoveIndexer("name2"))
+	_, err = store.ListByIndex("name2", "test1")
+	assert.NotNil(t, err)
+	assert.NotNil(t, store.RemoveIndexer("name2"))
+	assert.Len(t, store.GetIndexers(), 1)
+}
+
+func TestIndexerUniqueIndexer(t *testing.T) {
+	type user struct {
+		name, email string
+	}
+	store := NewIndexer[string](func(obj interface{}) (string, error) {
+		return obj.(*user).name, nil
+	})
+	assert.Nil(t, store.AddUniqueIndexer("email", func(obj interface{}) ([]string, error) {
+		return []string{obj.(*user).email}, nil
+	}))
+	assert.Nil(t, store.Add(&user{"alice", "a@example.com"}))
+	assert.ErrorIs(t, store.Add(&user{"bob", "a@example.com"}), ErrUniqueConflict)
+	assert.Equal(t, []string{"alice"}, store.ListKeys())
+
+	obj, exists, err := store.GetByUniqueIndex("email", "a@example.com")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "alice", obj.(*user).name)
+}
+
+func TestIndexerOrderedIndexer(t *testing.T) {
+	type event struct {
+		name string
+		at   time.Time
+	}
+	store := NewIndexer[time.Time](func(obj interface{}) (string, error) {
+		return obj.(*event).name, nil
+	})
+	assert.Nil(t, store.AddOrderedIndexer("at", func(obj interface{}) ([]time.Time, error) {
+		return []time.Time{obj.(*event).at}, nil
+	}, time.Time.Compare))
+	start := time.Unix(0, 0)
+	for i, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, store.Add(&event{name, start.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	items, err := store.ListByIndexRange("at", start, start.Add(2*time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "a", items[0].(*event).name)
+	assert.Equal(t, "b", items[1].(*event).name)
+	items, err = store.ListByIndexGreaterThan("at", start)
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	items, err = store.ListByIndexLessThan("at", start)
+	assert.Nil(t, err)
+	assert.Empty(t, items)
+}
+
+func TestIndexerListByIndexPrefix(t *testing.T) {
+	store := NewIndexer[string](testKeyFunc)
+	assert.Nil(t, store.AddOrderedIndexer("domain", func(obj interface{}) ([]string, error) {
+		user, domain, _ := strings.Cut(obj.(string), "@")
+		return []string{domain + "/" + user}, nil
+	}, cmp.Compare[string]))
+	assert.Nil(t, store.AddAll([]interface{}{"bob@example.org", "alice@example.com", "carol@example.com"}))
+
+	items, err := ListByIndexPrefix(store, "domain", "example.com/")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"alice@example.com", "carol@example.com"}, items)
+}
+
+func TestIndexerSetIndexNormalizer(t *testing.T) {
+	store := NewIndexer[string](testKeyFunc)
+	emailIndexFunc := func(obj interface{}) ([]string, error) {
+		_, email, _ := strings.Cut(obj.(string), ":")
+		return []string{email}, nil
+	}
+	assert.Nil(t, store.AddUniqueIndexer("email", emailIndexFunc))
+	assert.Nil(t, store.AddAll([]interface{}{"1:alice@example.com", "2:Alice@Example.com"}))
+
+	// Normalizing the emails would make them conflict, so nothing changes
+	err := store.SetIndexNormalizer("email", strings.ToLower)
+	assert.ErrorIs(t, err, ErrUniqueConflict)
+	_, exists, err := store.GetByUniqueIndex("email", "ALICE@EXAMPLE.COM")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	item, exists, err := store.GetByUniqueIndex("email", "Alice@Example.com")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "2:Alice@Example.com", item)
+
+	assert.Nil(t, store.Delete("2:Alice@Example.com"))
+	assert.Nil(t, store.SetIndexNormalizer("email", strings.ToLower))
+	item, exists, err = store.GetByUniqueIndex("email", "ALICE@EXAMPLE.COM")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "1:alice@example.com", item)
+	assert.ErrorIs(t, store.Add("3:Alice@example.COM"), ErrUniqueConflict)
+}
+
+func TestIndexerLazyIndexes(t *testing.T) {
+	var calls int
+	store := NewIndexer[string](testKeyFunc, WithThreadSafeStoreOptions(WithLazyIndexing()))
+	assert.Nil(t, store.AddIndexer("first", func(obj interface{}) ([]string, error) {
+		calls++
+		return []string{obj.(string)[:1]}, nil
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"apple", "avocado", "banana"}))
+	assert.Zero(t, calls)
+
+	items, err := store.ListByIndex("first", "a")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []interface{}{"apple", "avocado"}, items)
+	assert.Equal(t, 3, calls)
+}
+
+func TestIndexerListByIndexes(t *testing.T) {
+	store := NewIndexer[string](testKeyFunc)
+	assert.Nil(t, store.AddIndexers(Indexers[string]{
+		"first": func(obj interface{}) ([]string, error) { return []string{obj.(string)[:1]}, nil },
+		"last":  func(obj interface{}) ([]string, error) { return []string{obj.(string)[len(obj.(string))-1:]}, nil },
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"apple", "avocado", "banana", "agave"}))
+
+	items, err := store.ListByIndexes(map[string]string{"first": "a", "last": "e"})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []interface{}{"apple", "agave"}, items)
+}
+
+func TestIndexerCountByIndex(t *testing.T) {
+	store := NewIndexer[string](testKeyFunc)
+	assert.Nil(t, store.AddIndexer("first", func(obj interface{}) ([]string, error) {
+		return []string{obj.(string)[:1]}, nil
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"apple", "avocado", "banana"}))
+
+	count, err := store.CountByIndex("first", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	count, err = NewReadOnlyIndexer[string, string](store).CountByIndex("first", "b")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	_, err = store.CountByIndex("missing", "a")
+	assert.Error(t, err)
+}
+
+func TestIndexerGroupByIndex(t *testing.T) {
+	store := NewIndexer[string](testKeyFunc)
+	assert.Nil(t, store.AddIndexer("first", func(obj interface{}) ([]string, error) {
+		return []string{obj.(string)[:1]}, nil
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"apple", "avocado", "banana"}))
+
+	groups, err := store.GroupByIndex("first")
+	assert.Nil(t, err)
+	assert.Len(t, groups, 2)
+	assert.ElementsMatch(t, []string{"apple", "avocado"}, groups["a"])
+	assert.Equal(t, []string{"banana"}, groups["b"])
+
+	counts, err := store.GroupCountsByIndex("first")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, counts)
+	_, err = store.GroupCountsByIndex("missing")
+	assert.Error(t, err)
+}
+
+func TestIndexerReindex(t *testing.T) {
+	store := NewIndexer[string](testKeyFunc)
+	upper := false
+	assert.Nil(t, store.AddIndexer("name", func(obj interface{}) ([]string, error) {
+		if upper {
+			return []string{strings.ToUpper(obj.(string))}, nil
+		}
+		return []string{obj.(string)}, nil
+	}))
+	assert.Nil(t, store.Add("alice"))
+
+	upper = true
+	assert.Nil(t, store.Reindex("name"))
+	items, err := store.ListByIndex("name", "ALICE")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"alice"}, items)
+
+	assert.ErrorIs(t, store.Snapshot().(IndexedStore[string, string]).Reindex("name"), ErrReadOnly)
+}
+
+func TestCacheGetMany(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.Add("a"))
+	assert.Nil(t, store.Add("b"))
+
+	items, missing := store.GetMany([]string{"a", "b", "c"})
+	assert.Equal(t, map[string]interface{}{"a": "a", "b": "b"}, items)
+	assert.Equal(t, []string{"c"}, missing)
+}
+
+func TestCacheContains(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.Add("a"))
+
+	assert.True(t, store.Contains("a"))
+	assert.False(t, store.Contains("b"))
+}
+
+func TestCacheCompareAndSwap(t *testing.T) {
+	type version struct {
+		name string
+		rev  int
+	}
+	keyFunc := func(obj interface{}) (string, error) {
+		return obj.(*version).name, nil
+	}
+	store := NewStore(keyFunc)
+	v1 := &version{"config", 1}
+	v2 := &version{"config", 2}
+	assert.Nil(t, store.Add(v1))
+
+	// Only the writer holding the current version succeeds
+	swapped, err := store.CompareAndSwap(v1, v2)
+	assert.Nil(t, err)
+	assert.True(t, swapped)
+	swapped, err = store.CompareAndSwap(v1, &version{"config", 3})
+	assert.Nil(t, err)
+	assert.False(t, swapped)
+
+	deleted, err := store.CompareAndDelete(v1)
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+	deleted, err = store.CompareAndDelete(v2)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, 0, store.Size())
+}
+
+func TestCompareAndSwapUncomparable(t *testing.T) {
+	type tagged struct {
+		name string
+		tags []string
+	}
+	keyFunc := func(obj interface{}) (string, error) {
+		return obj.(tagged).name, nil
+	}
+	stores := map[string]Store[string]{
+		"store":    NewStore(keyFunc),
+		"eviction": NewEvictionCacheWithOptions[any](keyFunc),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			obj := tagged{"a", []string{"x"}}
+			assert.NoError(t, store.Add(obj))
+
+			// Objects that cannot be compared are never the same, rather than panic
+			swapped, err := store.CompareAndSwap(obj, tagged{"a", nil})
+			assert.NoError(t, err)
+			assert.False(t, swapped)
+			deleted, err := store.CompareAndDelete(obj)
+			assert.NoError(t, err)
+			assert.False(t, deleted)
+			assert.Equal(t, 1, store.Size())
+		})
+	}
+}
+func TestCacheBatch(t *testing.T) {
+	keyFunc := func(obj interface{}) (string, error) {
+		s, ok := obj.(string)
+		if !ok {
+			return "", fmt.Errorf("object is not a string")
+		}
+		return s, nil
+	}
+	store := NewStore(keyFunc)
+
+	errs := store.AddAll([]interface{}{"a", 1, "b"})
+	assert.Len(t, errs, 3)
+	assert.Nil(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.Nil(t, errs[2])
+	assert.ElementsMatch(t, []string{"a", "b"}, store.ListKeys())
+
+	assert.Nil(t, store.UpdateAll([]interface{}{"b", "c"}))
+	assert.Equal(t, 3, store.Size())
+
+	assert.Nil(t, store.DeleteAll([]interface{}{"a", "c"}))
+	assert.Equal(t, []string{"b"}, store.ListKeys())
+}
+
+func TestCacheRange(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b", "c"}))
+
+	var keys []string
+	store.Range(func(key string, obj interface{}) bool {
+		assert.Equal(t, key, obj)
+		keys = append(keys, key)
+		return true
+	})
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, keys)
+}
+
+func TestCacheAll(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b", "c"}))
+
+	items := make(map[string]interface{})
+	for key, obj := range store.All() {
+		items[key] = obj
+	}
+	assert.Equal(t, map[string]interface{}{"a": "a", "b": "b", "c": "c"}, items)
+
+	// Breaking out of the loop stops the iteration
+	count := 0
+	for range store.All() {
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestCacheListFiltered(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"apple", "avocado", "banana"}))
+	startsWithA := func(obj interface{}) bool {
+		return obj.(string)[0] == 'a'
+	}
+
+	assert.ElementsMatch(t, []interface{}{"apple", "avocado"}, store.ListFiltered(startsWithA))
+	assert.ElementsMatch(t, []string{"apple", "avocado"}, store.ListKeysFiltered(startsWithA))
 }
 
 // Benchmark testing
@@ -85,6 +418,20 @@ func BenchmarkCacheAdd(b *testing.B) {
 	}
 }
 
+func BenchmarkCacheAddAll(b *testing.B) {
+	store := NewStore(testKeyFunc)
+	objs := make([]interface{}, 0, 100)
+
+	for i := 0; i < b.N; i++ {
+		objs = append(objs, "test"+fmt.Sprintf("%d", i))
+		if len(objs) == cap(objs) {
+			store.AddAll(objs)
+			objs = objs[:0]
+		}
+	}
+	store.AddAll(objs)
+}
+
 func BenchmarkCacheGet(b *testing.B) {
 	store := NewStore(testKeyFunc)
 	store.Add("test1")
@@ -113,7 +460,7 @@ func BenchmarkCacheDelete(b *testing.B) {
 }
 
 // Example test
-func ExampleCache() {
+func ExampleNewStore() {
 	store := NewStore(testKeyFunc)
 
 	// Add items to the cache
@@ -153,3 +500,83 @@ func ExampleCache() {
 	// Updated to: item1_updated
 	// Deleted item2
 }
+
+func TestCacheCopyOnRead(t *testing.T) {
+	type counter struct {
+		name  string
+		count int
+	}
+	keyFunc := func(obj interface{}) (string, error) {
+		return obj.(*counter).name, nil
+	}
+	copier := func(obj interface{}) interface{} {
+		c := *obj.(*counter)
+		return &c
+	}
+	store := NewStore(keyFunc, WithCopyOnRead(copier))
+	assert.NoError(t, store.Add(&counter{name: "a"}))
+
+	item, exists, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	item.(*counter).count++
+	store.List()[0].(*counter).count++
+	for _, obj := range store.All() {
+		obj.(*counter).count++
+	}
+	items, _ := store.GetMany([]string{"a"})
+	items["a"].(*counter).count++
+	store.Snapshot().List()[0].(*counter).count++
+
+	item, _, _ = store.GetByKey("a")
+	assert.Zero(t, item.(*counter).count)
+}
+
+func TestCacheReplaceWithDiff(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+
+	added, updated, removed, err := store.ReplaceWithDiff([]interface{}{"b", "c", "d", "c"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, added)
+	assert.Equal(t, []string{"b"}, updated)
+	assert.Equal(t, []string{"a"}, removed)
+	assert.ElementsMatch(t, []string{"b", "c", "d"}, store.ListKeys())
+}
+
+func TestCacheReplaceWithDiffLimits(t *testing.T) {
+	store := NewStore(testKeyFunc, WithMaxEntries(1), WithOverflowHandler(func(obj interface{}) error {
+		return nil
+	}))
+
+	added, updated, removed, err := store.ReplaceWithDiff([]interface{}{"a", "b"})
+
+	// Only one object fits, so only that one is reported
+	assert.NoError(t, err)
+	assert.Len(t, added, 1)
+	assert.Empty(t, updated)
+	assert.Empty(t, removed)
+	assert.Equal(t, added, store.ListKeys())
+}
+
+func TestIndexerIndexErrors(t *testing.T) {
+	store := NewIndexer[int](testIntKeyFunc, WithMaxEntries(2))
+	assert.NoError(t, store.AddIndexer("value", nonNegativeIndexFunc))
+
+	// Objects that cannot be indexed are not stored and take no room
+	assert.ErrorAs(t, store.Add(-1), new(IndexError))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	errs := store.AddAll([]interface{}{-3, 1})
+	assert.Error(t, errs[0])
+	assert.NoError(t, errs[1])
+
+	assert.Error(t, store.Replace([]interface{}{3, -4}))
+	assert.Equal(t, []int{3}, store.ListKeys())
+	assert.Error(t, store.Txn(func(tx Txn[int]) error {
+		return tx.Add(-5)
+	}))
+	assert.NoError(t, store.Add(4))
+	assert.ElementsMatch(t, []int{3, 4}, store.ListKeys())
+}
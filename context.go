@@ -0,0 +1,131 @@
+package cache
+
+import "context"
+
+// ContextStore is a Store whose reads and writes take a context, so that
+// callers can bound them with a deadline or cancel them. Stores that load or
+// persist objects elsewhere use it to pass the context on, while in-memory
+// stores only give up before starting.
+type ContextStore[T comparable] interface {
+	Store[T]
+
+	// AddCtx inserts an object, like Add.
+	AddCtx(ctx context.Context, obj interface{}) error
+
+	// UpdateCtx modifies an existing object, like Update.
+	UpdateCtx(ctx context.Context, obj interface{}) error
+
+	// DeleteCtx removes an object, like Delete.
+	DeleteCtx(ctx context.Context, obj interface{}) error
+
+	// GetCtx returns the object stored under the key of obj, like Get.
+	GetCtx(ctx context.Context, obj interface{}) (interface{}, bool, error)
+
+	// GetByKeyCtx returns an object by its key, like GetByKey.
+	GetByKeyCtx(ctx context.Context, key T) (interface{}, bool, error)
+
+	// ListCtx returns all objects, like List. It stops early with the error
+	// of ctx once ctx is done.
+	ListCtx(ctx context.Context) ([]interface{}, error)
+
+	// ListKeysCtx returns all keys, like ListKeys, stopping early like ListCtx.
+	ListKeysCtx(ctx context.Context) ([]T, error)
+}
+
+// WithContext returns store as a ContextStore. Stores implementing
+// ContextStore are returned as they are, others are wrapped so that every
+// call fails with the error of its context if the context is done.
+func WithContext[T comparable](store Store[T]) ContextStore[T] {
+	if ctxStore, ok := store.(ContextStore[T]); ok {
+		return ctxStore
+	}
+	return contextStore[T]{store}
+}
+
+// contextCheckInterval is the number of objects a listing visits between
+// checks of its context.
+const contextCheckInterval = 256
+
+// contextStore implements ContextStore on top of a Store.
+type contextStore[T comparable] struct {
+	Store[T]
+}
+
+// AddCtx inserts an object unless ctx is done.
+func (s contextStore[T]) AddCtx(ctx context.Context, obj interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Add(obj)
+}
+
+// UpdateCtx modifies an object unless ctx is done.
+func (s contextStore[T]) UpdateCtx(ctx context.Context, obj interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Update(obj)
+}
+
+// DeleteCtx removes an object unless ctx is done.
+func (s contextStore[T]) DeleteCtx(ctx context.Context, obj interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(obj)
+}
+
+// GetCtx returns the object stored under the key of obj unless ctx is done.
+func (s contextStore[T]) GetCtx(ctx context.Context, obj interface{}) (interface{}, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	return s.Get(obj)
+}
+
+// GetByKeyCtx returns an object by its key unless ctx is done.
+func (s contextStore[T]) GetByKeyCtx(ctx context.Context, key T) (interface{}, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	return s.GetByKey(key)
+}
+
+// ListCtx returns all objects, checking ctx while it collects them.
+func (s contextStore[T]) ListCtx(ctx context.Context) ([]interface{}, error) {
+	var list []interface{}
+	err := s.rangeCtx(ctx, func(key T, obj interface{}) {
+		list = append(list, obj)
+	})
+	return list, err
+}
+
+// ListKeysCtx returns all keys, checking ctx while it collects them.
+func (s contextStore[T]) ListKeysCtx(ctx context.Context) ([]T, error) {
+	var list []T
+	err := s.rangeCtx(ctx, func(key T, obj interface{}) {
+		list = append(list, key)
+	})
+	return list, err
+}
+
+// rangeCtx calls fn for every object, stopping with the error of ctx once
+// ctx is done.
+func (s contextStore[T]) rangeCtx(ctx context.Context, fn func(key T, obj interface{})) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	visited := 0
+	s.Range(func(key T, obj interface{}) bool {
+		visited++
+		if visited%contextCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		fn(key, obj)
+		return true
+	})
+	return err
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithContext(t *testing.T) {
+	store := WithContext(NewStore(testKeyFunc))
+	ctx := context.Background()
+
+	assert.NoError(t, store.AddCtx(ctx, "a"))
+	item, exists, err := store.GetByKeyCtx(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "a", item)
+	list, err := store.ListCtx(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a"}, list)
+	assert.NoError(t, store.DeleteCtx(ctx, "a"))
+	assert.Zero(t, store.Size())
+
+	// Wrapping a ContextStore returns it as it is
+	assert.Equal(t, store, WithContext[string](store))
+}
+
+func TestWithContextCanceled(t *testing.T) {
+	store := WithContext(NewStore(testKeyFunc))
+	assert.NoError(t, store.Add("a"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, store.AddCtx(ctx, "b"), context.Canceled)
+	_, _, err := store.GetCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = store.ListKeysCtx(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, store.Size())
+}
+
+func TestWithContextCanceledDuringList(t *testing.T) {
+	store := WithContext(NewStore(testKeyFunc))
+	for i := 0; i < 2*contextCheckInterval; i++ {
+		assert.NoError(t, store.Add(string(rune('a'+i))))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	visited := 0
+	err := store.(contextStore[string]).rangeCtx(ctx, func(key string, obj interface{}) {
+		visited++
+		cancel()
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, contextCheckInterval-1, visited)
+}
@@ -0,0 +1,360 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrFIFOClosed is returned by Pop once the queue is closed and drained.
+var ErrFIFOClosed = errors.New("fifo is closed")
+
+// DeltaType is the kind of change recorded by a Delta.
+type DeltaType string
+
+const (
+	// Added means the object was added.
+	Added DeltaType = "Added"
+	// Updated means the object was modified.
+	Updated DeltaType = "Updated"
+	// Deleted means the object was removed. Its object is the last known
+	// state, or a DeletedFinalStateUnknown if that state was missed.
+	Deleted DeltaType = "Deleted"
+	// Replaced means the object was listed by Replace.
+	Replaced DeltaType = "Replaced"
+	// Sync means the object was requeued by Resync without changing.
+	Sync DeltaType = "Sync"
+)
+
+// Delta is a change of an object, as queued by a DeltaFIFO.
+type Delta struct {
+	Type   DeltaType
+	Object interface{}
+}
+
+// Deltas lists the changes of an object, oldest first.
+type Deltas []Delta
+
+// Oldest returns the oldest change, or nil if there is none.
+func (d Deltas) Oldest() *Delta {
+	if len(d) > 0 {
+		return &d[0]
+	}
+	return nil
+}
+
+// Newest returns the newest change, or nil if there is none.
+func (d Deltas) Newest() *Delta {
+	if n := len(d); n > 0 {
+		return &d[n-1]
+	}
+	return nil
+}
+
+// DeletedFinalStateUnknown is the object of a Deleted delta when the object
+// was removed without the queue seeing the removal, such as when Replace
+// no longer lists it. Obj is the last state known to the queue, which may be
+// stale.
+type DeletedFinalStateUnknown[T comparable] struct {
+	Key T
+	Obj interface{}
+}
+
+// DeltaFIFO is a queue of the changes of keyed objects. The changes of every
+// key are accumulated until the key is popped, and keys are popped in the
+// order of their first change. It is the producer half of a cache kept in
+// sync with a remote source: Pop hands the changes to a consumer, which
+// typically applies them to the store passed as knownObjects.
+type DeltaFIFO[T comparable] struct {
+	mu   sync.Mutex
+	cond sync.Cond
+	// items holds the changes of the queued keys, and queue their order
+	items map[T]Deltas
+	queue []T
+	// populated is set by the first Add, Update, Delete or Replace, and
+	// initialPopulationCount counts the keys of the first Replace not popped yet
+	populated              bool
+	initialPopulationCount int
+	keyFunc                KeyFunc[T]
+	// knownObjects lists the objects the consumer knows of, may be nil
+	knownObjects Store[T]
+	closed       bool
+}
+
+// NewDeltaFIFO creates a DeltaFIFO computing keys with keyFunc.
+// knownObjects, which may be nil, is the store the consumer keeps up to date:
+// it lets Delete skip unknown objects, Replace report the objects it no
+// longer lists as deleted and Resync requeue the known objects.
+func NewDeltaFIFO[T comparable](keyFunc KeyFunc[T], knownObjects Store[T]) *DeltaFIFO[T] {
+	f := &DeltaFIFO[T]{
+		items:        make(map[T]Deltas),
+		keyFunc:      keyFunc,
+		knownObjects: knownObjects,
+	}
+	f.cond.L = &f.mu
+	return f
+}
+
+// KeyOf returns the key of obj, which may be a Deltas, whose newest object is
+// used, or a DeletedFinalStateUnknown.
+func (f *DeltaFIFO[T]) KeyOf(obj interface{}) (T, error) {
+	if d, ok := obj.(Deltas); ok {
+		if len(d) == 0 {
+			var zero T
+			return zero, KeyError{obj, errors.New("no deltas")}
+		}
+		obj = d.Newest().Object
+	}
+	if d, ok := obj.(DeletedFinalStateUnknown[T]); ok {
+		return d.Key, nil
+	}
+	key, err := f.keyFunc(obj)
+	if err != nil {
+		return key, KeyError{obj, err}
+	}
+	return key, nil
+}
+
+// HasSynced reports whether the keys queued by the first Replace have all
+// been popped. A queue changed before any Replace is synced right away.
+func (f *DeltaFIFO[T]) HasSynced() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.populated && f.initialPopulationCount == 0
+}
+
+// Add queues an Added delta for obj.
+func (f *DeltaFIFO[T]) Add(obj interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.populated = true
+	return f.queueDelta(Added, obj)
+}
+
+// Update queues an Updated delta for obj.
+func (f *DeltaFIFO[T]) Update(obj interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.populated = true
+	return f.queueDelta(Updated, obj)
+}
+
+// Delete queues a Deleted delta for obj. Objects that are neither known nor
+// queued are ignored, since the consumer has nothing to delete.
+func (f *DeltaFIFO[T]) Delete(obj interface{}) error {
+	key, err := f.KeyOf(obj)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.populated = true
+	if f.knownObjects != nil {
+		_, known, err := f.knownObjects.GetByKey(key)
+		if err != nil {
+			return err
+		}
+		if _, queued := f.items[key]; !known && !queued {
+			return nil
+		}
+	}
+	return f.queueDelta(Deleted, obj)
+}
+
+// Replace queues a Replaced delta for every object of list, and a Deleted
+// delta with a DeletedFinalStateUnknown for every known or queued object it
+// does not list.
+func (f *DeltaFIFO[T]) Replace(list []interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	listed := make(map[T]struct{}, len(list))
+	for _, obj := range list {
+		key, err := f.KeyOf(obj)
+		if err != nil {
+			return err
+		}
+		listed[key] = struct{}{}
+		if err := f.queueDelta(Replaced, obj); err != nil {
+			return err
+		}
+	}
+
+	if f.knownObjects == nil {
+		for key, deltas := range f.items {
+			if _, ok := listed[key]; ok {
+				continue
+			}
+			if newest := deltas.Newest(); newest.Type != Deleted {
+				if err := f.queueDelta(Deleted, DeletedFinalStateUnknown[T]{key, newest.Object}); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		for _, key := range f.knownObjects.ListKeys() {
+			if _, ok := listed[key]; ok {
+				continue
+			}
+			obj, exists, err := f.knownObjects.GetByKey(key)
+			if err != nil || !exists {
+				continue
+			}
+			if err := f.queueDelta(Deleted, DeletedFinalStateUnknown[T]{key, obj}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !f.populated {
+		f.populated = true
+		f.initialPopulationCount = len(f.queue)
+	}
+	return nil
+}
+
+// Resync queues a Sync delta for every known object whose key is not queued,
+// so that the consumer processes it again.
+func (f *DeltaFIFO[T]) Resync() error {
+	if f.knownObjects == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range f.knownObjects.ListKeys() {
+		if _, queued := f.items[key]; queued {
+			continue
+		}
+		obj, exists, err := f.knownObjects.GetByKey(key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := f.queueDelta(Sync, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// queueDelta appends a delta of obj to its key, queuing the key if needed.
+// The caller must hold f.mu.
+func (f *DeltaFIFO[T]) queueDelta(deltaType DeltaType, obj interface{}) error {
+	key, err := f.KeyOf(obj)
+	if err != nil {
+		return err
+	}
+	deltas, queued := f.items[key]
+	deltas = dedupDeltas[T](append(deltas, Delta{deltaType, obj}))
+	if !queued {
+		f.queue = append(f.queue, key)
+	}
+	f.items[key] = deltas
+	f.cond.Broadcast()
+	return nil
+}
+
+// dedupDeltas collapses two trailing deletions into one, keeping the one
+// that knows the final state of the object.
+func dedupDeltas[T comparable](deltas Deltas) Deltas {
+	n := len(deltas)
+	if n < 2 || deltas[n-1].Type != Deleted || deltas[n-2].Type != Deleted {
+		return deltas
+	}
+	if _, unknown := deltas[n-1].Object.(DeletedFinalStateUnknown[T]); unknown {
+		return deltas[:n-1]
+	}
+	return append(deltas[:n-2], deltas[n-1])
+}
+
+// Pop waits until a key is queued, removes it and calls process with its
+// deltas. If process fails, the deltas are queued again ahead of the changes
+// made meanwhile, and its error is returned. Pop returns ErrFIFOClosed once
+// the queue is closed. process is called with the queue locked, so it must
+// not call back into the queue.
+func (f *DeltaFIFO[T]) Pop(process func(deltas Deltas) error) (Deltas, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.queue) == 0 {
+		if f.closed {
+			return nil, ErrFIFOClosed
+		}
+		f.cond.Wait()
+	}
+	key := f.queue[0]
+	f.queue = f.queue[1:]
+	if f.initialPopulationCount > 0 {
+		f.initialPopulationCount--
+	}
+	deltas := f.items[key]
+	delete(f.items, key)
+
+	if err := process(deltas); err != nil {
+		f.requeue(key, deltas)
+		return deltas, err
+	}
+	return deltas, nil
+}
+
+// requeue puts the deltas of a key that failed to be processed back at the
+// end of the queue. The caller must hold f.mu.
+func (f *DeltaFIFO[T]) requeue(key T, deltas Deltas) {
+	if _, queued := f.items[key]; !queued {
+		f.queue = append(f.queue, key)
+	}
+	f.items[key] = append(deltas, f.items[key]...)
+}
+
+// List returns the newest object of every queued key.
+func (f *DeltaFIFO[T]) List() []interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := make([]interface{}, 0, len(f.queue))
+	for _, key := range f.queue {
+		list = append(list, f.items[key].Newest().Object)
+	}
+	return list
+}
+
+// ListKeys returns the queued keys in order.
+func (f *DeltaFIFO[T]) ListKeys() []T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]T, len(f.queue))
+	copy(keys, f.queue)
+	return keys
+}
+
+// Get returns a copy of the deltas queued for the key of obj.
+func (f *DeltaFIFO[T]) Get(obj interface{}) (Deltas, bool, error) {
+	key, err := f.KeyOf(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return f.GetByKey(key)
+}
+
+// GetByKey returns a copy of the deltas queued for key.
+func (f *DeltaFIFO[T]) GetByKey(key T) (Deltas, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deltas, ok := f.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return append(Deltas(nil), deltas...), true, nil
+}
+
+// Len returns the number of queued keys.
+func (f *DeltaFIFO[T]) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue)
+}
+
+// Close makes Pop return ErrFIFOClosed once the queue is empty.
+func (f *DeltaFIFO[T]) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	f.cond.Broadcast()
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// popDeltas pops the next key of f, returning its deltas.
+func popDeltas(t *testing.T, f *DeltaFIFO[string]) Deltas {
+	deltas, err := f.Pop(func(Deltas) error { return nil })
+	assert.NoError(t, err)
+	return deltas
+}
+
+func TestDeltaFIFO(t *testing.T) {
+	f := NewDeltaFIFO[string](testKeyFunc, nil)
+	assert.NoError(t, f.Add("a"))
+	assert.NoError(t, f.Add("b"))
+	assert.NoError(t, f.Update("a"))
+	assert.NoError(t, f.Delete("a"))
+	assert.NoError(t, f.Delete("a"))
+
+	assert.Equal(t, []string{"a", "b"}, f.ListKeys())
+	// The second deletion is collapsed into the first
+	assert.Equal(t, Deltas{{Added, "a"}, {Updated, "a"}, {Deleted, "a"}}, popDeltas(t, f))
+	assert.Equal(t, Deltas{{Added, "b"}}, popDeltas(t, f))
+	assert.Zero(t, f.Len())
+}
+
+func TestDeltaFIFORequeue(t *testing.T) {
+	f := NewDeltaFIFO[string](testKeyFunc, nil)
+	assert.NoError(t, f.Add("a"))
+	assert.NoError(t, f.Add("b"))
+	errProcess := errors.New("not ready")
+
+	_, err := f.Pop(func(deltas Deltas) error {
+		return errProcess
+	})
+	assert.ErrorIs(t, err, errProcess)
+
+	// The failed deltas go to the back of the queue, ahead of later changes
+	assert.NoError(t, f.Update("a"))
+	assert.Equal(t, []string{"b", "a"}, f.ListKeys())
+	popDeltas(t, f)
+	assert.Equal(t, Deltas{{Added, "a"}, {Updated, "a"}}, popDeltas(t, f))
+}
+
+func TestDeltaFIFOKnownObjects(t *testing.T) {
+	known := NewStore(testKeyFunc)
+	assert.Nil(t, known.AddAll([]interface{}{"a", "b"}))
+	f := NewDeltaFIFO[string](testKeyFunc, known)
+
+	assert.False(t, f.HasSynced())
+	assert.NoError(t, f.Replace([]interface{}{"a", "d"}))
+	assert.Equal(t, []string{"a", "d", "b"}, f.ListKeys())
+	deltas, exists, err := f.GetByKey("b")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, Deltas{{Deleted, DeletedFinalStateUnknown[string]{"b", "b"}}}, deltas)
+
+	popDeltas(t, f)
+	popDeltas(t, f)
+	assert.False(t, f.HasSynced())
+	popDeltas(t, f)
+	assert.True(t, f.HasSynced())
+
+	// Unknown objects are not deleted
+	assert.NoError(t, f.Delete("c"))
+	assert.Zero(t, f.Len())
+
+	assert.NoError(t, f.Resync())
+	assert.ElementsMatch(t, []string{"a", "b"}, f.ListKeys())
+}
+
+func TestDeltaFIFOReplaceWithoutKnownObjects(t *testing.T) {
+	f := NewDeltaFIFO[string](testKeyFunc, nil)
+	assert.NoError(t, f.Add("a"))
+
+	assert.NoError(t, f.Replace([]interface{}{"b"}))
+
+	assert.Equal(t, Deltas{{Added, "a"}, {Deleted, DeletedFinalStateUnknown[string]{"a", "a"}}}, popDeltas(t, f))
+	assert.Equal(t, Deltas{{Replaced, "b"}}, popDeltas(t, f))
+}
+
+func TestDeltaFIFOPopBlocks(t *testing.T) {
+	f := NewDeltaFIFO[string](testKeyFunc, nil)
+	popped := make(chan Deltas)
+	go func() {
+		deltas, _ := f.Pop(func(Deltas) error { return nil })
+		popped <- deltas
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("Pop returned from an empty queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.NoError(t, f.Add("a"))
+	assert.Equal(t, Deltas{{Added, "a"}}, <-popped)
+
+	f.Close()
+	_, err := f.Pop(func(Deltas) error { return nil })
+	assert.ErrorIs(t, err, ErrFIFOClosed)
+}
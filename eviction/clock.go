@@ -0,0 +1,158 @@
+package eviction
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers to time-based code, so that it
+// can be driven deterministically in tests.
+type Clock interface {
+	Now() time.Time                 // Returns the current time.
+	NewTimer(d time.Duration) Timer // Creates a timer firing once after d.
+}
+
+// Timer is a single event delivered by a Clock, like time.Timer.
+type Timer interface {
+	C() <-chan time.Time        // Returns the channel the time is sent on when the timer fires.
+	Stop() bool                 // Prevents the timer from firing, returns false if it already fired or was stopped.
+	Reset(d time.Duration) bool // Makes the timer fire after d, returns false if it already fired or was stopped.
+}
+
+// RealClock is a Clock backed by the time package.
+type RealClock struct{}
+
+// Now returns the current local time.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a timer firing after d.
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer adapts time.Timer to Timer.
+type realTimer struct {
+	*time.Timer
+}
+
+// C returns the channel the time is sent on when the timer fires.
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// FakeClock is a Clock whose time only moves when Step or SetTime is called,
+// firing the timers that are due.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a new FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// NewTimer creates a timer firing once the clock has moved d forward.
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	t.deadline = f.now.Add(d)
+	t.active = true
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Step moves the clock forward by d.
+func (f *FakeClock) Step(d time.Duration) {
+	f.mu.Lock()
+	now := f.now.Add(d)
+	f.mu.Unlock()
+	f.SetTime(now)
+}
+
+// SetTime sets the time of the clock, firing the timers due by then.
+func (f *FakeClock) SetTime(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = now
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if !t.active {
+			continue
+		}
+		if t.deadline.After(now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.active = false
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+	f.timers = pending
+}
+
+// HasWaiters reports whether any timer is waiting to fire.
+func (f *FakeClock) HasWaiters() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.timers {
+		if t.active {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a Timer created by a FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+// C returns the channel the time is sent on when the timer fires.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop prevents the timer from firing.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+// Reset makes the timer fire once the clock has moved d forward from now.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasActive := t.active
+	t.deadline = t.clock.now.Add(d)
+	if !wasActive {
+		t.active = true
+		t.clock.timers = append(t.clock.timers, t)
+	}
+	return wasActive
+}
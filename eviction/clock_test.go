@@ -0,0 +1,65 @@
+package eviction
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealClock(t *testing.T) {
+	var clock Clock = RealClock{}
+	before := time.Now()
+	assert.False(t, clock.Now().Before(before))
+
+	timer := clock.NewTimer(time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+	assert.False(t, timer.Stop())
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+	assert.False(t, clock.HasWaiters())
+
+	timer := clock.NewTimer(time.Minute)
+	assert.True(t, clock.HasWaiters())
+
+	// The timer does not fire before its deadline
+	clock.Step(30 * time.Second)
+	assert.Equal(t, start.Add(30*time.Second), clock.Now())
+	assert.Empty(t, timer.C())
+
+	clock.Step(30 * time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-timer.C())
+	assert.False(t, clock.HasWaiters())
+	assert.False(t, timer.Stop())
+
+	// A reset timer fires again relative to the current time
+	assert.False(t, timer.Reset(time.Second))
+	clock.SetTime(start.Add(time.Hour))
+	assert.Equal(t, start.Add(time.Hour), <-timer.C())
+}
+
+func TestFakeClockStop(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	timer := clock.NewTimer(time.Second)
+
+	assert.True(t, timer.Stop())
+	assert.False(t, clock.HasWaiters())
+	clock.Step(time.Minute)
+	assert.Empty(t, timer.C())
+
+	// Resetting an active timer moves its deadline
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Reset(time.Minute))
+	clock.Step(time.Second)
+	assert.Empty(t, timer.C())
+	clock.Step(time.Minute)
+	assert.Len(t, timer.C(), 1)
+}
@@ -0,0 +1,423 @@
+package eviction
+
+import "sync"
+
+// clockProStatus is the state of a page tracked by the clockPro policy.
+type clockProStatus int
+
+const (
+	// clockProHot marks a resident page that has been reused frequently.
+	clockProHot clockProStatus = iota
+	// clockProCold marks a resident page that is a candidate for eviction.
+	clockProCold
+	// clockProTest marks a non-resident page whose key has been evicted but
+	// whose reuse is still being watched.
+	clockProTest
+)
+
+// clockProNode is a page on the clock of the clockPro policy.
+type clockProNode[T comparable] struct {
+	key    T
+	status clockProStatus
+	ref    bool
+	pinned bool
+	prev   *clockProNode[T]
+	next   *clockProNode[T]
+}
+
+// clockPro implements the CLOCK-Pro eviction policy.
+//
+// Keys live on a single clock swept by three hands. The cold hand evicts
+// unreferenced cold pages and promotes referenced ones to hot. The hot hand
+// demotes unreferenced hot pages to cold and forgets test pages, which remember
+// recently evicted keys. Reusing a key during its test period enlarges the
+// share of cold pages, while test pages expiring unused shrink it, so the
+// policy adapts between recency and frequency and resists one-off scans.
+type clockPro[T comparable] struct {
+	mu       sync.Mutex
+	capacity int
+	stats    Stats
+	// coldTarget is the adaptive number of resident cold pages
+	coldTarget int
+	nodes      map[T]*clockProNode[T]
+	handHot    *clockProNode[T]
+	handCold   *clockProNode[T]
+	handTest   *clockProNode[T]
+	countHot   int
+	countCold  int
+	countTest  int
+	// testHits and testExpirations count the test pages reused and forgotten
+	testHits        uint64
+	testExpirations uint64
+}
+
+// NewClockPro creates a new CLOCK-Pro cache with the given capacity.
+func NewClockPro[T comparable](capacity int) AdaptivePolicy[T] {
+	return &clockPro[T]{
+		capacity:   capacity,
+		coldTarget: capacity,
+		nodes:      make(map[T]*clockProNode[T]),
+	}
+}
+
+// Put adds a key to the cache. If the cache is full, it evicts the cold page under the cold hand.
+func (c *clockPro[T]) Put(key T) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.put(key)
+}
+
+// PutAll adds keys to the cache under a single lock acquisition and returns
+// the keys evicted to make room for them.
+func (c *clockPro[T]) PutAll(keys []T) []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var evictedKeys []T
+	for _, key := range keys {
+		if evictedKey, evicted := c.put(key); evicted {
+			evictedKeys = append(evictedKeys, evictedKey)
+		}
+	}
+	return evictedKeys
+}
+
+// put is an internal method that adds a key to the cache.
+func (c *clockPro[T]) put(key T) (T, bool) {
+	var evictedKey T
+	var evicted bool
+
+	status := clockProCold
+	if node, ok := c.nodes[key]; ok {
+		if node.status != clockProTest {
+			node.ref = true
+			return evictedKey, false
+		}
+		// The key is reused during its test period, so it deserves to be hot
+		// and cold pages deserve more room.
+		c.remove(node)
+		c.countTest--
+		c.testHits++
+		if c.coldTarget < c.capacity {
+			c.coldTarget++
+		}
+		status = clockProHot
+	}
+	if c.countHot+c.countCold >= c.capacity {
+		evictedKey, evicted = c.evict()
+	}
+	c.insert(&clockProNode[T]{key: key, status: status})
+	if status == clockProHot {
+		c.countHot++
+		c.balanceHot()
+	} else {
+		c.countCold++
+	}
+	c.stats.Puts++
+	return evictedKey, evicted
+}
+
+// Touch marks the key as referenced without inserting it.
+func (c *clockPro[T]) Touch(key T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, ok := c.nodes[key]
+	if ok && node.status != clockProTest {
+		node.ref = true
+		c.stats.Hits++
+		return true
+	}
+	c.stats.Misses++
+	return false
+}
+
+// Delete removes a key from the cache.
+func (c *clockPro[T]) Delete(key T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.nodes[key]; ok {
+		c.remove(node)
+		switch node.status {
+		case clockProHot:
+			c.countHot--
+		case clockProCold:
+			c.countCold--
+		case clockProTest:
+			c.countTest--
+		}
+	}
+}
+
+// Evict removes the cold page under the cold hand from the cache.
+func (c *clockPro[T]) Evict() (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evict()
+}
+
+// EvictN removes up to n keys from the cache and returns them.
+func (c *clockPro[T]) EvictN(n int) []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var evictedKeys []T
+	for i := 0; i < n; i++ {
+		key, ok := c.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Peek returns the key that would be evicted next without removing it.
+// It may advance the hands, promoting referenced cold pages on the way.
+func (c *clockPro[T]) Peek() (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node := c.victim()
+	if node == nil {
+		var zero T
+		return zero, false
+	}
+	return node.key, true
+}
+
+// Resize changes the capacity of the cache. When shrinking, it evicts cold
+// pages until the cache fits the new capacity and returns them.
+func (c *clockPro[T]) Resize(capacity int) []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	if c.coldTarget > capacity {
+		c.coldTarget = capacity
+	}
+	var evictedKeys []T
+	for c.countHot+c.countCold > capacity {
+		key, ok := c.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.trimTest()
+	return evictedKeys
+}
+
+// Pin excludes the key from eviction until it is unpinned. If every key is
+// pinned, the cache grows beyond its capacity.
+func (c *clockPro[T]) Pin(key T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, ok := c.nodes[key]
+	if ok && node.status != clockProTest {
+		node.pinned = true
+		return true
+	}
+	return false
+}
+
+// Unpin makes a pinned key evictable again.
+func (c *clockPro[T]) Unpin(key T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, ok := c.nodes[key]
+	if ok && node.status != clockProTest {
+		node.pinned = false
+		return true
+	}
+	return false
+}
+
+// Reset clears all keys from the cache.
+func (c *clockPro[T]) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nodes = make(map[T]*clockProNode[T])
+	c.handHot, c.handCold, c.handTest = nil, nil, nil
+	c.countHot, c.countCold, c.countTest = 0, 0, 0
+	c.coldTarget = c.capacity
+}
+
+// Size returns the current number of keys in the cache.
+func (c *clockPro[T]) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.countHot + c.countCold
+}
+
+// Capacity returns the current capacity of the cache.
+func (c *clockPro[T]) Capacity() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.capacity
+}
+
+// Stats returns the counters collected by the cache.
+func (c *clockPro[T]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.countHot + c.countCold
+	stats.Capacity = c.capacity
+	return stats
+}
+
+// AdaptiveStats returns the sizes of the hot, cold and test pages, how often
+// test pages were reused or forgotten and the target number of cold pages.
+func (c *clockPro[T]) AdaptiveStats() AdaptiveStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return AdaptiveStats{
+		Recent:           c.countCold,
+		Frequent:         c.countHot,
+		Ghosts:           c.countTest,
+		GhostHits:        c.testHits,
+		GhostExpirations: c.testExpirations,
+		RecentTarget:     c.coldTarget,
+	}
+}
+
+// evict is an internal method that turns the cold page under the cold hand
+// into a test page and returns its key.
+func (c *clockPro[T]) evict() (T, bool) {
+	node := c.victim()
+	if node == nil {
+		var zero T
+		return zero, false
+	}
+	node.status = clockProTest
+	c.countCold--
+	c.countTest++
+	c.handCold = node.next
+	c.trimTest()
+	c.stats.Evictions++
+	return node.key, true
+}
+
+// victim runs the hands until the cold hand points at an unreferenced,
+// unpinned cold page and returns it, or nil if no page can be evicted.
+func (c *clockPro[T]) victim() *clockProNode[T] {
+	// Every page is visited a bounded number of times before each hand has
+	// cleared all reference bits; going further means everything is pinned.
+	for budget := 4*len(c.nodes) + 4; budget > 0; budget-- {
+		if c.countCold == 0 {
+			if c.countHot == 0 {
+				return nil
+			}
+			c.runHandHot()
+			continue
+		}
+		node := c.handCold
+		switch {
+		case node.status != clockProCold:
+			c.handCold = node.next
+		case node.ref:
+			node.ref = false
+			node.status = clockProHot
+			c.countCold--
+			c.countHot++
+			c.handCold = node.next
+			c.balanceHot()
+		case node.pinned:
+			c.handCold = node.next
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+// balanceHot demotes hot pages until they fit in the room left by coldTarget.
+func (c *clockPro[T]) balanceHot() {
+	for c.countHot > 0 && c.countHot > c.capacity-c.coldTarget {
+		c.runHandHot()
+	}
+}
+
+// runHandHot moves the hot hand by one page, demoting an unreferenced hot page
+// to cold and terminating the test period of a test page.
+func (c *clockPro[T]) runHandHot() {
+	node := c.handHot
+	c.handHot = node.next
+	switch node.status {
+	case clockProHot:
+		if node.ref {
+			node.ref = false
+		} else {
+			node.status = clockProCold
+			c.countHot--
+			c.countCold++
+		}
+	case clockProTest:
+		c.forgetTest(node)
+	}
+}
+
+// trimTest forgets the oldest test pages until there are no more of them than the capacity.
+func (c *clockPro[T]) trimTest() {
+	for c.countTest > 0 && c.countTest > c.capacity {
+		node := c.handTest
+		c.handTest = node.next
+		if node.status == clockProTest {
+			c.forgetTest(node)
+		}
+	}
+}
+
+// forgetTest removes a test page whose key was not reused during its test
+// period, giving cold pages less room.
+func (c *clockPro[T]) forgetTest(node *clockProNode[T]) {
+	c.remove(node)
+	c.countTest--
+	c.testExpirations++
+	if c.coldTarget > 1 {
+		c.coldTarget--
+	}
+}
+
+// insert places a new page at the head of the clock, right behind the hot hand.
+func (c *clockPro[T]) insert(node *clockProNode[T]) {
+	c.nodes[node.key] = node
+	if c.handHot == nil {
+		node.prev, node.next = node, node
+		c.handHot, c.handCold, c.handTest = node, node, node
+		return
+	}
+	node.prev = c.handHot.prev
+	node.next = c.handHot
+	node.prev.next = node
+	c.handHot.prev = node
+}
+
+// remove unlinks a page from the clock, moving the hands pointing at it forward.
+func (c *clockPro[T]) remove(node *clockProNode[T]) {
+	delete(c.nodes, node.key)
+	if node.next == node {
+		c.handHot, c.handCold, c.handTest = nil, nil, nil
+		return
+	}
+	if c.handHot == node {
+		c.handHot = node.next
+	}
+	if c.handCold == node {
+		c.handCold = node.next
+	}
+	if c.handTest == node {
+		c.handTest = node.next
+	}
+	node.prev.next = node.next
+	node.next.prev = node.prev
+}
@@ -0,0 +1,188 @@
+package eviction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockPro(t *testing.T) {
+	cache := NewClockPro[int](2)
+
+	// Test Put and Size
+	evictedKey, evicted := cache.Put(1)
+	assert.False(t, evicted)
+	assert.Equal(t, 0, evictedKey)
+	assert.Equal(t, 1, cache.Size())
+
+	evictedKey, evicted = cache.Put(2)
+	assert.False(t, evicted)
+	assert.Equal(t, 0, evictedKey)
+	assert.Equal(t, 2, cache.Size())
+
+	// Test Put with eviction
+	evictedKey, evicted = cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 1, evictedKey)
+	assert.Equal(t, 2, cache.Size())
+
+	// Test Delete
+	cache.Delete(2)
+	assert.Equal(t, 1, cache.Size())
+
+	// Test Reset
+	cache.Reset()
+	assert.Equal(t, 0, cache.Size())
+
+	// Test Evict
+	cache.Put(1)
+	cache.Put(2)
+	key, ok := cache.Evict()
+	assert.True(t, ok)
+	assert.Equal(t, 1, key)
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestClockProReferencedSurvive(t *testing.T) {
+	cache := NewClockPro[int](3)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+
+	// Referenced pages get a second chance
+	assert.True(t, cache.Touch(1))
+	assert.False(t, cache.Touch(4))
+	evictedKey, evicted := cache.Put(4)
+	assert.True(t, evicted)
+	assert.Equal(t, 2, evictedKey)
+
+	// An evicted key is no longer resident
+	assert.False(t, cache.Touch(2))
+	assert.Equal(t, 3, cache.Size())
+}
+
+func TestClockProScanResistance(t *testing.T) {
+	cache := NewClockPro[int](10)
+
+	// Build a hot working set that is reused after being evicted once
+	for round := 0; round < 3; round++ {
+		for key := 0; key < 5; key++ {
+			if !cache.Touch(key) {
+				cache.Put(key)
+			}
+		}
+		for key := 100 * (round + 1); key < 100*(round+1)+10; key++ {
+			cache.Put(key)
+		}
+	}
+
+	// A long one-off scan does not flush the working set
+	for key := 1000; key < 2000; key++ {
+		cache.Put(key)
+	}
+	hits := 0
+	for key := 0; key < 5; key++ {
+		if cache.Touch(key) {
+			hits++
+		}
+	}
+	assert.Equal(t, 5, hits)
+	assert.Equal(t, 10, cache.Size())
+}
+
+func TestClockProPeek(t *testing.T) {
+	cache := NewClockPro[int](2)
+
+	_, ok := cache.Peek()
+	assert.False(t, ok)
+
+	cache.Put(1)
+	cache.Put(2)
+	cache.Touch(1)
+	key, ok := cache.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 2, key)
+	assert.Equal(t, 2, cache.Size())
+
+	// Peek reports the key that Put evicts next
+	evictedKey, _ := cache.Put(3)
+	assert.Equal(t, key, evictedKey)
+}
+
+func TestClockProEvictNAndResize(t *testing.T) {
+	cache := NewClockPro[int](5)
+	for i := 1; i <= 4; i++ {
+		cache.Put(i)
+	}
+
+	assert.Equal(t, []int{1, 2}, cache.EvictN(2))
+	assert.Equal(t, 2, cache.Size())
+
+	cache.Put(5)
+	cache.Put(6)
+	assert.Equal(t, []int{3, 4}, cache.Resize(2))
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, 2, cache.Stats().Capacity)
+}
+
+func TestClockProPin(t *testing.T) {
+	cache := NewClockPro[int](2)
+
+	assert.False(t, cache.Pin(1))
+
+	cache.Put(1)
+	cache.Put(2)
+	assert.True(t, cache.Pin(1))
+
+	// The pinned key is skipped by eviction
+	evictedKey, evicted := cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 2, evictedKey)
+
+	// With every key pinned nothing can be evicted
+	assert.True(t, cache.Pin(3))
+	_, ok := cache.Evict()
+	assert.False(t, ok)
+	_, evicted = cache.Put(4)
+	assert.False(t, evicted)
+	assert.Equal(t, 3, cache.Size())
+
+	assert.True(t, cache.Unpin(1))
+	assert.ElementsMatch(t, []int{1, 4}, cache.Resize(1))
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestClockProStats(t *testing.T) {
+	cache := NewClockPro[int](2)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+	cache.Touch(3)
+	cache.Touch(1)
+	cache.Evict()
+
+	assert.Equal(t, Stats{Puts: 3, Hits: 1, Misses: 1, Evictions: 2, Size: 1, Capacity: 2}, cache.Stats())
+}
+
+func TestClockProAdaptiveStats(t *testing.T) {
+	cache := NewClockPro[int](2)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+	assert.Equal(t, AdaptiveStats{Recent: 2, Ghosts: 1, RecentTarget: 2}, cache.AdaptiveStats())
+
+	// Reusing an evicted key is a ghost hit and makes the key hot
+	cache.Put(1)
+	stats := cache.AdaptiveStats()
+	assert.Equal(t, uint64(1), stats.GhostHits)
+	assert.Equal(t, 2, stats.Recent+stats.Frequent)
+
+	// Ghosts beyond the capacity are forgotten
+	for i := 10; i < 20; i++ {
+		cache.Put(i)
+	}
+	stats = cache.AdaptiveStats()
+	assert.LessOrEqual(t, stats.Ghosts, 2)
+	assert.Positive(t, stats.GhostExpirations)
+	assert.Less(t, stats.RecentTarget, 2)
+}
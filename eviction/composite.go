@@ -0,0 +1,198 @@
+package eviction
+
+import "sync"
+
+// AdmissionPolicy decides whether a new key may enter a full cache.
+type AdmissionPolicy[T comparable] interface {
+	Record(key T)                   // Records an access to a key, whether or not it is cached.
+	Admit(candidate, victim T) bool // Reports whether candidate may replace victim in the cache.
+}
+
+// AdmittingPolicy is a Policy that may reject the keys it is given, such as
+// one created by NewComposite. A rejected Put returns the key itself as the
+// evicted key.
+type AdmittingPolicy[T comparable] interface {
+	Policy[T]
+
+	// Admission returns the AdmissionPolicy deciding which keys enter.
+	Admission() AdmissionPolicy[T]
+}
+
+// composite combines an AdmissionPolicy with the Policy evicting its keys.
+type composite[T comparable] struct {
+	mu         sync.Mutex
+	admission  AdmissionPolicy[T]
+	eviction   Policy[T]
+	keys       map[T]struct{}
+	rejections uint64
+}
+
+// NewComposite creates a new cache that evicts keys with the eviction policy
+// but only lets a new key in when the admission policy prefers it over the
+// key that would be evicted. A rejected Put returns the candidate itself as
+// the evicted key.
+func NewComposite[T comparable](admission AdmissionPolicy[T], eviction Policy[T]) Policy[T] {
+	return &composite[T]{
+		admission: admission,
+		eviction:  eviction,
+		keys:      make(map[T]struct{}),
+	}
+}
+
+// Admission returns the admission policy of the cache.
+func (c *composite[T]) Admission() AdmissionPolicy[T] {
+	return c.admission
+}
+
+// Put adds a key to the cache if the admission policy accepts it.
+func (c *composite[T]) Put(key T) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.put(key)
+}
+
+// PutAll adds keys to the cache under a single lock acquisition and returns
+// the keys evicted to make room for them.
+func (c *composite[T]) PutAll(keys []T) []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var evictedKeys []T
+	for _, key := range keys {
+		if evictedKey, evicted := c.put(key); evicted {
+			evictedKeys = append(evictedKeys, evictedKey)
+		}
+	}
+	return evictedKeys
+}
+
+// put is an internal method that adds a key to the cache.
+func (c *composite[T]) put(key T) (T, bool) {
+	c.admission.Record(key)
+	if _, ok := c.keys[key]; !ok && c.eviction.Size() >= c.eviction.Capacity() {
+		if victim, ok := c.eviction.Peek(); ok && !c.admission.Admit(key, victim) {
+			c.rejections++
+			return key, true
+		}
+	}
+	evictedKey, evicted := c.eviction.Put(key)
+	if evicted {
+		delete(c.keys, evictedKey)
+	}
+	c.keys[key] = struct{}{}
+	return evictedKey, evicted
+}
+
+// Touch records an access to the key in both policies.
+func (c *composite[T]) Touch(key T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.admission.Record(key)
+	return c.eviction.Touch(key)
+}
+
+// Delete removes a key from the cache.
+func (c *composite[T]) Delete(key T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.eviction.Delete(key)
+	delete(c.keys, key)
+}
+
+// Evict removes a key from the cache based on the eviction policy.
+func (c *composite[T]) Evict() (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.eviction.Evict()
+	if ok {
+		delete(c.keys, key)
+	}
+	return key, ok
+}
+
+// EvictN removes up to n keys from the cache based on the eviction policy.
+func (c *composite[T]) EvictN(n int) []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evictedKeys := c.eviction.EvictN(n)
+	for _, key := range evictedKeys {
+		delete(c.keys, key)
+	}
+	return evictedKeys
+}
+
+// Peek returns the key the eviction policy would evict next.
+func (c *composite[T]) Peek() (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.eviction.Peek()
+}
+
+// Resize changes the capacity of the eviction policy.
+func (c *composite[T]) Resize(capacity int) []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evictedKeys := c.eviction.Resize(capacity)
+	for _, key := range evictedKeys {
+		delete(c.keys, key)
+	}
+	return evictedKeys
+}
+
+// Pin excludes the key from eviction until it is unpinned.
+func (c *composite[T]) Pin(key T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.eviction.Pin(key)
+}
+
+// Unpin makes a pinned key evictable again.
+func (c *composite[T]) Unpin(key T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.eviction.Unpin(key)
+}
+
+// Reset clears all keys from the cache. The admission history is kept.
+func (c *composite[T]) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.eviction.Reset()
+	c.keys = make(map[T]struct{})
+}
+
+// Size returns the current number of keys in the cache.
+func (c *composite[T]) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.eviction.Size()
+}
+
+// Capacity returns the current capacity of the cache.
+func (c *composite[T]) Capacity() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.eviction.Capacity()
+}
+
+// Stats returns the counters collected by the eviction policy, counting
+// rejected candidates as evictions.
+func (c *composite[T]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.eviction.Stats()
+	stats.Evictions += c.rejections
+	return stats
+}
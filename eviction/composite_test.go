@@ -0,0 +1,62 @@
+package eviction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposite(t *testing.T) {
+	cache := NewComposite(NewTinyLFU[int](1024), NewLRU[int](2))
+
+	cache.Put(1)
+	cache.Put(2)
+	assert.Equal(t, 2, cache.Size())
+
+	// A key seen once is not preferred over the victim
+	evictedKey, evicted := cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 3, evictedKey)
+	assert.Equal(t, 2, cache.Size())
+	assert.False(t, cache.Touch(3))
+
+	// A key seen more often than the victim replaces it
+	cache.Touch(4)
+	cache.Touch(4)
+	evictedKey, evicted = cache.Put(4)
+	assert.True(t, evicted)
+	assert.Equal(t, 1, evictedKey)
+	assert.True(t, cache.Touch(4))
+
+	// Existing keys are always accepted
+	_, evicted = cache.Put(2)
+	assert.False(t, evicted)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+}
+
+func TestCompositeDelegates(t *testing.T) {
+	cache := NewComposite(NewTinyLFU[int](1024), NewFIFO[int](3))
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+
+	key, ok := cache.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 1, key)
+	assert.True(t, cache.Pin(1))
+	assert.Equal(t, []int{2}, cache.EvictN(1))
+	assert.True(t, cache.Unpin(1))
+	assert.Equal(t, []int{1}, cache.Resize(1))
+
+	cache.Delete(3)
+	assert.Equal(t, 0, cache.Size())
+
+	// Room freed by Delete is available without admission
+	_, evicted := cache.Put(5)
+	assert.False(t, evicted)
+	cache.Reset()
+	assert.Equal(t, 0, cache.Size())
+}
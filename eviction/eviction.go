@@ -2,9 +2,57 @@ package eviction
 
 // Policy defines the interface for cache eviction policies.
 type Policy[T comparable] interface {
-	Put(key T) (T, bool) // Adds a key to the cache, returns the evicted key if any.
-	Delete(key T)        // Removes a key from the cache.
-	Evict() (T, bool)    // Evicts a key from the cache based on the policy.
-	Reset()              // Clears all keys from the cache.
-	Size() int           // Returns the current number of keys in the cache.
+	Put(key T) (T, bool)     // Adds a key to the cache, returns the evicted key if any.
+	PutAll(keys []T) []T     // Adds keys to the cache, returns the evicted keys.
+	Touch(key T) bool        // Records an access to a key, returns false if it is not in the cache.
+	Delete(key T)            // Removes a key from the cache.
+	Evict() (T, bool)        // Evicts a key from the cache based on the policy.
+	EvictN(n int) []T        // Evicts up to n keys from the cache, returns the evicted keys.
+	Peek() (T, bool)         // Returns the key that would be evicted next without removing it.
+	Resize(capacity int) []T // Changes the capacity, returns the keys evicted to fit it.
+	Pin(key T) bool          // Excludes a key from eviction, returns false if it is not in the cache.
+	Unpin(key T) bool        // Makes a pinned key evictable again, returns false if it is not in the cache.
+	Reset()                  // Clears all keys from the cache.
+	Size() int               // Returns the current number of keys in the cache.
+	Capacity() int           // Returns the current capacity of the cache, 0 if it has none.
+	Stats() Stats            // Returns the counters collected by the policy.
+}
+
+// Stats holds the counters collected by a Policy since it was created.
+type Stats struct {
+	Puts      uint64 // Number of keys inserted by Put.
+	Hits      uint64 // Number of Touch calls for keys in the cache.
+	Misses    uint64 // Number of Touch calls for keys not in the cache.
+	Evictions uint64 // Number of keys evicted, whether by Put, Evict, EvictN or Resize.
+	Size      int    // Current number of keys in the cache.
+	Capacity  int    // Current capacity of the cache.
+}
+
+// HitRatio returns the fraction of Touch calls that found their key, or 0 if
+// there were none.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// AdaptivePolicy is a Policy that balances recency against frequency using a
+// history of recently evicted keys, such as CLOCK-Pro.
+type AdaptivePolicy[T comparable] interface {
+	Policy[T]
+
+	// AdaptiveStats returns the internal state driving the adaptation.
+	AdaptiveStats() AdaptiveStats
+}
+
+// AdaptiveStats describes the internal state of an AdaptivePolicy.
+type AdaptiveStats struct {
+	Recent           int    // Number of resident keys seen recently but not reused yet.
+	Frequent         int    // Number of resident keys that have been reused.
+	Ghosts           int    // Number of evicted keys still remembered in the history.
+	GhostHits        uint64 // Number of Puts of keys found in the history, each favoring recency.
+	GhostExpirations uint64 // Number of keys dropped from the history unused, each favoring frequency.
+	RecentTarget     int    // Adaptive target number of recent keys.
 }
@@ -9,8 +9,15 @@ import (
 type FIFO[T comparable] struct {
 	mu       sync.Mutex
 	capacity int
+	stats    Stats
 	cache    map[T]*list.Element
 	list     *list.List
+	// pinned holds the pinned keys out of list, so that finding the oldest
+	// unpinned key does not walk past them
+	pinned *list.List
+	// seq orders the keys by their insertion, to put them back in list when
+	// they are unpinned
+	seq uint64
 }
 
 // NewFIFO creates a new FIFO cache with the given capacity.
@@ -19,6 +26,7 @@ func NewFIFO[T comparable](capacity int) Policy[T] {
 		capacity: capacity,
 		cache:    make(map[T]*list.Element),
 		list:     list.New(),
+		pinned:   list.New(),
 	}
 }
 
@@ -26,28 +34,67 @@ func NewFIFO[T comparable](capacity int) Policy[T] {
 func (f *FIFO[T]) Put(key T) (T, bool) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	return f.put(key)
+}
+
+// PutAll adds keys to the cache under a single lock acquisition and returns
+// the keys evicted to make room for them.
+func (f *FIFO[T]) PutAll(keys []T) []T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var evictedKeys []T
+	for _, key := range keys {
+		if evictedKey, evicted := f.put(key); evicted {
+			evictedKeys = append(evictedKeys, evictedKey)
+		}
+	}
+	return evictedKeys
+}
 
+// put is an internal method that adds a key to the cache.
+func (f *FIFO[T]) put(key T) (T, bool) {
 	var evictedKey T
 	var evicted bool
 
 	if _, ok := f.cache[key]; ok {
 		return evictedKey, false
 	}
-	if f.list.Len() >= f.capacity {
+	if len(f.cache) >= f.capacity {
 		evictedKey, evicted = f.evict()
 	}
-	elem := f.list.PushBack(&entry[T]{key})
+	f.seq++
+	elem := f.list.PushBack(&entry[T]{key: key, seq: f.seq})
 	f.cache[key] = elem
+	f.stats.Puts++
 	return evictedKey, evicted
 }
 
+// Touch reports whether the key is in the cache. Accesses do not affect FIFO order.
+func (f *FIFO[T]) Touch(key T) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.cache[key]
+	if ok {
+		f.stats.Hits++
+	} else {
+		f.stats.Misses++
+	}
+	return ok
+}
+
 // Delete removes a key from the cache.
 func (f *FIFO[T]) Delete(key T) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
 	if elem, ok := f.cache[key]; ok {
-		f.list.Remove(elem)
+		if elem.Value.(*entry[T]).pinned {
+			f.pinned.Remove(elem)
+		} else {
+			f.list.Remove(elem)
+		}
 		delete(f.cache, key)
 	}
 }
@@ -59,6 +106,129 @@ func (f *FIFO[T]) Evict() (T, bool) {
 	return f.evict()
 }
 
+// EvictN removes up to n of the oldest keys from the cache and returns them.
+func (f *FIFO[T]) EvictN(n int) []T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var evictedKeys []T
+	for i := 0; i < n; i++ {
+		key, ok := f.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Peek returns the oldest key without removing it.
+func (f *FIFO[T]) Peek() (T, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	elem := f.victim()
+	if elem == nil {
+		var zero T
+		return zero, false
+	}
+	return elem.Value.(*entry[T]).key, true
+}
+
+// Resize changes the capacity of the cache. When shrinking, it evicts the oldest
+// keys until the cache fits the new capacity and returns them.
+func (f *FIFO[T]) Resize(capacity int) []T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.capacity = capacity
+	var evictedKeys []T
+	for len(f.cache) > capacity {
+		key, ok := f.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Pin excludes the key from eviction until it is unpinned. If every key is
+// pinned, the cache grows beyond its capacity.
+func (f *FIFO[T]) Pin(key T) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	elem, ok := f.cache[key]
+	if ok && !elem.Value.(*entry[T]).pinned {
+		e := f.list.Remove(elem).(*entry[T])
+		e.pinned = true
+		f.cache[key] = f.pinned.PushBack(e)
+	}
+	return ok
+}
+
+// Unpin makes a pinned key evictable again, in the place its insertion gives
+// it among the other keys.
+func (f *FIFO[T]) Unpin(key T) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	elem, ok := f.cache[key]
+	if ok && elem.Value.(*entry[T]).pinned {
+		e := f.pinned.Remove(elem).(*entry[T])
+		e.pinned = false
+		f.cache[key] = f.insert(e)
+	}
+	return ok
+}
+
+// Snapshot serializes the keys from the oldest to the newest.
+func (f *FIFO[T]) Snapshot() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries := make([]snapshotEntry[T], 0, len(f.cache))
+	for _, e := range sortedEntries[T](f.list, f.pinned) {
+		entries = append(entries, snapshotEntry[T]{Key: e.key, Pinned: e.pinned})
+	}
+	return encodeSnapshot(entries)
+}
+
+// Restore replaces the keys of the cache with a snapshot, evicting the
+// oldest ones beyond the capacity.
+func (f *FIFO[T]) Restore(data []byte) error {
+	entries, err := decodeSnapshot[T](data)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cache = make(map[T]*list.Element, len(entries))
+	f.list.Init()
+	f.pinned.Init()
+	for _, e := range entries {
+		if _, ok := f.cache[e.Key]; ok {
+			continue
+		}
+		f.seq++
+		restored := &entry[T]{key: e.Key, seq: f.seq, pinned: e.Pinned}
+		if e.Pinned {
+			f.cache[e.Key] = f.pinned.PushBack(restored)
+		} else {
+			f.cache[e.Key] = f.list.PushBack(restored)
+		}
+	}
+	for len(f.cache) > f.capacity {
+		if _, ok := f.evict(); !ok {
+			break
+		}
+	}
+	return nil
+}
+
 // Reset clears all keys from the cache.
 func (f *FIFO[T]) Reset() {
 	f.mu.Lock()
@@ -66,6 +236,7 @@ func (f *FIFO[T]) Reset() {
 
 	f.cache = make(map[T]*list.Element)
 	f.list.Init()
+	f.pinned.Init()
 }
 
 // Size returns the current number of keys in the cache.
@@ -76,9 +247,17 @@ func (f *FIFO[T]) Size() int {
 	return len(f.cache)
 }
 
-// evict is an internal method that removes the oldest key from the cache.
+// Capacity returns the current capacity of the cache.
+func (f *FIFO[T]) Capacity() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.capacity
+}
+
+// evict is an internal method that removes the oldest unpinned key from the cache.
 func (f *FIFO[T]) evict() (T, bool) {
-	elem := f.list.Front()
+	elem := f.victim()
 	if elem == nil {
 		var zero T
 		return zero, false
@@ -86,5 +265,33 @@ func (f *FIFO[T]) evict() (T, bool) {
 	f.list.Remove(elem)
 	entry := elem.Value.(*entry[T])
 	delete(f.cache, entry.key)
+	f.stats.Evictions++
 	return entry.key, true
 }
+
+// Stats returns the counters collected by the cache.
+func (f *FIFO[T]) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := f.stats
+	stats.Size = len(f.cache)
+	stats.Capacity = f.capacity
+	return stats
+}
+
+// victim returns the element of the oldest unpinned key, or nil if there is none.
+func (f *FIFO[T]) victim() *list.Element {
+	return f.list.Front()
+}
+
+// insert puts an unpinned entry back in list before the keys inserted after
+// it, and returns its element.
+func (f *FIFO[T]) insert(e *entry[T]) *list.Element {
+	for elem := f.list.Front(); elem != nil; elem = elem.Next() {
+		if elem.Value.(*entry[T]).seq > e.seq {
+			return f.list.InsertBefore(e, elem)
+		}
+	}
+	return f.list.PushBack(e)
+}
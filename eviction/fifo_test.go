@@ -76,3 +76,141 @@ func TestFIFODelNonExistentKey(t *testing.T) {
 	cache.Delete(1)
 	assert.Equal(t, 0, cache.Size())
 }
+
+func TestFIFOPeek(t *testing.T) {
+	cache := NewFIFO[int](2)
+
+	_, ok := cache.Peek()
+	assert.False(t, ok)
+
+	cache.Put(1)
+	cache.Put(2)
+	key, ok := cache.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 1, key)
+	assert.Equal(t, 2, cache.Size())
+
+	// Peek reports the key that Put evicts next
+	evictedKey, _ := cache.Put(3)
+	assert.Equal(t, key, evictedKey)
+}
+
+func TestFIFOResize(t *testing.T) {
+	cache := NewFIFO[int](3)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+
+	// Growing keeps every key
+	assert.Empty(t, cache.Resize(4))
+	assert.Equal(t, 3, cache.Size())
+	_, evicted := cache.Put(4)
+	assert.False(t, evicted)
+
+	// Shrinking evicts down to the new capacity
+	assert.Equal(t, []int{1, 2}, cache.Resize(2))
+	assert.Equal(t, 2, cache.Size())
+	evictedKey, evicted := cache.Put(5)
+	assert.True(t, evicted)
+	assert.Equal(t, 3, evictedKey)
+}
+
+func TestFIFOTouch(t *testing.T) {
+	cache := NewFIFO[int](2)
+
+	assert.False(t, cache.Touch(1))
+	assert.Equal(t, 0, cache.Size())
+
+	cache.Put(1)
+	cache.Put(2)
+	assert.True(t, cache.Touch(1))
+
+	// Touching does not change insertion order
+	evictedKey, evicted := cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 1, evictedKey)
+}
+
+func TestFIFOEvictN(t *testing.T) {
+	cache := NewFIFO[int](5)
+	for i := 1; i <= 4; i++ {
+		cache.Put(i)
+	}
+
+	assert.Equal(t, []int{1, 2}, cache.EvictN(2))
+	assert.Equal(t, 2, cache.Size())
+
+	// Asking for more keys than present evicts everything
+	assert.Equal(t, []int{3, 4}, cache.EvictN(10))
+	assert.Equal(t, 0, cache.Size())
+	assert.Empty(t, cache.EvictN(1))
+}
+
+func TestFIFOPin(t *testing.T) {
+	cache := NewFIFO[int](2)
+
+	assert.False(t, cache.Pin(1))
+
+	cache.Put(1)
+	cache.Put(2)
+	assert.True(t, cache.Pin(1))
+
+	// The pinned key is skipped by eviction
+	key, ok := cache.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 2, key)
+	evictedKey, evicted := cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 2, evictedKey)
+
+	// With every key pinned nothing can be evicted
+	assert.True(t, cache.Pin(3))
+	_, ok = cache.Evict()
+	assert.False(t, ok)
+	_, evicted = cache.Put(4)
+	assert.False(t, evicted)
+	assert.Equal(t, 3, cache.Size())
+
+	assert.True(t, cache.Unpin(1))
+	assert.Equal(t, []int{1, 4}, cache.Resize(1))
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestFIFOUnpinOrder(t *testing.T) {
+	cache := NewFIFO[int](4)
+	for i := 1; i <= 4; i++ {
+		cache.Put(i)
+	}
+
+	// An unpinned key takes its place back among the others
+	assert.True(t, cache.Pin(2))
+	assert.True(t, cache.Pin(3))
+	assert.True(t, cache.Unpin(3))
+	assert.True(t, cache.Unpin(1))
+	assert.Equal(t, []int{1, 3, 4}, cache.EvictN(4))
+	assert.True(t, cache.Unpin(2))
+	assert.Equal(t, []int{2}, cache.EvictN(1))
+}
+
+func TestFIFOStats(t *testing.T) {
+	cache := NewFIFO[int](2)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+	cache.Touch(3)
+	cache.Touch(1)
+	cache.Evict()
+
+	assert.Equal(t, Stats{Puts: 3, Hits: 1, Misses: 1, Evictions: 2, Size: 1, Capacity: 2}, cache.Stats())
+	assert.Equal(t, 0.5, cache.Stats().HitRatio())
+}
+
+func TestFIFOPutAll(t *testing.T) {
+	cache := NewFIFO[int](3)
+	cache.Put(1)
+
+	assert.Equal(t, []int{1, 2}, cache.PutAll([]int{2, 3, 4, 5}))
+	assert.Equal(t, 3, cache.Size())
+	assert.Equal(t, uint64(5), cache.Stats().Puts)
+	assert.Empty(t, cache.PutAll(nil))
+}
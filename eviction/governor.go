@@ -0,0 +1,161 @@
+package eviction
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Resizable is a cache whose capacity can be changed at runtime. It is
+// implemented by every Policy as well as by cache.EvictionStore, which also
+// removes the evicted objects.
+type Resizable[T comparable] interface {
+	Resize(capacity int) []T // Changes the capacity, returns the keys evicted to fit it.
+	Capacity() int           // Returns the current capacity.
+}
+
+// MemoryGovernor adjusts the capacity of a cache to keep the heap of the
+// process below a fraction of its memory limit. It shrinks the capacity in
+// proportion to the excess when the heap is too large and grows it back
+// gradually, up to its initial value, once memory is available again.
+type MemoryGovernor[T comparable] struct {
+	mu          sync.Mutex
+	target      Resizable[T]
+	fraction    float64
+	minCapacity int
+	maxCapacity int
+	memoryLimit int64
+	clock       Clock
+	stop        chan struct{}
+	// readMemStats reads the heap statistics, replaced in tests.
+	readMemStats func(*runtime.MemStats)
+}
+
+// GovernorOption configures optional behaviour of a MemoryGovernor.
+type GovernorOption func(*governorOptions)
+
+// governorOptions holds the optional settings applied by GovernorOption.
+type governorOptions struct {
+	minCapacity int
+	maxCapacity int
+	memoryLimit int64
+	clock       Clock
+}
+
+// WithCapacityBounds limits the capacities chosen by the governor. By default
+// the capacity stays between 1 and the capacity of the cache at creation.
+func WithCapacityBounds(minCapacity, maxCapacity int) GovernorOption {
+	return func(o *governorOptions) {
+		o.minCapacity = minCapacity
+		o.maxCapacity = maxCapacity
+	}
+}
+
+// WithMemoryLimit sets the memory limit in bytes that the heap fraction is
+// relative to. By default the governor uses the Go runtime memory limit set by
+// GOMEMLIMIT or debug.SetMemoryLimit.
+func WithMemoryLimit(bytes int64) GovernorOption {
+	return func(o *governorOptions) {
+		o.memoryLimit = bytes
+	}
+}
+
+// WithClock sets the clock driving the periodic adjustments started by Start.
+// By default the governor uses the real time.
+func WithClock(clock Clock) GovernorOption {
+	return func(o *governorOptions) {
+		o.clock = clock
+	}
+}
+
+// NewMemoryGovernor creates a new MemoryGovernor keeping the heap below
+// targetHeapFraction of the memory limit by resizing target.
+func NewMemoryGovernor[T comparable](target Resizable[T], targetHeapFraction float64, opts ...GovernorOption) *MemoryGovernor[T] {
+	o := governorOptions{
+		minCapacity: 1,
+		maxCapacity: target.Capacity(),
+		clock:       RealClock{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.memoryLimit <= 0 {
+		o.memoryLimit = debug.SetMemoryLimit(-1)
+	}
+	return &MemoryGovernor[T]{
+		target:       target,
+		fraction:     targetHeapFraction,
+		minCapacity:  o.minCapacity,
+		maxCapacity:  o.maxCapacity,
+		memoryLimit:  o.memoryLimit,
+		clock:        o.clock,
+		readMemStats: runtime.ReadMemStats,
+	}
+}
+
+// Start adjusts the capacity every interval in a background goroutine until
+// Stop is called.
+func (g *MemoryGovernor[T]) Start(interval time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	g.stop = stop
+	go func() {
+		timer := g.clock.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C():
+				g.Adjust()
+				timer.Reset(interval)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background adjustments started by Start.
+func (g *MemoryGovernor[T]) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stop != nil {
+		close(g.stop)
+		g.stop = nil
+	}
+}
+
+// Adjust reads the heap statistics once and resizes the cache if needed. It
+// returns the keys evicted by shrinking.
+func (g *MemoryGovernor[T]) Adjust() []T {
+	// Without a memory limit there is nothing to compare the heap to.
+	if g.memoryLimit <= 0 || g.memoryLimit == math.MaxInt64 {
+		return nil
+	}
+	var ms runtime.MemStats
+	g.readMemStats(&ms)
+
+	heap := float64(ms.HeapAlloc)
+	budget := g.fraction * float64(g.memoryLimit)
+	capacity := g.target.Capacity()
+	newCapacity := capacity
+	switch {
+	case heap > budget:
+		newCapacity = int(float64(capacity) * budget / heap)
+	case heap < 0.9*budget:
+		// Grow gradually so that a single quiet period does not undo the shrinking.
+		newCapacity = capacity + capacity/10 + 1
+	}
+	newCapacity = min(max(newCapacity, g.minCapacity), g.maxCapacity)
+	if newCapacity == capacity {
+		return nil
+	}
+	return g.target.Resize(newCapacity)
+}
@@ -0,0 +1,89 @@
+package eviction
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryGovernorAdjust(t *testing.T) {
+	cache := NewLRU[int](100)
+	for i := 0; i < 100; i++ {
+		cache.Put(i)
+	}
+
+	var heapAlloc uint64
+	governor := NewMemoryGovernor(cache, 0.5, WithMemoryLimit(1000))
+	governor.readMemStats = func(ms *runtime.MemStats) {
+		ms.HeapAlloc = heapAlloc
+	}
+
+	// Within budget at full capacity nothing changes
+	heapAlloc = 400
+	assert.Empty(t, governor.Adjust())
+	assert.Equal(t, 100, cache.Stats().Capacity)
+
+	// Twice over budget halves the capacity
+	heapAlloc = 1000
+	assert.Len(t, governor.Adjust(), 50)
+	assert.Equal(t, 50, cache.Size())
+	assert.Equal(t, 50, cache.Stats().Capacity)
+
+	// Memory available again grows the capacity back gradually
+	heapAlloc = 100
+	assert.Empty(t, governor.Adjust())
+	assert.Equal(t, 56, cache.Stats().Capacity)
+	for i := 0; i < 20; i++ {
+		governor.Adjust()
+	}
+	assert.Equal(t, 100, cache.Stats().Capacity)
+}
+
+func TestMemoryGovernorBounds(t *testing.T) {
+	cache := NewFIFO[int](10)
+	governor := NewMemoryGovernor(cache, 0.5, WithMemoryLimit(1000), WithCapacityBounds(4, 20))
+	governor.readMemStats = func(ms *runtime.MemStats) {
+		ms.HeapAlloc = 1_000_000
+	}
+
+	governor.Adjust()
+	assert.Equal(t, 4, cache.Stats().Capacity)
+}
+
+func TestMemoryGovernorStartStop(t *testing.T) {
+	cache := NewFIFO[int](10)
+	governor := NewMemoryGovernor(cache, 0.5, WithMemoryLimit(1000))
+	governor.readMemStats = func(ms *runtime.MemStats) {
+		ms.HeapAlloc = 1_000_000
+	}
+
+	governor.Start(time.Millisecond)
+	defer governor.Stop()
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Capacity == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestMemoryGovernorClock(t *testing.T) {
+	cache := NewFIFO[int](10)
+	clock := NewFakeClock(time.Unix(0, 0))
+	governor := NewMemoryGovernor(cache, 0.5, WithMemoryLimit(1000), WithClock(clock))
+	governor.readMemStats = func(ms *runtime.MemStats) {
+		ms.HeapAlloc = 1_000_000
+	}
+
+	governor.Start(time.Minute)
+	defer governor.Stop()
+	assert.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+
+	// Nothing happens until the interval has elapsed
+	clock.Step(time.Second)
+	assert.Equal(t, 10, cache.Stats().Capacity)
+
+	clock.Step(time.Minute)
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Capacity == 1
+	}, time.Second, time.Millisecond)
+}
@@ -9,6 +9,7 @@ import (
 type LFU[T comparable] struct {
 	mu       sync.Mutex
 	capacity int
+	stats    Stats
 	cache    map[T]*lfuEntry[T]
 	freqHeap *lfuHeap[T]
 }
@@ -17,6 +18,7 @@ type lfuEntry[T comparable] struct {
 	key       T
 	frequency int
 	index     int
+	pinned    bool
 }
 
 type lfuHeap[T comparable] []*lfuEntry[T]
@@ -34,7 +36,26 @@ func NewLFU[T comparable](capacity int) Policy[T] {
 func (l *LFU[T]) Put(key T) (T, bool) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	return l.put(key)
+}
+
+// PutAll adds keys to the cache under a single lock acquisition and returns
+// the keys evicted to make room for them.
+func (l *LFU[T]) PutAll(keys []T) []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var evictedKeys []T
+	for _, key := range keys {
+		if evictedKey, evicted := l.put(key); evicted {
+			evictedKeys = append(evictedKeys, evictedKey)
+		}
+	}
+	return evictedKeys
+}
 
+// put is an internal method that adds a key to the cache.
+func (l *LFU[T]) put(key T) (T, bool) {
 	var evictedKey T
 	var evicted bool
 
@@ -49,9 +70,26 @@ func (l *LFU[T]) Put(key T) (T, bool) {
 	entry := &lfuEntry[T]{key: key, frequency: 1}
 	heap.Push(l.freqHeap, entry)
 	l.cache[key] = entry
+	l.stats.Puts++
 	return evictedKey, evicted
 }
 
+// Touch increments the frequency of the key without inserting it.
+func (l *LFU[T]) Touch(key T) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.cache[key]
+	if ok {
+		entry.frequency++
+		heap.Fix(l.freqHeap, entry.index)
+		l.stats.Hits++
+	} else {
+		l.stats.Misses++
+	}
+	return ok
+}
+
 // Delete removes a key from the cache.
 func (l *LFU[T]) Delete(key T) {
 	l.mu.Lock()
@@ -63,6 +101,94 @@ func (l *LFU[T]) Delete(key T) {
 	}
 }
 
+// Resize changes the capacity of the cache. When shrinking, it evicts the least frequently used
+// keys until the cache fits the new capacity and returns them.
+func (l *LFU[T]) Resize(capacity int) []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.capacity = capacity
+	var evictedKeys []T
+	for len(l.cache) > capacity {
+		key, ok := l.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Pin excludes the key from eviction until it is unpinned. If every key is
+// pinned, the cache grows beyond its capacity.
+func (l *LFU[T]) Pin(key T) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.cache[key]
+	if ok {
+		entry.pinned = true
+		heap.Fix(l.freqHeap, entry.index)
+	}
+	return ok
+}
+
+// Unpin makes a pinned key evictable again.
+func (l *LFU[T]) Unpin(key T) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.cache[key]
+	if ok {
+		entry.pinned = false
+		heap.Fix(l.freqHeap, entry.index)
+	}
+	return ok
+}
+
+// Snapshot serializes the keys with their frequencies.
+func (l *LFU[T]) Snapshot() ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]snapshotEntry[T], 0, len(*l.freqHeap))
+	for _, e := range *l.freqHeap {
+		entries = append(entries, snapshotEntry[T]{Key: e.key, Frequency: e.frequency, Pinned: e.pinned})
+	}
+	return encodeSnapshot(entries)
+}
+
+// Restore replaces the keys of the cache with a snapshot, evicting the
+// least frequently used ones beyond the capacity.
+func (l *LFU[T]) Restore(data []byte) error {
+	entries, err := decodeSnapshot[T](data)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cache = make(map[T]*lfuEntry[T], len(entries))
+	h := make(lfuHeap[T], 0, len(entries))
+	for _, e := range entries {
+		if _, ok := l.cache[e.Key]; ok {
+			continue
+		}
+		entry := &lfuEntry[T]{key: e.Key, frequency: e.Frequency, index: len(h), pinned: e.Pinned}
+		h = append(h, entry)
+		l.cache[e.Key] = entry
+	}
+	heap.Init(&h)
+	l.freqHeap = &h
+	for len(l.cache) > l.capacity {
+		if _, ok := l.evict(); !ok {
+			break
+		}
+	}
+	return nil
+}
+
 // Reset clears all keys from the cache.
 func (l *LFU[T]) Reset() {
 	l.mu.Lock()
@@ -80,6 +206,14 @@ func (l *LFU[T]) Size() int {
 	return len(l.cache)
 }
 
+// Capacity returns the current capacity of the cache.
+func (l *LFU[T]) Capacity() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.capacity
+}
+
 // Evict removes the least frequently used key from the cache.
 func (l *LFU[T]) Evict() (T, bool) {
 	l.mu.Lock()
@@ -87,20 +221,66 @@ func (l *LFU[T]) Evict() (T, bool) {
 	return l.evict()
 }
 
-// evict is an internal method that removes the least frequently used key from the cache.
+// EvictN removes up to n of the least frequently used keys from the cache and returns them.
+func (l *LFU[T]) EvictN(n int) []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var evictedKeys []T
+	for i := 0; i < n; i++ {
+		key, ok := l.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Peek returns the least frequently used key without removing it.
+func (l *LFU[T]) Peek() (T, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(*l.freqHeap) == 0 || (*l.freqHeap)[0].pinned {
+		var zero T
+		return zero, false
+	}
+	return (*l.freqHeap)[0].key, true
+}
+
+// evict is an internal method that removes the least frequently used unpinned key from the cache.
 func (l *LFU[T]) evict() (T, bool) {
-	if len(*l.freqHeap) == 0 {
+	if len(*l.freqHeap) == 0 || (*l.freqHeap)[0].pinned {
 		var zero T
 		return zero, false
 	}
 	entry := heap.Pop(l.freqHeap).(*lfuEntry[T])
 	delete(l.cache, entry.key)
+	l.stats.Evictions++
 	return entry.key, true
 }
 
-func (h lfuHeap[T]) Len() int           { return len(h) }
-func (h lfuHeap[T]) Less(i, j int) bool { return h[i].frequency < h[j].frequency }
-func (h lfuHeap[T]) Swap(i, j int)      { h[i], h[j] = h[j], h[i]; h[i].index = i; h[j].index = j }
+// Stats returns the counters collected by the cache.
+func (l *LFU[T]) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := l.stats
+	stats.Size = len(l.cache)
+	stats.Capacity = l.capacity
+	return stats
+}
+
+func (h lfuHeap[T]) Len() int { return len(h) }
+func (h lfuHeap[T]) Less(i, j int) bool {
+	// Pinned entries sink below every unpinned one so that the root is always evictable.
+	if h[i].pinned != h[j].pinned {
+		return !h[i].pinned
+	}
+	return h[i].frequency < h[j].frequency
+}
+func (h lfuHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i]; h[i].index = i; h[j].index = j }
 func (h *lfuHeap[T]) Push(x interface{}) {
 	entry := x.(*lfuEntry[T])
 	entry.index = len(*h)
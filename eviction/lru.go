@@ -1,7 +1,9 @@
 package eviction
 
 import (
+	"cmp"
 	"container/list"
+	"slices"
 	"sync"
 )
 
@@ -9,12 +11,21 @@ import (
 type lru[T comparable] struct {
 	mu       sync.Mutex
 	capacity int
+	stats    Stats
 	cache    map[T]*list.Element
 	list     *list.List
+	// pinned holds the pinned keys out of list, so that finding the least
+	// recently used unpinned key does not walk past them
+	pinned *list.List
+	// seq orders the keys by their last use, to put them back in list when
+	// they are unpinned
+	seq uint64
 }
 
 type entry[T comparable] struct {
-	key T
+	key    T
+	seq    uint64
+	pinned bool
 }
 
 // NewLRU creates a new lru cache with the given capacity.
@@ -23,6 +34,7 @@ func NewLRU[T comparable](capacity int) Policy[T] {
 		capacity: capacity,
 		cache:    make(map[T]*list.Element),
 		list:     list.New(),
+		pinned:   list.New(),
 	}
 }
 
@@ -30,33 +42,164 @@ func NewLRU[T comparable](capacity int) Policy[T] {
 func (l *lru[T]) Put(key T) (T, bool) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	return l.put(key)
+}
+
+// PutAll adds keys to the cache under a single lock acquisition and returns
+// the keys evicted to make room for them.
+func (l *lru[T]) PutAll(keys []T) []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
+	var evictedKeys []T
+	for _, key := range keys {
+		if evictedKey, evicted := l.put(key); evicted {
+			evictedKeys = append(evictedKeys, evictedKey)
+		}
+	}
+	return evictedKeys
+}
+
+// put is an internal method that adds a key to the cache.
+func (l *lru[T]) put(key T) (T, bool) {
 	var evictedKey T
 	var evicted bool
 
 	if elem, ok := l.cache[key]; ok {
-		l.list.MoveToFront(elem)
+		l.touch(elem)
 		return evictedKey, false
 	}
-	if l.list.Len() >= l.capacity {
+	if len(l.cache) >= l.capacity {
 		evictedKey, evicted = l.evict()
 	}
-	elem := l.list.PushFront(&entry[T]{key})
+	l.seq++
+	elem := l.list.PushFront(&entry[T]{key: key, seq: l.seq})
 	l.cache[key] = elem
+	l.stats.Puts++
 	return evictedKey, evicted
 }
 
+// Touch marks the key as most recently used without inserting it.
+func (l *lru[T]) Touch(key T) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.cache[key]
+	if ok {
+		l.touch(elem)
+		l.stats.Hits++
+	} else {
+		l.stats.Misses++
+	}
+	return ok
+}
+
 // Delete removes a key from the cache.
 func (l *lru[T]) Delete(key T) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	if elem, ok := l.cache[key]; ok {
-		l.list.Remove(elem)
+		l.listOf(elem).Remove(elem)
 		delete(l.cache, key)
 	}
 }
 
+// Resize changes the capacity of the cache. When shrinking, it evicts the least recently used
+// keys until the cache fits the new capacity and returns them.
+func (l *lru[T]) Resize(capacity int) []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.capacity = capacity
+	var evictedKeys []T
+	for len(l.cache) > capacity {
+		key, ok := l.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Pin excludes the key from eviction until it is unpinned. If every key is
+// pinned, the cache grows beyond its capacity.
+func (l *lru[T]) Pin(key T) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.cache[key]
+	if ok && !elem.Value.(*entry[T]).pinned {
+		e := l.list.Remove(elem).(*entry[T])
+		e.pinned = true
+		l.cache[key] = l.pinned.PushBack(e)
+	}
+	return ok
+}
+
+// Unpin makes a pinned key evictable again, in the place its last use gives
+// it among the other keys.
+func (l *lru[T]) Unpin(key T) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.cache[key]
+	if ok && elem.Value.(*entry[T]).pinned {
+		e := l.pinned.Remove(elem).(*entry[T])
+		e.pinned = false
+		l.cache[key] = l.insert(e)
+	}
+	return ok
+}
+
+// Snapshot serializes the keys from the least to the most recently used.
+func (l *lru[T]) Snapshot() ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]snapshotEntry[T], 0, len(l.cache))
+	for _, e := range sortedEntries[T](l.list, l.pinned) {
+		entries = append(entries, snapshotEntry[T]{Key: e.key, Pinned: e.pinned})
+	}
+	return encodeSnapshot(entries)
+}
+
+// Restore replaces the keys of the cache with a snapshot, evicting the
+// least recently used ones beyond the capacity.
+func (l *lru[T]) Restore(data []byte) error {
+	entries, err := decodeSnapshot[T](data)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cache = make(map[T]*list.Element, len(entries))
+	l.list.Init()
+	l.pinned.Init()
+	for _, e := range entries {
+		if elem, ok := l.cache[e.Key]; ok {
+			l.touch(elem)
+			continue
+		}
+		l.seq++
+		restored := &entry[T]{key: e.Key, seq: l.seq, pinned: e.Pinned}
+		if e.Pinned {
+			l.cache[e.Key] = l.pinned.PushBack(restored)
+		} else {
+			l.cache[e.Key] = l.list.PushFront(restored)
+		}
+	}
+	for len(l.cache) > l.capacity {
+		if _, ok := l.evict(); !ok {
+			break
+		}
+	}
+	return nil
+}
+
 // Reset clears all keys from the cache.
 func (l *lru[T]) Reset() {
 	l.mu.Lock()
@@ -64,6 +207,7 @@ func (l *lru[T]) Reset() {
 
 	l.cache = make(map[T]*list.Element)
 	l.list.Init()
+	l.pinned.Init()
 }
 
 // Size returns the current number of keys in the cache.
@@ -74,6 +218,14 @@ func (l *lru[T]) Size() int {
 	return len(l.cache)
 }
 
+// Capacity returns the current capacity of the cache.
+func (l *lru[T]) Capacity() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.capacity
+}
+
 // Evict removes the least recently used key from the cache.
 func (l *lru[T]) Evict() (T, bool) {
 	l.mu.Lock()
@@ -81,9 +233,38 @@ func (l *lru[T]) Evict() (T, bool) {
 	return l.evict()
 }
 
-// evict is an internal method that removes the least recently used key from the cache.
+// EvictN removes up to n of the least recently used keys from the cache and returns them.
+func (l *lru[T]) EvictN(n int) []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var evictedKeys []T
+	for i := 0; i < n; i++ {
+		key, ok := l.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Peek returns the least recently used key without removing it.
+func (l *lru[T]) Peek() (T, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem := l.victim()
+	if elem == nil {
+		var zero T
+		return zero, false
+	}
+	return elem.Value.(*entry[T]).key, true
+}
+
+// evict is an internal method that removes the least recently used unpinned key from the cache.
 func (l *lru[T]) evict() (T, bool) {
-	elem := l.list.Back()
+	elem := l.victim()
 	if elem == nil {
 		var zero T
 		return zero, false
@@ -91,5 +272,62 @@ func (l *lru[T]) evict() (T, bool) {
 	l.list.Remove(elem)
 	entry := elem.Value.(*entry[T])
 	delete(l.cache, entry.key)
+	l.stats.Evictions++
 	return entry.key, true
 }
+
+// Stats returns the counters collected by the cache.
+func (l *lru[T]) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := l.stats
+	stats.Size = len(l.cache)
+	stats.Capacity = l.capacity
+	return stats
+}
+
+// victim returns the element of the least recently used unpinned key, or nil if there is none.
+func (l *lru[T]) victim() *list.Element {
+	return l.list.Back()
+}
+
+// touch marks the key of elem as the most recently used.
+func (l *lru[T]) touch(elem *list.Element) {
+	l.seq++
+	elem.Value.(*entry[T]).seq = l.seq
+	l.listOf(elem).MoveToFront(elem)
+}
+
+// listOf returns the list holding elem, pinned or not.
+func (l *lru[T]) listOf(elem *list.Element) *list.List {
+	if elem.Value.(*entry[T]).pinned {
+		return l.pinned
+	}
+	return l.list
+}
+
+// insert puts an unpinned entry back in list after the keys used since, and
+// returns its element.
+func (l *lru[T]) insert(e *entry[T]) *list.Element {
+	for elem := l.list.Back(); elem != nil; elem = elem.Prev() {
+		if elem.Value.(*entry[T]).seq > e.seq {
+			return l.list.InsertAfter(e, elem)
+		}
+	}
+	return l.list.PushFront(e)
+}
+
+// sortedEntries returns the entries of lists in the order of their seq.
+func sortedEntries[T comparable](lists ...*list.List) []*entry[T] {
+	var entries []*entry[T]
+	for _, l := range lists {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			entries = append(entries, elem.Value.(*entry[T]))
+		}
+	}
+	slices.SortFunc(entries, func(a, b *entry[T]) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return entries
+}
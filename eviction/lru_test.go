@@ -75,3 +75,141 @@ func TestLRUDeleteNonExistentKey(t *testing.T) {
 	cache.Delete(1)
 	assert.Equal(t, 0, cache.Size())
 }
+
+func TestLRUPeek(t *testing.T) {
+	cache := NewLRU[int](2)
+
+	_, ok := cache.Peek()
+	assert.False(t, ok)
+
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(1)
+	key, ok := cache.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 2, key)
+	assert.Equal(t, 2, cache.Size())
+
+	// Peek reports the key that Put evicts next
+	evictedKey, _ := cache.Put(3)
+	assert.Equal(t, key, evictedKey)
+}
+
+func TestLRUResize(t *testing.T) {
+	cache := NewLRU[int](3)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+
+	// Growing keeps every key
+	assert.Empty(t, cache.Resize(4))
+	assert.Equal(t, 3, cache.Size())
+	_, evicted := cache.Put(4)
+	assert.False(t, evicted)
+
+	// Shrinking evicts down to the new capacity
+	assert.Equal(t, []int{1, 2}, cache.Resize(2))
+	assert.Equal(t, 2, cache.Size())
+	evictedKey, evicted := cache.Put(5)
+	assert.True(t, evicted)
+	assert.Equal(t, 3, evictedKey)
+}
+
+func TestLRUTouch(t *testing.T) {
+	cache := NewLRU[int](2)
+
+	assert.False(t, cache.Touch(1))
+	assert.Equal(t, 0, cache.Size())
+
+	cache.Put(1)
+	cache.Put(2)
+	assert.True(t, cache.Touch(1))
+
+	evictedKey, evicted := cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 2, evictedKey)
+}
+
+func TestLRUEvictN(t *testing.T) {
+	cache := NewLRU[int](5)
+	for i := 1; i <= 4; i++ {
+		cache.Put(i)
+	}
+
+	assert.Equal(t, []int{1, 2}, cache.EvictN(2))
+	assert.Equal(t, 2, cache.Size())
+
+	// Asking for more keys than present evicts everything
+	assert.Equal(t, []int{3, 4}, cache.EvictN(10))
+	assert.Equal(t, 0, cache.Size())
+	assert.Empty(t, cache.EvictN(1))
+}
+
+func TestLRUPin(t *testing.T) {
+	cache := NewLRU[int](2)
+
+	assert.False(t, cache.Pin(1))
+
+	cache.Put(1)
+	cache.Put(2)
+	assert.True(t, cache.Pin(1))
+
+	// The pinned key is skipped by eviction
+	key, ok := cache.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 2, key)
+	evictedKey, evicted := cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 2, evictedKey)
+
+	// With every key pinned nothing can be evicted
+	assert.True(t, cache.Pin(3))
+	_, ok = cache.Evict()
+	assert.False(t, ok)
+	_, evicted = cache.Put(4)
+	assert.False(t, evicted)
+	assert.Equal(t, 3, cache.Size())
+
+	assert.True(t, cache.Unpin(1))
+	assert.Equal(t, []int{1, 4}, cache.Resize(1))
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestLRUUnpinOrder(t *testing.T) {
+	cache := NewLRU[int](3)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+	cache.Touch(1)
+
+	// A pinned key keeps being used, and takes its place back when unpinned
+	assert.True(t, cache.Pin(2))
+	assert.True(t, cache.Pin(2))
+	assert.True(t, cache.Touch(2))
+	assert.True(t, cache.Unpin(3))
+	assert.True(t, cache.Unpin(2))
+	assert.Equal(t, []int{3, 1, 2}, cache.EvictN(3))
+}
+
+func TestLRUStats(t *testing.T) {
+	cache := NewLRU[int](2)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+	cache.Touch(3)
+	cache.Touch(1)
+	cache.Evict()
+
+	assert.Equal(t, Stats{Puts: 3, Hits: 1, Misses: 1, Evictions: 2, Size: 1, Capacity: 2}, cache.Stats())
+	assert.Equal(t, 0.5, cache.Stats().HitRatio())
+}
+
+func TestLRUPutAll(t *testing.T) {
+	cache := NewLRU[int](3)
+	cache.Put(1)
+
+	assert.Equal(t, []int{1, 2}, cache.PutAll([]int{2, 3, 4, 5}))
+	assert.Equal(t, 3, cache.Size())
+	assert.Equal(t, uint64(5), cache.Stats().Puts)
+	assert.Empty(t, cache.PutAll(nil))
+}
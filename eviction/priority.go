@@ -0,0 +1,303 @@
+package eviction
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+)
+
+// PriorityPolicy is a Policy whose keys carry a priority level. Keys of a
+// lower level are always evicted before keys of a higher one.
+type PriorityPolicy[T comparable] interface {
+	Policy[T]
+
+	// PutWithPriority adds a key with the given priority level, or moves an
+	// existing key to that level. It returns the evicted key if any.
+	PutWithPriority(key T, level int) (T, bool)
+}
+
+// priority implements the PriorityPolicy, evicting the least recently used key
+// of the lowest level first.
+type priority[T comparable] struct {
+	mu       sync.Mutex
+	capacity int
+	stats    Stats
+	cache    map[T]*list.Element
+	lists    map[int]*list.List
+	// levels holds the levels that have keys, in ascending order
+	levels []int
+}
+
+type priorityEntry[T comparable] struct {
+	key    T
+	level  int
+	pinned bool
+}
+
+// NewPriority creates a new priority cache with the given capacity. Keys added
+// by Put get level 0.
+func NewPriority[T comparable](capacity int) PriorityPolicy[T] {
+	return &priority[T]{
+		capacity: capacity,
+		cache:    make(map[T]*list.Element),
+		lists:    make(map[int]*list.List),
+	}
+}
+
+// Put adds a key to the cache, keeping the level of an existing key.
+func (p *priority[T]) Put(key T) (T, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.put(key)
+}
+
+// PutAll adds keys to the cache under a single lock acquisition and returns
+// the keys evicted to make room for them.
+func (p *priority[T]) PutAll(keys []T) []T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var evictedKeys []T
+	for _, key := range keys {
+		if evictedKey, evicted := p.put(key); evicted {
+			evictedKeys = append(evictedKeys, evictedKey)
+		}
+	}
+	return evictedKeys
+}
+
+// put is an internal method that adds a key to the cache.
+func (p *priority[T]) put(key T) (T, bool) {
+	if elem, ok := p.cache[key]; ok {
+		p.lists[elem.Value.(*priorityEntry[T]).level].MoveToFront(elem)
+		var zero T
+		return zero, false
+	}
+	return p.insert(key, 0)
+}
+
+// PutWithPriority adds a key with the given priority level. If the cache is
+// full, it evicts the least recently used key of the lowest level.
+func (p *priority[T]) PutWithPriority(key T, level int) (T, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, ok := p.cache[key]; ok {
+		entry := elem.Value.(*priorityEntry[T])
+		p.unlink(elem)
+		entry.level = level
+		p.cache[key] = p.levelList(level).PushFront(entry)
+		var zero T
+		return zero, false
+	}
+	return p.insert(key, level)
+}
+
+// Touch marks the key as most recently used within its level without inserting it.
+func (p *priority[T]) Touch(key T) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem, ok := p.cache[key]
+	if ok {
+		p.lists[elem.Value.(*priorityEntry[T]).level].MoveToFront(elem)
+		p.stats.Hits++
+	} else {
+		p.stats.Misses++
+	}
+	return ok
+}
+
+// Delete removes a key from the cache.
+func (p *priority[T]) Delete(key T) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, ok := p.cache[key]; ok {
+		p.unlink(elem)
+		delete(p.cache, key)
+	}
+}
+
+// Evict removes the least recently used key of the lowest level from the cache.
+func (p *priority[T]) Evict() (T, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.evict()
+}
+
+// EvictN removes up to n keys from the cache, lowest levels first, and returns them.
+func (p *priority[T]) EvictN(n int) []T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var evictedKeys []T
+	for i := 0; i < n; i++ {
+		key, ok := p.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Peek returns the key that would be evicted next without removing it.
+func (p *priority[T]) Peek() (T, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem := p.victim()
+	if elem == nil {
+		var zero T
+		return zero, false
+	}
+	return elem.Value.(*priorityEntry[T]).key, true
+}
+
+// Resize changes the capacity of the cache. When shrinking, it evicts keys,
+// lowest levels first, until the cache fits the new capacity and returns them.
+func (p *priority[T]) Resize(capacity int) []T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.capacity = capacity
+	var evictedKeys []T
+	for len(p.cache) > capacity {
+		key, ok := p.evict()
+		if !ok {
+			break
+		}
+		evictedKeys = append(evictedKeys, key)
+	}
+	return evictedKeys
+}
+
+// Pin excludes the key from eviction until it is unpinned. If every key is
+// pinned, the cache grows beyond its capacity.
+func (p *priority[T]) Pin(key T) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem, ok := p.cache[key]
+	if ok {
+		elem.Value.(*priorityEntry[T]).pinned = true
+	}
+	return ok
+}
+
+// Unpin makes a pinned key evictable again.
+func (p *priority[T]) Unpin(key T) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem, ok := p.cache[key]
+	if ok {
+		elem.Value.(*priorityEntry[T]).pinned = false
+	}
+	return ok
+}
+
+// Reset clears all keys from the cache.
+func (p *priority[T]) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cache = make(map[T]*list.Element)
+	p.lists = make(map[int]*list.List)
+	p.levels = nil
+}
+
+// Size returns the current number of keys in the cache.
+func (p *priority[T]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.cache)
+}
+
+// Capacity returns the current capacity of the cache.
+func (p *priority[T]) Capacity() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.capacity
+}
+
+// Stats returns the counters collected by the cache.
+func (p *priority[T]) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Size = len(p.cache)
+	stats.Capacity = p.capacity
+	return stats
+}
+
+// insert adds a new key at the given level, evicting a key first if the cache is full.
+func (p *priority[T]) insert(key T, level int) (T, bool) {
+	var evictedKey T
+	var evicted bool
+
+	if len(p.cache) >= p.capacity {
+		evictedKey, evicted = p.evict()
+	}
+	p.cache[key] = p.levelList(level).PushFront(&priorityEntry[T]{key: key, level: level})
+	p.stats.Puts++
+	return evictedKey, evicted
+}
+
+// evict is an internal method that removes the least recently used unpinned key
+// of the lowest level from the cache.
+func (p *priority[T]) evict() (T, bool) {
+	elem := p.victim()
+	if elem == nil {
+		var zero T
+		return zero, false
+	}
+	entry := elem.Value.(*priorityEntry[T])
+	p.unlink(elem)
+	delete(p.cache, entry.key)
+	p.stats.Evictions++
+	return entry.key, true
+}
+
+// victim returns the element of the least recently used unpinned key of the
+// lowest level, or nil if there is none.
+func (p *priority[T]) victim() *list.Element {
+	for _, level := range p.levels {
+		for elem := p.lists[level].Back(); elem != nil; elem = elem.Prev() {
+			if !elem.Value.(*priorityEntry[T]).pinned {
+				return elem
+			}
+		}
+	}
+	return nil
+}
+
+// levelList returns the list of the given level, creating it if needed.
+func (p *priority[T]) levelList(level int) *list.List {
+	l, ok := p.lists[level]
+	if !ok {
+		l = list.New()
+		p.lists[level] = l
+		i := sort.SearchInts(p.levels, level)
+		p.levels = append(p.levels, 0)
+		copy(p.levels[i+1:], p.levels[i:])
+		p.levels[i] = level
+	}
+	return l
+}
+
+// unlink removes an element from the list of its level, dropping the level once empty.
+func (p *priority[T]) unlink(elem *list.Element) {
+	level := elem.Value.(*priorityEntry[T]).level
+	l := p.lists[level]
+	l.Remove(elem)
+	if l.Len() == 0 {
+		delete(p.lists, level)
+		i := sort.SearchInts(p.levels, level)
+		p.levels = append(p.levels[:i], p.levels[i+1:]...)
+	}
+}
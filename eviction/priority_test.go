@@ -0,0 +1,86 @@
+package eviction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriority(t *testing.T) {
+	cache := NewPriority[string](3)
+
+	cache.PutWithPriority("expensive", 10)
+	cache.PutWithPriority("cheap1", 1)
+	cache.PutWithPriority("cheap2", 1)
+	assert.Equal(t, 3, cache.Size())
+
+	// Lower levels are evicted first, least recently used first within a level
+	cache.Touch("cheap1")
+	evictedKey, evicted := cache.Put("default")
+	assert.True(t, evicted)
+	assert.Equal(t, "cheap2", evictedKey)
+
+	// Keys added by Put get level 0
+	evictedKey, evicted = cache.PutWithPriority("cheap3", 1)
+	assert.True(t, evicted)
+	assert.Equal(t, "default", evictedKey)
+
+	// Changing the level of an existing key moves it
+	_, evicted = cache.PutWithPriority("expensive", 0)
+	assert.False(t, evicted)
+	key, ok := cache.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, "expensive", key)
+
+	// Put keeps the level of an existing key
+	cache.Put("expensive")
+	key, _ = cache.Peek()
+	assert.Equal(t, "expensive", key)
+}
+
+func TestPriorityEvictNAndResize(t *testing.T) {
+	cache := NewPriority[int](5)
+	cache.PutWithPriority(1, 3)
+	cache.PutWithPriority(2, 1)
+	cache.PutWithPriority(3, 2)
+	cache.PutWithPriority(4, 1)
+
+	assert.Equal(t, []int{2, 4}, cache.EvictN(2))
+	assert.Equal(t, []int{3}, cache.Resize(1))
+	assert.Equal(t, 1, cache.Size())
+
+	cache.Delete(1)
+	assert.Equal(t, 0, cache.Size())
+	assert.Empty(t, cache.EvictN(1))
+}
+
+func TestPriorityPin(t *testing.T) {
+	cache := NewPriority[int](2)
+	cache.PutWithPriority(1, 0)
+	cache.PutWithPriority(2, 1)
+	assert.True(t, cache.Pin(1))
+	assert.False(t, cache.Pin(3))
+
+	evictedKey, evicted := cache.Put(3)
+	assert.True(t, evicted)
+	assert.Equal(t, 2, evictedKey)
+
+	assert.True(t, cache.Unpin(1))
+	evictedKey, _ = cache.Put(4)
+	assert.Equal(t, 1, evictedKey)
+}
+
+func TestPriorityStats(t *testing.T) {
+	cache := NewPriority[int](2)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+	cache.Touch(3)
+	cache.Touch(1)
+	cache.Evict()
+
+	assert.Equal(t, Stats{Puts: 3, Hits: 1, Misses: 1, Evictions: 2, Size: 1, Capacity: 2}, cache.Stats())
+
+	cache.Reset()
+	assert.Equal(t, 0, cache.Size())
+}
@@ -0,0 +1,105 @@
+// Package simulator replays access traces against eviction policies to
+// compare how they would perform on a given workload.
+package simulator
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+// Result holds the outcome of replaying a trace against a policy.
+type Result struct {
+	Accesses   uint64 // Number of keys replayed.
+	Hits       uint64 // Number of accesses that found their key in the cache.
+	Misses     uint64 // Number of accesses that did not find their key.
+	Inserts    uint64 // Number of missed keys stored in the cache.
+	Rejections uint64 // Number of missed keys refused by an admission policy.
+	Evictions  uint64 // Number of keys evicted to make room for inserted ones.
+}
+
+// HitRatio returns the fraction of accesses that found their key, or 0 if
+// the trace was empty.
+func (r Result) HitRatio() float64 {
+	if r.Accesses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Accesses)
+}
+
+// MemoryOps returns the number of writes the cache performed, counting both
+// insertions and evictions.
+func (r Result) MemoryOps() uint64 {
+	return r.Inserts + r.Evictions
+}
+
+// Simulator replays accesses against a policy, inserting every missed key as
+// a cache would on a read-through miss.
+type Simulator[T comparable] struct {
+	policy eviction.Policy[T]
+	result Result
+}
+
+// New creates a new Simulator replaying accesses against policy.
+func New[T comparable](policy eviction.Policy[T]) *Simulator[T] {
+	return &Simulator[T]{policy: policy}
+}
+
+// Access replays a single access to key and reports whether it was a hit.
+func (s *Simulator[T]) Access(key T) bool {
+	s.result.Accesses++
+	if s.policy.Touch(key) {
+		s.result.Hits++
+		return true
+	}
+	s.result.Misses++
+	evictedKey, evicted := s.policy.Put(key)
+	switch {
+	case evicted && evictedKey == key:
+		s.result.Rejections++
+	case evicted:
+		s.result.Inserts++
+		s.result.Evictions++
+	default:
+		s.result.Inserts++
+	}
+	return false
+}
+
+// Result returns the counters collected so far.
+func (s *Simulator[T]) Result() Result {
+	return s.result
+}
+
+// Run replays keys against policy and returns the result.
+func Run[T comparable](policy eviction.Policy[T], keys []T) Result {
+	s := New(policy)
+	for _, key := range keys {
+		s.Access(key)
+	}
+	return s.Result()
+}
+
+// RunReader replays a trace of one key per line read from r against policy.
+// Empty lines are skipped.
+func RunReader(policy eviction.Policy[string], r io.Reader) (Result, error) {
+	s := New(policy)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if key := scanner.Text(); key != "" {
+			s.Access(key)
+		}
+	}
+	return s.Result(), scanner.Err()
+}
+
+// Compare replays the same keys against each of the named policies and returns
+// their results by name.
+func Compare[T comparable](policies map[string]eviction.Policy[T], keys []T) map[string]Result {
+	results := make(map[string]Result, len(policies))
+	for name, policy := range policies {
+		results[name] = Run(policy, keys)
+	}
+	return results
+}
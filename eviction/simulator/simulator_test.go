@@ -0,0 +1,53 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/liuxinbot/cache/eviction"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	result := Run(eviction.NewLRU[int](2), []int{1, 2, 1, 3, 2, 1})
+
+	// 1 and 2 miss, 1 hits, 3 evicts 2, 2 evicts 1, 1 evicts 3
+	assert.Equal(t, Result{Accesses: 6, Hits: 1, Misses: 5, Inserts: 5, Evictions: 3}, result)
+	assert.InDelta(t, 1.0/6, result.HitRatio(), 1e-9)
+	assert.Equal(t, uint64(8), result.MemoryOps())
+	assert.Zero(t, Result{}.HitRatio())
+}
+
+func TestRunReader(t *testing.T) {
+	trace := "a\nb\n\na\nc\na\n"
+	result, err := RunReader(eviction.NewFIFO[string](2), strings.NewReader(trace))
+	assert.NoError(t, err)
+
+	// c evicts a under FIFO, so the last access misses
+	assert.Equal(t, Result{Accesses: 5, Hits: 1, Misses: 4, Inserts: 4, Evictions: 2}, result)
+}
+
+func TestRunRejections(t *testing.T) {
+	policy := eviction.NewComposite(eviction.NewTinyLFU[int](1024), eviction.NewLRU[int](1))
+	keys := []int{1, 1, 1, 2}
+
+	result := Run(policy, keys)
+	assert.Equal(t, uint64(1), result.Rejections)
+	assert.Equal(t, uint64(1), result.Inserts)
+	assert.Zero(t, result.Evictions)
+}
+
+func TestCompare(t *testing.T) {
+	// A scan over many keys between accesses to a hot set
+	var keys []int
+	for i := 0; i < 100; i++ {
+		keys = append(keys, i%4, 100+i)
+	}
+
+	results := Compare(map[string]eviction.Policy[int]{
+		"fifo": eviction.NewFIFO[int](4),
+		"lfu":  eviction.NewLFU[int](4),
+	}, keys)
+	assert.Len(t, results, 2)
+	assert.Greater(t, results["lfu"].HitRatio(), results["fifo"].HitRatio())
+}
@@ -0,0 +1,106 @@
+package eviction
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+const (
+	// sketchDepth is the number of counter rows, each indexed by a different hash.
+	sketchDepth = 4
+	// sketchMaxCount is the value at which counters saturate.
+	sketchMaxCount = 15
+)
+
+// FrequencySketch is a count-min sketch estimating how often keys were seen.
+//
+// It keeps a few small saturating counters per row instead of one counter per
+// key, so estimates may overcount but never undercount. After a number of
+// increments proportional to its width, the sketch halves every counter so that
+// old popularity fades, as done by the TinyLFU admission policy.
+type FrequencySketch[T comparable] struct {
+	mu         sync.Mutex
+	seed       maphash.Seed
+	counters   [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+// NewFrequencySketch creates a new FrequencySketch sized to track about capacity keys.
+func NewFrequencySketch[T comparable](capacity int) *FrequencySketch[T] {
+	width := 1
+	for width < capacity {
+		width <<= 1
+	}
+	s := &FrequencySketch[T]{
+		seed:       maphash.MakeSeed(),
+		mask:       uint64(width - 1),
+		sampleSize: 10 * width,
+	}
+	for i := range s.counters {
+		s.counters[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// Increment records an occurrence of the key. It halves all counters once
+// enough occurrences have been recorded since the last reset.
+func (s *FrequencySketch[T]) Increment(key T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := false
+	h1, h2 := s.hash(key)
+	for i := range s.counters {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.counters[i][idx] < sketchMaxCount {
+			s.counters[i][idx]++
+			added = true
+		}
+	}
+	if added {
+		s.additions++
+		if s.additions >= s.sampleSize {
+			s.reset()
+		}
+	}
+}
+
+// Estimate returns the estimated number of occurrences of the key.
+func (s *FrequencySketch[T]) Estimate(key T) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	estimate := uint8(sketchMaxCount)
+	h1, h2 := s.hash(key)
+	for i := range s.counters {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		estimate = min(estimate, s.counters[i][idx])
+	}
+	return int(estimate)
+}
+
+// Reset halves all counters so that past occurrences weigh less than new ones.
+func (s *FrequencySketch[T]) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+}
+
+// reset is an internal method that halves all counters.
+func (s *FrequencySketch[T]) reset() {
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			s.counters[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// hash derives the two hashes combined to index every row.
+func (s *FrequencySketch[T]) hash(key T) (uint64, uint64) {
+	h := maphash.Comparable(s.seed, key)
+	// An odd second hash visits distinct slots in every row.
+	return h, (h>>32 | h<<32) | 1
+}
@@ -0,0 +1,46 @@
+package eviction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrequencySketch(t *testing.T) {
+	sketch := NewFrequencySketch[string](64)
+
+	assert.Equal(t, 0, sketch.Estimate("a"))
+
+	for i := 0; i < 5; i++ {
+		sketch.Increment("a")
+	}
+	sketch.Increment("b")
+	assert.GreaterOrEqual(t, sketch.Estimate("a"), 5)
+	assert.GreaterOrEqual(t, sketch.Estimate("b"), 1)
+	assert.Less(t, sketch.Estimate("b"), sketch.Estimate("a"))
+
+	// Counters saturate
+	for i := 0; i < 100; i++ {
+		sketch.Increment("a")
+	}
+	assert.Equal(t, 15, sketch.Estimate("a"))
+
+	// Reset halves the counters
+	sketch.Reset()
+	assert.Equal(t, 7, sketch.Estimate("a"))
+}
+
+func TestFrequencySketchAging(t *testing.T) {
+	sketch := NewFrequencySketch[int](16)
+
+	for i := 0; i < 8; i++ {
+		sketch.Increment(-1)
+	}
+	assert.GreaterOrEqual(t, sketch.Estimate(-1), 8)
+
+	// Enough increments of other keys halve the old counters
+	for i := 0; i < 100*16 && sketch.Estimate(-1) >= 8; i++ {
+		sketch.Increment(i)
+	}
+	assert.Less(t, sketch.Estimate(-1), 8)
+}
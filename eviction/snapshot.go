@@ -0,0 +1,36 @@
+package eviction
+
+import "encoding/json"
+
+// Snapshotter is implemented by policies whose state can be saved and later
+// restored, for instance across process restarts.
+type Snapshotter interface {
+	// Snapshot serializes the keys and their eviction order. Keys must be
+	// encodable as JSON.
+	Snapshot() ([]byte, error)
+
+	// Restore replaces the state of the policy with a snapshot. Keys beyond
+	// the current capacity are evicted in policy order.
+	Restore(data []byte) error
+}
+
+// snapshotEntry is the serialized state of a key, in eviction order.
+type snapshotEntry[T comparable] struct {
+	Key       T    `json:"key"`
+	Frequency int  `json:"frequency,omitempty"`
+	Pinned    bool `json:"pinned,omitempty"`
+}
+
+// encodeSnapshot serializes the entries of a snapshot.
+func encodeSnapshot[T comparable](entries []snapshotEntry[T]) ([]byte, error) {
+	return json.Marshal(entries)
+}
+
+// decodeSnapshot deserializes the entries of a snapshot.
+func decodeSnapshot[T comparable](data []byte) ([]snapshotEntry[T], error) {
+	var entries []snapshotEntry[T]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
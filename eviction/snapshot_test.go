@@ -0,0 +1,70 @@
+package eviction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFIFOSnapshot(t *testing.T) {
+	cache := NewFIFO[int](3)
+	cache.Put(1)
+	cache.Put(2)
+	cache.Put(3)
+	cache.Pin(1)
+
+	data, err := cache.(Snapshotter).Snapshot()
+	assert.NoError(t, err)
+
+	restored := NewFIFO[int](3)
+	restored.Put(9)
+	assert.NoError(t, restored.(Snapshotter).Restore(data))
+	assert.Equal(t, 3, restored.Size())
+	assert.False(t, restored.Touch(9))
+
+	// The pinned oldest key survives, the next oldest is evicted
+	assert.Equal(t, []int{2, 3}, restored.EvictN(3))
+}
+
+func TestLRUSnapshot(t *testing.T) {
+	cache := NewLRU[string](3)
+	cache.Put("a")
+	cache.Put("b")
+	cache.Put("c")
+	cache.Touch("a")
+
+	data, err := cache.(Snapshotter).Snapshot()
+	assert.NoError(t, err)
+
+	// Restoring into a smaller cache drops the least recently used keys
+	restored := NewLRU[string](2)
+	assert.NoError(t, restored.(Snapshotter).Restore(data))
+	assert.Equal(t, 2, restored.Size())
+	assert.Equal(t, []string{"c", "a"}, restored.EvictN(2))
+}
+
+func TestLFUSnapshot(t *testing.T) {
+	cache := NewLFU[int](3)
+	for i := 1; i <= 3; i++ {
+		for j := 0; j < i; j++ {
+			cache.Put(i)
+		}
+	}
+
+	data, err := cache.(Snapshotter).Snapshot()
+	assert.NoError(t, err)
+
+	restored := NewLFU[int](3)
+	assert.NoError(t, restored.(Snapshotter).Restore(data))
+	evictedKey, evicted := restored.Put(4)
+	assert.True(t, evicted)
+	assert.Equal(t, 1, evictedKey)
+	assert.Equal(t, []int{4, 2, 3}, restored.EvictN(3))
+}
+
+func TestSnapshotRestoreInvalid(t *testing.T) {
+	cache := NewLRU[int](2)
+	cache.Put(1)
+	assert.Error(t, cache.(Snapshotter).Restore([]byte("not json")))
+	assert.Equal(t, 1, cache.Size())
+}
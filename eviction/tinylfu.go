@@ -0,0 +1,25 @@
+package eviction
+
+// tinyLFU implements the TinyLFU admission policy.
+type tinyLFU[T comparable] struct {
+	sketch *FrequencySketch[T]
+}
+
+// NewTinyLFU creates a new TinyLFU admission policy for a cache of the given
+// capacity. It admits a candidate only if it was seen more often recently than
+// the key it would replace, which keeps one-hit wonders out of the cache.
+func NewTinyLFU[T comparable](capacity int) AdmissionPolicy[T] {
+	return &tinyLFU[T]{
+		sketch: NewFrequencySketch[T](capacity),
+	}
+}
+
+// Record records an access to the key in the frequency sketch.
+func (t *tinyLFU[T]) Record(key T) {
+	t.sketch.Increment(key)
+}
+
+// Admit reports whether candidate was seen more often than victim.
+func (t *tinyLFU[T]) Admit(candidate, victim T) bool {
+	return t.sketch.Estimate(candidate) > t.sketch.Estimate(victim)
+}
@@ -0,0 +1,140 @@
+package eviction
+
+import "sync"
+
+// unbounded implements a policy that never evicts any key.
+type unbounded[T comparable] struct {
+	mu    sync.Mutex
+	stats Stats
+	keys  map[T]struct{}
+}
+
+// NewUnbounded creates a new cache without a size limit. Put, Evict, EvictN
+// and Resize never evict anything, so the cache holds every key until it is
+// deleted.
+func NewUnbounded[T comparable]() Policy[T] {
+	return &unbounded[T]{
+		keys: make(map[T]struct{}),
+	}
+}
+
+// Put adds a key to the cache.
+func (u *unbounded[T]) Put(key T) (T, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.put(key)
+}
+
+// PutAll adds keys to the cache under a single lock acquisition and returns
+// the keys evicted to make room for them.
+func (u *unbounded[T]) PutAll(keys []T) []T {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var evictedKeys []T
+	for _, key := range keys {
+		if evictedKey, evicted := u.put(key); evicted {
+			evictedKeys = append(evictedKeys, evictedKey)
+		}
+	}
+	return evictedKeys
+}
+
+// put is an internal method that adds a key to the cache.
+func (u *unbounded[T]) put(key T) (T, bool) {
+	if _, ok := u.keys[key]; !ok {
+		u.keys[key] = struct{}{}
+		u.stats.Puts++
+	}
+	var zero T
+	return zero, false
+}
+
+// Touch reports whether the key is in the cache.
+func (u *unbounded[T]) Touch(key T) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, ok := u.keys[key]
+	if ok {
+		u.stats.Hits++
+	} else {
+		u.stats.Misses++
+	}
+	return ok
+}
+
+// Delete removes a key from the cache.
+func (u *unbounded[T]) Delete(key T) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.keys, key)
+}
+
+// Evict never evicts anything.
+func (u *unbounded[T]) Evict() (T, bool) {
+	var zero T
+	return zero, false
+}
+
+// EvictN never evicts anything.
+func (u *unbounded[T]) EvictN(n int) []T {
+	return nil
+}
+
+// Peek never reports a key since none would be evicted.
+func (u *unbounded[T]) Peek() (T, bool) {
+	var zero T
+	return zero, false
+}
+
+// Resize is a no-op since the cache has no capacity.
+func (u *unbounded[T]) Resize(capacity int) []T {
+	return nil
+}
+
+// Pin reports whether the key is in the cache. Keys are never evicted anyway.
+func (u *unbounded[T]) Pin(key T) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, ok := u.keys[key]
+	return ok
+}
+
+// Unpin reports whether the key is in the cache.
+func (u *unbounded[T]) Unpin(key T) bool {
+	return u.Pin(key)
+}
+
+// Reset clears all keys from the cache.
+func (u *unbounded[T]) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.keys = make(map[T]struct{})
+}
+
+// Size returns the current number of keys in the cache.
+func (u *unbounded[T]) Size() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return len(u.keys)
+}
+
+// Capacity returns 0, since the cache has no capacity.
+func (u *unbounded[T]) Capacity() int {
+	return 0
+}
+
+// Stats returns the counters collected by the cache. Capacity is always 0.
+func (u *unbounded[T]) Stats() Stats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	stats := u.stats
+	stats.Size = len(u.keys)
+	return stats
+}
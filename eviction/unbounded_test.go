@@ -0,0 +1,36 @@
+package eviction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnbounded(t *testing.T) {
+	cache := NewUnbounded[int]()
+
+	for i := 0; i < 1000; i++ {
+		_, evicted := cache.Put(i)
+		assert.False(t, evicted)
+	}
+	assert.Equal(t, 1000, cache.Size())
+
+	_, ok := cache.Evict()
+	assert.False(t, ok)
+	_, ok = cache.Peek()
+	assert.False(t, ok)
+	assert.Empty(t, cache.EvictN(10))
+	assert.Empty(t, cache.Resize(1))
+	assert.Equal(t, 1000, cache.Size())
+
+	assert.True(t, cache.Touch(1))
+	assert.True(t, cache.Pin(1))
+	cache.Delete(1)
+	assert.False(t, cache.Touch(1))
+	assert.False(t, cache.Unpin(1))
+
+	assert.Equal(t, Stats{Puts: 1000, Hits: 1, Misses: 1, Size: 999}, cache.Stats())
+
+	cache.Reset()
+	assert.Equal(t, 0, cache.Size())
+}
@@ -1,8 +1,14 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
+	"iter"
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/liuxinbot/cache/eviction"
 )
@@ -12,23 +18,258 @@ type EvictionStore[K, T comparable] interface {
 	IndexedStore[K, T]
 
 	Evict() error
+
+	// EvictOne removes an object based on the eviction policy and returns its
+	// key and the object itself. It returns false if nothing can be evicted.
+	EvictOne() (T, interface{}, bool)
+
+	// EvictN removes up to n objects based on the eviction policy in a single
+	// pass and returns their keys.
+	EvictN(n int) []T
+
+	// Pin excludes the object stored under key from eviction until it is
+	// unpinned. It returns false if the key is not in the store.
+	Pin(key T) bool
+
+	// Unpin makes a pinned object evictable again. It returns false if the
+	// key is not in the store.
+	Unpin(key T) bool
+
+	// Stats returns the usage counters of the store, whose hits, misses and
+	// size are those of the eviction policy.
+	Stats() Stats
+
+	// Resize changes the capacity of the eviction policy and removes the
+	// objects it evicts to fit the new capacity, returning their keys.
+	Resize(capacity int) []T
+
+	// Capacity returns the capacity of the eviction policy, so that the store
+	// satisfies eviction.Resizable, such as to be resized by a
+	// MemoryGovernor.
+	Capacity() int
+
+	// Peek returns the object stored under key without recording an access in
+	// the eviction policy, so it neither refreshes the object nor counts a hit.
+	Peek(key T) (interface{}, bool)
+
+	// Contains reports whether an object is stored under key. Like Peek, it
+	// does not record an access in the eviction policy.
+	Contains(key T) bool
+
+	// AddWithTTL adds an object that expires once ttl has elapsed, in addition
+	// to being subject to the eviction policy. Expired objects are no longer
+	// returned, listed or counted by any read, such as Get, List, Size or the
+	// index queries, nor reported by Contains, and are removed by
+	// DeleteExpired. An object with a ttl of zero or less never expires.
+	AddWithTTL(obj interface{}, ttl time.Duration) error
+
+	// DeleteExpired removes the expired objects and returns their keys.
+	DeleteExpired() []T
+
+	// GetWithExpiration returns the object stored under key like GetByKey,
+	// along with when it expires, the zero time if it never does, so that
+	// callers can tell how long it stays fresh. Unlike GetByKey, it does not
+	// look into the victim cache.
+	GetWithExpiration(key T) (obj interface{}, expiresAt time.Time, ok bool)
+
+	// GetOrAdd returns the object stored under the key of obj if there is
+	// one, and otherwise adds obj, atomically. It reports whether the object
+	// was already stored.
+	GetOrAdd(obj interface{}) (actual interface{}, loaded bool, err error)
+
+	// ReplaceOrdered replaces all objects like Replace, but feeds them to the
+	// eviction policy in list order, the last object being the most recently
+	// used, so that a resync does not shuffle the eviction order.
+	ReplaceOrdered(list []interface{}) error
+
+	// AddObserver registers an observer notified of every object added,
+	// updated, deleted or evicted from then on, including the evictions made
+	// by the policy.
+	AddObserver(o Observer[T])
+
+	// EvictionCounts returns the number of objects removed for each reason
+	// since the cache was created, or nil unless it was created
+	// WithEvictionCounts.
+	EvictionCounts() map[EvictionReason]uint64
+
+	// Close stops the background janitor started WithJanitor, if any.
+	Close()
+
+	// Warm bulk-loads objects, for instance restored from a snapshot, and feeds
+	// them to the eviction policy in the recency order given by order, so that
+	// the policy evicts them as if they had been used in that order. Unlike
+	// Replace it keeps the objects already stored, and it bypasses admission.
+	Warm(items []interface{}, order WarmOrder) error
+}
+
+// WarmOrder describes how the objects passed to Warm are ordered by recency.
+type WarmOrder int
+
+const (
+	// WarmOrderMostRecentLast means the last object is the most recently used.
+	WarmOrderMostRecentLast WarmOrder = iota
+	// WarmOrderMostRecentFirst means the first object is the most recently used.
+	WarmOrderMostRecentFirst
+)
+
+// EvictionReason describes why an object was removed from an EvictionStore.
+type EvictionReason int
+
+const (
+	// EvictionReasonCapacity means the object was evicted to make room for others.
+	EvictionReasonCapacity EvictionReason = iota
+	// EvictionReasonManual means the object was evicted by Evict or EvictN.
+	EvictionReasonManual
+	// EvictionReasonReplaced means the object was dropped by Replace.
+	EvictionReasonReplaced
+	// EvictionReasonRejected means the admission function or the policy
+	// refused to admit the object.
+	EvictionReasonRejected
+	// EvictionReasonExpired means the time to live of the object elapsed.
+	EvictionReasonExpired
+	// EvictionReasonDeleted means the object was removed by Delete.
+	EvictionReasonDeleted
+)
+
+// String returns a human-readable name of the EvictionReason.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionReasonCapacity:
+		return "Capacity"
+	case EvictionReasonManual:
+		return "Manual"
+	case EvictionReasonReplaced:
+		return "Replaced"
+	case EvictionReasonRejected:
+		return "Rejected"
+	case EvictionReasonExpired:
+		return "Expired"
+	case EvictionReasonDeleted:
+		return "Deleted"
+	default:
+		return fmt.Sprintf("EvictionReason(%d)", int(r))
+	}
 }
 
+// OnEvictedFunc is called with the key and object removed from an EvictionStore
+// and the reason of the removal.
+type OnEvictedFunc[T comparable] func(key T, obj interface{}, reason EvictionReason)
+
+// accessBufferSize is the number of reads buffered before they are applied to
+// the eviction policy.
+const accessBufferSize = 64
+
 // NewEvictionCache creates a new EvictionStore.
-func NewEvictionCache[K comparable, T comparable](keyFunc KeyFunc[T], evictionPolicy eviction.Policy[T], indexers Indexers[K]) EvictionStore[K, T] {
-	return &evictionCache[K, T]{
-		store:          NewThreadSafeStore(indexers, make(Indexes[K, T])),
+func NewEvictionCache[K comparable, T comparable](keyFunc KeyFunc[T], evictionPolicy eviction.Policy[T], indexers Indexers[K], opts ...EvictionOption[K, T]) EvictionStore[K, T] {
+	opts = append([]EvictionOption[K, T]{WithPolicy[K, T](evictionPolicy), WithIndexers[K, T](indexers)}, opts...)
+	return NewEvictionCacheWithOptions(keyFunc, opts...)
+}
+
+// NewEvictionCacheWithOptions creates a new EvictionStore configured entirely
+// by options. Without WithPolicy the cache never evicts objects.
+func NewEvictionCacheWithOptions[K comparable, T comparable](keyFunc KeyFunc[T], opts ...EvictionOption[K, T]) EvictionStore[K, T] {
+	var o evictionOptions[K, T]
+	for _, opt := range opts {
+		opt(&o)
+	}
+	evictionPolicy := o.policy
+	if evictionPolicy == nil {
+		evictionPolicy = eviction.NewUnbounded[T]()
+	}
+	indexers := o.indexers
+	if indexers == nil {
+		indexers = Indexers[K]{}
+	}
+	c := &evictionCache[K, T]{
+		store:          newThreadSafeMap(indexers, make(Indexes[K, T])),
 		keyFunc:        keyFunc,
 		evictionPolicy: evictionPolicy,
+		ttl:            o.ttl,
+		admit:          o.admit,
+		victim:         o.victim,
+		onEvicted:      o.onEvicted,
+		indexTouch:     !o.noIndexTouch,
+		accesses:       make(chan T, accessBufferSize),
+		clock:          o.clock,
+		expiries:       newExpiryHeap[T](),
 	}
+	if c.clock == nil {
+		c.clock = eviction.RealClock{}
+	}
+	if o.weigher != nil {
+		c.weigher = o.weigher
+		c.maxWeight = o.maxWeight
+		c.weights = make(map[T]int64)
+	}
+	if p, ok := evictionPolicy.(eviction.PriorityPolicy[T]); ok && o.priority != nil {
+		c.priorityPolicy = p
+		c.priority = o.priority
+	}
+	if o.evictionCounts {
+		c.counts = make(map[EvictionReason]uint64)
+	}
+	if o.meta {
+		c.meta = newMetaTracker[T](c.clock)
+	}
+	if o.janitorInterval > 0 {
+		c.stopJanitor = make(chan struct{})
+		go c.runJanitor(o.janitorInterval, c.stopJanitor)
+	}
+	return c
 }
 
 // cache implements IndexedStore and EvictionStore.
 type evictionCache[K comparable, T comparable] struct {
-	store          ThreadSafeStore[K, T]
+	// store is only accessed through its unlocked methods under mu, so that
+	// the store and the eviction policy share a single lock
+	store          *threadSafeMap[K, T, interface{}]
 	keyFunc        KeyFunc[T]
 	evictionPolicy eviction.Policy[T]
-	mu             sync.Mutex
+	mu             sync.RWMutex
+	// accesses buffers the keys read under the read lock until they are
+	// applied to the eviction policy by the next holder of the write lock
+	accesses chan T
+	// onEvicted is notified of evicted objects, may be nil
+	onEvicted OnEvictedFunc[T]
+	// admit decides whether new objects are stored, nil to admit all of them
+	admit func(key T, obj interface{}) bool
+	// victim receives the objects evicted for capacity, nil without a victim cache
+	victim Store[T]
+	// indexTouch records an access to the keys returned by ListKeysByIndex
+	indexTouch bool
+	// priorityPolicy is evictionPolicy when objects are put with the level
+	// computed by priority, nil otherwise
+	priorityPolicy eviction.PriorityPolicy[T]
+	priority       func(obj interface{}) int
+	// weigher computes the weight of objects, nil if only the policy bounds the cache
+	weigher   func(obj interface{}) int64
+	maxWeight int64
+	weights   map[T]int64
+	weight    int64
+	// events holds the changes made while mu is held, reported by unlock
+	events []Event[T]
+	// indexChanges holds the index changes made while mu is held, reported by
+	// unlock to the hooks registered by OnIndexChange
+	indexChanges []queuedIndexChange[K, T]
+	// hooks counts the hooks registered by OnIndexChange, to identify them
+	hooks int
+	// observers are notified of every change, copied on write
+	observers []Observer[T]
+	// counts holds the number of removals by reason, nil unless enabled
+	counts map[EvictionReason]uint64
+	// counters are reported by Stats along with those of the eviction policy
+	counters storeCounters
+	// meta records the history of the objects, nil if it is not tracked
+	meta  *metaTracker[T]
+	clock eviction.Clock
+	// ttl is the time to live of the objects stored by Add and Update
+	ttl time.Duration
+	// expiries holds the expiration time of the objects added with a TTL, the
+	// earliest first
+	expiries *expiryHeap[T]
+	// stopJanitor is closed by Close to stop the janitor, nil without one
+	stopJanitor chan struct{}
+	closeOnce   sync.Once
 }
 
 // Add adds an object to the cache.
@@ -38,18 +279,12 @@ func (c *evictionCache[K, T]) Add(obj interface{}) error {
 		return KeyError{obj, err}
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Call Add on eviction policy
-	evictedKey, evicted := c.evictionPolicy.Put(key)
-	if evicted {
-		// EvictionPolicy.Add returned true, indicating eviction occurred
-		c.store.Delete(evictedKey) // Delete the eliminated key from store
+	c.lock()
+	defer c.unlock()
+	if err := c.put(key, obj, c.ttl); err != nil {
+		return err
 	}
-
-	// Add the new object to store
-	c.store.Add(key, obj)
+	c.counters.adds.Add(1)
 	return nil
 }
 
@@ -59,62 +294,672 @@ func (c *evictionCache[K, T]) Update(obj interface{}) error {
 	if err != nil {
 		return KeyError{obj, err}
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.store.Update(key, obj)
-	c.evictionPolicy.Put(key)
+
+	c.lock()
+	defer c.unlock()
+	if err := c.put(key, obj, c.ttl); err != nil {
+		return err
+	}
+	c.counters.updates.Add(1)
+	return nil
+}
+
+// AddWithTTL adds an object to the cache that expires after ttl.
+func (c *evictionCache[K, T]) AddWithTTL(obj interface{}, ttl time.Duration) error {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+
+	c.lock()
+	defer c.unlock()
+	if err := c.put(key, obj, ttl); err != nil {
+		return err
+	}
+	c.counters.adds.Add(1)
+	return nil
+}
+
+// AddAll adds objects to the cache under a single lock acquisition.
+func (c *evictionCache[K, T]) AddAll(objs []interface{}) []error {
+	return c.putAll(objs, &c.counters.adds)
+}
+
+// UpdateAll updates objects in the cache under a single lock acquisition.
+func (c *evictionCache[K, T]) UpdateAll(objs []interface{}) []error {
+	return c.putAll(objs, &c.counters.updates)
+}
+
+// putAll stores objs under a single lock acquisition, incrementing counter
+// for each of them.
+func (c *evictionCache[K, T]) putAll(objs []interface{}, counter *atomic.Uint64) []error {
+	errs := make([]error, len(objs))
+	keys := make([]T, len(objs))
+	for i, obj := range objs {
+		key, err := c.keyFunc(obj)
+		if err != nil {
+			errs[i] = KeyError{obj, err}
+			continue
+		}
+		keys[i] = key
+	}
+
+	c.lock()
+	defer c.unlock()
+	for i, obj := range objs {
+		if errs[i] == nil {
+			if errs[i] = c.put(keys[i], obj, c.ttl); errs[i] == nil {
+				counter.Add(1)
+			}
+		}
+	}
+	return batchErrors(errs)
+}
+
+// DeleteAll deletes objects from the cache under a single lock acquisition.
+func (c *evictionCache[K, T]) DeleteAll(objs []interface{}) []error {
+	errs := make([]error, len(objs))
+	keys := make([]T, len(objs))
+	for i, obj := range objs {
+		key, err := c.keyFunc(obj)
+		if err != nil {
+			errs[i] = KeyError{obj, err}
+			continue
+		}
+		keys[i] = key
+	}
+
+	c.lock()
+	defer c.unlock()
+	for i, obj := range objs {
+		if errs[i] == nil {
+			errs[i] = c.delete(keys[i], obj)
+		}
+	}
+	return batchErrors(errs)
+}
+
+// put stores obj under key and records it in the eviction policy. A positive
+// ttl makes the object expire, otherwise it never does. It returns an
+// IndexError if obj cannot be indexed, and nil if obj was refused.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) put(key T, obj interface{}, ttl time.Duration) error {
+	if c.admit != nil {
+		if _, exists := c.store.get(key); !exists && !c.admit(key, obj) {
+			c.reject(key, obj)
+			return nil
+		}
+	}
+	return c.insert(key, obj, ttl)
+}
+
+// insert stores obj under key and records it in the eviction policy without
+// consulting the admission function.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) insert(key T, obj interface{}, ttl time.Duration) error {
+	// Add the new object to store first, so that an object that cannot be
+	// indexed never reaches the policy
+	old, existed := c.store.get(key)
+	if err := c.store.update(key, obj); err != nil {
+		return err
+	}
+	c.track(key, obj, old, existed, ttl)
 	return nil
 }
 
+// track records obj, just stored under key in place of old if existed, in the
+// eviction policy, removing it again if the policy rejects it.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) track(key T, obj, old interface{}, existed bool, ttl time.Duration) {
+	// Call Put on eviction policy
+	evictedKey, evicted := c.policyPut(key, obj)
+	if evicted && evictedKey == key {
+		// The policy rejected the new key, so it is not stored at all. The old
+		// object may no longer be indexable once other objects of a Txn are
+		// stored, in which case the key is dropped as well
+		if !existed || c.store.update(key, old) != nil {
+			c.store.delete(key)
+		}
+		c.reject(key, obj)
+		return
+	}
+	if evicted {
+		// EvictionPolicy.Put returned true, indicating eviction occurred
+		c.evict(evictedKey, EvictionReasonCapacity) // Delete the eliminated key from store
+	}
+
+	c.notifyStored(key, obj, existed)
+	if ttl > 0 {
+		c.expiries.set(key, c.clock.Now().Add(ttl))
+	} else {
+		c.expiries.delete(key)
+	}
+	if c.weigher != nil {
+		c.setWeight(key, c.weigher(obj))
+		c.shed()
+	}
+}
+
+// Warm bulk-loads objects into the cache in the given recency order.
+func (c *evictionCache[K, T]) Warm(items []interface{}, order WarmOrder) error {
+	keys := make([]T, len(items))
+	for i, item := range items {
+		key, err := c.keyFunc(item)
+		if err != nil {
+			return KeyError{item, err}
+		}
+		keys[i] = key
+	}
+
+	c.lock()
+	defer c.unlock()
+	for i := range items {
+		// The policy treats the last inserted object as the most recent one
+		if order == WarmOrderMostRecentFirst {
+			i = len(items) - 1 - i
+		}
+		if err := c.insert(keys[i], items[i], c.ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reject reports an object refused by the admission function or the policy.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) reject(key T, obj interface{}) {
+	c.notify(key, obj, EvictionReasonRejected)
+}
+
+// notify records the removal of an object for onEvicted, the counters and
+// the metadata. The caller must hold c.mu.
+func (c *evictionCache[K, T]) notify(key T, obj interface{}, reason EvictionReason) {
+	c.meta.forget(key)
+	if c.counts != nil {
+		c.counts[reason]++
+	}
+	if c.onEvicted != nil || len(c.observers) > 0 {
+		eventType := EventEvict
+		if reason == EvictionReasonDeleted {
+			eventType = EventDelete
+		}
+		c.events = append(c.events, Event[T]{eventType, key, obj, reason})
+	}
+}
+
+// notifyStored records the storage of an object for the observers and the
+// metadata. The caller must hold c.mu.
+func (c *evictionCache[K, T]) notifyStored(key T, obj interface{}, existed bool) {
+	c.meta.stored(key)
+	if len(c.observers) == 0 {
+		return
+	}
+	eventType := EventAdd
+	if existed {
+		eventType = EventUpdate
+	}
+	c.events = append(c.events, Event[T]{Type: eventType, Key: key, Obj: obj})
+}
+
+// AddObserver registers an observer notified of every later change.
+func (c *evictionCache[K, T]) AddObserver(o Observer[T]) {
+	c.lock()
+	defer c.unlock()
+	c.observers = append(slices.Clip(c.observers), o)
+}
+
+// policyPut records key in the eviction policy, with the priority of obj if configured.
+func (c *evictionCache[K, T]) policyPut(key T, obj interface{}) (T, bool) {
+	if c.priorityPolicy != nil {
+		return c.priorityPolicy.PutWithPriority(key, c.priority(obj))
+	}
+	return c.evictionPolicy.Put(key)
+}
+
 // Delete deletes an object from the cache.
 func (c *evictionCache[K, T]) Delete(obj interface{}) error {
 	key, err := c.keyFunc(obj)
 	if err != nil {
 		return KeyError{obj, err}
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.lock()
+	defer c.unlock()
+	return c.delete(key, obj)
+}
+
+// delete removes the object stored under key, obj being any object with that
+// key. The caller must hold c.mu.
+func (c *evictionCache[K, T]) delete(key T, obj interface{}) error {
+	if stored, exists := c.store.get(key); exists {
+		c.notify(key, stored, EvictionReasonDeleted)
+	}
 	c.evictionPolicy.Delete(key)
-	c.store.Delete(key)
+	c.store.delete(key)
+	c.setWeight(key, 0)
+	c.expiries.delete(key)
+	c.counters.deletes.Add(1)
+	if c.victim != nil {
+		return c.victim.Delete(obj)
+	}
 	return nil
 }
 
-// List returns a list of all cached objects.
+// CompareAndSwap replaces old by new if old is the stored object.
+func (c *evictionCache[K, T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	key, err := c.keyFunc(new)
+	if err != nil {
+		return false, KeyError{new, err}
+	}
+	c.lock()
+	defer c.unlock()
+	if current, exists := c.store.get(key); !exists || c.expired(key) || !sameObject(current, old) {
+		return false, nil
+	}
+	if err := c.put(key, new, c.ttl); err != nil {
+		return false, err
+	}
+	c.counters.updates.Add(1)
+	return true, nil
+}
+
+// CompareAndDelete removes old if it is the stored object.
+func (c *evictionCache[K, T]) CompareAndDelete(old interface{}) (bool, error) {
+	key, err := c.keyFunc(old)
+	if err != nil {
+		return false, KeyError{old, err}
+	}
+	c.lock()
+	defer c.unlock()
+	if current, exists := c.store.get(key); !exists || c.expired(key) || !sameObject(current, old) {
+		return false, nil
+	}
+	return true, c.delete(key, old)
+}
+
+// List returns a list of all cached objects, skipping expired ones.
 func (c *evictionCache[K, T]) List() []interface{} {
-	return c.store.List()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.skipExpired() == nil {
+		return c.store.list()
+	}
+	return c.objects(c.liveKeys(c.store.listKeys()))
+}
+
+// ListFiltered returns the cached objects for which pred returns true,
+// skipping expired ones.
+func (c *evictionCache[K, T]) ListFiltered(pred func(obj interface{}) bool) []interface{} {
+	var list []interface{}
+	c.Range(func(key T, obj interface{}) bool {
+		if pred(obj) {
+			list = append(list, obj)
+		}
+		return true
+	})
+	return list
+}
+
+// ListKeysFiltered returns the keys of the cached objects for which pred
+// returns true, skipping expired ones.
+func (c *evictionCache[K, T]) ListKeysFiltered(pred func(obj interface{}) bool) []T {
+	var list []T
+	c.Range(func(key T, obj interface{}) bool {
+		if pred(obj) {
+			list = append(list, key)
+		}
+		return true
+	})
+	return list
+}
+
+// Range calls fn for every cached object until fn returns false, without
+// touching the eviction policy. Expired objects are skipped.
+func (c *evictionCache[K, T]) Range(fn func(key T, obj interface{}) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.store.rangeItems(func(key T, obj interface{}) bool {
+		return c.expired(key) || fn(key, obj)
+	})
 }
 
-// ListKeys returns a list of keys for all cached objects.
+// All returns an iterator over the keys and cached objects, like Range.
+func (c *evictionCache[K, T]) All() iter.Seq2[T, interface{}] {
+	return c.Range
+}
+
+// ListPage returns a page of cached objects and the continue token of the
+// next one, without touching the eviction policy. Expired objects are skipped.
+func (c *evictionCache[K, T]) ListPage(limit int, continueToken string) ([]interface{}, string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store.listPage(limit, continueToken, c.expired)
+}
+
+// ListKeys returns a list of keys for all cached objects, skipping expired
+// ones.
 func (c *evictionCache[K, T]) ListKeys() []T {
-	return c.store.ListKeys()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.liveKeys(c.store.listKeys())
 }
 
-// ListKeysByIndex returns a list of keys based on the index name and indexed value.
+// ListKeysByIndex returns a list of keys based on the index name and indexed
+// value. Unless the cache was created WithoutIndexTouch, it records an access
+// to every returned key in the eviction policy.
 func (c *evictionCache[K, T]) ListKeysByIndex(indexName string, indexedValue K) ([]T, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	keys, err := c.store.IndexKeys(indexName, indexedValue, nil)
+	c.counters.indexQueries.Add(1)
+	if !c.indexTouch {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		keys, err := c.store.indexKeys(indexName, indexedValue, nil)
+		return c.liveKeys(keys), err
+	}
+	c.mu.RLock()
+	keys, err := c.store.indexKeys(indexName, indexedValue, nil)
+	keys = c.liveKeys(keys)
+	c.mu.RUnlock()
 	if err != nil {
 		return keys, err
 	}
 	for _, key := range keys {
-		c.evictionPolicy.Put(key)
+		c.record(key)
 	}
 	return keys, nil
 }
 
 // ListByIndex returns a list of objects based on the index name and indexed value.
 func (c *evictionCache[K, T]) ListByIndex(indexName string, indexedValue K) ([]interface{}, error) {
-	return c.store.ByIndex(indexName, indexedValue, nil)
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys, err := c.store.indexKeys(indexName, indexedValue, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.objects(c.liveKeys(keys)), nil
+}
+
+// CountByIndex returns the number of objects holding the indexed value, like
+// ListByIndex. It records no access, since it returns no object.
+func (c *evictionCache[K, T]) CountByIndex(indexName string, indexedValue K) (int, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.skipExpired() == nil {
+		return c.store.countByIndex(indexName, indexedValue)
+	}
+	keys, err := c.store.indexKeys(indexName, indexedValue, nil)
+	return len(c.liveKeys(keys)), err
+}
+
+// ListByIndexValues returns the objects whose indexed values include any of
+// the given indexed values, like ListByIndex.
+func (c *evictionCache[K, T]) ListByIndexValues(indexName string, indexedValues ...K) ([]interface{}, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keySet, err := c.store.index.getKeysByIndexValues(indexName, indexedValues)
+	if err != nil {
+		return nil, err
+	}
+	return c.objects(c.liveKeys(keySet.UnsortedList())), nil
+}
+
+// Query returns a Query over the cached objects, which does not touch the
+// eviction policy.
+func (c *evictionCache[K, T]) Query() *Query[K, T, interface{}] {
+	return newQuery(c.ListByIndexes, c.Range)
+}
+
+// ListByIndexes returns the objects whose indexed values include the queried
+// value for every index in queries, like ListByIndex.
+func (c *evictionCache[K, T]) ListByIndexes(queries map[string]K) ([]interface{}, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keySet, err := c.store.index.getKeysByIndexes(queries)
+	if err != nil {
+		return nil, err
+	}
+	return c.objects(c.liveKeys(keySet.UnsortedList())), nil
+}
+
+// ListIndexValues returns the distinct indexed values of the objects in the
+// cache for the named index. It records no access, since it returns no object.
+func (c *evictionCache[K, T]) ListIndexValues(indexName string) ([]K, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.skipExpired() == nil {
+		return c.store.index.listIndexValues(indexName)
+	}
+	groups, err := c.groupKeys(indexName)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Collect(maps.Keys(groups)), nil
+}
+
+// IndexStats returns the number of objects in the cache held by each value of
+// the named index. Like ListIndexValues, it records no access.
+func (c *evictionCache[K, T]) IndexStats(indexName string) (IndexStats[K], error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	counts, err := c.valueCounts(indexName)
+	if err != nil {
+		return IndexStats[K]{}, err
+	}
+	return newIndexStats(counts), nil
+}
+
+// GroupByIndex returns the keys of the cached objects held by each value of
+// the named index. It records no access, unlike ListKeysByIndex.
+func (c *evictionCache[K, T]) GroupByIndex(indexName string) (map[K][]T, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.groupKeys(indexName)
+}
+
+// GroupCountsByIndex returns the number of cached objects held by each value
+// of the named index.
+func (c *evictionCache[K, T]) GroupCountsByIndex(indexName string) (map[K]int, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.valueCounts(indexName)
 }
 
 // AddIndexer add new indexer.
 func (c *evictionCache[K, T]) AddIndexer(indexName string, indexFunc IndexFunc[K]) error {
-	return c.store.AddIndexer(indexName, indexFunc)
+	c.lock()
+	defer c.unlock()
+	return c.store.addIndexer(indexName, indexFunc)
 }
 
+// AddIndexers add new indexers.
 func (c *evictionCache[K, T]) AddIndexers(newIndexers Indexers[K]) error {
-	return c.store.AddIndexers(newIndexers)
+	c.lock()
+	defer c.unlock()
+	return c.store.addIndexers(newIndexers)
+}
+
+// RemoveIndexer removes an indexer and its index.
+func (c *evictionCache[K, T]) RemoveIndexer(indexName string) error {
+	c.lock()
+	defer c.unlock()
+	return c.store.index.removeIndexer(indexName)
+}
+
+// Reindex rebuilds an index from the cached objects.
+func (c *evictionCache[K, T]) Reindex(indexName string) error {
+	c.lock()
+	defer c.unlock()
+	previous, err := c.store.rebuildExisting(indexName)
+	if err != nil {
+		return err
+	}
+	c.store.index.notifyIndexRebuilt(indexName, previous.index)
+	return nil
+}
+
+// OnIndexChange registers hook for the changes of an index, including those
+// of the objects evicted or expired. Unlike those of a ThreadSafeStore, the
+// changes are reported once the lock is released, along with the events, so
+// that hook may call back into the cache, and the changes undone before, such
+// as those of an object the eviction policy rejected, are not reported.
+func (c *evictionCache[K, T]) OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error {
+	c.lock()
+	defer c.unlock()
+	c.hooks++
+	id := c.hooks
+	return c.store.index.addHook(indexName, func(change IndexChange[K, T]) {
+		c.indexChanges = append(c.indexChanges, queuedIndexChange[K, T]{hook, id, change})
+	})
+}
+
+// SetIndexNormalizer makes normalize map the values of an index.
+func (c *evictionCache[K, T]) SetIndexNormalizer(indexName string, normalize func(K) K) error {
+	c.lock()
+	defer c.unlock()
+	return c.store.setIndexNormalizer(indexName, normalize)
+}
+
+// AddUniqueIndexer adds new unique indexer.
+func (c *evictionCache[K, T]) AddUniqueIndexer(indexName string, indexFunc IndexFunc[K]) error {
+	c.lock()
+	defer c.unlock()
+	return c.store.addUniqueIndexer(indexName, indexFunc)
+}
+
+// GetByUniqueIndex returns the object holding indexedValue in the named unique
+// index, unless it expired. Like ListKeysByIndex, it records an access unless
+// the cache was created WithoutIndexTouch.
+func (c *evictionCache[K, T]) GetByUniqueIndex(indexName string, indexedValue K) (interface{}, bool, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	key, exists, err := c.store.index.getKeyByUniqueIndex(indexName, indexedValue)
+	var item interface{}
+	if exists && !c.expired(key) {
+		item = c.store.items[key]
+	} else {
+		exists = false
+	}
+	c.mu.RUnlock()
+	if exists && c.indexTouch {
+		c.record(key)
+	}
+	return item, exists, err
+}
+
+// AddOrderedIndexer adds new ordered indexer.
+func (c *evictionCache[K, T]) AddOrderedIndexer(indexName string, indexFunc IndexFunc[K], compare func(a, b K) int) error {
+	c.lock()
+	defer c.unlock()
+	return c.store.addOrderedIndexer(indexName, indexFunc, compare)
+}
+
+// ListByIndexRange returns the objects holding a value in [from, to) in the
+// named ordered index.
+func (c *evictionCache[K, T]) ListByIndexRange(indexName string, from, to K) ([]interface{}, error) {
+	return c.byRange(indexName, indexRange[K]{from: &from, fromInclusive: true, to: &to})
+}
+
+// ListByIndexGreaterThan returns the objects holding a value greater than
+// indexedValue in the named ordered index.
+func (c *evictionCache[K, T]) ListByIndexGreaterThan(indexName string, indexedValue K) ([]interface{}, error) {
+	return c.byRange(indexName, indexRange[K]{from: &indexedValue})
+}
+
+// ListByIndexLessThan returns the objects holding a value less than
+// indexedValue in the named ordered index.
+func (c *evictionCache[K, T]) ListByIndexLessThan(indexName string, indexedValue K) ([]interface{}, error) {
+	return c.byRange(indexName, indexRange[K]{to: &indexedValue})
+}
+
+// matchValues returns the objects holding any of indexedValues in the named
+// index, along with how many of them each holds.
+func (c *evictionCache[K, T]) matchValues(indexName string, indexedValues []K) ([]TokenMatch[interface{}], error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	counts, err := c.store.index.countKeysByIndexValues(indexName, indexedValues)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]TokenMatch[interface{}], 0, len(counts))
+	skip := c.skipExpired()
+	for key, count := range counts {
+		if skip == nil || !skip(key) {
+			matches = append(matches, TokenMatch[interface{}]{Object: c.store.items[key], Matches: count})
+		}
+	}
+	return matches, nil
+}
+
+// byRange returns the objects holding a value within r in the named ordered
+// index.
+func (c *evictionCache[K, T]) byRange(indexName string, r indexRange[K]) ([]interface{}, error) {
+	c.counters.indexQueries.Add(1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys, _, err := c.store.index.getKeysByRange(indexName, r)
+	if err != nil {
+		return nil, err
+	}
+	return c.objects(c.liveKeys(keys)), nil
+}
+
+// groupKeys returns the keys of the cached objects held by each value of the
+// named index, skipping expired ones.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) groupKeys(indexName string) (map[K][]T, error) {
+	groups, err := c.store.index.groupKeys(indexName)
+	if err != nil || c.skipExpired() == nil {
+		return groups, err
+	}
+	for value, keys := range groups {
+		if keys = c.liveKeys(keys); len(keys) > 0 {
+			groups[value] = keys
+		} else {
+			delete(groups, value)
+		}
+	}
+	return groups, nil
+}
+
+// valueCounts returns the number of cached objects held by each value of the
+// named index, skipping expired ones.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) valueCounts(indexName string) (map[K]int, error) {
+	if c.skipExpired() == nil {
+		return c.store.index.valueCounts(indexName)
+	}
+	groups, err := c.groupKeys(indexName)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[K]int, len(groups))
+	for value, keys := range groups {
+		counts[value] = len(keys)
+	}
+	return counts, nil
+}
+
+// GetIndexers returns a copy of the indexers of the cache.
+func (c *evictionCache[K, T]) GetIndexers() Indexers[K] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return maps.Clone(c.store.index.indexers)
+}
+
+// KeyOf returns the key of obj in the cache.
+func (c *evictionCache[K, T]) KeyOf(obj interface{}) (T, error) {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return key, KeyError{obj, err}
+	}
+	return key, nil
 }
 
 // Get retrieves an object from the cache based on the object.
@@ -126,18 +971,167 @@ func (c *evictionCache[K, T]) Get(obj interface{}) (interface{}, bool, error) {
 	return c.GetByKey(key)
 }
 
-// GetByKey retrieves an object from the cache based on the key.
+// GetByKey retrieves an object from the cache based on the key. It only takes
+// the read lock and buffers the access for the eviction policy.
 func (c *evictionCache[K, T]) GetByKey(key T) (interface{}, bool, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	item, exists := c.store.Get(key)
+	c.mu.RLock()
+	item, exists := c.store.get(key)
+	if exists && c.expired(key) {
+		item, exists = nil, false
+	}
+	c.mu.RUnlock()
+	c.counters.gets.Add(1)
+	// Record missing keys too so that the policy counts the miss
+	c.record(key)
 	if exists {
-		c.evictionPolicy.Put(key)
+		c.meta.accessed(key)
+	}
+	if !exists && c.victim != nil {
+		return c.promote(key)
 	}
 	return item, exists, nil
 }
 
-// Replace replaces all objects in the cache.
+// GetWithExpiration retrieves an object from the cache along with its expiry.
+func (c *evictionCache[K, T]) GetWithExpiration(key T) (interface{}, time.Time, bool) {
+	c.mu.RLock()
+	item, exists := c.store.get(key)
+	expiresAt, _ := c.expiries.get(key)
+	if exists && c.expired(key) {
+		item, exists = nil, false
+	}
+	c.mu.RUnlock()
+	c.counters.gets.Add(1)
+	c.record(key)
+	if !exists {
+		return nil, time.Time{}, false
+	}
+	c.meta.accessed(key)
+	return item, expiresAt, true
+}
+
+// GetMeta returns the history of the object stored under key, if the cache
+// was created WithEntryMetadata.
+func (c *evictionCache[K, T]) GetMeta(key T) (EntryMeta, bool) {
+	return c.meta.get(key)
+}
+
+// GetOrAdd returns the stored object with the key of obj, or adds obj.
+func (c *evictionCache[K, T]) GetOrAdd(obj interface{}) (interface{}, bool, error) {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return nil, false, KeyError{obj, err}
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.counters.gets.Add(1)
+	if item, exists := c.store.get(key); exists && !c.expired(key) {
+		c.evictionPolicy.Touch(key)
+		c.meta.accessed(key)
+		return item, true, nil
+	}
+	if c.victim != nil {
+		item, exists, err := c.promoteLocked(key)
+		if err != nil || exists {
+			return item, exists, err
+		}
+	}
+	if err := c.put(key, obj, c.ttl); err != nil {
+		return nil, false, err
+	}
+	c.counters.adds.Add(1)
+	return obj, false, nil
+}
+
+// GetMany retrieves objects from the cache under a single read lock and
+// records an access to every key, found or not.
+func (c *evictionCache[K, T]) GetMany(keys []T) (map[T]interface{}, []T) {
+	items := make(map[T]interface{}, len(keys))
+	var missing []T
+	c.mu.RLock()
+	for _, key := range keys {
+		if item, exists := c.store.get(key); exists && !c.expired(key) {
+			items[key] = item
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	c.mu.RUnlock()
+	c.counters.gets.Add(uint64(len(keys)))
+	for _, key := range keys {
+		c.record(key)
+	}
+	for key := range items {
+		c.meta.accessed(key)
+	}
+	if c.victim == nil || len(missing) == 0 {
+		return items, missing
+	}
+	// Look the missing keys up in the victim cache
+	stillMissing := missing[:0]
+	for _, key := range missing {
+		if item, exists, err := c.promote(key); err == nil && exists {
+			items[key] = item
+		} else {
+			stillMissing = append(stillMissing, key)
+		}
+	}
+	return items, stillMissing
+}
+
+// promote moves the object stored under key from the victim cache back into
+// the cache.
+func (c *evictionCache[K, T]) promote(key T) (interface{}, bool, error) {
+	c.lock()
+	defer c.unlock()
+	// Another caller may have promoted or added the object meanwhile
+	if item, exists := c.store.get(key); exists && !c.expired(key) {
+		return item, true, nil
+	}
+	return c.promoteLocked(key)
+}
+
+// promoteLocked moves the object stored under key from the victim cache back
+// into the cache.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) promoteLocked(key T) (interface{}, bool, error) {
+	item, exists, err := c.victim.GetByKey(key)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	if err := c.victim.Delete(item); err != nil {
+		return nil, false, err
+	}
+	if err := c.insert(key, item, c.ttl); err != nil {
+		// Leave the object in the victim cache
+		return nil, false, errors.Join(err, c.victim.Add(item))
+	}
+	return item, true, nil
+}
+
+// Contains reports whether an unexpired object is stored under key. Like
+// Peek, it does not touch the eviction policy, the entry metadata or the
+// counters, and does not look in the victim cache.
+func (c *evictionCache[K, T]) Contains(key T) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, exists := c.store.items[key]
+	return exists && !c.expired(key)
+}
+
+// Peek retrieves an object from the cache without touching the eviction policy.
+func (c *evictionCache[K, T]) Peek(key T) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.expired(key) {
+		return nil, false
+	}
+	return c.store.get(key)
+}
+
+// Replace replaces all objects in the cache. The new objects are fed to the
+// eviction policy in no particular order.
 func (c *evictionCache[K, T]) Replace(list []interface{}) error {
 	items := make(map[T]interface{}, len(list))
 	for _, item := range list {
@@ -147,32 +1141,547 @@ func (c *evictionCache[K, T]) Replace(list []interface{}) error {
 		}
 		items[key] = item
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	keys := make([]T, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	return c.replace(keys, items, nil)
+}
+
+// ReplaceWithDiff replaces all objects in the cache like Replace and reports
+// what changed. Expired objects count as absent before the replacement, and
+// objects evicted by it as absent after it.
+func (c *evictionCache[K, T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	items := make(map[T]interface{}, len(list))
+	keys := make([]T, 0, len(list))
+	for _, item := range list {
+		key, err := c.keyFunc(item)
+		if err != nil {
+			return nil, nil, nil, KeyError{item, err}
+		}
+		if _, exists := items[key]; !exists {
+			keys = append(keys, key)
+		}
+		items[key] = item
+	}
+	var oldKeys []T
+	if err := c.replace(keys, items, &oldKeys); err != nil {
+		return nil, nil, nil, err
+	}
+	added, updated, removed := replaceDiff(oldKeys, keys, items)
+	return added, updated, removed, nil
+}
+
+// ReplaceOrdered replaces all objects in the cache, feeding them to the
+// eviction policy in list order so that the last one is the most recent.
+func (c *evictionCache[K, T]) ReplaceOrdered(list []interface{}) error {
+	items := make(map[T]interface{}, len(list))
+	keys := make([]T, len(list))
+	for i, item := range list {
+		key, err := c.keyFunc(item)
+		if err != nil {
+			return KeyError{item, err}
+		}
+		items[key] = item
+		keys[i] = key
+	}
+	// Keep the last occurrence of duplicate keys, like the stored object
+	seen := make(map[T]struct{}, len(items))
+	ordered := make([]T, len(items))
+	n := len(items)
+	for i := len(keys) - 1; i >= 0; i-- {
+		if _, ok := seen[keys[i]]; !ok {
+			seen[keys[i]] = struct{}{}
+			n--
+			ordered[n] = keys[i]
+		}
+	}
+	return c.replace(ordered, items, nil)
+}
+
+// replace swaps in items, feeding their keys to the eviction policy in order.
+// If oldKeys is not nil, it receives the keys of the unexpired objects that
+// were present before. items is left holding the objects that remain stored.
+func (c *evictionCache[K, T]) replace(keys []T, items map[T]interface{}, oldKeys *[]T) error {
+	c.lock()
+	defer c.unlock()
+	if oldKeys != nil {
+		c.store.rangeItems(func(key T, obj interface{}) bool {
+			if !c.expired(key) {
+				*oldKeys = append(*oldKeys, key)
+			}
+			return true
+		})
+	}
+	if c.onEvicted != nil || c.counts != nil || len(c.observers) > 0 {
+		for _, key := range c.store.listKeys() {
+			if _, ok := items[key]; !ok {
+				obj, _ := c.store.get(key)
+				c.notify(key, obj, EvictionReasonReplaced)
+			}
+		}
+	}
+	existed := make(map[T]bool, len(keys))
+	for _, key := range keys {
+		_, existed[key] = c.store.get(key)
+	}
 	// reset the eviction policy
 	c.evictionPolicy.Reset()
-	// Replace the store
-	c.store.Replace(items)
-	// Re-add items to eviction policy
-	for key := range items {
-		c.evictionPolicy.Put(key)
+	// Replace the store, whose new objects get the default TTL. The objects
+	// that cannot be indexed are removed from items and left out.
+	err := c.store.replace(items)
+	if err != nil {
+		keys = slices.DeleteFunc(keys, func(key T) bool {
+			_, ok := items[key]
+			return !ok
+		})
 	}
-	return nil
+	for _, key := range keys {
+		c.notifyStored(key, items[key], existed[key])
+	}
+	if c.victim != nil {
+		if err := c.victim.Replace(nil); err != nil {
+			return err
+		}
+	}
+	c.expiries = newExpiryHeap[T]()
+	if c.ttl > 0 {
+		expiry := c.clock.Now().Add(c.ttl)
+		for key := range items {
+			c.expiries.set(key, expiry)
+		}
+	}
+	// Re-add items to eviction policy. A policy that may reject keys is given
+	// them one by one, so that the rejected keys are told apart from those
+	// evicted to make room.
+	var evictedKeys, rejectedKeys []T
+	if _, admitting := c.evictionPolicy.(eviction.AdmittingPolicy[T]); admitting || c.priorityPolicy != nil {
+		for _, key := range keys {
+			evictedKey, evicted := c.policyPut(key, items[key])
+			switch {
+			case !evicted:
+			case evictedKey == key:
+				rejectedKeys = append(rejectedKeys, key)
+			default:
+				evictedKeys = append(evictedKeys, evictedKey)
+			}
+		}
+	} else {
+		evictedKeys = c.evictionPolicy.PutAll(keys)
+	}
+	if c.weigher != nil {
+		c.weights = make(map[T]int64, len(items))
+		c.weight = 0
+		for key, item := range items {
+			c.setWeight(key, c.weigher(item))
+		}
+	}
+	// Drop the objects that did not fit in the policy
+	for _, key := range rejectedKeys {
+		c.evict(key, EvictionReasonRejected)
+	}
+	for _, key := range evictedKeys {
+		c.evict(key, EvictionReasonCapacity)
+	}
+	c.shed()
+	// Forget the removed objects even if they were not reported
+	c.meta.replaced(items)
+	return err
 }
 
 // Evict removes an object from the cache based on the cache eviction policy.
 func (c *evictionCache[K, T]) Evict() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.lock()
+	defer c.unlock()
 	key, ok := c.evictionPolicy.Evict()
 	if !ok {
 		return fmt.Errorf("no items to evict")
 	}
-	c.store.Delete(key)
+	c.evict(key, EvictionReasonManual)
+	return nil
+}
+
+// EvictOne removes an object from the cache based on the cache eviction policy
+// and returns it along with its key.
+func (c *evictionCache[K, T]) EvictOne() (T, interface{}, bool) {
+	c.lock()
+	defer c.unlock()
+	key, ok := c.evictionPolicy.Evict()
+	if !ok {
+		return key, nil, false
+	}
+	obj, _ := c.store.get(key)
+	c.evict(key, EvictionReasonManual)
+	return key, obj, true
+}
+
+// EvictN removes up to n objects from the cache based on the cache eviction policy.
+func (c *evictionCache[K, T]) EvictN(n int) []T {
+	c.lock()
+	defer c.unlock()
+	evictedKeys := c.evictionPolicy.EvictN(n)
+	for _, key := range evictedKeys {
+		c.evict(key, EvictionReasonManual)
+	}
+	return evictedKeys
+}
+
+// Pin excludes the object stored under key from eviction.
+func (c *evictionCache[K, T]) Pin(key T) bool {
+	c.lock()
+	defer c.unlock()
+	return c.evictionPolicy.Pin(key)
+}
+
+// Unpin makes a pinned object evictable again.
+func (c *evictionCache[K, T]) Unpin(key T) bool {
+	c.lock()
+	defer c.unlock()
+	return c.evictionPolicy.Unpin(key)
+}
+
+// Capacity returns the capacity of the eviction policy.
+func (c *evictionCache[K, T]) Capacity() int {
+	return c.evictionPolicy.Capacity()
+}
+
+// Resize changes the capacity of the cache, evicting objects when it shrinks.
+func (c *evictionCache[K, T]) Resize(capacity int) []T {
+	c.lock()
+	defer c.unlock()
+	evictedKeys := c.evictionPolicy.Resize(capacity)
+	for _, key := range evictedKeys {
+		c.evict(key, EvictionReasonCapacity)
+	}
+	return evictedKeys
+}
+
+// Stats returns the counters of the cache along with those collected by the
+// eviction policy, including the buffered accesses.
+func (c *evictionCache[K, T]) Stats() Stats {
+	c.lock()
+	defer c.unlock()
+	stats := c.counters.stats(0)
+	stats.Stats = c.evictionPolicy.Stats()
+	return stats
+}
+
+// DeleteExpired removes the expired objects from the cache, the earliest
+// first. It only visits the expired objects, and does not take the write lock
+// if there are none.
+func (c *evictionCache[K, T]) DeleteExpired() []T {
+	now := c.clock.Now()
+	c.mu.RLock()
+	due := c.expiries.due(now)
+	c.mu.RUnlock()
+	if !due {
+		return nil
+	}
+
+	c.lock()
+	defer c.unlock()
+	expiredKeys := c.expiries.popExpired(now)
+	for _, key := range expiredKeys {
+		c.evictionPolicy.Delete(key)
+		c.evict(key, EvictionReasonExpired)
+	}
+	return expiredKeys
+}
+
+// EvictionCounts returns the number of removals by reason.
+func (c *evictionCache[K, T]) EvictionCounts() map[EvictionReason]uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.counts == nil {
+		return nil
+	}
+	counts := make(map[EvictionReason]uint64, len(c.counts))
+	for reason, n := range c.counts {
+		counts[reason] = n
+	}
+	return counts
+}
+
+// Close stops the background janitor.
+func (c *evictionCache[K, T]) Close() {
+	if c.stopJanitor != nil {
+		c.closeOnce.Do(func() { close(c.stopJanitor) })
+	}
+}
+
+// runJanitor calls DeleteExpired every interval until stop is closed.
+func (c *evictionCache[K, T]) runJanitor(interval time.Duration, stop chan struct{}) {
+	timer := c.clock.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			c.DeleteExpired()
+			timer.Reset(interval)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// expired reports whether the object stored under key has expired.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) expired(key T) bool {
+	expiry, ok := c.expiries.get(key)
+	return ok && !c.clock.Now().Before(expiry)
+}
+
+// skipExpired returns expired if any object expired, so that reads leave it
+// out, or nil if none did and reads need not check every key.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) skipExpired() func(key T) bool {
+	if c.expiries.due(c.clock.Now()) {
+		return c.expired
+	}
 	return nil
 }
 
-// Size returns count of object in the cache.
+// liveKeys removes from keys those of expired objects and returns the
+// remaining ones.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) liveKeys(keys []T) []T {
+	if skip := c.skipExpired(); skip != nil {
+		return slices.DeleteFunc(keys, skip)
+	}
+	return keys
+}
+
+// objects returns the objects stored under keys.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) objects(keys []T) []interface{} {
+	list := make([]interface{}, len(keys))
+	for i, key := range keys {
+		list[i] = c.store.items[key]
+	}
+	return list
+}
+
+// Txn applies the changes buffered by fn under a single lock acquisition. If
+// storing any of the objects fails like Add, nothing is applied. Stored objects
+// are still subject to admission and may evict others, including objects of
+// the same transaction, as if they had been stored one by one.
+func (c *evictionCache[K, T]) Txn(fn func(tx Txn[T]) error) error {
+	tx := newTxn(c.keyFunc)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	updates := make(map[T]interface{})
+	var deletes []T
+	for _, key := range tx.keys {
+		if change := tx.changes[key]; change.deleted {
+			deletes = append(deletes, key)
+		} else {
+			updates[key] = change.obj
+		}
+	}
+
+	c.lock()
+	defer c.unlock()
+	// Index every object before changing anything, so that an object that
+	// cannot be indexed leaves the cache unchanged
+	batch, err := c.store.index.prepareBatch(updates, deletes)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range deletes {
+		if err := c.delete(key, tx.changes[key].obj); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	olds := make(map[T]interface{}, len(updates))
+	for key, obj := range updates {
+		old, existed := c.store.get(key)
+		if !existed && c.admit != nil && !c.admit(key, obj) {
+			c.reject(key, obj)
+			delete(updates, key)
+			continue
+		}
+		if existed {
+			olds[key] = old
+		}
+	}
+	c.store.commit(updates, batch, nil, &c.counters)
+	// The policy sees the objects in the order they were buffered
+	for _, key := range tx.keys {
+		if obj, ok := updates[key]; ok {
+			old, existed := olds[key]
+			c.track(key, obj, old, existed, c.ttl)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Snapshot returns a read-only copy of the cached objects and their indices,
+// leaving out expired ones. The copy does not touch the eviction policy and is
+// not affected by later evictions.
+func (c *evictionCache[K, T]) Snapshot() Store[T] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return newSnapshot[K](c.keyFunc, c.store.snapshot(c.expired))
+}
+
+// Size returns count of object in the cache, leaving out expired ones.
 func (c *evictionCache[K, T]) Size() int {
-	return c.store.Size()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	skip := c.skipExpired()
+	if skip == nil {
+		return len(c.store.items)
+	}
+	size := 0
+	for key := range c.store.items {
+		if !skip(key) {
+			size++
+		}
+	}
+	return size
+}
+
+// evict removes the object stored under a key evicted by the policy.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) evict(key T, reason EvictionReason) {
+	if obj, exists := c.store.get(key); exists {
+		c.notify(key, obj, reason)
+		if c.victim != nil && reason == EvictionReasonCapacity {
+			// The victim cache is best effort, an object it refuses is dropped
+			_ = c.victim.Add(obj)
+		}
+	}
+	c.store.delete(key)
+	c.setWeight(key, 0)
+	c.expiries.delete(key)
+}
+
+// setWeight records the weight of the object stored under key, 0 once removed.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) setWeight(key T, weight int64) {
+	if c.weigher == nil {
+		return
+	}
+	c.weight += weight - c.weights[key]
+	if weight == 0 {
+		delete(c.weights, key)
+	} else {
+		c.weights[key] = weight
+	}
+}
+
+// shed evicts objects until their total weight fits the maximum weight.
+// The caller must hold c.mu.
+func (c *evictionCache[K, T]) shed() {
+	for c.weigher != nil && c.weight > c.maxWeight {
+		key, ok := c.evictionPolicy.Evict()
+		if !ok {
+			return
+		}
+		c.evict(key, EvictionReasonCapacity)
+	}
+}
+
+// lock acquires c.mu exclusively and applies the buffered accesses, so that
+// the eviction policy sees them before any change.
+func (c *evictionCache[K, T]) lock() {
+	c.mu.Lock()
+	c.drain()
+}
+
+// record buffers an access to key for the eviction policy. When the buffer is
+// full it applies the buffered accesses if the lock is free, and otherwise
+// drops the access rather than wait for it.
+func (c *evictionCache[K, T]) record(key T) {
+	select {
+	case c.accesses <- key:
+		return
+	default:
+	}
+	if c.mu.TryLock() {
+		c.drain()
+		c.evictionPolicy.Touch(key)
+		c.mu.Unlock()
+	}
+}
+
+// drain applies the buffered accesses to the eviction policy.
+// The caller must hold c.mu exclusively.
+func (c *evictionCache[K, T]) drain() {
+	for {
+		select {
+		case key := <-c.accesses:
+			c.evictionPolicy.Touch(key)
+		default:
+			return
+		}
+	}
+}
+
+// unlock releases c.mu and then reports the changes made while it was held,
+// so that onEvicted and the observers may safely call back into the cache.
+func (c *evictionCache[K, T]) unlock() {
+	events := c.events
+	c.events = nil
+	indexChanges := netIndexChanges(c.indexChanges)
+	c.indexChanges = nil
+	observers := c.observers
+	c.mu.Unlock()
+	for _, q := range indexChanges {
+		q.hook(q.change)
+	}
+	for _, e := range events {
+		if c.onEvicted != nil && e.Type != EventAdd && e.Type != EventUpdate {
+			c.onEvicted(e.Key, e.Obj, e.Reason)
+		}
+		for _, o := range observers {
+			o.Observe(e)
+		}
+	}
+}
+
+// queuedIndexChange is an index change to report to a hook once the lock of
+// an eviction cache is released.
+type queuedIndexChange[K, T comparable] struct {
+	hook IndexChangeFunc[K, T]
+	// id identifies hook, which cannot be compared
+	id     int
+	change IndexChange[K, T]
+}
+
+// netIndexChanges returns changes without the pairs of changes of the same
+// key, value and hook that cancel out, such as a key entering a value and
+// then leaving it.
+func netIndexChanges[K, T comparable](changes []queuedIndexChange[K, T]) []queuedIndexChange[K, T] {
+	if len(changes) < 2 {
+		return changes
+	}
+	type slot struct {
+		id    int
+		index string
+		key   T
+		value K
+	}
+	// last holds the position of the last change of every slot not
+	// cancelled yet
+	last := make(map[slot]int)
+	cancelled := make([]bool, len(changes))
+	for i, q := range changes {
+		s := slot{q.id, q.change.Index, q.change.Key, q.change.Value}
+		if j, ok := last[s]; ok && changes[j].change.Entered != q.change.Entered {
+			cancelled[i], cancelled[j] = true, true
+			delete(last, s)
+			continue
+		}
+		last[s] = i
+	}
+	net := make([]queuedIndexChange[K, T], 0, len(changes))
+	for i, q := range changes {
+		if !cancelled[i] {
+			net = append(net, q)
+		}
+	}
+	return net
 }
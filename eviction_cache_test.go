@@ -1,7 +1,12 @@
 package cache
 
 import (
+	"cmp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -89,3 +94,1091 @@ func TestEvictionCacheLFU(t *testing.T) {
 	_, exists, _ = store.Get(2)
 	assert.True(t, exists)
 }
+
+func TestEvictionCacheResize(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](3), make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+
+	assert.Equal(t, []int{1, 2}, store.Resize(1))
+	assert.Equal(t, []int{3}, store.ListKeys())
+
+	assert.Empty(t, store.Resize(2))
+	assert.NoError(t, store.Add(4))
+	assert.ElementsMatch(t, []int{3, 4}, store.ListKeys())
+}
+
+func TestEvictionCacheMemoryGovernor(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](4), make(Indexers[int]))
+	assert.Nil(t, store.AddAll([]interface{}{1, 2, 3, 4}))
+	assert.Equal(t, 4, store.Capacity())
+
+	// A heap far over the limit shrinks the store to its minimum capacity,
+	// removing the evicted objects
+	governor := eviction.NewMemoryGovernor[int](store, 0.5, eviction.WithMemoryLimit(1),
+		eviction.WithCapacityBounds(1, 4))
+	assert.Equal(t, []int{1, 2, 3}, governor.Adjust())
+	assert.Equal(t, 1, store.Capacity())
+	assert.Equal(t, []int{4}, store.ListKeys())
+}
+
+func TestEvictionCacheUpdateEvicts(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+
+	// Updating a new key evicts like Add does
+	assert.NoError(t, store.Update(3))
+	assert.ElementsMatch(t, []int{2, 3}, store.ListKeys())
+
+	// Reading a missing key does not insert it into the policy
+	_, exists, err := store.GetByKey(1)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, store.Add(4))
+	assert.ElementsMatch(t, []int{3, 4}, store.ListKeys())
+}
+
+func TestEvictionCacheEvictN(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](5), make(Indexers[int]))
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, store.EvictN(3))
+	assert.Equal(t, []int{4}, store.ListKeys())
+	assert.Equal(t, []int{4}, store.EvictN(3))
+	assert.Equal(t, 0, store.Size())
+}
+
+func TestEvictionCacheOnEvicted(t *testing.T) {
+	type evictedCall struct {
+		key    int
+		obj    interface{}
+		reason EvictionReason
+	}
+	var calls []evictedCall
+	var store EvictionStore[int, int]
+	onEvicted := func(key int, obj interface{}, reason EvictionReason) {
+		// The callback may call back into the cache
+		_, exists, _ := store.GetByKey(key)
+		assert.False(t, exists)
+		calls = append(calls, evictedCall{key, obj, reason})
+	}
+	store = NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](2), make(Indexers[int]), WithOnEvicted[int](onEvicted))
+
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+	assert.Equal(t, []evictedCall{{1, 1, EvictionReasonCapacity}}, calls)
+
+	calls = nil
+	assert.NoError(t, store.Evict())
+	assert.Equal(t, []evictedCall{{2, 2, EvictionReasonManual}}, calls)
+
+	calls = nil
+	assert.NoError(t, store.Replace([]interface{}{4}))
+	assert.Equal(t, []evictedCall{{3, 3, EvictionReasonReplaced}}, calls)
+
+	calls = nil
+	assert.NoError(t, store.Delete(4))
+	assert.Equal(t, []evictedCall{{4, 4, EvictionReasonDeleted}}, calls)
+
+	// Deleting a missing object removes nothing
+	calls = nil
+	assert.NoError(t, store.Delete(4))
+	assert.Empty(t, calls)
+}
+
+func TestEvictionCacheEvictionCounts(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[int, int](eviction.NewFIFO[int](2)),
+		WithClock[int, int](clock),
+		WithEvictionCounts[int, int]())
+
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+	assert.NoError(t, store.Delete(2))
+	assert.NoError(t, store.AddWithTTL(4, time.Second))
+	clock.Step(time.Second)
+	store.DeleteExpired()
+	assert.NoError(t, store.Replace([]interface{}{5}))
+
+	assert.Equal(t, map[EvictionReason]uint64{
+		EvictionReasonCapacity: 1,
+		EvictionReasonDeleted:  1,
+		EvictionReasonExpired:  1,
+		EvictionReasonReplaced: 1,
+	}, store.EvictionCounts())
+
+	// Counting is disabled by default
+	store = NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](2), make(Indexers[int]))
+	assert.NoError(t, store.Delete(1))
+	assert.Nil(t, store.EvictionCounts())
+}
+
+func TestEvictionReasonString(t *testing.T) {
+	assert.Equal(t, "Capacity", EvictionReasonCapacity.String())
+	assert.Equal(t, "Deleted", EvictionReasonDeleted.String())
+	assert.Equal(t, "EvictionReason(42)", EvictionReason(42).String())
+}
+
+func TestEvictionCachePin(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.True(t, store.Pin(1))
+	assert.False(t, store.Pin(5))
+
+	assert.NoError(t, store.Add(3))
+	assert.NoError(t, store.Add(4))
+	assert.ElementsMatch(t, []int{1, 4}, store.ListKeys())
+
+	assert.True(t, store.Unpin(1))
+	assert.NoError(t, store.Add(5))
+	assert.ElementsMatch(t, []int{4, 5}, store.ListKeys())
+}
+
+func TestEvictionCacheStats(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+	_, _, _ = store.GetByKey(3)
+	_, _, _ = store.GetByKey(1)
+	_, _, _ = store.GetByKey(2)
+
+	stats := store.Stats()
+	assert.Equal(t, uint64(3), stats.Puts)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+}
+
+func TestEvictionCacheAdmissionRejected(t *testing.T) {
+	var rejected []int
+	onEvicted := func(key int, obj interface{}, reason EvictionReason) {
+		if reason == EvictionReasonRejected {
+			rejected = append(rejected, key)
+		}
+	}
+	policy := eviction.NewComposite(eviction.NewTinyLFU[int](1024), eviction.NewLRU[int](2))
+	store := NewEvictionCache(testIntKeyFunc, policy, make(Indexers[int]), WithOnEvicted[int](onEvicted))
+
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	_, _, _ = store.GetByKey(1)
+	_, _, _ = store.GetByKey(2)
+
+	// A key seen once is not admitted over frequently used ones
+	assert.NoError(t, store.Add(3))
+	assert.ElementsMatch(t, []int{1, 2}, store.ListKeys())
+	assert.Equal(t, []int{3}, rejected)
+}
+
+func TestEvictionCacheReplaceRejected(t *testing.T) {
+	reasons := make(map[int]EvictionReason)
+	onEvicted := func(key int, obj interface{}, reason EvictionReason) {
+		reasons[key] = reason
+	}
+	policy := eviction.NewComposite(eviction.NewTinyLFU[int](1024), eviction.NewLRU[int](2))
+	victim := NewStore(testIntKeyFunc)
+	store := NewEvictionCache(testIntKeyFunc, policy, make(Indexers[int]),
+		WithOnEvicted[int](onEvicted), WithVictimCache[int, int](victim))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	for i := 0; i < 3; i++ {
+		_, _, _ = store.GetByKey(1)
+		_, _, _ = store.GetByKey(2)
+	}
+
+	// 3 is rejected in favour of the frequently used keys, rather than
+	// evicted, so it is not kept by the victim cache. The order is fixed so
+	// that 3 is the key offered last.
+	assert.NoError(t, store.ReplaceOrdered([]interface{}{1, 2, 3}))
+	assert.ElementsMatch(t, []int{1, 2}, store.ListKeys())
+	assert.Equal(t, map[int]EvictionReason{3: EvictionReasonRejected}, reasons)
+	assert.Zero(t, victim.Size())
+}
+
+func TestEvictionCachePriority(t *testing.T) {
+	// Even numbers are expensive to recompute
+	priority := func(obj interface{}) int {
+		return 1 - obj.(int)%2
+	}
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewPriority[int](3), make(Indexers[int]), WithPriority[int, int](priority))
+
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(4))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(3))
+	assert.NoError(t, store.Add(6))
+	assert.ElementsMatch(t, []int{2, 4, 6}, store.ListKeys())
+}
+
+func TestEvictionCacheReplaceOverCapacity(t *testing.T) {
+	var evicted []int
+	onEvicted := func(key int, obj interface{}, reason EvictionReason) {
+		assert.Equal(t, EvictionReasonCapacity, reason)
+		evicted = append(evicted, key)
+	}
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), make(Indexers[int]), WithOnEvicted[int](onEvicted))
+
+	assert.NoError(t, store.Replace([]interface{}{1, 2, 3, 4}))
+	assert.Equal(t, 2, store.Size())
+	assert.Len(t, evicted, 2)
+	assert.ElementsMatch(t, []int{1, 2, 3, 4}, append(evicted, store.ListKeys()...))
+}
+
+func TestEvictionCacheMaxWeight(t *testing.T) {
+	// Each object weighs as much as its value
+	weigher := func(obj interface{}) int64 {
+		return int64(obj.(int))
+	}
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](100), make(Indexers[int]), WithMaxWeight[int, int](10, weigher))
+
+	assert.NoError(t, store.Add(3))
+	assert.NoError(t, store.Add(4))
+	assert.NoError(t, store.Add(2))
+	assert.Equal(t, 3, store.Size())
+
+	// Adding 5 exceeds the weight, so the least recently used objects go
+	_, _, _ = store.GetByKey(3)
+	assert.NoError(t, store.Add(5))
+	assert.ElementsMatch(t, []int{2, 3, 5}, store.ListKeys())
+
+	// Deleting frees weight
+	assert.NoError(t, store.Delete(5))
+	assert.NoError(t, store.Add(7))
+	assert.ElementsMatch(t, []int{3, 7}, store.ListKeys())
+
+	// An object heavier than the maximum does not stay
+	assert.NoError(t, store.Add(11))
+	assert.Equal(t, 0, store.Size())
+
+	assert.NoError(t, store.Replace([]interface{}{1, 2, 3, 4, 5}))
+	total := 0
+	for _, key := range store.ListKeys() {
+		total += key
+	}
+	assert.LessOrEqual(t, total, 10)
+	assert.Greater(t, total, 5)
+}
+
+func TestEvictionCachePeek(t *testing.T) {
+	lru := eviction.NewLRU[int](2)
+	store := NewEvictionCache(testIntKeyFunc, lru, make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+
+	obj, exists := store.Peek(1)
+	assert.True(t, exists)
+	assert.Equal(t, 1, obj)
+	_, exists = store.Peek(5)
+	assert.False(t, exists)
+	assert.Zero(t, store.Stats().Hits)
+	assert.Zero(t, store.Stats().Misses)
+
+	// Peeking did not make 1 recently used, so it is still evicted first
+	assert.NoError(t, store.Add(3))
+	_, exists = store.Peek(1)
+	assert.False(t, exists)
+}
+
+func TestEvictionCacheContains(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	lru := eviction.NewLRU[int](2)
+	store := NewEvictionCache(testIntKeyFunc, lru, make(Indexers[int]), WithClock[int, int](clock))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+
+	assert.True(t, store.Contains(1))
+	assert.False(t, store.Contains(5))
+	assert.Zero(t, store.Stats().Hits)
+	assert.Zero(t, store.Stats().Misses)
+
+	// Checking 1 did not make it recently used, so it is still evicted first
+	assert.NoError(t, store.Add(3))
+	assert.False(t, store.Contains(1))
+	assert.True(t, store.Contains(2))
+
+	// Expired objects are not reported
+	assert.NoError(t, store.AddWithTTL(4, time.Minute))
+	assert.True(t, store.Contains(4))
+	clock.Step(time.Minute)
+	assert.False(t, store.Contains(4))
+}
+
+func TestEvictionCacheIndexTouch(t *testing.T) {
+	indexers := Indexers[string]{
+		"parity": func(obj interface{}) ([]string, error) {
+			if obj.(int)%2 == 0 {
+				return []string{"even"}, nil
+			}
+			return []string{"odd"}, nil
+		},
+	}
+
+	// By default querying 1 makes it recently used, so 2 is evicted
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), indexers)
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	keys, err := store.ListKeysByIndex("parity", "odd")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, keys)
+	assert.NoError(t, store.Add(3))
+	assert.ElementsMatch(t, []int{1, 3}, store.ListKeys())
+
+	// Without index touch the query leaves the recency order alone
+	store = NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), indexers, WithoutIndexTouch[string, int]())
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	keys, err = store.ListKeysByIndex("parity", "odd")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, keys)
+	assert.NoError(t, store.Add(3))
+	assert.ElementsMatch(t, []int{2, 3}, store.ListKeys())
+	assert.Zero(t, store.Stats().Hits)
+}
+
+func TestEvictionCacheListIndexValues(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), Indexers[int]{"value": nonNegativeIndexFunc})
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+
+	values, err := store.ListIndexValues("value")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{1, 2}, values)
+
+	// Listing values is not an access, so 1 is still evicted first
+	assert.NoError(t, store.Add(3))
+	values, err = store.ListIndexValues("value")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{2, 3}, values)
+}
+
+func TestEvictionCacheUniqueIndexer(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), Indexers[string]{}, WithClock[string, int](clock))
+	assert.NoError(t, store.AddUniqueIndexer("parity", func(obj interface{}) ([]string, error) {
+		if obj.(int)%2 == 0 {
+			return []string{"even"}, nil
+		}
+		return []string{"odd"}, nil
+	}))
+	assert.NoError(t, store.Add(1))
+	assert.ErrorIs(t, store.Add(3), ErrUniqueConflict)
+	assert.Equal(t, []int{1}, store.ListKeys())
+
+	// The conflicting object was not fed to the eviction policy
+	assert.NoError(t, store.AddWithTTL(2, time.Minute))
+	assert.ElementsMatch(t, []int{1, 2}, store.ListKeys())
+
+	obj, exists, err := store.GetByUniqueIndex("parity", "even")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 2, obj)
+
+	// Expired objects are not returned
+	clock.Step(time.Minute)
+	_, exists, err = store.GetByUniqueIndex("parity", "even")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestEvictionCacheOrderedIndexer(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](3), Indexers[int]{})
+	assert.NoError(t, store.AddOrderedIndexer("value", nonNegativeIndexFunc, cmp.Compare[int]))
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+
+	// 1 was evicted along with its index entry
+	items, err := store.ListByIndexLessThan("value", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{2}, items)
+	items, err = store.ListByIndexRange("value", 2, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{2, 3}, items)
+	items, err = store.ListByIndexGreaterThan("value", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{3, 4}, items)
+}
+
+func TestEvictionCacheListByIndexPrefix(t *testing.T) {
+	store := NewEvictionCache[string](testKeyFunc, eviction.NewLRU[string](2), Indexers[string]{})
+	assert.NoError(t, store.AddOrderedIndexer("path", func(obj interface{}) ([]string, error) {
+		return []string{obj.(string)}, nil
+	}, cmp.Compare[string]))
+	for _, path := range []string{"a/b", "a/c", "a/d"} {
+		assert.NoError(t, store.Add(path))
+	}
+
+	// a/b was evicted along with its index entry
+	items, err := ListByIndexPrefix(store, "path", "a/")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a/c", "a/d"}, items)
+}
+
+func TestEvictionCacheSetIndexNormalizer(t *testing.T) {
+	store := NewEvictionCache[string](testKeyFunc, eviction.NewLRU[string](2), Indexers[string]{
+		"name": func(obj interface{}) ([]string, error) { return []string{obj.(string)}, nil },
+	})
+	assert.NoError(t, store.SetIndexNormalizer("name", strings.ToUpper))
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Add(name))
+	}
+
+	// a was evicted along with its index entry
+	items, err := store.ListByIndex("name", "a")
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+	items, err = store.ListByIndex("name", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"b"}, items)
+	values, err := store.ListIndexValues("name")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"B", "C"}, values)
+}
+
+func TestEvictionCacheIndexStats(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](3), Indexers[int]{
+		"parity": func(obj interface{}) ([]int, error) { return []int{obj.(int) % 2}, nil },
+	})
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+
+	// 1 and 2 were evicted along with their index entries
+	stats, err := store.IndexStats("parity")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 1, 1: 2}, stats.Counts)
+	assert.Equal(t, 2, stats.MaxBucket)
+	assert.Equal(t, 1, stats.MaxBucketValue)
+	assert.Equal(t, 3, stats.Postings)
+}
+
+func TestEvictionCacheCountByIndex(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](3), Indexers[int]{
+		"parity": func(obj interface{}) ([]int, error) { return []int{obj.(int) % 2}, nil },
+	})
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+
+	// 1 and 2 were evicted
+	count, err := store.CountByIndex("parity", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = store.CountByIndex("missing", 1)
+	assert.Error(t, err)
+}
+
+func TestEvictionCacheGroupByIndex(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](3), Indexers[int]{
+		"parity": func(obj interface{}) ([]int, error) { return []int{obj.(int) % 2}, nil },
+	})
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+
+	// 1 and 2 were evicted
+	groups, err := store.GroupByIndex("parity")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4}, groups[0])
+	assert.ElementsMatch(t, []int{3, 5}, groups[1])
+	counts, err := store.GroupCountsByIndex("parity")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 1, 1: 2}, counts)
+}
+
+func TestEvictionCacheOnIndexChange(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), Indexers[int]{
+		"parity": func(obj interface{}) ([]int, error) { return []int{obj.(int) % 2}, nil },
+	})
+	var changes []IndexChange[int, int]
+	assert.NoError(t, store.OnIndexChange("parity", func(change IndexChange[int, int]) {
+		changes = append(changes, change)
+	}))
+	assert.Error(t, store.OnIndexChange("missing", func(IndexChange[int, int]) {}))
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+
+	// Evicting 1 makes it leave its value
+	assert.ElementsMatch(t, []IndexChange[int, int]{
+		{Index: "parity", Key: 1, Value: 1, Entered: true},
+		{Index: "parity", Key: 2, Value: 0, Entered: true},
+		{Index: "parity", Key: 3, Value: 1, Entered: true},
+		{Index: "parity", Key: 1, Value: 1},
+	}, changes)
+
+	// Rebuilding the index with a normalizer moves the keys whose values change
+	changes = nil
+	assert.NoError(t, store.SetIndexNormalizer("parity", func(v int) int { return 1 - v }))
+	assert.ElementsMatch(t, []IndexChange[int, int]{
+		{Index: "parity", Key: 2, Value: 0},
+		{Index: "parity", Key: 3, Value: 1},
+		{Index: "parity", Key: 2, Value: 1, Entered: true},
+		{Index: "parity", Key: 3, Value: 0, Entered: true},
+	}, changes)
+}
+
+func TestEvictionCacheOnIndexChangeRejected(t *testing.T) {
+	policy := eviction.NewComposite(eviction.NewTinyLFU[int](1024), eviction.NewLRU[int](2))
+	store := NewEvictionCache(testIntKeyFunc, policy, Indexers[int]{
+		"parity": func(obj interface{}) ([]int, error) { return []int{obj.(int) % 2}, nil },
+	})
+	var changes []IndexChange[int, int]
+	assert.NoError(t, store.OnIndexChange("parity", func(change IndexChange[int, int]) {
+		// Hooks run once the lock is released, so they may call back
+		if _, exists := store.Peek(change.Key); exists == change.Entered {
+			changes = append(changes, change)
+		}
+	}))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	_, _, _ = store.GetByKey(1)
+	_, _, _ = store.GetByKey(2)
+
+	// 3 is rejected by the admission policy, so it never enters its value
+	assert.NoError(t, store.Add(3))
+	assert.Equal(t, []IndexChange[int, int]{
+		{Index: "parity", Key: 1, Value: 1, Entered: true},
+		{Index: "parity", Key: 2, Value: 0, Entered: true},
+	}, changes)
+}
+
+func TestEvictionCacheRemoveIndexer(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), Indexers[int]{"value": nonNegativeIndexFunc})
+	assert.NoError(t, store.Add(1))
+	assert.Len(t, store.GetIndexers(), 1)
+	assert.NoError(t, store.RemoveIndexer("value"))
+	assert.Empty(t, store.GetIndexers())
+	_, err := store.ListByIndex("value", 1)
+	assert.Error(t, err)
+	assert.Error(t, store.RemoveIndexer("value"))
+
+	// Objects the removed indexer rejected can be stored
+	assert.NoError(t, store.Add(-1))
+}
+
+func TestEvictionCacheEvictOne(t *testing.T) {
+	type item struct {
+		id   int
+		name string
+	}
+	keyFunc := func(obj interface{}) (int, error) {
+		return obj.(*item).id, nil
+	}
+	store := NewEvictionCache(keyFunc, eviction.NewFIFO[int](2), make(Indexers[int]))
+	assert.NoError(t, store.Add(&item{1, "one"}))
+	assert.NoError(t, store.Add(&item{2, "two"}))
+
+	key, obj, ok := store.EvictOne()
+	assert.True(t, ok)
+	assert.Equal(t, 1, key)
+	assert.Equal(t, &item{1, "one"}, obj)
+	assert.Equal(t, 1, store.Size())
+
+	_, _, ok = store.EvictOne()
+	assert.True(t, ok)
+	key, obj, ok = store.EvictOne()
+	assert.False(t, ok)
+	assert.Zero(t, key)
+	assert.Nil(t, obj)
+}
+
+func TestEvictionCacheBufferedAccesses(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+
+	// Uncontended reads overflowing the buffer are applied, not dropped
+	for i := 0; i < 3*accessBufferSize; i++ {
+		_, _, _ = store.GetByKey(1)
+	}
+	assert.Equal(t, uint64(3*accessBufferSize), store.Stats().Hits)
+
+	// Buffered accesses reach the policy before the next write
+	_, _, _ = store.GetByKey(2)
+	_, _, _ = store.GetByKey(1)
+	assert.NoError(t, store.Add(3))
+	assert.ElementsMatch(t, []int{1, 3}, store.ListKeys())
+}
+
+func TestEvictionCacheConcurrentReads(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](64), make(Indexers[int]))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if i%10 == g {
+					assert.NoError(t, store.Add(i%100))
+				}
+				_, _, _ = store.GetByKey(i % 100)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := store.Stats()
+	assert.LessOrEqual(t, store.Size(), 64)
+	assert.LessOrEqual(t, stats.Hits+stats.Misses, uint64(8000))
+}
+
+func TestEvictionCacheTTL(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	var expired []int
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](10), make(Indexers[int]),
+		WithClock[int, int](clock),
+		WithOnEvicted[int, int](func(key int, obj interface{}, reason EvictionReason) {
+			assert.Equal(t, EvictionReasonExpired, reason)
+			expired = append(expired, key)
+		}))
+	assert.NoError(t, store.AddWithTTL(1, time.Minute))
+	assert.NoError(t, store.AddWithTTL(2, time.Hour))
+	assert.NoError(t, store.Add(3))
+
+	clock.Step(time.Minute)
+	_, exists, _ := store.GetByKey(1)
+	assert.False(t, exists)
+	_, exists = store.Peek(1)
+	assert.False(t, exists)
+	_, exists, _ = store.GetByKey(2)
+	assert.True(t, exists)
+
+	// Expired objects are left out of reads until they are deleted
+	assert.Equal(t, 2, store.Size())
+	assert.ElementsMatch(t, []int{2, 3}, store.ListKeys())
+	assert.Equal(t, []int{1}, store.DeleteExpired())
+	assert.Equal(t, []int{1}, expired)
+	assert.Equal(t, 2, store.Size())
+	assert.Equal(t, 2, store.Stats().Size)
+
+	// Adding without a TTL clears the expiration
+	assert.NoError(t, store.Add(2))
+	clock.Step(time.Hour)
+	assert.Empty(t, store.DeleteExpired())
+	assert.ElementsMatch(t, []int{2, 3}, store.ListKeys())
+}
+
+func TestEvictionCacheExpiredReads(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	tokenizer := NewTokenizer()
+	indexFunc := TokenIndexFunc(tokenizer, func(obj interface{}) string { return obj.(string) })
+	store := NewEvictionCacheWithOptions(testKeyFunc, WithClock[string, string](clock),
+		WithIndexers[string, string](Indexers[string]{"text": indexFunc}))
+	assert.NoError(t, store.AddWithTTL("red apple", time.Minute))
+	assert.NoError(t, store.Add("green apple"))
+	clock.Step(time.Minute)
+
+	// Every read leaves out the expired object before it is deleted
+	assert.Equal(t, []interface{}{"green apple"}, store.List())
+	assert.Equal(t, []string{"green apple"}, store.ListKeys())
+	assert.Equal(t, 1, store.Size())
+	objs, err := store.ListByIndex("text", "apple")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"green apple"}, objs)
+	keys, err := store.ListKeysByIndex("text", "apple")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"green apple"}, keys)
+	count, err := store.CountByIndex("text", "red")
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+	groups, err := store.GroupByIndex("text")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"green": {"green apple"}, "apple": {"green apple"}}, groups)
+	counts, err := store.GroupCountsByIndex("text")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"green": 1, "apple": 1}, counts)
+	values, err := store.ListIndexValues("text")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"green", "apple"}, values)
+	objs, err = store.ListByIndexValues("text", "red", "green")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"green apple"}, objs)
+	matches, err := ListSearchByTokens(store, "text", tokenizer, "red apple")
+	assert.NoError(t, err)
+	assert.Equal(t, []TokenMatch[interface{}]{{"green apple", 1}}, matches)
+}
+
+func TestEvictionCacheGetWithExpiration(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](10), make(Indexers[int]),
+		WithClock[int, int](clock))
+	assert.NoError(t, store.AddWithTTL(1, time.Minute))
+	assert.NoError(t, store.Add(2))
+
+	obj, expiresAt, exists := store.GetWithExpiration(1)
+	assert.True(t, exists)
+	assert.Equal(t, 1, obj)
+	assert.Equal(t, time.Unix(0, 0).Add(time.Minute), expiresAt)
+
+	// Objects without a TTL never expire
+	_, expiresAt, exists = store.GetWithExpiration(2)
+	assert.True(t, exists)
+	assert.True(t, expiresAt.IsZero())
+
+	clock.Step(time.Minute)
+	_, _, exists = store.GetWithExpiration(1)
+	assert.False(t, exists)
+	_, _, exists = store.GetWithExpiration(3)
+	assert.False(t, exists)
+}
+
+func TestEvictionCacheJanitor(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](10), make(Indexers[int]),
+		WithClock[int, int](clock), WithJanitor[int, int](time.Minute))
+	defer store.Close()
+	assert.NoError(t, store.AddWithTTL(1, time.Second))
+	assert.NoError(t, store.Add(2))
+
+	assert.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+	clock.Step(time.Minute)
+	assert.Eventually(t, func() bool {
+		return store.Size() == 1
+	}, time.Second, time.Millisecond)
+
+	store.Close()
+	store.Close()
+}
+
+func TestNewEvictionCacheWithOptions(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	var evicted []int
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[string, int](eviction.NewFIFO[int](2)),
+		WithIndexers[string, int](Indexers[string]{
+			"parity": func(obj interface{}) ([]string, error) {
+				return []string{strconv.Itoa(obj.(int) % 2)}, nil
+			},
+		}),
+		WithOnEvicted[string, int](func(key int, obj interface{}, reason EvictionReason) {
+			evicted = append(evicted, key)
+		}),
+		WithClock[string, int](clock),
+		WithTTL[string, int](time.Minute),
+	)
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+	assert.Equal(t, []int{1}, evicted)
+
+	keys, err := store.ListKeysByIndex("parity", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, keys)
+
+	// Add uses the default TTL, AddWithTTL overrides it
+	assert.NoError(t, store.AddWithTTL(2, time.Hour))
+	clock.Step(time.Minute)
+	assert.Equal(t, []int{3}, store.DeleteExpired())
+	assert.Equal(t, []int{2}, store.ListKeys())
+}
+
+func TestNewEvictionCacheWithOptionsDefaults(t *testing.T) {
+	store := NewEvictionCacheWithOptions[string](testIntKeyFunc)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+	assert.Equal(t, 100, store.Size())
+	assert.Error(t, store.Evict())
+}
+
+func TestEvictionCacheAdmission(t *testing.T) {
+	// Only admit objects requested at least twice
+	sketch := eviction.NewFrequencySketch[int](1024)
+	var rejected []int
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[int, int](eviction.NewLRU[int](10)),
+		WithAdmission[int, int](func(key int, obj interface{}) bool {
+			sketch.Increment(key)
+			return sketch.Estimate(key) >= 2
+		}),
+		WithOnEvicted[int, int](func(key int, obj interface{}, reason EvictionReason) {
+			assert.Equal(t, EvictionReasonRejected, reason)
+			rejected = append(rejected, key)
+		}),
+	)
+
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.Equal(t, 0, store.Size())
+	assert.Equal(t, []int{1, 2}, rejected)
+
+	assert.NoError(t, store.Add(1))
+	_, exists, _ := store.GetByKey(1)
+	assert.True(t, exists)
+	assert.Equal(t, 1, store.Stats().Size)
+
+	// Updating a stored object bypasses admission
+	sketch.Reset()
+	sketch.Reset()
+	assert.NoError(t, store.Update(1))
+	assert.Equal(t, []int{1, 2}, rejected)
+}
+
+func TestEvictionCacheWarm(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](3), make(Indexers[int]))
+	assert.NoError(t, store.Warm([]interface{}{1, 2, 3}, WarmOrderMostRecentLast))
+	assert.NoError(t, store.Add(4))
+	assert.ElementsMatch(t, []int{2, 3, 4}, store.ListKeys())
+
+	store = NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](3), make(Indexers[int]))
+	assert.NoError(t, store.Warm([]interface{}{1, 2, 3}, WarmOrderMostRecentFirst))
+	assert.NoError(t, store.Add(4))
+	assert.ElementsMatch(t, []int{1, 2, 4}, store.ListKeys())
+
+	// Warming more objects than fit keeps the most recent ones
+	assert.NoError(t, store.Warm([]interface{}{5, 6, 7, 8}, WarmOrderMostRecentFirst))
+	assert.ElementsMatch(t, []int{5, 6, 7}, store.ListKeys())
+}
+
+func TestEvictionCacheWarmBypassesAdmission(t *testing.T) {
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithAdmission[int, int](func(key int, obj interface{}) bool { return false }))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Warm([]interface{}{2, 3}, WarmOrderMostRecentLast))
+	assert.ElementsMatch(t, []int{2, 3}, store.ListKeys())
+}
+
+func TestEvictionCacheVictim(t *testing.T) {
+	victim := NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](2), make(Indexers[int]))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[int, int](eviction.NewLRU[int](2)),
+		WithVictimCache[int, int](victim))
+
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+	assert.Equal(t, []int{1}, victim.ListKeys())
+
+	// A miss finds 1 in the victim cache and promotes it, demoting 2
+	obj, exists, err := store.GetByKey(1)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 1, obj)
+	assert.ElementsMatch(t, []int{1, 3}, store.ListKeys())
+	assert.Equal(t, []int{2}, victim.ListKeys())
+
+	// Deleting removes the object from both tiers
+	assert.NoError(t, store.Delete(2))
+	assert.Empty(t, victim.ListKeys())
+	_, exists, _ = store.GetByKey(2)
+	assert.False(t, exists)
+
+	// Manual evictions are not demoted
+	assert.NoError(t, store.Evict())
+	assert.Empty(t, victim.ListKeys())
+	assert.Equal(t, 1, store.Size())
+}
+
+func TestEvictionCacheGetOrAdd(t *testing.T) {
+	type item struct {
+		id   int
+		name string
+	}
+	keyFunc := func(obj interface{}) (int, error) {
+		return obj.(*item).id, nil
+	}
+	store := NewEvictionCache(keyFunc, eviction.NewLRU[int](10), make(Indexers[int]))
+
+	actual, loaded, err := store.GetOrAdd(&item{1, "first"})
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, &item{1, "first"}, actual)
+
+	actual, loaded, err = store.GetOrAdd(&item{1, "second"})
+	assert.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, &item{1, "first"}, actual)
+	assert.Equal(t, uint64(1), store.Stats().Hits)
+
+	// Concurrent callers all get the object added by the first one
+	var wg sync.WaitGroup
+	results := make([]interface{}, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = store.GetOrAdd(&item{2, strconv.Itoa(i)})
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range results {
+		assert.Same(t, results[0], result)
+	}
+}
+
+func TestEvictionCacheGetMany(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](3), make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+
+	items, missing := store.GetMany([]int{1, 2, 4})
+	assert.Equal(t, map[int]interface{}{1: 1, 2: 2}, items)
+	assert.Equal(t, []int{4}, missing)
+	stats := store.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+
+	// 1 and 2 were touched, so 3 is the least recently used
+	assert.NoError(t, store.Add(4))
+	assert.ElementsMatch(t, []int{1, 2, 4}, store.ListKeys())
+}
+
+func TestEvictionCacheReplaceOrdered(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](4), make(Indexers[int]))
+	assert.NoError(t, store.Add(9))
+
+	assert.NoError(t, store.ReplaceOrdered([]interface{}{1, 2, 3, 1, 4}))
+	assert.ElementsMatch(t, []int{1, 2, 3, 4}, store.ListKeys())
+
+	// 2 is the least recent, then 3 since 1 occurs again after it
+	assert.Equal(t, []int{2, 3}, store.EvictN(2))
+
+	// Objects beyond the capacity are dropped from the front
+	assert.NoError(t, store.ReplaceOrdered([]interface{}{5, 6, 7, 8, 9, 10}))
+	assert.ElementsMatch(t, []int{7, 8, 9, 10}, store.ListKeys())
+}
+
+func TestEvictionCacheCompareAndSwap(t *testing.T) {
+	type version struct {
+		id  int
+		rev int
+	}
+	keyFunc := func(obj interface{}) (int, error) {
+		return obj.(*version).id, nil
+	}
+	var reasons []EvictionReason
+	store := NewEvictionCache(keyFunc, eviction.NewLRU[int](2), make(Indexers[int]),
+		WithOnEvicted[int, int](func(key int, obj interface{}, reason EvictionReason) {
+			reasons = append(reasons, reason)
+		}))
+	v1 := &version{1, 1}
+	v2 := &version{1, 2}
+	assert.NoError(t, store.Add(v1))
+
+	swapped, err := store.CompareAndSwap(v2, &version{1, 3})
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	swapped, err = store.CompareAndSwap(v1, v2)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+	obj, _, _ := store.GetByKey(1)
+	assert.Same(t, v2, obj)
+
+	deleted, err := store.CompareAndDelete(v1)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = store.CompareAndDelete(v2)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, 0, store.Stats().Size)
+	assert.Equal(t, []EvictionReason{EvictionReasonDeleted}, reasons)
+}
+
+func TestEvictionCacheBatch(t *testing.T) {
+	var reasons []EvictionReason
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](3), make(Indexers[int]),
+		WithOnEvicted[int, int](func(key int, obj interface{}, reason EvictionReason) {
+			reasons = append(reasons, reason)
+		}))
+
+	assert.Nil(t, store.AddAll([]interface{}{1, 2, 3, 4}))
+	assert.ElementsMatch(t, []int{2, 3, 4}, store.ListKeys())
+	assert.Nil(t, store.UpdateAll([]interface{}{2, 5}))
+	assert.ElementsMatch(t, []int{3, 4, 5}, store.ListKeys())
+
+	assert.Nil(t, store.DeleteAll([]interface{}{3, 4, 9}))
+	assert.Equal(t, []int{5}, store.ListKeys())
+	assert.Equal(t, 1, store.Stats().Size)
+	assert.Equal(t, []EvictionReason{
+		EvictionReasonCapacity, EvictionReasonCapacity, EvictionReasonDeleted, EvictionReasonDeleted,
+	}, reasons)
+}
+
+func TestEvictionCacheRange(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[int, int](eviction.NewLRU[int](3)),
+		WithClock[int, int](clock))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.AddWithTTL(3, time.Second))
+	clock.Step(time.Second)
+
+	var keys []int
+	store.Range(func(key int, obj interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.ElementsMatch(t, []int{1, 2}, keys)
+	keys = keys[:0]
+	for key := range store.All() {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []int{1, 2}, keys)
+
+	// Ranging is not an access, so 1 is still the least recently used
+	assert.Zero(t, store.Stats().Hits)
+	assert.Equal(t, []int{1}, store.EvictN(1))
+}
+
+func TestEvictionCacheListFiltered(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc, WithClock[int, int](clock))
+	assert.Nil(t, store.AddAll([]interface{}{1, 2, 3}))
+	assert.NoError(t, store.AddWithTTL(4, time.Second))
+	clock.Step(time.Second)
+	even := func(obj interface{}) bool {
+		return obj.(int)%2 == 0
+	}
+
+	assert.Equal(t, []interface{}{2}, store.ListFiltered(even))
+	assert.Equal(t, []int{2}, store.ListKeysFiltered(even))
+}
+
+func TestEvictionCacheReplaceWithDiff(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[int, int](eviction.NewFIFO[int](3)),
+		WithClock[int, int](clock))
+	assert.Nil(t, store.AddAll([]interface{}{1, 2}))
+	assert.NoError(t, store.AddWithTTL(3, time.Second))
+	clock.Step(time.Second)
+
+	added, updated, removed, err := store.ReplaceWithDiff([]interface{}{2, 3, 4, 5})
+
+	// 3 had expired, so it is added again, and 2 is evicted to fit the others
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 4, 5}, added)
+	assert.Empty(t, updated)
+	assert.ElementsMatch(t, []int{1, 2}, removed)
+	assert.ElementsMatch(t, []int{3, 4, 5}, store.ListKeys())
+}
+
+func TestEvictionCacheIndexErrors(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), Indexers[int]{"value": nonNegativeIndexFunc})
+	assert.NoError(t, store.Add(1))
+	assert.ErrorAs(t, store.Add(-1), new(IndexError))
+
+	// The failed object did not reach the policy, so nothing was evicted
+	assert.NoError(t, store.Add(2))
+	assert.ElementsMatch(t, []int{1, 2}, store.ListKeys())
+
+	assert.Error(t, store.Replace([]interface{}{3, -4}))
+	assert.Equal(t, []int{3}, store.ListKeys())
+	items, err := store.ListByIndex("value", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{3}, items)
+}
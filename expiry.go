@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap holds the expiration time of keys in a binary heap ordered by
+// time, so that the expired keys are found without scanning the others.
+type expiryHeap[T comparable] struct {
+	entries []expiryEntry[T]
+	// index holds the position of every key in entries
+	index map[T]int
+}
+
+// expiryEntry is the expiration time of a key.
+type expiryEntry[T comparable] struct {
+	key T
+	at  time.Time
+}
+
+// newExpiryHeap creates an empty expiryHeap.
+func newExpiryHeap[T comparable]() *expiryHeap[T] {
+	return &expiryHeap[T]{index: make(map[T]int)}
+}
+
+// get returns the expiration time of key, and false if it has none.
+func (h *expiryHeap[T]) get(key T) (time.Time, bool) {
+	i, ok := h.index[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	return h.entries[i].at, true
+}
+
+// set makes key expire at the given time, moving it if it had another one.
+func (h *expiryHeap[T]) set(key T, at time.Time) {
+	if i, ok := h.index[key]; ok {
+		h.entries[i].at = at
+		heap.Fix(h, i)
+		return
+	}
+	heap.Push(h, expiryEntry[T]{key: key, at: at})
+}
+
+// delete removes the expiration time of key, if any.
+func (h *expiryHeap[T]) delete(key T) {
+	if i, ok := h.index[key]; ok {
+		heap.Remove(h, i)
+	}
+}
+
+// popExpired removes and returns the keys expiring no later than now, the
+// earliest first.
+func (h *expiryHeap[T]) popExpired(now time.Time) []T {
+	var keys []T
+	for len(h.entries) > 0 && !now.Before(h.entries[0].at) {
+		keys = append(keys, heap.Pop(h).(expiryEntry[T]).key)
+	}
+	return keys
+}
+
+// due reports whether a key expires no later than now.
+func (h *expiryHeap[T]) due(now time.Time) bool {
+	return len(h.entries) > 0 && !now.Before(h.entries[0].at)
+}
+
+func (h *expiryHeap[T]) Len() int {
+	return len(h.entries)
+}
+
+func (h *expiryHeap[T]) Less(i, j int) bool {
+	return h.entries[i].at.Before(h.entries[j].at)
+}
+
+func (h *expiryHeap[T]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].key] = i
+	h.index[h.entries[j].key] = j
+}
+
+func (h *expiryHeap[T]) Push(x interface{}) {
+	entry := x.(expiryEntry[T])
+	h.index[entry.key] = len(h.entries)
+	h.entries = append(h.entries, entry)
+}
+
+func (h *expiryHeap[T]) Pop() interface{} {
+	n := len(h.entries) - 1
+	entry := h.entries[n]
+	h.entries[n] = expiryEntry[T]{}
+	h.entries = h.entries[:n]
+	delete(h.index, entry.key)
+	return entry
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiryHeap(t *testing.T) {
+	start := time.Unix(0, 0)
+	h := newExpiryHeap[string]()
+	assert.False(t, h.due(start))
+
+	h.set("a", start.Add(3*time.Second))
+	h.set("b", start.Add(1*time.Second))
+	h.set("c", start.Add(2*time.Second))
+	h.set("d", start.Add(4*time.Second))
+
+	// Moving and deleting keys keeps the heap ordered
+	h.set("b", start.Add(5*time.Second))
+	h.delete("d")
+	h.delete("missing")
+	at, ok := h.get("b")
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(5*time.Second), at)
+	_, ok = h.get("d")
+	assert.False(t, ok)
+
+	assert.False(t, h.due(start.Add(time.Second)))
+	assert.True(t, h.due(start.Add(2*time.Second)))
+	assert.Equal(t, []string{"c", "a"}, h.popExpired(start.Add(4*time.Second)))
+	assert.Empty(t, h.popExpired(start.Add(4*time.Second)))
+	_, ok = h.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"b"}, h.popExpired(start.Add(time.Minute)))
+	assert.Zero(t, h.Len())
+}
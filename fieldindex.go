@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// IndexTag is the struct tag naming the index of a field for IndexersByTag.
+const IndexTag = "cacheindex"
+
+// IndexBy returns an IndexFunc indexing the objects of type O by the value
+// get returns, failing on objects of another type. It spares writing the
+// type assertion of every indexer:
+//
+//	store.AddIndexer("age", cache.IndexBy(func(u *User) int { return u.Age }))
+func IndexBy[O any, K comparable](get func(obj O) K) IndexFunc[K] {
+	return func(obj interface{}) ([]K, error) {
+		o, ok := obj.(O)
+		if !ok {
+			return nil, fmt.Errorf("object of type %T is not a %s", obj, reflect.TypeFor[O]())
+		}
+		return []K{get(o)}, nil
+	}
+}
+
+// IndexByField returns an IndexFunc indexing structs, or pointers to them,
+// by the exported field at fieldPath, whose names are separated by dots to
+// reach the fields of nested structs, such as "Address.City". Pointers are
+// followed, and objects with a nil pointer on the path, or in the field, have
+// no value. Those whose field is a slice or an array have one value per
+// element. It fails on objects without such a field, and on values that are
+// not of type K. The fields are looked up once per struct type.
+func IndexByField[K comparable, V any](fieldPath string) ValueIndexFunc[K, V] {
+	p := &structPath{path: fieldPath, names: strings.Split(fieldPath, ".")}
+	return func(obj V) ([]K, error) {
+		return fieldValues[K](obj, p)
+	}
+}
+
+// structPath is a field path split into the names of its fields, which keeps
+// the fields it looked up by the struct type holding them.
+type structPath struct {
+	path  string
+	names []string
+	// fields maps a structStep to the index of its field for FieldByIndex,
+	// nil if the struct has no such exported field
+	fields sync.Map
+}
+
+// structStep is a name of a structPath, by its position, looked up in a
+// struct type.
+type structStep struct {
+	t reflect.Type
+	i int
+}
+
+// field returns the index of the exported field of the struct type t named by
+// the i-th name of the path, and whether there is such a field.
+func (p *structPath) field(t reflect.Type, i int) ([]int, bool) {
+	step := structStep{t, i}
+	if cached, ok := p.fields.Load(step); ok {
+		index := cached.([]int)
+		return index, index != nil
+	}
+	var index []int
+	if field, found := t.FieldByName(p.names[i]); found && field.IsExported() {
+		index = field.Index
+	}
+	p.fields.Store(step, index)
+	return index, index != nil
+}
+
+// IndexersByTag returns an indexer for every exported field of the struct
+// type of sample, or of the struct it points to, tagged with
+// `cacheindex:"name"`, indexing the objects by the field like IndexByField
+// under that name. Since sample only provides the type, V is usually
+// interface{} for the indexers of an IndexedStore:
+//
+//	indexers, err := cache.IndexersByTag[any, interface{}](&User{})
+func IndexersByTag[K comparable, V any](sample V) (ValueIndexers[K, V], error) {
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sample of type %T is not a struct", sample)
+	}
+	indexers := make(ValueIndexers[K, V])
+	for _, field := range reflect.VisibleFields(t) {
+		indexName, tagged := field.Tag.Lookup(IndexTag)
+		if !tagged || !field.IsExported() {
+			continue
+		}
+		if indexName == "" {
+			return nil, fmt.Errorf("field %s of %s has an empty %s tag", field.Name, t, IndexTag)
+		}
+		if _, exists := indexers[indexName]; exists {
+			return nil, fmt.Errorf("indexer conflict: %s", indexName)
+		}
+		indexers[indexName] = IndexByField[K, V](field.Name)
+	}
+	return indexers, nil
+}
+
+// fieldValues returns the values of the field of obj reached through p.
+func fieldValues[K comparable](obj interface{}, p *structPath) ([]K, error) {
+	fieldPath := p.path
+	v := reflect.ValueOf(obj)
+	for i := range p.names {
+		if v = indirect(v); !v.IsValid() {
+			return nil, nil
+		}
+		if v.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field %s of %T: %s is not a struct", fieldPath, obj, v.Type())
+		}
+		index, found := p.field(v.Type(), i)
+		if !found {
+			return nil, fmt.Errorf("%T has no exported field %s", obj, fieldPath)
+		}
+		var err error
+		if v, err = v.FieldByIndexErr(index); err != nil {
+			// An embedded struct on the way is a nil pointer
+			return nil, nil
+		}
+	}
+	if v = indirect(v); !v.IsValid() {
+		return nil, nil
+	}
+	if kind := v.Kind(); kind == reflect.Slice || kind == reflect.Array {
+		values := make([]K, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			value, err := fieldValue[K](indirect(v.Index(i)), fieldPath, obj)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	value, err := fieldValue[K](v, fieldPath, obj)
+	if err != nil {
+		return nil, err
+	}
+	return []K{value}, nil
+}
+
+// fieldValue returns v as a K.
+func fieldValue[K comparable](v reflect.Value, fieldPath string, obj interface{}) (K, error) {
+	var zero K
+	if !v.IsValid() {
+		return zero, fmt.Errorf("field %s of %T holds a nil value", fieldPath, obj)
+	}
+	if !v.CanInterface() {
+		// Such as a field promoted from an unexported embedded struct
+		return zero, fmt.Errorf("field %s of %T cannot be read", fieldPath, obj)
+	}
+	value, ok := v.Interface().(K)
+	if !ok {
+		return zero, fmt.Errorf("field %s of %T: %s is not a %s", fieldPath, obj, v.Type(), reflect.TypeFor[K]())
+	}
+	return value, nil
+}
+
+// indirect follows the pointers and interfaces from v, returning the zero
+// Value if one of them is nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type indexedAddress struct {
+	City string
+}
+
+type indexedPerson struct {
+	Name    string `cacheindex:"name"`
+	Age     int    `cacheindex:"age"`
+	Tags    []string
+	Address *indexedAddress
+	secret  string `cacheindex:"secret"`
+}
+
+func TestIndexBy(t *testing.T) {
+	indexFunc := IndexBy(func(p *indexedPerson) int { return p.Age })
+
+	values, err := indexFunc(&indexedPerson{Age: 30})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{30}, values)
+	_, err = indexFunc(indexedPerson{Age: 30})
+	assert.Error(t, err)
+}
+
+func TestIndexByField(t *testing.T) {
+	alice := &indexedPerson{Name: "alice", Tags: []string{"admin", "dev"}, Address: &indexedAddress{"Paris"}}
+
+	values, err := IndexByField[string, interface{}]("Name")(alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, values)
+	values, err = IndexByField[string, interface{}]("Tags")(*alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "dev"}, values)
+	values, err = IndexByField[string, *indexedPerson]("Address.City")(alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Paris"}, values)
+
+	// A nil pointer on the path leaves the object out of the index
+	values, err = IndexByField[string, interface{}]("Address.City")(&indexedPerson{})
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+
+	for _, fieldPath := range []string{"Missing", "secret", "Name.First"} {
+		_, err = IndexByField[string, interface{}](fieldPath)(alice)
+		assert.Error(t, err, fieldPath)
+	}
+	_, err = IndexByField[string, interface{}]("Age")(alice)
+	assert.Error(t, err)
+	_, err = IndexByField[string, interface{}]("Name")("alice")
+	assert.Error(t, err)
+}
+
+func TestIndexByFieldTypes(t *testing.T) {
+	type team struct {
+		ID   int
+		Name string
+	}
+	indexFunc := IndexByField[string, interface{}]("Name")
+
+	// The field is looked up in every type the index func sees, and then
+	// reused for the following objects of the same type
+	for i := 0; i < 2; i++ {
+		values, err := indexFunc(&indexedPerson{Name: "alice"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alice"}, values)
+		values, err = indexFunc(team{ID: 1, Name: "core"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"core"}, values)
+		_, err = indexFunc(indexedAddress{"Paris"})
+		assert.Error(t, err)
+	}
+}
+
+func TestIndexersByTag(t *testing.T) {
+	indexers, err := IndexersByTag[any, interface{}](&indexedPerson{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"name", "age"}, slices.Collect(maps.Keys(indexers)))
+
+	store := NewIndexer[any](func(obj interface{}) (string, error) {
+		return obj.(*indexedPerson).Name, nil
+	})
+	assert.NoError(t, store.AddIndexers(indexers))
+	alice, bob := &indexedPerson{Name: "alice", Age: 30}, &indexedPerson{Name: "bob", Age: 40}
+	assert.NoError(t, store.Add(alice))
+	assert.NoError(t, store.Add(bob))
+	items, err := store.ListByIndex("age", 40)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{bob}, items)
+
+	_, err = IndexersByTag[any, interface{}]("alice")
+	assert.Error(t, err)
+	type conflicting struct {
+		A int `cacheindex:"same"`
+		B int `cacheindex:"same"`
+	}
+	_, err = IndexersByTag[any](conflicting{})
+	assert.Error(t, err)
+}
@@ -1,6 +1,9 @@
 module github.com/liuxinbot/cache
 
-go 1.22.0
+// Go 1.24 is the first release with generic type aliases, which IndexFunc
+// and Indexers are, and with maphash.Comparable, which hashes the keys of the
+// tiered store, the paging order and the frequency sketch.
+go 1.24.0
 
 require github.com/stretchr/testify v1.9.0
 
@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// Heap is a Store that orders its objects by a comparison on them, so that
+// the least one can be looked up and removed first, as in a priority queue.
+type Heap[T comparable] interface {
+	Store[T]
+
+	// Peek returns the least object without removing it, and false if the
+	// heap is empty.
+	Peek() (interface{}, bool)
+
+	// Pop removes and returns the least object, and false if the heap is
+	// empty. Unlike Queue.Pop, it does not wait for an object to be added.
+	Pop() (interface{}, bool)
+}
+
+// NewHeap creates a new Heap whose objects are ordered by lessFunc, which
+// reports whether lhs must be popped before rhs. Updating an object moves it
+// to its new position.
+func NewHeap[T comparable](keyFunc KeyFunc[T], lessFunc func(lhs, rhs interface{}) bool, opts ...StoreOption) Heap[T] {
+	return &heapStore[T]{
+		cache: newCache[any](keyFunc, opts),
+		items: heapItems[T]{
+			less:  lessFunc,
+			index: map[T]int{},
+		},
+	}
+}
+
+// heapStore implements Heap by keeping the objects of a cache in a binary
+// heap.
+type heapStore[T comparable] struct {
+	*cache[any, T]
+	// mu is held for writing by mutations, so that the cache and items change
+	// together, and for reading by Peek
+	mu    sync.RWMutex
+	items heapItems[T]
+}
+
+var _ Heap[string] = &heapStore[string]{}
+
+// Add inserts an item into the store.
+func (h *heapStore[T]) Add(obj interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.cache.Add(obj)
+	h.syncObjs(obj)
+	return err
+}
+
+// Update sets an item in the store to its updated state.
+func (h *heapStore[T]) Update(obj interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.cache.Update(obj)
+	h.syncObjs(obj)
+	return err
+}
+
+// Delete removes an item from the store.
+func (h *heapStore[T]) Delete(obj interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.cache.Delete(obj)
+	h.syncObjs(obj)
+	return err
+}
+
+// AddAll inserts items into the store in a single batch.
+func (h *heapStore[T]) AddAll(objs []interface{}) []error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	errs := h.cache.AddAll(objs)
+	h.syncObjs(objs...)
+	return errs
+}
+
+// UpdateAll sets items in the store to their updated state in a single batch.
+func (h *heapStore[T]) UpdateAll(objs []interface{}) []error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	errs := h.cache.UpdateAll(objs)
+	h.syncObjs(objs...)
+	return errs
+}
+
+// DeleteAll removes items from the store in a single batch.
+func (h *heapStore[T]) DeleteAll(objs []interface{}) []error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	errs := h.cache.DeleteAll(objs)
+	h.syncObjs(objs...)
+	return errs
+}
+
+// CompareAndSwap replaces old by new if old is the stored item.
+func (h *heapStore[T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	swapped, err := h.cache.CompareAndSwap(old, new)
+	h.syncObjs(new)
+	return swapped, err
+}
+
+// CompareAndDelete removes old if it is the stored item.
+func (h *heapStore[T]) CompareAndDelete(old interface{}) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	deleted, err := h.cache.CompareAndDelete(old)
+	h.syncObjs(old)
+	return deleted, err
+}
+
+// Replace will delete the contents of the store, using instead the given list.
+func (h *heapStore[T]) Replace(list []interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.cache.Replace(list)
+	h.rebuild()
+	return err
+}
+
+// ReplaceWithDiff replaces the contents of the store and reports what changed.
+func (h *heapStore[T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	added, updated, removed, err := h.cache.ReplaceWithDiff(list)
+	h.rebuild()
+	return added, updated, removed, err
+}
+
+// Txn applies the changes buffered by fn atomically.
+func (h *heapStore[T]) Txn(fn func(tx Txn[T]) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var changed []interface{}
+	err := h.cache.Txn(func(tx Txn[T]) error {
+		return fn(recordingTxn[T]{tx, &changed})
+	})
+	h.syncObjs(changed...)
+	return err
+}
+
+// Peek returns the least item without removing it.
+func (h *heapStore[T]) Peek() (interface{}, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.items.entries) == 0 {
+		return nil, false
+	}
+	return h.copy(h.items.entries[0].obj), true
+}
+
+// Pop removes and returns the least item.
+func (h *heapStore[T]) Pop() (interface{}, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items.entries) == 0 {
+		return nil, false
+	}
+	entry := heap.Pop(&h.items).(heapEntry[T])
+	if err := h.cache.Delete(entry.obj); err != nil {
+		heap.Push(&h.items, entry)
+		return nil, false
+	}
+	return h.copy(entry.obj), true
+}
+
+// syncObjs pushes, moves or removes the entries of objs so that the items
+// match the cache. The caller must hold h.mu.
+func (h *heapStore[T]) syncObjs(objs ...interface{}) {
+	for _, obj := range objs {
+		key, err := h.keyFunc(obj)
+		if err != nil {
+			continue
+		}
+		stored, exists := h.cache.store.Get(key)
+		i, queued := h.items.index[key]
+		switch {
+		case exists && queued:
+			h.items.entries[i].obj = stored
+			heap.Fix(&h.items, i)
+		case exists:
+			heap.Push(&h.items, heapEntry[T]{key: key, obj: stored})
+		case queued:
+			heap.Remove(&h.items, i)
+		}
+	}
+}
+
+// rebuild refills the items with the stored objects. The caller must hold h.mu.
+func (h *heapStore[T]) rebuild() {
+	h.items.entries = h.items.entries[:0]
+	clear(h.items.index)
+	for key, obj := range h.cache.store.All() {
+		h.items.index[key] = len(h.items.entries)
+		h.items.entries = append(h.items.entries, heapEntry[T]{key: key, obj: obj})
+	}
+	heap.Init(&h.items)
+}
+
+// heapEntry is an object of a heapStore along with its key.
+type heapEntry[T comparable] struct {
+	key T
+	obj interface{}
+}
+
+// heapItems implements heap.Interface over the entries of a heapStore,
+// tracking the position of every key so that entries can be moved or removed.
+type heapItems[T comparable] struct {
+	entries []heapEntry[T]
+	index   map[T]int
+	less    func(lhs, rhs interface{}) bool
+}
+
+func (h *heapItems[T]) Len() int {
+	return len(h.entries)
+}
+
+func (h *heapItems[T]) Less(i, j int) bool {
+	return h.less(h.entries[i].obj, h.entries[j].obj)
+}
+
+func (h *heapItems[T]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].key] = i
+	h.index[h.entries[j].key] = j
+}
+
+func (h *heapItems[T]) Push(x interface{}) {
+	entry := x.(heapEntry[T])
+	h.index[entry.key] = len(h.entries)
+	h.entries = append(h.entries, entry)
+}
+
+func (h *heapItems[T]) Pop() interface{} {
+	n := len(h.entries) - 1
+	entry := h.entries[n]
+	h.entries[n] = heapEntry[T]{}
+	h.entries = h.entries[:n]
+	delete(h.index, entry.key)
+	return entry
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lessPairValue(lhs, rhs interface{}) bool {
+	return lhs.(testPair).value < rhs.(testPair).value
+}
+
+func TestHeap(t *testing.T) {
+	h := NewHeap(testPairKeyFunc, lessPairValue)
+	_, ok := h.Peek()
+	assert.False(t, ok)
+
+	assert.Nil(t, h.AddAll([]interface{}{testPair{"a", 3}, testPair{"b", 1}, testPair{"c", 2}}))
+	obj, ok := h.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, testPair{"b", 1}, obj)
+	assert.Equal(t, 3, h.Size())
+
+	// Updates and deletions move the objects
+	assert.NoError(t, h.Update(testPair{"a", 0}))
+	assert.NoError(t, h.Delete(testPair{"c", 2}))
+
+	obj, ok = h.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, testPair{"a", 0}, obj)
+	obj, ok = h.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, testPair{"b", 1}, obj)
+	_, ok = h.Pop()
+	assert.False(t, ok)
+	assert.Equal(t, 0, h.Size())
+}
+
+func TestHeapReplaceAndTxn(t *testing.T) {
+	h := NewHeap(testPairKeyFunc, lessPairValue)
+	assert.NoError(t, h.Add(testPair{"a", 1}))
+	assert.NoError(t, h.Replace([]interface{}{testPair{"b", 5}, testPair{"c", 4}}))
+
+	obj, _ := h.Peek()
+	assert.Equal(t, testPair{"c", 4}, obj)
+
+	assert.NoError(t, h.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Delete(testPair{"c", 4}))
+		return tx.Add(testPair{"d", 2})
+	}))
+	obj, _ = h.Pop()
+	assert.Equal(t, testPair{"d", 2}, obj)
+	obj, _ = h.Pop()
+	assert.Equal(t, testPair{"b", 5}, obj)
+}
+
+func TestHeapLimits(t *testing.T) {
+	h := NewHeap(testPairKeyFunc, lessPairValue, WithMaxEntries(1))
+	assert.NoError(t, h.Add(testPair{"a", 2}))
+	assert.ErrorIs(t, h.Add(testPair{"b", 1}), ErrStoreFull)
+
+	// Rejected objects are not popped, and popping frees room
+	obj, _ := h.Peek()
+	assert.Equal(t, testPair{"a", 2}, obj)
+	_, ok := h.Pop()
+	assert.True(t, ok)
+	assert.NoError(t, h.Add(testPair{"b", 1}))
+}
@@ -1,7 +1,12 @@
 package cache
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/liuxinbot/cache/sets"
 )
@@ -16,38 +21,230 @@ type IndexedStore[K, T comparable] interface {
 	// ListByIndex returns objects whose indexed values for the specified index include the given indexed value.
 	ListByIndex(indexName string, indexedValue K) ([]interface{}, error)
 
+	// CountByIndex returns the number of objects whose indexed values for the specified index include the given indexed value, without listing them.
+	CountByIndex(indexName string, indexedValue K) (int, error)
+
+	// ListByIndexValues returns objects whose indexed values for the specified index include any of the given indexed values, each of them once.
+	ListByIndexValues(indexName string, indexedValues ...K) ([]interface{}, error)
+
+	// ListByIndexes returns objects whose indexed values include the given indexed value for every index in queries, such as sex=woman and age=20.
+	ListByIndexes(queries map[string]K) ([]interface{}, error)
+
+	// Query returns a Query over the stored objects, such as Query().Where("age", 20).And("sex", "woman").Limit(50).Do().
+	Query() *Query[K, T, interface{}]
+
+	// Reindex rebuilds the specified index from the stored objects, such as after fixing its IndexFunc, leaving it unchanged if that fails.
+	Reindex(indexName string) error
+
+	// ListIndexValues returns the distinct indexed values of the stored objects for the specified index.
+	ListIndexValues(indexName string) ([]K, error)
+
+	// IndexStats returns the number of keys held by each value of the specified index, along with their totals.
+	IndexStats(indexName string) (IndexStats[K], error)
+
+	// GroupByIndex returns the storage keys of the objects held by each indexed value of the specified index.
+	GroupByIndex(indexName string) (map[K][]T, error)
+
+	// GroupCountsByIndex returns the number of objects held by each indexed value of the specified index, such as facet counts.
+	GroupCountsByIndex(indexName string) (map[K]int, error)
+
 	// AddIndexer add new indexer.
 	AddIndexer(indexName string, indexFunc IndexFunc[K]) error
 
 	// AddIndexers adds more indexers to this store.
 	AddIndexers(newIndexers Indexers[K]) error
+
+	// RemoveIndexer removes the named indexer along with its index, failing if
+	// there is no such indexer.
+	RemoveIndexer(indexName string) error
+
+	// GetIndexers returns a copy of the indexers of this store.
+	GetIndexers() Indexers[K]
+
+	// AddUniqueIndexer adds an indexer whose indexed values each identify at
+	// most one object. Storing an object under another key with a value
+	// already held fails with an IndexError wrapping ErrUniqueConflict, as
+	// does adding the indexer if the stored objects already share a value.
+	AddUniqueIndexer(indexName string, indexFunc IndexFunc[K]) error
+
+	// GetByUniqueIndex returns the object holding indexedValue in the
+	// specified unique index, failing if the index is not unique.
+	GetByUniqueIndex(indexName string, indexedValue K) (interface{}, bool, error)
+
+	// AddOrderedIndexer adds an indexer whose values are kept ordered by
+	// compare, such as cmp.Compare, so that objects can be listed by ranges of
+	// values.
+	AddOrderedIndexer(indexName string, indexFunc IndexFunc[K], compare func(a, b K) int) error
+
+	// ListByIndexRange returns the objects holding a value in [from, to) in the specified ordered index, in ascending order of their values.
+	ListByIndexRange(indexName string, from, to K) ([]interface{}, error)
+
+	// ListByIndexGreaterThan returns the objects holding a value greater than indexedValue in the specified ordered index, like ListByIndexRange.
+	ListByIndexGreaterThan(indexName string, indexedValue K) ([]interface{}, error)
+
+	// ListByIndexLessThan returns the objects holding a value less than indexedValue in the specified ordered index, like ListByIndexRange.
+	ListByIndexLessThan(indexName string, indexedValue K) ([]interface{}, error)
+
+	// SetIndexNormalizer makes normalize map the values of the specified index, both those of the stored objects and those queried, rebuilding the index.
+	SetIndexNormalizer(indexName string, normalize func(K) K) error
+
+	// OnIndexChange registers hook to be invoked whenever a key enters or leaves a value of the specified index.
+	OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error
 }
 
 // IndexFunc is a function type that calculates a set of indexed values for an object.
-type IndexFunc[K comparable] func(obj interface{}) ([]K, error)
+type IndexFunc[K comparable] = ValueIndexFunc[K, interface{}]
+
+// ValueIndexFunc is an IndexFunc of the objects of type V, for stores typed
+// over their objects.
+type ValueIndexFunc[K comparable, V any] func(obj V) ([]K, error)
 
 // Index maps the indexed value to a set of keys in the store that match on that value.
 type Index[K, T comparable] map[K]sets.Set[T]
 
 // Indexers maps an index name to an IndexFunc.
-type Indexers[K comparable] map[string]IndexFunc[K]
+type Indexers[K comparable] = ValueIndexers[K, interface{}]
+
+// ValueIndexers maps an index name to a ValueIndexFunc.
+type ValueIndexers[K comparable, V any] map[string]ValueIndexFunc[K, V]
 
 // Indexes maps an index name to an Index.
 type Indexes[K, T comparable] map[string]Index[K, T]
 
+// ErrUniqueConflict is wrapped by the IndexError returned when an object would
+// share a value of a unique index with an object stored under another key.
+var ErrUniqueConflict = errors.New("unique index conflict")
+
+// IndexError reports that an IndexFunc failed to compute the index values of
+// the object stored under Key, which was then not stored.
+type IndexError struct {
+	Name string
+	Key  interface{}
+	Err  error
+}
+
+// Error returns a human-readable description of the IndexError.
+func (e IndexError) Error() string {
+	return fmt.Sprintf("unable to calculate index entry for key %v on index %q: %v", e.Key, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e IndexError) Unwrap() error {
+	return e.Err
+}
+
+// indexErrorKeys returns the keys of the IndexErrors found in err, which may
+// join several of them.
+func indexErrorKeys[T comparable](err error) map[T]struct{} {
+	keys := make(map[T]struct{})
+	var walk func(err error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case IndexError:
+			if key, ok := e.Key.(T); ok {
+				keys[key] = struct{}{}
+			}
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		}
+	}
+	walk(err)
+	return keys
+}
+
+// IndexChange is a key entering or leaving a value of an index, such as the
+// key of an object whose status moves from "pending" to "ready", which
+// leaves "pending" and enters "ready".
+type IndexChange[K, T comparable] struct {
+	Index   string
+	Key     T
+	Value   K
+	Entered bool // Whether the key entered the value rather than left it.
+}
+
+// IndexChangeFunc is invoked with the changes of an index it was registered
+// for by OnIndexChange.
+type IndexChangeFunc[K, T comparable] func(change IndexChange[K, T])
+
 // storeIndex implements the indexing functionality for a ThreadSafeStore.
-type storeIndex[K, T comparable] struct {
-	indexers Indexers[K]
+type storeIndex[K, T comparable, V any] struct {
+	indexers ValueIndexers[K, V]
 	indices  Indexes[K, T]
+	// strict makes a failing IndexFunc panic rather than return an error
+	strict bool
+	// lazy makes addIndexer and addIndexers register their indexers in
+	// unbuilt rather than index the objects
+	lazy bool
+	// unbuilt holds the indexers registered lazily by name, until their index
+	// is first queried and built, see threadSafeMap.buildIndex
+	unbuilt map[string]ValueIndexFunc[K, V]
+	// unique holds the names of the indexers whose values identify at most
+	// one key
+	unique sets.Set[string]
+	// sorted holds the values of the ordered indices by name, in order
+	sorted map[string]*skipList[K]
+	// normalizers map the values of the indices by name, both those returned
+	// by their indexers and those queried
+	normalizers map[string]func(K) K
+	// pending holds the indices being built in the background by name
+	pending map[string]*pendingIndex[K, T, V]
+	// scratch is reused by updateIndices, which runs under the write lock
+	scratch []indexUpdate[K]
+	// hooks are invoked by updateIndices with the changes of the indices by
+	// name. They are not cloned.
+	hooks map[string][]IndexChangeFunc[K, T]
+}
+
+// indexUpdate holds the new values of an object in the named index.
+type indexUpdate[K comparable] struct {
+	name   string
+	values []K
 }
 
 // reset clears all indices.
-func (si *storeIndex[K, T]) reset() {
+func (si *storeIndex[K, T, V]) reset() {
 	si.indices = Indexes[K, T]{}
+	for name, sorted := range si.sorted {
+		si.sorted[name] = newSkipListFunc(sorted.compare)
+	}
+	for _, p := range si.pending {
+		p.index = Index[K, T]{}
+	}
+}
+
+// clone returns a copy of the indexers and indices that shares no sets with si.
+func (si *storeIndex[K, T, V]) clone() *storeIndex[K, T, V] {
+	indices := make(Indexes[K, T], len(si.indices))
+	for name, index := range si.indices {
+		copied := make(Index[K, T], len(index))
+		for indexValue, keySet := range index {
+			copied[indexValue] = sets.NewSet(keySet.UnsortedList()...)
+		}
+		indices[name] = copied
+	}
+	var sorted map[string]*skipList[K]
+	for name, values := range si.sorted {
+		if sorted == nil {
+			sorted = make(map[string]*skipList[K], len(si.sorted))
+		}
+		sorted[name] = sortValues(indices[name], values.compare)
+	}
+	return &storeIndex[K, T, V]{
+		indexers:    maps.Clone(si.indexers),
+		indices:     indices,
+		strict:      si.strict,
+		lazy:        si.lazy,
+		unbuilt:     maps.Clone(si.unbuilt),
+		unique:      maps.Clone(si.unique),
+		sorted:      sorted,
+		normalizers: maps.Clone(si.normalizers),
+	}
 }
 
 // getKeysFromIndex retrieves the set of keys from the specified index that match the object.
-func (si *storeIndex[K, T]) getKeysFromIndex(indexName string, obj interface{}) (sets.Set[T], error) {
+func (si *storeIndex[K, T, V]) getKeysFromIndex(indexName string, obj V) (sets.Set[T], error) {
 	indexFunc, exists := si.indexers[indexName]
 	if !exists {
 		return nil, fmt.Errorf("index with name %s does not exist", indexName)
@@ -57,6 +254,7 @@ func (si *storeIndex[K, T]) getKeysFromIndex(indexName string, obj interface{})
 	if err != nil {
 		return nil, err
 	}
+	indexValues = si.normalizeAll(indexName, indexValues)
 	index := si.indices[indexName]
 
 	var keySet sets.Set[T]
@@ -74,99 +272,891 @@ func (si *storeIndex[K, T]) getKeysFromIndex(indexName string, obj interface{})
 }
 
 // getKeysByIndex retrieves the set of keys from the specified index that match the indexed value.
-func (si *storeIndex[K, T]) getKeysByIndex(indexName string, indexedValue K) (sets.Set[T], error) {
+func (si *storeIndex[K, T, V]) getKeysByIndex(indexName string, indexedValue K) (sets.Set[T], error) {
 	_, exists := si.indexers[indexName]
 	if !exists {
 		return nil, fmt.Errorf("index with name %s does not exist", indexName)
 	}
 	index := si.indices[indexName]
-	return index[indexedValue], nil
+	return index[si.normalize(indexName, indexedValue)], nil
+}
+
+// getKeysByIndexValues retrieves the set of keys from the specified index that
+// match any of the indexed values.
+func (si *storeIndex[K, T, V]) getKeysByIndexValues(indexName string, indexedValues []K) (sets.Set[T], error) {
+	if _, exists := si.indexers[indexName]; !exists {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	index := si.indices[indexName]
+	if len(indexedValues) == 1 {
+		return index[si.normalize(indexName, indexedValues[0])], nil
+	}
+	keySet := sets.NewSet[T]()
+	for _, indexedValue := range indexedValues {
+		for key := range index[si.normalize(indexName, indexedValue)] {
+			keySet.Insert(key)
+		}
+	}
+	return keySet, nil
+}
+
+// countKeysByIndexValues returns how many of the distinct indexedValues each
+// key holds in the specified index, leaving out the keys holding none.
+func (si *storeIndex[K, T, V]) countKeysByIndexValues(indexName string, indexedValues []K) (map[T]int, error) {
+	if _, exists := si.indexers[indexName]; !exists {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	index := si.indices[indexName]
+	counts := make(map[T]int)
+	seen := sets.NewSet[K]()
+	for _, indexedValue := range indexedValues {
+		indexedValue = si.normalize(indexName, indexedValue)
+		if seen.Has(indexedValue) {
+			continue
+		}
+		seen.Insert(indexedValue)
+		for key := range index[indexedValue] {
+			counts[key]++
+		}
+	}
+	return counts, nil
+}
+
+// getKeysByIndexes retrieves the set of keys matching the indexed value of
+// every index in queries, intersecting their sets from the smallest one. It
+// matches no key if queries is empty.
+func (si *storeIndex[K, T, V]) getKeysByIndexes(queries map[string]K) (sets.Set[T], error) {
+	keySets := make([]sets.Set[T], 0, len(queries))
+	for indexName, indexedValue := range queries {
+		keySet, err := si.getKeysByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		keySets = append(keySets, keySet)
+	}
+	matching := sets.NewSet[T]()
+	if len(keySets) == 0 {
+		return matching, nil
+	}
+	smallest := slices.MinFunc(keySets, func(a, b sets.Set[T]) int {
+		return cmp.Compare(a.Len(), b.Len())
+	})
+	for key := range smallest {
+		if !slices.ContainsFunc(keySets, func(keySet sets.Set[T]) bool { return !keySet.Has(key) }) {
+			matching.Insert(key)
+		}
+	}
+	return matching, nil
+}
+
+// listIndexValues returns the values present in the specified index.
+func (si *storeIndex[K, T, V]) listIndexValues(indexName string) ([]K, error) {
+	if _, exists := si.indexers[indexName]; !exists {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	return slices.Collect(maps.Keys(si.indices[indexName])), nil
+}
+
+// valueCounts returns the number of keys held by each value of the specified
+// index.
+func (si *storeIndex[K, T, V]) valueCounts(indexName string) (map[K]int, error) {
+	if _, exists := si.indexers[indexName]; !exists {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	index := si.indices[indexName]
+	counts := make(map[K]int, len(index))
+	for indexValue, keySet := range index {
+		counts[indexValue] = keySet.Len()
+	}
+	return counts, nil
+}
+
+// groupKeys returns the keys held by each value of the specified index.
+func (si *storeIndex[K, T, V]) groupKeys(indexName string) (map[K][]T, error) {
+	if _, exists := si.indexers[indexName]; !exists {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	index := si.indices[indexName]
+	groups := make(map[K][]T, len(index))
+	for indexValue, keySet := range index {
+		groups[indexValue] = keySet.UnsortedList()
+	}
+	return groups, nil
 }
 
 // addIndexer adds new indexer to the store.
-func (si *storeIndex[K, T]) addIndexer(indexName string, indexFunc IndexFunc[K]) error {
+func (si *storeIndex[K, T, V]) addIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
 	if _, exists := si.indexers[indexName]; exists {
 		return fmt.Errorf("indexer conflict: %s", indexName)
 	}
+	if _, exists := si.pending[indexName]; exists {
+		return fmt.Errorf("indexer conflict: %s", indexName)
+	}
+	if _, exists := si.unbuilt[indexName]; exists {
+		return fmt.Errorf("indexer conflict: %s", indexName)
+	}
 	si.indexers[indexName] = indexFunc
 	return nil
 }
 
+// addUnbuilt registers newIndexers without indexing anything, failing if any
+// of them conflicts with an existing, pending or unbuilt indexer.
+func (si *storeIndex[K, T, V]) addUnbuilt(newIndexers ValueIndexers[K, V]) error {
+	if err := si.checkConflicts(newIndexers); err != nil {
+		return err
+	}
+	if si.unbuilt == nil {
+		si.unbuilt = make(map[string]ValueIndexFunc[K, V], len(newIndexers))
+	}
+	maps.Copy(si.unbuilt, newIndexers)
+	return nil
+}
+
+// isUnbuilt reports whether the named indexer was registered lazily and its
+// index not built yet.
+func (si *storeIndex[K, T, V]) isUnbuilt(indexName string) bool {
+	_, unbuilt := si.unbuilt[indexName]
+	return unbuilt
+}
+
+// addUniqueIndexer adds new unique indexer to the store.
+func (si *storeIndex[K, T, V]) addUniqueIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
+	if err := si.addIndexer(indexName, indexFunc); err != nil {
+		return err
+	}
+	if si.unique == nil {
+		si.unique = sets.NewSet[string]()
+	}
+	si.unique.Insert(indexName)
+	return nil
+}
+
+// addOrderedIndexer adds new indexer to the store, keeping its values ordered
+// by compare.
+func (si *storeIndex[K, T, V]) addOrderedIndexer(indexName string, indexFunc ValueIndexFunc[K, V], compare func(a, b K) int) error {
+	if err := si.addIndexer(indexName, indexFunc); err != nil {
+		return err
+	}
+	if si.sorted == nil {
+		si.sorted = make(map[string]*skipList[K])
+	}
+	si.sorted[indexName] = newSkipListFunc(compare)
+	return nil
+}
+
+// indexRange bounds the values of a query on an ordered index. A nil bound is
+// unbounded.
+type indexRange[K any] struct {
+	from          *K
+	fromInclusive bool
+	// to is excluded from the range
+	to *K
+	// while, if not nil, ends the range at the first value it rejects, given
+	// from as normalized by the index, which must then be set
+	while func(from, indexValue K) bool
+}
+
+// indexRanger is implemented by the stores of this package answering queries
+// on the ranges of their ordered indices.
+type indexRanger[K comparable, V any] interface {
+	byRange(indexName string, r indexRange[K]) ([]V, error)
+}
+
+// ByIndexPrefix returns the objects of store whose values in the specified
+// ordered index start with prefix, in ascending order of their values. The
+// index must order its values bytewise, as cmp.Compare does, and the prefix
+// is normalized like them.
+func ByIndexPrefix[T comparable, V any](store ThreadSafeStore[string, T, V], indexName, prefix string) ([]V, error) {
+	return byIndexPrefix[V](store, indexName, prefix)
+}
+
+// ListByIndexPrefix returns the objects of store whose values in the specified
+// ordered index start with prefix, like ByIndexPrefix.
+func ListByIndexPrefix[T comparable](store IndexedStore[string, T], indexName, prefix string) ([]interface{}, error) {
+	return byIndexPrefix[interface{}](store, indexName, prefix)
+}
+
+// byIndexPrefix queries the range of values starting at prefix, up to the
+// first one not starting with it.
+func byIndexPrefix[V any](store interface{}, indexName, prefix string) ([]V, error) {
+	q, ok := store.(indexRanger[string, V])
+	if !ok {
+		return nil, fmt.Errorf("prefix queries on %T: %w", store, errors.ErrUnsupported)
+	}
+	return q.byRange(indexName, indexRange[string]{
+		from:          &prefix,
+		fromInclusive: true,
+		while:         func(from, indexValue string) bool { return strings.HasPrefix(indexValue, from) },
+	})
+}
+
+// getKeysByRange returns the keys whose values in the specified ordered index
+// are within r, along with the value each of them was found under, in
+// ascending order of the values. A key holding several values within r is
+// returned once, under the lowest.
+func (si *storeIndex[K, T, V]) getKeysByRange(indexName string, r indexRange[K]) ([]T, []K, error) {
+	if _, exists := si.indexers[indexName]; !exists {
+		return nil, nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	sorted := si.sorted[indexName]
+	if sorted == nil {
+		return nil, nil, fmt.Errorf("index with name %s is not ordered", indexName)
+	}
+	if r.from != nil {
+		from := si.normalize(indexName, *r.from)
+		r.from = &from
+	}
+	if r.to != nil {
+		to := si.normalize(indexName, *r.to)
+		r.to = &to
+	}
+	node := sorted.first()
+	if r.from != nil {
+		node = sorted.seek(*r.from)
+		if node != nil && !r.fromInclusive && sorted.compare(node.key, *r.from) == 0 {
+			node = node.next[0]
+		}
+	}
+
+	index := si.indices[indexName]
+	var keys []T
+	var values []K
+	seen := sets.NewSet[T]()
+	for ; node != nil; node = node.next[0] {
+		if r.to != nil && sorted.compare(node.key, *r.to) >= 0 {
+			break
+		}
+		if r.while != nil && !r.while(*r.from, node.key) {
+			break
+		}
+		for key := range index[node.key] {
+			if !seen.Has(key) {
+				seen.Insert(key)
+				keys = append(keys, key)
+				values = append(values, node.key)
+			}
+		}
+	}
+	return keys, values, nil
+}
+
+// getKeyByUniqueIndex returns the key holding indexedValue in the specified
+// unique index.
+func (si *storeIndex[K, T, V]) getKeyByUniqueIndex(indexName string, indexedValue K) (T, bool, error) {
+	var zero T
+	if _, exists := si.indexers[indexName]; !exists {
+		return zero, false, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	if !si.unique.Has(indexName) {
+		return zero, false, fmt.Errorf("index with name %s is not unique", indexName)
+	}
+	for key := range si.indices[indexName][si.normalize(indexName, indexedValue)] {
+		return key, true, nil
+	}
+	return zero, false, nil
+}
+
+// setNormalizer makes normalize map the values of the named index, or stops
+// normalizing them if it is nil, and returns the previous normalizer. The
+// index must then be rebuilt, see threadSafeMap.setIndexNormalizer.
+func (si *storeIndex[K, T, V]) setNormalizer(indexName string, normalize func(K) K) (func(K) K, error) {
+	if _, exists := si.indexers[indexName]; !exists && !si.isUnbuilt(indexName) {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	previous := si.normalizers[indexName]
+	if normalize == nil {
+		delete(si.normalizers, indexName)
+	} else {
+		if si.normalizers == nil {
+			si.normalizers = make(map[string]func(K) K)
+		}
+		si.normalizers[indexName] = normalize
+	}
+	return previous, nil
+}
+
+// builtIndex holds an index along with its ordered values, nil unless it is
+// ordered.
+type builtIndex[K, T comparable] struct {
+	index  Index[K, T]
+	sorted *skipList[K]
+}
+
+// clearIndex removes every key from the named index, and returns what it held
+// so that restoreIndex can put it back.
+func (si *storeIndex[K, T, V]) clearIndex(indexName string) builtIndex[K, T] {
+	previous := builtIndex[K, T]{index: si.indices[indexName], sorted: si.sorted[indexName]}
+	si.indices[indexName] = Index[K, T]{}
+	if previous.sorted != nil {
+		si.sorted[indexName] = newSkipListFunc(previous.sorted.compare)
+	}
+	return previous
+}
+
+// restoreIndex puts back the named index returned by clearIndex, unless it is
+// the zero builtIndex.
+func (si *storeIndex[K, T, V]) restoreIndex(indexName string, built builtIndex[K, T]) {
+	if built.index == nil {
+		return
+	}
+	si.indices[indexName] = built.index
+	if built.sorted != nil {
+		si.sorted[indexName] = built.sorted
+	}
+}
+
+// normalize maps indexValue with the normalizer of the named index, if any.
+func (si *storeIndex[K, T, V]) normalize(indexName string, indexValue K) K {
+	if normalize := si.normalizers[indexName]; normalize != nil {
+		return normalize(indexValue)
+	}
+	return indexValue
+}
+
+// normalizeAll maps indexValues with the normalizer of the named index, if
+// any. The values returned by an IndexFunc may be shared with the object, so
+// they are copied rather than normalized in place.
+func (si *storeIndex[K, T, V]) normalizeAll(indexName string, indexValues []K) []K {
+	normalize := si.normalizers[indexName]
+	if normalize == nil {
+		return indexValues
+	}
+	normalized := make([]K, len(indexValues))
+	for i, indexValue := range indexValues {
+		normalized[i] = normalize(indexValue)
+	}
+	return normalized
+}
+
+// checkUnique fails if a unique index holds any of indexValues for a key
+// other than key.
+func (si *storeIndex[K, T, V]) checkUnique(name string, indexValues []K, key T) error {
+	if !si.unique.Has(name) {
+		return nil
+	}
+	index := si.indices[name]
+	for _, indexValue := range indexValues {
+		for existing := range index[indexValue] {
+			if existing != key {
+				return uniqueConflict(name, indexValue, key, existing)
+			}
+		}
+	}
+	return nil
+}
+
+// uniqueConflict returns the IndexError of key failing to take indexValue of
+// a unique index from existing.
+func uniqueConflict[K, T comparable](name string, indexValue K, key, existing T) error {
+	err := fmt.Errorf("%w: value %v is held by key %v", ErrUniqueConflict, indexValue, existing)
+	return IndexError{Name: name, Key: key, Err: err}
+}
+
 // addIndexers adds new indexers to the store.
-func (si *storeIndex[K, T]) addIndexers(newIndexers Indexers[K]) error {
-	existingKeys := sets.KeySet[string](si.indexers)
+func (si *storeIndex[K, T, V]) addIndexers(newIndexers ValueIndexers[K, V]) error {
+	if err := si.checkConflicts(newIndexers); err != nil {
+		return err
+	}
+
+	for name, indexer := range newIndexers {
+		si.indexers[name] = indexer
+	}
+	return nil
+}
+
+// checkConflicts fails if any of newIndexers has the name of an existing,
+// pending or unbuilt indexer.
+func (si *storeIndex[K, T, V]) checkConflicts(newIndexers ValueIndexers[K, V]) error {
+	existingKeys := sets.KeySet[string](si.indexers).Union(sets.KeySet[string](si.pending)).Union(sets.KeySet[string](si.unbuilt))
 	newKeys := sets.KeySet[string](newIndexers)
 
 	if existingKeys.HasAny(newKeys.UnsortedList()...) {
 		return fmt.Errorf("indexer conflict: %v", existingKeys.Intersection(newKeys))
 	}
+	return nil
+}
 
-	for name, indexer := range newIndexers {
-		si.indexers[name] = indexer
+// removeIndexer removes the named indexer and its index, failing if there is
+// no such indexer. Indexers still being built by AddIndexersAsync are not
+// found.
+func (si *storeIndex[K, T, V]) removeIndexer(indexName string) error {
+	if _, exists := si.indexers[indexName]; !exists && !si.isUnbuilt(indexName) {
+		return fmt.Errorf("index with name %s does not exist", indexName)
 	}
+	si.removeIndexers(indexName)
 	return nil
 }
 
+// unbuild drops the index of the named indexer and registers indexFunc as
+// unbuilt again, keeping its hooks and normalizer.
+func (si *storeIndex[K, T, V]) unbuild(indexName string, indexFunc ValueIndexFunc[K, V]) {
+	delete(si.indexers, indexName)
+	delete(si.indices, indexName)
+	if si.unbuilt == nil {
+		si.unbuilt = make(map[string]ValueIndexFunc[K, V])
+	}
+	si.unbuilt[indexName] = indexFunc
+}
+
+// removeIndexers removes the named indexers and their indices.
+func (si *storeIndex[K, T, V]) removeIndexers(names ...string) {
+	for _, name := range names {
+		delete(si.indexers, name)
+		delete(si.indices, name)
+		si.unique.Delete(name)
+		delete(si.sorted, name)
+		delete(si.normalizers, name)
+		delete(si.unbuilt, name)
+		delete(si.hooks, name)
+	}
+}
+
+// addHook registers hook for the changes of the named index.
+func (si *storeIndex[K, T, V]) addHook(indexName string, hook IndexChangeFunc[K, T]) error {
+	if _, exists := si.indexers[indexName]; !exists && !si.isUnbuilt(indexName) {
+		return fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	if si.hooks == nil {
+		si.hooks = make(map[string][]IndexChangeFunc[K, T])
+	}
+	si.hooks[indexName] = append(si.hooks[indexName], hook)
+	return nil
+}
+
+// notifyChanges invokes hooks with key leaving the values of oldIndexValues
+// missing from newIndexValues, then entering those of newIndexValues missing
+// from oldIndexValues. Values may repeat, and are reported once.
+func notifyChanges[K, T comparable](hooks []IndexChangeFunc[K, T], name string, oldIndexValues, newIndexValues []K, key T) {
+	notify := func(indexValues, others []K, entered bool) {
+		for i, indexValue := range indexValues {
+			if slices.Contains(others, indexValue) || slices.Contains(indexValues[:i], indexValue) {
+				continue
+			}
+			notifyChange(hooks, IndexChange[K, T]{Index: name, Key: key, Value: indexValue, Entered: entered})
+		}
+	}
+	notify(oldIndexValues, newIndexValues, false)
+	notify(newIndexValues, oldIndexValues, true)
+}
+
+// notifyRebuilt invokes the hooks of every index with the keys that left and
+// then entered its values since previous, the indices before they were
+// rebuilt from scratch.
+func (si *storeIndex[K, T, V]) notifyRebuilt(previous Indexes[K, T]) {
+	for name := range si.hooks {
+		si.notifyIndexRebuilt(name, previous[name])
+	}
+}
+
+// notifyIndexRebuilt invokes the hooks of the named index with the keys that
+// left and then entered its values since previous, the index before it was
+// rebuilt.
+func (si *storeIndex[K, T, V]) notifyIndexRebuilt(name string, previous Index[K, T]) {
+	hooks := si.hooks[name]
+	if len(hooks) == 0 {
+		return
+	}
+	oldIndex, newIndex := previous, si.indices[name]
+	for indexValue, keySet := range oldIndex {
+		for key := range keySet {
+			if !newIndex[indexValue].Has(key) {
+				notifyChange(hooks, IndexChange[K, T]{Index: name, Key: key, Value: indexValue})
+			}
+		}
+	}
+	for indexValue, keySet := range newIndex {
+		for key := range keySet {
+			if !oldIndex[indexValue].Has(key) {
+				notifyChange(hooks, IndexChange[K, T]{Index: name, Key: key, Value: indexValue, Entered: true})
+			}
+		}
+	}
+}
+
+// notifyChange invokes hooks with change, in the order they were registered.
+func notifyChange[K, T comparable](hooks []IndexChangeFunc[K, T], change IndexChange[K, T]) {
+	for _, hook := range hooks {
+		hook(change)
+	}
+}
+
 // updateIndices updates the object's location in the managed indexes:
 // - For create, provide only the newObj
 // - For update, provide both oldObj and newObj
 // - For delete, provide only the oldObj
-func (si *storeIndex[K, T]) updateIndices(oldObj, newObj interface{}, key T) {
-	for name := range si.indexers {
-		si.updateSingleIndex(name, oldObj, newObj, key)
+// The objects are passed by pointer, nil standing for the missing one. If an
+// IndexFunc fails on newObj, it returns an IndexError and leaves the indexes
+// unchanged. Otherwise the hooks are invoked with the changes.
+func (si *storeIndex[K, T, V]) updateIndices(oldObj, newObj *V, key T) error {
+	// Collect the new values of every index in the reused scratch slice
+	// before changing any of them
+	updates := si.scratch[:0]
+	defer func() {
+		clear(updates)
+		si.scratch = updates[:0]
+	}()
+	updates, err := si.appendIndexUpdates(updates, newObj, key)
+	if err != nil {
+		return err
+	}
+	for _, update := range updates {
+		if err := si.checkUnique(update.name, update.values, key); err != nil {
+			return err
+		}
 	}
+	si.applyIndexUpdates(updates, oldObj, newObj, key)
+	return nil
 }
 
-// updateSingleIndex updates a single index for the object.
-func (si *storeIndex[K, T]) updateSingleIndex(name string, oldObj, newObj interface{}, key T) {
-	var oldIndexValues, newIndexValues []K
-	indexFunc, exists := si.indexers[name]
-	if !exists {
-		panic(fmt.Errorf("indexer %q does not exist", name))
+// appendIndexUpdates appends the values of newObj, stored under key, in every
+// index to updates, with no values if newObj is nil. It fails like
+// updateIndices, without checking unique indexes.
+func (si *storeIndex[K, T, V]) appendIndexUpdates(updates []indexUpdate[K], newObj *V, key T) ([]indexUpdate[K], error) {
+	for name := range si.indexers {
+		var indexValues []K
+		if newObj != nil {
+			var err error
+			if indexValues, err = si.indexValues(name, *newObj, key); err != nil {
+				return updates, err
+			}
+		}
+		updates = append(updates, indexUpdate[K]{name, indexValues})
 	}
+	return updates, nil
+}
 
-	if oldObj != nil {
-		var err error
-		oldIndexValues, err = indexFunc(oldObj)
+// prepareBatch returns the index values of every object of updates by key,
+// checking that storing all of them and deleting the keys of deletes leaves
+// no value of a unique index held by two keys. If any IndexFunc fails or any
+// value conflicts, it returns their errors joined, so that the batch can be
+// rejected before changing anything.
+func (si *storeIndex[K, T, V]) prepareBatch(updates map[T]V, deletes []T) (map[T][]indexUpdate[K], error) {
+	var errs []error
+	batch := make(map[T][]indexUpdate[K], len(updates))
+	for key, obj := range updates {
+		indexUpdates, err := si.appendIndexUpdates(nil, &obj, key)
 		if err != nil {
-			panic(fmt.Errorf("unable to calculate index entry for key %v on index %q: %v", key, name, err))
+			errs = append(errs, err)
+			continue
 		}
+		batch[key] = indexUpdates
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
+	deleted := sets.NewSet(deletes...)
+	// claims holds the key of the batch taking each value of a unique index
+	claims := make(map[string]map[K]T)
+	for key, indexUpdates := range batch {
+		for _, update := range indexUpdates {
+			if !si.unique.Has(update.name) {
+				continue
+			}
+			if claims[update.name] == nil {
+				claims[update.name] = make(map[K]T)
+			}
+			for _, indexValue := range update.values {
+				if other, claimed := claims[update.name][indexValue]; claimed && other != key {
+					errs = append(errs, uniqueConflict(update.name, indexValue, key, other))
+					continue
+				}
+				claims[update.name][indexValue] = key
+				for existing := range si.indices[update.name][indexValue] {
+					// Keys of the batch release their values, unless they claim
+					// them again
+					_, moved := batch[existing]
+					if existing != key && !moved && !deleted.Has(existing) {
+						errs = append(errs, uniqueConflict(update.name, indexValue, key, existing))
+					}
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return batch, nil
+}
+
+// applyIndexUpdates moves key from the values of oldObj to those of updates,
+// and invokes the hooks with the changes.
+func (si *storeIndex[K, T, V]) applyIndexUpdates(updates []indexUpdate[K], oldObj, newObj *V, key T) {
+	for _, update := range updates {
+		oldIndexValues := si.moveKey(update.name, oldObj, update.values, key)
+		if hooks := si.hooks[update.name]; len(hooks) > 0 {
+			notifyChanges(hooks, update.name, oldIndexValues, update.values, key)
+		}
+	}
+	for name, p := range si.pending {
+		p.update(name, oldObj, newObj, key)
+	}
+}
+
+// updateSingleIndex updates a single index for the object, like updateIndices.
+func (si *storeIndex[K, T, V]) updateSingleIndex(name string, oldObj, newObj *V, key T) error {
+	var newIndexValues []K
 	if newObj != nil {
 		var err error
-		newIndexValues, err = indexFunc(newObj)
+		newIndexValues, err = si.indexValues(name, *newObj, key)
 		if err != nil {
-			panic(fmt.Errorf("unable to calculate index entry for key %v on index %q: %v", key, name, err))
+			return err
+		}
+		if err = si.checkUnique(name, newIndexValues, key); err != nil {
+			return err
 		}
 	}
+	si.moveKey(name, oldObj, newIndexValues, key)
+	return nil
+}
 
+// indexValues returns the values of obj, stored under key, in the named
+// index. It panics instead of returning an IndexError if si is strict.
+func (si *storeIndex[K, T, V]) indexValues(name string, obj V, key T) ([]K, error) {
+	indexFunc, exists := si.indexers[name]
+	if !exists {
+		panic(fmt.Errorf("indexer %q does not exist", name))
+	}
+	indexValues, err := indexFunc(obj)
+	if err != nil {
+		err = IndexError{Name: name, Key: key, Err: err}
+		if si.strict {
+			panic(err)
+		}
+		return nil, err
+	}
+	return si.normalizeAll(name, indexValues), nil
+}
+
+// moveKey moves key from the values of oldObj to newIndexValues in the named
+// index, and returns the values of oldObj, nil if its IndexFunc fails. Values
+// may repeat, and those shared by both objects are left alone.
+func (si *storeIndex[K, T, V]) moveKey(name string, oldObj *V, newIndexValues []K, key T) []K {
 	index := si.indices[name]
 	if index == nil {
 		index = Index[K, T]{}
 		si.indices[name] = index
 	}
 
-	if len(newIndexValues) == 1 && len(oldIndexValues) == 1 && newIndexValues[0] == oldIndexValues[0] {
+	var oldIndexValues []K
+	if oldObj != nil {
+		var err error
+		oldIndexValues, err = si.indexValues(name, *oldObj, key)
+		if err != nil {
+			// The values of oldObj are unknown, so look for key everywhere
+			removeKey(index, key)
+			if sorted := si.sorted[name]; sorted != nil {
+				si.sorted[name] = sortValues(index, sorted.compare)
+			}
+		}
+	}
+
+	moveValues(index, oldIndexValues, newIndexValues, key)
+	if sorted := si.sorted[name]; sorted != nil {
+		// Values are only removed once no key holds them
+		for _, indexValue := range oldIndexValues {
+			if _, exists := index[indexValue]; !exists {
+				sorted.delete(indexValue)
+			}
+		}
+		for _, indexValue := range newIndexValues {
+			sorted.insert(indexValue)
+		}
+	}
+	return oldIndexValues
+}
+
+// sortValues returns the values of index ordered by compare.
+func sortValues[K, T comparable](index Index[K, T], compare func(a, b K) int) *skipList[K] {
+	sorted := newSkipListFunc(compare)
+	for indexValue := range index {
+		sorted.insert(indexValue)
+	}
+	return sorted
+}
+
+// moveValues moves key from oldIndexValues to newIndexValues in index.
+func moveValues[K, T comparable](index Index[K, T], oldIndexValues, newIndexValues []K, key T) {
+	// Fast path for single-valued indexers
+	if len(oldIndexValues) <= 1 && len(newIndexValues) <= 1 {
+		var spare sets.Set[T]
+		if len(oldIndexValues) == 1 {
+			if len(newIndexValues) == 1 && newIndexValues[0] == oldIndexValues[0] {
+				return
+			}
+			spare = removeValue(index, oldIndexValues[0], key)
+		}
+		if len(newIndexValues) == 1 {
+			insertValue(index, newIndexValues[0], key, spare)
+		}
 		return
 	}
 
+	// Values shared by both objects are kept. Short lists are searched
+	// rather than hashed, which does not allocate.
+	kept := func(indexValue K) bool {
+		return slices.Contains(newIndexValues, indexValue)
+	}
+	if len(oldIndexValues) > 0 && len(newIndexValues) > maxScannedValues {
+		keptSet := sets.NewSet(newIndexValues...)
+		kept = keptSet.Has
+	}
+	var spare sets.Set[T]
 	for _, indexValue := range oldIndexValues {
-		keySet := index[indexValue]
-		if keySet == nil {
-			return
+		if kept(indexValue) {
+			continue
+		}
+		if emptied := removeValue(index, indexValue, key); emptied != nil {
+			spare = emptied
+		}
+	}
+	for _, indexValue := range newIndexValues {
+		if insertValue(index, indexValue, key, spare) {
+			spare = nil
+		}
+	}
+}
+
+// maxScannedValues is the number of new values up to which moveValues
+// searches them rather than hashing them into a set.
+const maxScannedValues = 8
+
+// removeValue removes key from indexValue in index, and returns the set of
+// indexValue if it was left empty and removed, so that it can be reused.
+func removeValue[K, T comparable](index Index[K, T], indexValue K, key T) sets.Set[T] {
+	keySet := index[indexValue]
+	if keySet == nil {
+		// Already removed, or never indexed
+		return nil
+	}
+	keySet.Delete(key)
+	if len(keySet) > 0 {
+		return nil
+	}
+	delete(index, indexValue)
+	return keySet
+}
+
+// insertValue adds key to indexValue in index, using spare as its set if it
+// has none and spare is not nil. It reports whether spare was used.
+func insertValue[K, T comparable](index Index[K, T], indexValue K, key T, spare sets.Set[T]) bool {
+	keySet := index[indexValue]
+	usedSpare := false
+	if keySet == nil {
+		if spare != nil {
+			keySet, usedSpare = spare, true
+		} else {
+			keySet = sets.NewSet[T]()
 		}
+		index[indexValue] = keySet
+	}
+	keySet.Insert(key)
+	return usedSpare
+}
+
+// removeKey removes key from every value of index.
+func removeKey[K, T comparable](index Index[K, T], key T) {
+	for indexValue, keySet := range index {
 		keySet.Delete(key)
 		if len(keySet) == 0 {
 			delete(index, indexValue)
 		}
 	}
-	for _, indexValue := range newIndexValues {
-		keySet := index[indexValue]
-		if keySet == nil {
-			keySet = sets.NewSet[T]()
-			index[indexValue] = keySet
+}
+
+// defaultReindexChunk is the number of objects AddIndexersAsync indexes per
+// lock acquisition when given no chunk size.
+const defaultReindexChunk = 1024
+
+// pendingIndex is an index built in the background by AddIndexersAsync. The
+// changes made meanwhile keep it up to date, but queries do not see it until
+// it is published with the other indices.
+type pendingIndex[K, T comparable, V any] struct {
+	indexFunc ValueIndexFunc[K, V]
+	index     Index[K, T]
+	// err is the first error of indexFunc, which abandons the index
+	err error
+}
+
+// update moves key from the values of oldObj to those of newObj, like
+// storeIndex.moveKey. Indexing an object that is not yet in the index is
+// harmless, since adding a key to a value is idempotent and removing it from
+// a value that does not hold it does nothing.
+func (p *pendingIndex[K, T, V]) update(name string, oldObj, newObj *V, key T) {
+	if p.err != nil {
+		return
+	}
+	var oldIndexValues, newIndexValues []K
+	var err error
+	if oldObj != nil {
+		if oldIndexValues, err = p.indexFunc(*oldObj); err != nil {
+			removeKey(p.index, key)
+			oldIndexValues = nil
+		}
+	}
+	if newObj != nil {
+		if newIndexValues, err = p.indexFunc(*newObj); err != nil {
+			p.err = IndexError{Name: name, Key: key, Err: err}
+			return
+		}
+	}
+	moveValues(p.index, oldIndexValues, newIndexValues, key)
+}
+
+// addPending registers newIndexers as pending, failing if any of them
+// conflicts with an existing or pending indexer.
+func (si *storeIndex[K, T, V]) addPending(newIndexers ValueIndexers[K, V]) error {
+	if err := si.checkConflicts(newIndexers); err != nil {
+		return err
+	}
+	if si.pending == nil {
+		si.pending = make(map[string]*pendingIndex[K, T, V], len(newIndexers))
+	}
+	for name, indexFunc := range newIndexers {
+		si.pending[name] = &pendingIndex[K, T, V]{indexFunc: indexFunc, index: Index[K, T]{}}
+	}
+	return nil
+}
+
+// indexPending adds the objects stored under keys in items to the named
+// pending indices, and returns the first error any of them met.
+func (si *storeIndex[K, T, V]) indexPending(names []string, keys []T, items map[T]V) error {
+	for _, name := range names {
+		p := si.pending[name]
+		for _, key := range keys {
+			if item, exists := items[key]; exists {
+				p.update(name, nil, &item, key)
+			}
+		}
+		if p.err != nil {
+			return p.err
 		}
-		keySet.Insert(key)
 	}
+	return nil
+}
+
+// publish makes the named pending indices visible to queries.
+func (si *storeIndex[K, T, V]) publish(names []string) {
+	for _, name := range names {
+		p := si.pending[name]
+		si.indexers[name] = p.indexFunc
+		si.indices[name] = p.index
+		delete(si.pending, name)
+	}
+}
+
+// dropPending abandons the named pending indices.
+func (si *storeIndex[K, T, V]) dropPending(names []string) {
+	for _, name := range names {
+		delete(si.pending, name)
+	}
+}
+
+// reindexChunk returns the number of objects to index per lock acquisition
+// for the chunk size requested by the caller.
+func reindexChunk(chunkSize int) int {
+	if chunkSize <= 0 {
+		return defaultReindexChunk
+	}
+	return chunkSize
 }
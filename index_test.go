@@ -1,7 +1,12 @@
 package cache
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,10 +14,15 @@ import (
 	"github.com/liuxinbot/cache/sets"
 )
 
+// ref returns a pointer to obj, as storeIndex takes the objects it indexes.
+func ref(obj interface{}) *interface{} {
+	return &obj
+}
+
 // TestStoreIndexAddIndexer tests adding indexers to the storeIndex
 func TestStoreIndexAddIndexer(t *testing.T) {
 	indexers := Indexers[string]{}
-	si := &storeIndex[string, string]{
+	si := &storeIndex[string, string, interface{}]{
 		indexers: indexers,
 		indices:  Indexes[string, string]{},
 	}
@@ -38,14 +48,14 @@ func TestStoreIndexGetKeysFromIndex(t *testing.T) {
 			return []string{obj.(string)}, nil
 		},
 	}
-	si := &storeIndex[string, string]{
+	si := &storeIndex[string, string, interface{}]{
 		indexers: indexers,
 		indices:  Indexes[string, string]{},
 	}
 
 	// Add objects
-	si.updateIndices(nil, "obj1", "key1")
-	si.updateIndices(nil, "obj2", "key2")
+	si.updateIndices(nil, ref("obj1"), "key1")
+	si.updateIndices(nil, ref("obj2"), "key2")
 
 	// Retrieve keys from index
 	keys, err := si.getKeysFromIndex("name", "obj1")
@@ -64,14 +74,14 @@ func TestStoreIndexGetKeysByIndex(t *testing.T) {
 			return []string{obj.(string)}, nil
 		},
 	}
-	si := &storeIndex[string, string]{
+	si := &storeIndex[string, string, interface{}]{
 		indexers: indexers,
 		indices:  Indexes[string, string]{},
 	}
 
 	// Add objects
-	si.updateIndices(nil, "obj1", "key1")
-	si.updateIndices(nil, "obj2", "key2")
+	si.updateIndices(nil, ref("obj1"), "key1")
+	si.updateIndices(nil, ref("obj2"), "key2")
 
 	// Retrieve keys by index value
 	keys, err := si.getKeysByIndex("name", "obj1")
@@ -90,17 +100,17 @@ func TestStoreIndexUpdateIndices(t *testing.T) {
 			return []string{obj.(string)}, nil
 		},
 	}
-	si := &storeIndex[string, string]{
+	si := &storeIndex[string, string, interface{}]{
 		indexers: indexers,
 		indices:  Indexes[string, string]{},
 	}
 
 	// Add objects
-	si.updateIndices(nil, "obj1", "key1")
-	si.updateIndices(nil, "obj2", "key2")
+	si.updateIndices(nil, ref("obj1"), "key1")
+	si.updateIndices(nil, ref("obj2"), "key2")
 
 	// Update object
-	si.updateIndices("obj1", "obj1_updated", "key1")
+	si.updateIndices(ref("obj1"), ref("obj1_updated"), "key1")
 
 	// Verify updated indices
 	keys, err := si.getKeysFromIndex("name", "obj1_updated")
@@ -119,17 +129,17 @@ func TestStoreIndexDeleteIndices(t *testing.T) {
 			return []string{obj.(string)}, nil
 		},
 	}
-	si := &storeIndex[string, string]{
+	si := &storeIndex[string, string, interface{}]{
 		indexers: indexers,
 		indices:  Indexes[string, string]{},
 	}
 
 	// Add objects
-	si.updateIndices(nil, "obj1", "key1")
-	si.updateIndices(nil, "obj2", "key2")
+	si.updateIndices(nil, ref("obj1"), "key1")
+	si.updateIndices(nil, ref("obj2"), "key2")
 
 	// Delete object
-	si.updateIndices("obj1", nil, "key1")
+	si.updateIndices(ref("obj1"), nil, "key1")
 
 	// Verify deleted indices
 	keys, err := si.getKeysFromIndex("name", "obj1")
@@ -140,7 +150,7 @@ func TestStoreIndexDeleteIndices(t *testing.T) {
 // TestStoreIndexAddIndexers tests adding multiple indexers
 func TestStoreIndexAddIndexers(t *testing.T) {
 	indexers := Indexers[string]{}
-	si := &storeIndex[string, string]{
+	si := &storeIndex[string, string, interface{}]{
 		indexers: indexers,
 		indices:  Indexes[string, string]{},
 	}
@@ -158,3 +168,236 @@ func TestStoreIndexAddIndexers(t *testing.T) {
 	err := si.addIndexers(newIndexers)
 	assert.Nil(t, err)
 }
+
+// TestStoreIndexUpdateError tests that failing to index an object leaves the indices unchanged
+func TestStoreIndexUpdateError(t *testing.T) {
+	si := &storeIndex[int, string, interface{}]{
+		indexers: Indexers[int]{
+			"value": nonNegativeIndexFunc,
+			"parity": func(obj interface{}) ([]int, error) {
+				return []int{obj.(int) & 1}, nil
+			},
+		},
+		indices: Indexes[int, string]{},
+	}
+	assert.NoError(t, si.updateIndices(nil, ref(1), "a"))
+	assert.Error(t, si.updateIndices(ref(1), ref(-1), "a"))
+	keys, _ := si.getKeysByIndex("value", 1)
+	assert.Equal(t, sets.NewSet("a"), keys)
+	keys, _ = si.getKeysByIndex("parity", 1)
+	assert.Equal(t, sets.NewSet("a"), keys)
+
+	// An object that cannot be indexed any more is still removed
+	assert.NoError(t, si.updateIndices(ref(-1), nil, "a"))
+	assert.Empty(t, si.indices["value"])
+	assert.Empty(t, si.indices["parity"])
+}
+
+// valuesIndexFunc indexes an []int by its elements, which may repeat.
+func valuesIndexFunc(obj interface{}) ([]int, error) {
+	return obj.([]int), nil
+}
+
+// bruteForceIndex indexes objs from scratch with indexFunc.
+func bruteForceIndex(objs map[string]interface{}, indexFunc IndexFunc[int]) Index[int, string] {
+	index := Index[int, string]{}
+	for key, obj := range objs {
+		values, _ := indexFunc(obj)
+		for _, value := range values {
+			if index[value] == nil {
+				index[value] = sets.NewSet[string]()
+			}
+			index[value].Insert(key)
+		}
+	}
+	return index
+}
+
+// TestStoreIndexRepeatedValues tests that repeated old values do not stop the removal of the others
+func TestStoreIndexRepeatedValues(t *testing.T) {
+	si := &storeIndex[int, string, interface{}]{
+		indexers: Indexers[int]{"values": valuesIndexFunc},
+		indices:  Indexes[int, string]{},
+	}
+	assert.NoError(t, si.updateIndices(nil, ref([]int{1, 1, 2}), "a"))
+	assert.NoError(t, si.updateIndices(ref([]int{1, 1, 2}), ref([]int{3}), "a"))
+	assert.Equal(t, Index[int, string]{3: sets.NewSet("a")}, si.indices["values"])
+
+	// Values shared by the old and new objects are kept
+	assert.NoError(t, si.updateIndices(nil, ref([]int{3, 4}), "b"))
+	assert.NoError(t, si.updateIndices(ref([]int{3, 4}), ref([]int{4, 5, 5}), "b"))
+	assert.Equal(t, Index[int, string]{3: sets.NewSet("a"), 4: sets.NewSet("b"), 5: sets.NewSet("b")}, si.indices["values"])
+
+	assert.NoError(t, si.updateIndices(ref([]int{4, 5, 5}), nil, "b"))
+	assert.NoError(t, si.updateIndices(ref([]int{3}), nil, "a"))
+	assert.Empty(t, si.indices["values"])
+}
+
+// TestStoreIndexRandomUpdates compares the indices maintained through random
+// updates with those rebuilt from scratch
+func TestStoreIndexRandomUpdates(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	si := &storeIndex[int, string, interface{}]{
+		indexers: Indexers[int]{"values": valuesIndexFunc},
+		indices:  Indexes[int, string]{},
+	}
+	objs := make(map[string]interface{})
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("key-%d", rng.IntN(50))
+		var old *interface{}
+		if obj, exists := objs[key]; exists {
+			old = &obj
+		}
+		if old != nil && rng.IntN(4) == 0 {
+			assert.NoError(t, si.updateIndices(old, nil, key))
+			delete(objs, key)
+		} else {
+			values := make([]int, rng.IntN(5))
+			for j := range values {
+				values[j] = rng.IntN(10)
+			}
+			assert.NoError(t, si.updateIndices(old, ref(values), key))
+			objs[key] = values
+		}
+		if !assert.Equal(t, bruteForceIndex(objs, valuesIndexFunc), si.indices["values"], "after %d updates", i+1) {
+			return
+		}
+	}
+}
+
+// TestStoreIndexOrderedRandomUpdates compares the sorted values of an ordered
+// index maintained through random updates with those of the index
+func TestStoreIndexOrderedRandomUpdates(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	si := &storeIndex[int, string, interface{}]{
+		indexers: Indexers[int]{},
+		indices:  Indexes[int, string]{},
+	}
+	assert.NoError(t, si.addOrderedIndexer("values", valuesIndexFunc, cmp.Compare[int]))
+	objs := make(map[string]interface{})
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key-%d", rng.IntN(20))
+		var old *interface{}
+		if obj, exists := objs[key]; exists {
+			old = &obj
+		}
+		if old != nil && rng.IntN(4) == 0 {
+			assert.NoError(t, si.updateIndices(old, nil, key))
+			delete(objs, key)
+		} else {
+			values := make([]int, rng.IntN(4))
+			for j := range values {
+				values[j] = rng.IntN(30)
+			}
+			assert.NoError(t, si.updateIndices(old, ref(values), key))
+			objs[key] = values
+		}
+		asc, _ := skipListKeys(si.sorted["values"])
+		want := slices.Sorted(maps.Keys(si.indices["values"]))
+		if !assert.Equal(t, want, asc, "after %d updates", i+1) {
+			return
+		}
+	}
+}
+
+// TestStoreIndexGetKeysByRange tests the bounds of range queries
+func TestStoreIndexGetKeysByRange(t *testing.T) {
+	si := &storeIndex[int, string, interface{}]{
+		indexers: Indexers[int]{"plain": valuesIndexFunc},
+		indices:  Indexes[int, string]{},
+	}
+	assert.NoError(t, si.addOrderedIndexer("values", valuesIndexFunc, cmp.Compare[int]))
+	assert.NoError(t, si.updateIndices(nil, ref([]int{3}), "a"))
+	assert.NoError(t, si.updateIndices(nil, ref([]int{1, 5}), "b"))
+	assert.NoError(t, si.updateIndices(nil, ref([]int{2, 4}), "c"))
+
+	two, five := 2, 5
+	keys, values, err := si.getKeysByRange("values", indexRange[int]{from: &two, fromInclusive: true, to: &five})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, keys)
+	assert.Equal(t, []int{2, 3}, values)
+	keys, _, err = si.getKeysByRange("values", indexRange[int]{from: &two})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "b"}, keys)
+	keys, _, err = si.getKeysByRange("values", indexRange[int]{to: &two})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, keys)
+
+	_, _, err = si.getKeysByRange("plain", indexRange[int]{})
+	assert.Error(t, err)
+	si.reset()
+	keys, _, err = si.getKeysByRange("values", indexRange[int]{})
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+// benchmarkUpdateIndices updates the objects stored under 1000 keys, indexed by
+// indexers, key i%1000 receiving object i%2000 of objs.
+func benchmarkUpdateIndices(b *testing.B, indexers Indexers[int], objs []interface{}) {
+	si := &storeIndex[int, int, interface{}]{indexers: indexers, indices: Indexes[int, int]{}}
+	stored := make([]interface{}, 1000)
+	for key := range stored {
+		stored[key] = objs[key]
+		_ = si.updateIndices(nil, ref(stored[key]), key)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := i % len(stored)
+		obj := objs[i%len(objs)]
+		_ = si.updateIndices(ref(stored[key]), ref(obj), key)
+		stored[key] = obj
+	}
+}
+
+// benchmarkObjs returns 2000 objects made by newObj.
+func benchmarkObjs(newObj func(i int) interface{}) []interface{} {
+	objs := make([]interface{}, 2000)
+	for i := range objs {
+		objs[i] = newObj(i)
+	}
+	return objs
+}
+
+func BenchmarkUpdateIndicesSingleValue(b *testing.B) {
+	indexers := Indexers[int]{"values": valuesIndexFunc}
+	benchmarkUpdateIndices(b, indexers, benchmarkObjs(func(i int) interface{} { return []int{i % 100} }))
+}
+
+func BenchmarkUpdateIndicesUniqueValue(b *testing.B) {
+	indexers := Indexers[int]{"values": valuesIndexFunc}
+	benchmarkUpdateIndices(b, indexers, benchmarkObjs(func(i int) interface{} { return []int{i} }))
+}
+
+func BenchmarkUpdateIndicesMultiValue(b *testing.B) {
+	indexers := Indexers[int]{"values": valuesIndexFunc}
+	benchmarkUpdateIndices(b, indexers, benchmarkObjs(func(i int) interface{} {
+		return []int{i % 10, i % 7, i % 3, i % 10}
+	}))
+}
+
+func BenchmarkUpdateIndicesManyIndexers(b *testing.B) {
+	indexers := Indexers[int]{}
+	for n := 0; n < 4; n++ {
+		indexers[fmt.Sprintf("index-%d", n)] = func(obj interface{}) ([]int, error) {
+			return obj.([]int)[n : n+1], nil
+		}
+	}
+	benchmarkUpdateIndices(b, indexers, benchmarkObjs(func(i int) interface{} {
+		return []int{i % 50, i % 40, i % 30, i % 20}
+	}))
+}
+
+func BenchmarkThreadSafeStoreUpdateIndexed(b *testing.B) {
+	store := NewThreadSafeStore(Indexers[int]{"values": valuesIndexFunc}, Indexes[int, string]{})
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	objs := benchmarkObjs(func(i int) interface{} { return []int{i % 100} })
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = store.Update(keys[i%len(keys)], objs[i%len(objs)])
+	}
+}
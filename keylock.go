@@ -0,0 +1,56 @@
+package cache
+
+import "sync"
+
+// keyMutex is a set of mutexes by key, so that callers serialize their work
+// on a key without blocking that on other keys. The mutex of a key only
+// exists while it is held or awaited. The zero value is ready to use.
+type keyMutex[T comparable] struct {
+	mu    sync.Mutex
+	locks map[T]*keyLock
+}
+
+// keyLock is the mutex of a key along with the number of its holders and
+// waiters.
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks the mutex of key, waiting until it is available.
+func (m *keyMutex[T]) lock(key T) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[T]*keyLock)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+	l.mu.Lock()
+}
+
+// unlock unlocks the mutex of key. It panics if key is not locked.
+func (m *keyMutex[T]) unlock(key T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.locks[key]
+	if !ok {
+		panic("cache: unlock of unlocked key")
+	}
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+	l.mu.Unlock()
+}
+
+// size returns the number of keys locked or awaited.
+func (m *keyMutex[T]) size() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.locks)
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyMutex(t *testing.T) {
+	var m keyMutex[string]
+	counts := make(map[string]int)
+	var countsMu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := []string{"a", "b"}[j%2]
+				m.lock(key)
+				// Read and write the count in separate steps, which only
+				// works if the key is held exclusively
+				countsMu.Lock()
+				count := counts[key]
+				countsMu.Unlock()
+				countsMu.Lock()
+				counts[key] = count + 1
+				countsMu.Unlock()
+				m.unlock(key)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]int{"a": 400, "b": 400}, counts)
+	// Released mutexes are forgotten
+	assert.Equal(t, 0, m.size())
+	assert.Panics(t, func() { m.unlock("a") })
+}
+
+func TestThreadSafeStoreLockKey(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			store.LockKey("a")
+
+			// Other keys and the store itself are not blocked
+			done := make(chan struct{})
+			go func() {
+				store.LockKey("b")
+				assert.NoError(t, store.Add("a", 1))
+				store.UnlockKey("b")
+				close(done)
+			}()
+			<-done
+
+			locked := make(chan struct{})
+			go func() {
+				store.LockKey("a")
+				close(locked)
+				store.UnlockKey("a")
+			}()
+			select {
+			case <-locked:
+				t.Fatal("key locked twice")
+			case <-time.After(10 * time.Millisecond):
+			}
+			store.UnlockKey("a")
+			<-locked
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package cache
+
+import "sync"
+
+// storeLimits enforces the entry-count and byte-size limits of a store.
+type storeLimits[T comparable] struct {
+	// mu serializes all mutations of the store so that checking the limits
+	// and applying the change happen atomically.
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	sizer      func(obj interface{}) int64
+	onOverflow func(obj interface{}) error
+	sizes      map[T]int64
+	bytes      int64
+}
+
+// newStoreLimits returns the limits described by opts, or nil if no limit is configured.
+func newStoreLimits[T comparable](opts storeOptions) *storeLimits[T] {
+	if opts.maxEntries <= 0 && (opts.maxBytes <= 0 || opts.sizer == nil) {
+		return nil
+	}
+	l := &storeLimits[T]{
+		maxEntries: opts.maxEntries,
+		onOverflow: opts.onOverflow,
+		sizes:      make(map[T]int64),
+	}
+	if opts.maxBytes > 0 {
+		l.maxBytes = opts.maxBytes
+		l.sizer = opts.sizer
+	}
+	return l
+}
+
+// reserve checks that storing obj under key keeps the store within its limits
+// and, if so, records the size of obj. count is the current number of objects
+// and exists reports whether key is already present. The caller must hold l.mu.
+func (l *storeLimits[T]) reserve(key T, obj interface{}, exists bool, count int) bool {
+	if !exists && l.maxEntries > 0 && count >= l.maxEntries {
+		return false
+	}
+	if l.sizer == nil {
+		return true
+	}
+	size := l.sizer(obj)
+	if l.bytes-l.sizes[key]+size > l.maxBytes {
+		return false
+	}
+	l.bytes += size - l.sizes[key]
+	l.sizes[key] = size
+	return true
+}
+
+// reserveAll checks that applying updates and deletes together keeps the
+// store within its limits and, if so, records the sizes of the updated objects
+// and forgets those of the deleted ones. count is the current number of objects
+// and exists reports whether a key is present. The caller must hold l.mu.
+func (l *storeLimits[T]) reserveAll(updates map[T]interface{}, deletes []T, exists func(key T) bool, count int) bool {
+	sizes := make(map[T]int64, len(updates))
+	bytes := l.bytes
+	for _, key := range deletes {
+		if exists(key) {
+			count--
+		}
+		bytes -= l.sizes[key]
+	}
+	for key, obj := range updates {
+		if !exists(key) {
+			count++
+		}
+		if l.sizer != nil {
+			sizes[key] = l.sizer(obj)
+			bytes += sizes[key] - l.sizes[key]
+		}
+	}
+	if (l.maxEntries > 0 && count > l.maxEntries) || (l.sizer != nil && bytes > l.maxBytes) {
+		return false
+	}
+	for _, key := range deletes {
+		delete(l.sizes, key)
+	}
+	for key, size := range sizes {
+		l.sizes[key] = size
+	}
+	l.bytes = bytes
+	return true
+}
+
+// release forgets the size recorded for key. The caller must hold l.mu.
+func (l *storeLimits[T]) release(key T) {
+	l.bytes -= l.sizes[key]
+	delete(l.sizes, key)
+}
+
+// record records the size of current as that of key if exists, and forgets
+// it otherwise, to follow the store after a reserved change failed. The caller
+// must hold l.mu.
+func (l *storeLimits[T]) record(key T, current interface{}, exists bool) {
+	if !exists {
+		l.release(key)
+		return
+	}
+	if l.sizer != nil {
+		size := l.sizer(current)
+		l.bytes += size - l.sizes[key]
+		l.sizes[key] = size
+	}
+}
+
+// reset replaces all recorded sizes with those of items, visited in the order
+// of keys so that the first objects are kept. Those that do not fit within
+// the limits are passed to overflow and removed from items if it returns nil.
+// The caller must hold l.mu.
+func (l *storeLimits[T]) reset(keys []T, items map[T]interface{}) error {
+	sizes := make(map[T]int64, len(items))
+	var total int64
+	for _, key := range keys {
+		obj := items[key]
+		var size int64
+		if l.sizer != nil {
+			size = l.sizer(obj)
+		}
+		if (l.maxEntries > 0 && len(sizes) >= l.maxEntries) || (l.sizer != nil && total+size > l.maxBytes) {
+			if err := l.overflow(obj); err != nil {
+				return err
+			}
+			delete(items, key)
+			continue
+		}
+		total += size
+		sizes[key] = size
+	}
+	l.sizes = sizes
+	l.bytes = total
+	return nil
+}
+
+// overflow reports that obj does not fit in the store.
+func (l *storeLimits[T]) overflow(obj interface{}) error {
+	if l.onOverflow != nil {
+		return l.onOverflow(obj)
+	}
+	return ErrStoreFull
+}
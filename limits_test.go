@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreMaxEntries(t *testing.T) {
+	store := NewStore(testKeyFunc, WithMaxEntries(2))
+
+	assert.NoError(t, store.Add("a"))
+	assert.NoError(t, store.Add("b"))
+	assert.ErrorIs(t, store.Add("c"), ErrStoreFull)
+	assert.Equal(t, 2, store.Size())
+
+	// Updating an existing key does not grow the store
+	assert.NoError(t, store.Update("a"))
+
+	// Deleting frees room for a new key
+	assert.NoError(t, store.Delete("a"))
+	assert.NoError(t, store.Add("c"))
+
+	assert.ErrorIs(t, store.Replace([]interface{}{"x", "y", "z"}), ErrStoreFull)
+	assert.ElementsMatch(t, []string{"b", "c"}, store.ListKeys())
+}
+
+func TestStoreMaxBytes(t *testing.T) {
+	sizer := func(obj interface{}) int64 {
+		return int64(len(obj.(string)))
+	}
+	store := NewIndexer[any](testKeyFunc, WithMaxBytes(10, sizer))
+
+	assert.NoError(t, store.Add("hello"))
+	assert.NoError(t, store.Add("abcd"))
+	assert.ErrorIs(t, store.Add("xy"), ErrStoreFull)
+
+	assert.NoError(t, store.Delete("abcd"))
+	assert.NoError(t, store.Add("xy"))
+	assert.NoError(t, store.Add("abc"))
+	assert.Equal(t, 3, store.Size())
+
+	assert.NoError(t, store.Replace([]interface{}{"0123456789"}))
+	assert.ErrorIs(t, store.Add("a"), ErrStoreFull)
+}
+
+func TestStoreReplaceOverflowOrder(t *testing.T) {
+	var rejected []interface{}
+	store := NewStore(testKeyFunc, WithMaxEntries(3), WithOverflowHandler(func(obj interface{}) error {
+		rejected = append(rejected, obj)
+		return nil
+	}))
+
+	// The first objects of the list are kept whatever the order of the map
+	for i := 0; i < 20; i++ {
+		rejected = nil
+		assert.NoError(t, store.Replace([]interface{}{"e", "d", "c", "b", "a"}))
+		assert.ElementsMatch(t, []string{"e", "d", "c"}, store.ListKeys())
+		assert.Equal(t, []interface{}{"b", "a"}, rejected)
+	}
+	rejected = nil
+	added, _, removed, err := store.ReplaceWithDiff([]interface{}{"a", "b", "c", "d"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, added)
+	assert.ElementsMatch(t, []string{"d", "e"}, removed)
+	assert.Equal(t, []interface{}{"d"}, rejected)
+}
+
+func TestStoreOverflowHandler(t *testing.T) {
+	var rejected []interface{}
+	errRejected := errors.New("rejected")
+	store := NewStore(testKeyFunc, WithMaxEntries(1), WithOverflowHandler(func(obj interface{}) error {
+		rejected = append(rejected, obj)
+		if obj == "drop" {
+			return nil
+		}
+		return errRejected
+	}))
+
+	assert.NoError(t, store.Add("a"))
+	assert.ErrorIs(t, store.Add("b"), errRejected)
+	assert.NoError(t, store.Add("drop"))
+	assert.Equal(t, []interface{}{"b", "drop"}, rejected)
+	assert.Equal(t, []string{"a"}, store.ListKeys())
+}
+
+func TestStoreLimitsCompareAndSwap(t *testing.T) {
+	type blob struct {
+		name string
+		data string
+	}
+	keyFunc := func(obj interface{}) (string, error) {
+		return obj.(*blob).name, nil
+	}
+	sizer := func(obj interface{}) int64 {
+		return int64(len(obj.(*blob).data))
+	}
+	store := NewStore(keyFunc, WithMaxBytes(10, sizer))
+	small := &blob{"a", "12345"}
+	assert.NoError(t, store.Add(small))
+
+	swapped, err := store.CompareAndSwap(small, &blob{"a", "0123456789x"})
+	assert.ErrorIs(t, err, ErrStoreFull)
+	assert.False(t, swapped)
+
+	large := &blob{"a", "0123456789"}
+	swapped, err = store.CompareAndSwap(small, large)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	// Deleting releases the bytes of the object
+	deleted, err := store.CompareAndDelete(large)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.NoError(t, store.Add(&blob{"b", "0123456789"}))
+}
+
+func TestStoreLimitsBatch(t *testing.T) {
+	store := NewStore(testKeyFunc, WithMaxEntries(3))
+	assert.NoError(t, store.Add("a"))
+
+	// Objects already stored or repeated in the batch do not count twice
+	errs := store.AddAll([]interface{}{"a", "b", "b", "c", "d"})
+	assert.Equal(t, []error{nil, nil, nil, nil, ErrStoreFull}, errs)
+	assert.Equal(t, 3, store.Size())
+
+	assert.Nil(t, store.DeleteAll([]interface{}{"a", "b"}))
+	assert.Nil(t, store.AddAll([]interface{}{"d", "e"}))
+	assert.ElementsMatch(t, []string{"c", "d", "e"}, store.ListKeys())
+}
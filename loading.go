@@ -0,0 +1,470 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+// ErrNotFound is returned by a LoaderFunc when there is no object for a key.
+var ErrNotFound = errors.New("object not found")
+
+// errLoaderPanicked is returned to the reads waiting for a loader call that
+// panicked.
+var errLoaderPanicked = errors.New("loader panicked")
+
+// LoaderFunc loads the object for key from outside of a store, such as a
+// database, returning ErrNotFound if there is none.
+type LoaderFunc[T comparable] func(ctx context.Context, key T) (interface{}, error)
+
+// LoadingOption configures optional behaviour of a store created by
+// NewLoadingStore.
+type LoadingOption func(*loadingOptions)
+
+// loadingOptions holds the optional settings applied by LoadingOption.
+type loadingOptions struct {
+	ttl          time.Duration
+	maxStale     time.Duration
+	refreshAhead float64
+	clock        eviction.Clock
+}
+
+// WithStaleWhileRevalidate makes the objects loaded more than ttl ago stale.
+// A read of a stale object returns it at once and reloads it in the
+// background, so that a slow loader does not delay the read, unless it has
+// been stale for maxStale or more, in which case it is loaded again before
+// returning, like a missing object. A loader returning ErrNotFound for a
+// stored object deletes it. Objects that were not loaded, such as those added
+// or updated directly, never go stale, and neither do loaded objects that the
+// store did not accept.
+func WithStaleWhileRevalidate(ttl, maxStale time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		o.ttl = ttl
+		o.maxStale = maxStale
+	}
+}
+
+// WithRefreshAhead makes the objects loaded more than ttl ago expire, so that
+// they are loaded again before returning, and reloads them in the background
+// when read once the given fraction of ttl has elapsed, such as 0.8, so that
+// frequently read objects never expire. A fraction outside of (0, 1) reloads
+// nothing ahead. Combined with WithStaleWhileRevalidate, whose ttl is
+// overridden, expired objects are returned while stale instead.
+func WithRefreshAhead(ttl time.Duration, fraction float64) LoadingOption {
+	return func(o *loadingOptions) {
+		o.ttl = ttl
+		o.refreshAhead = fraction
+	}
+}
+
+// WithLoadingClock sets the clock telling how long ago the objects were
+// loaded, for WithStaleWhileRevalidate and WithRefreshAhead. By default the
+// store uses the real time.
+func WithLoadingClock(clock eviction.Clock) LoadingOption {
+	return func(o *loadingOptions) {
+		o.clock = clock
+	}
+}
+
+// NewLoadingStore creates a read-through ContextStore on top of store. A read
+// missing store calls loader and stores the object it returns; concurrent
+// reads of the same key share a single call. Loaded objects that store does
+// not accept, such as when it is full, are still returned. The call uses the
+// context of the read that started it, while the other reads only wait for
+// it until their own context is done.
+func NewLoadingStore[T comparable](store Store[T], loader LoaderFunc[T], opts ...LoadingOption) ContextStore[T] {
+	var o loadingOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s := &loadingStore[T]{
+		contextStore: contextStore[T]{store},
+		loader:       loader,
+		calls:        make(map[T]*loadCall),
+		ttl:          o.ttl,
+		refreshAfter: o.ttl,
+		maxStale:     o.maxStale,
+		clock:        o.clock,
+	}
+	if o.refreshAhead > 0 && o.refreshAhead < 1 {
+		s.refreshAfter = time.Duration(float64(o.ttl) * o.refreshAhead)
+	}
+	if s.ttl > 0 {
+		s.loaded = make(map[T]time.Time)
+	}
+	if s.clock == nil {
+		s.clock = eviction.RealClock{}
+	}
+	return s
+}
+
+// freshness tells whether a stored object can be returned as it is.
+type freshness int
+
+const (
+	// fresh objects are returned as they are
+	fresh freshness = iota
+	// stale objects, or those due to be refreshed ahead, are returned while
+	// they are reloaded in the background
+	stale
+	// expired objects are reloaded before returning
+	expired
+)
+
+// loadCall is a loader call in progress, shared by the reads of its key.
+type loadCall struct {
+	// done is closed once obj and err are set
+	done chan struct{}
+	obj  interface{}
+	err  error
+}
+
+// loadingStore implements the ContextStore returned by NewLoadingStore.
+type loadingStore[T comparable] struct {
+	contextStore[T]
+	loader LoaderFunc[T]
+	// mu guards calls, the loader calls in progress by key, and loaded
+	mu    sync.Mutex
+	calls map[T]*loadCall
+	// loaded holds the time the objects were loaded at by key, nil unless
+	// the store was created WithStaleWhileRevalidate or WithRefreshAhead
+	loaded map[T]time.Time
+	ttl    time.Duration
+	// refreshAfter is the age the objects are reloaded in the background at,
+	// ttl unless refreshed ahead
+	refreshAfter time.Duration
+	maxStale     time.Duration
+	clock        eviction.Clock
+}
+
+// Get returns the object stored under the key of obj, loading it if missing.
+func (s *loadingStore[T]) Get(obj interface{}) (interface{}, bool, error) {
+	return s.GetCtx(context.Background(), obj)
+}
+
+// GetByKey returns the object stored under key, loading it if missing.
+func (s *loadingStore[T]) GetByKey(key T) (interface{}, bool, error) {
+	return s.GetByKeyCtx(context.Background(), key)
+}
+
+// GetCtx returns the object stored under the key of obj, loading it with ctx
+// if missing.
+func (s *loadingStore[T]) GetCtx(ctx context.Context, obj interface{}) (interface{}, bool, error) {
+	key, err := s.KeyOf(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.GetByKeyCtx(ctx, key)
+}
+
+// GetByKeyCtx returns the object stored under key, loading it with ctx if
+// missing.
+func (s *loadingStore[T]) GetByKeyCtx(ctx context.Context, key T) (interface{}, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	item, exists, err := s.Store.GetByKey(key)
+	if err != nil {
+		return item, exists, err
+	}
+	if exists {
+		switch s.freshness(key) {
+		case fresh:
+			return item, true, nil
+		case stale:
+			s.refresh(key)
+			return item, true, nil
+		}
+		// Too stale to be returned, so loaded again like a missing object
+	}
+	item, err = s.load(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return item, true, nil
+}
+
+// GetMany returns the objects stored under keys, loading the missing ones.
+// Keys whose object cannot be loaded are returned as missing.
+func (s *loadingStore[T]) GetMany(keys []T) (map[T]interface{}, []T) {
+	items, missing := s.Store.GetMany(keys)
+	for key := range items {
+		switch s.freshness(key) {
+		case stale:
+			s.refresh(key)
+		case expired:
+			delete(items, key)
+			missing = append(missing, key)
+		}
+	}
+	stillMissing := missing[:0]
+	for _, key := range missing {
+		if item, err := s.load(context.Background(), key); err == nil {
+			items[key] = item
+		} else {
+			stillMissing = append(stillMissing, key)
+		}
+	}
+	return items, stillMissing
+}
+
+// load calls the loader for key and stores the object it returns, or waits
+// for the call already in progress.
+func (s *loadingStore[T]) load(ctx context.Context, key T) (interface{}, error) {
+	s.mu.Lock()
+	if call, ok := s.calls[key]; ok {
+		s.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.obj, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &loadCall{done: make(chan struct{})}
+	s.calls[key] = call
+	s.mu.Unlock()
+	return s.run(ctx, key, call)
+}
+
+// run makes call, which must be registered in s.calls under key, and
+// unregisters it once done, even if the loader panics.
+func (s *loadingStore[T]) run(ctx context.Context, key T, call *loadCall) (interface{}, error) {
+	finished := false
+	defer func() {
+		if !finished {
+			call.obj, call.err = nil, errLoaderPanicked
+		}
+		s.mu.Lock()
+		delete(s.calls, key)
+		s.mu.Unlock()
+		close(call.done)
+	}()
+
+	// A call that finished since the store was checked may have stored the object
+	item, exists, err := s.Store.GetByKey(key)
+	if err == nil && exists && s.freshness(key) == fresh {
+		call.obj = item
+	} else {
+		call.obj, call.err = s.loader(ctx, key)
+		switch {
+		case call.err == nil:
+			// The object is returned even if the store does not accept it
+			if s.Store.Add(call.obj) == nil {
+				s.setLoaded(key)
+			}
+		case errors.Is(call.err, ErrNotFound) && exists:
+			// The stale object no longer exists
+			_ = s.Store.Delete(item)
+			s.forget(key)
+		}
+	}
+	finished = true
+	return call.obj, call.err
+}
+
+// refresh reloads the object stored under key in the background, unless it is
+// being loaded already.
+func (s *loadingStore[T]) refresh(key T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, loading := s.calls[key]; loading {
+		return
+	}
+	call := &loadCall{done: make(chan struct{})}
+	s.calls[key] = call
+	go s.run(context.Background(), key, call)
+}
+
+// freshness returns the freshness of the object stored under key.
+func (s *loadingStore[T]) freshness(key T) freshness {
+	if s.loaded == nil {
+		return fresh
+	}
+	s.mu.Lock()
+	loadedAt, ok := s.loaded[key]
+	s.mu.Unlock()
+	age := s.clock.Now().Sub(loadedAt)
+	switch {
+	case !ok || age < s.refreshAfter:
+		return fresh
+	case age < s.ttl+s.maxStale:
+		return stale
+	default:
+		return expired
+	}
+}
+
+// setLoaded records that the object stored under key was loaded now. Once
+// the records outnumber the stored objects, those of the keys the store no
+// longer holds, such as evicted ones, are dropped.
+func (s *loadingStore[T]) setLoaded(key T) {
+	if s.loaded == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaded[key] = s.clock.Now()
+	if len(s.loaded) <= 2*s.Store.Size()+16 {
+		return
+	}
+	for loadedKey := range s.loaded {
+		if !s.Store.Contains(loadedKey) {
+			delete(s.loaded, loadedKey)
+		}
+	}
+}
+
+// forget drops the load times of keys, whose objects were written or deleted
+// directly.
+func (s *loadingStore[T]) forget(keys ...T) {
+	if s.loaded == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.loaded, key)
+	}
+}
+
+// forgetObjects drops the load times of the keys of objs, like forget.
+func (s *loadingStore[T]) forgetObjects(objs ...interface{}) {
+	if s.loaded == nil {
+		return
+	}
+	keys := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		if key, err := s.Store.KeyOf(obj); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	s.forget(keys...)
+}
+
+// Add inserts an object, which never goes stale.
+func (s *loadingStore[T]) Add(obj interface{}) error {
+	err := s.Store.Add(obj)
+	s.forgetObjects(obj)
+	return err
+}
+
+// Update modifies an object, which never goes stale.
+func (s *loadingStore[T]) Update(obj interface{}) error {
+	err := s.Store.Update(obj)
+	s.forgetObjects(obj)
+	return err
+}
+
+// Delete removes an object.
+func (s *loadingStore[T]) Delete(obj interface{}) error {
+	err := s.Store.Delete(obj)
+	s.forgetObjects(obj)
+	return err
+}
+
+// AddCtx inserts an object unless ctx is done, like Add.
+func (s *loadingStore[T]) AddCtx(ctx context.Context, obj interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Add(obj)
+}
+
+// UpdateCtx modifies an object unless ctx is done, like Update.
+func (s *loadingStore[T]) UpdateCtx(ctx context.Context, obj interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Update(obj)
+}
+
+// DeleteCtx removes an object unless ctx is done, like Delete.
+func (s *loadingStore[T]) DeleteCtx(ctx context.Context, obj interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(obj)
+}
+
+// AddAll inserts objects, which never go stale.
+func (s *loadingStore[T]) AddAll(objs []interface{}) []error {
+	errs := s.Store.AddAll(objs)
+	s.forgetObjects(objs...)
+	return errs
+}
+
+// UpdateAll modifies objects, which never go stale.
+func (s *loadingStore[T]) UpdateAll(objs []interface{}) []error {
+	errs := s.Store.UpdateAll(objs)
+	s.forgetObjects(objs...)
+	return errs
+}
+
+// DeleteAll removes objects.
+func (s *loadingStore[T]) DeleteAll(objs []interface{}) []error {
+	errs := s.Store.DeleteAll(objs)
+	s.forgetObjects(objs...)
+	return errs
+}
+
+// CompareAndSwap replaces old by new if old is stored, like Update.
+func (s *loadingStore[T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	swapped, err := s.Store.CompareAndSwap(old, new)
+	if swapped {
+		s.forgetObjects(new)
+	}
+	return swapped, err
+}
+
+// CompareAndDelete removes old if it is stored.
+func (s *loadingStore[T]) CompareAndDelete(old interface{}) (bool, error) {
+	deleted, err := s.Store.CompareAndDelete(old)
+	if deleted {
+		s.forgetObjects(old)
+	}
+	return deleted, err
+}
+
+// Replace replaces all objects, none of which goes stale.
+func (s *loadingStore[T]) Replace(list []interface{}) error {
+	err := s.Store.Replace(list)
+	s.forgetAll()
+	return err
+}
+
+// ReplaceWithDiff replaces all objects like Replace and reports what changed.
+func (s *loadingStore[T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	added, updated, removed, err := s.Store.ReplaceWithDiff(list)
+	s.forgetAll()
+	return added, updated, removed, err
+}
+
+// Txn applies the changes buffered by fn atomically, none of whose objects
+// goes stale.
+func (s *loadingStore[T]) Txn(fn func(tx Txn[T]) error) error {
+	var changed []interface{}
+	err := s.Store.Txn(func(tx Txn[T]) error {
+		return fn(recordingTxn[T]{tx, &changed})
+	})
+	if err == nil {
+		s.forgetObjects(changed...)
+	}
+	return err
+}
+
+// forgetAll drops every load time.
+func (s *loadingStore[T]) forgetAll() {
+	if s.loaded == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.loaded)
+}
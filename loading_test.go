@@ -0,0 +1,313 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+func TestLoadingStore(t *testing.T) {
+	var calls atomic.Int32
+	store := NewLoadingStore(NewStore(testKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		calls.Add(1)
+		if key == "missing" {
+			return nil, ErrNotFound
+		}
+		return key, nil
+	})
+
+	item, exists, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "a", item)
+	// The loaded object is stored, so it is not loaded again
+	_, _, err = store.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 1, store.Size())
+
+	_, exists, err = store.GetByKey("missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	items, missing := store.GetMany([]string{"a", "b", "missing"})
+	assert.Equal(t, map[string]interface{}{"a": "a", "b": "b"}, items)
+	assert.Equal(t, []string{"missing"}, missing)
+}
+
+func TestLoadingStoreSingleFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	store := NewLoadingStore(NewStore(testKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return key, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item, exists, err := store.GetByKey("a")
+			assert.NoError(t, err)
+			assert.True(t, exists)
+			assert.Equal(t, "a", item)
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestLoadingStoreError(t *testing.T) {
+	errLoad := errors.New("database is down")
+	store := NewLoadingStore(NewStore(testKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		return nil, errLoad
+	})
+
+	_, exists, err := store.GetByKey("a")
+
+	assert.ErrorIs(t, err, errLoad)
+	assert.False(t, exists)
+	assert.Zero(t, store.Size())
+}
+
+func TestLoadingStoreContext(t *testing.T) {
+	started := make(chan struct{})
+	store := NewLoadingStore(NewStore(testKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	loadCtx, cancelLoad := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := store.GetByKeyCtx(loadCtx, "a")
+		done <- err
+	}()
+	<-started
+
+	// A read waiting for the call gives up with its own context
+	waitCtx, cancelWait := context.WithCancel(context.Background())
+	waited := make(chan error)
+	go func() {
+		_, _, err := store.GetByKeyCtx(waitCtx, "a")
+		waited <- err
+	}()
+	cancelWait()
+	assert.ErrorIs(t, <-waited, context.Canceled)
+
+	cancelLoad()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestLoadingStoreStaleWhileRevalidate(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Now())
+	// Objects are "key@version", so that a reload is told apart
+	versionKeyFunc := func(obj interface{}) (string, error) {
+		key, _, _ := strings.Cut(obj.(string), "@")
+		return key, nil
+	}
+	var calls atomic.Int32
+	loaded := make(chan struct{}, 1)
+	backing := NewStore(versionKeyFunc)
+	store := NewLoadingStore(backing, func(ctx context.Context, key string) (interface{}, error) {
+		defer func() { loaded <- struct{}{} }()
+		return fmt.Sprintf("%s@%d", key, calls.Add(1)), nil
+	}, WithStaleWhileRevalidate(time.Minute, time.Minute), WithLoadingClock(clock))
+
+	item, _, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a@1", item)
+	<-loaded
+
+	// A fresh object is returned as it is
+	clock.Step(30 * time.Second)
+	item, _, err = store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a@1", item)
+
+	// A stale object is returned at once, then replaced in the background
+	clock.Step(time.Minute)
+	item, _, err = store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a@1", item)
+	<-loaded
+	assert.Eventually(t, func() bool {
+		s := store.(*loadingStore[string])
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.calls) == 0
+	}, time.Second, time.Millisecond)
+	item, _, _ = backing.GetByKey("a")
+	assert.Equal(t, "a@2", item)
+
+	// An object stale for too long is loaded again before returning
+	clock.Step(2 * time.Minute)
+	item, _, err = store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a@3", item)
+	<-loaded
+	items, missing := store.GetMany([]string{"a"})
+	assert.Equal(t, map[string]interface{}{"a": "a@3"}, items)
+	assert.Empty(t, missing)
+
+	// Objects added directly never go stale
+	assert.NoError(t, store.Add("b@0"))
+	clock.Step(time.Hour)
+	item, _, err = store.GetByKey("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b@0", item)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestLoadingStoreStaleNotFound(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Now())
+	var calls atomic.Int32
+	store := NewLoadingStore(NewStore(testKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		if calls.Add(1) > 1 {
+			return nil, ErrNotFound
+		}
+		return key, nil
+	}, WithStaleWhileRevalidate(time.Minute, time.Minute), WithLoadingClock(clock))
+	_, _, err := store.GetByKey("a")
+	assert.NoError(t, err)
+
+	// The stale object is deleted once the loader no longer finds it
+	clock.Step(time.Minute)
+	_, exists, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Eventually(t, func() bool { return store.Size() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestLoadingStoreRefreshAhead(t *testing.T) {
+	start := time.Now()
+	clock := eviction.NewFakeClock(start)
+	var calls atomic.Int32
+	store := NewLoadingStore(NewStore(testKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		calls.Add(1)
+		return key, nil
+	}, WithRefreshAhead(time.Minute, 0.8), WithLoadingClock(clock))
+	idle := func() bool {
+		s := store.(*loadingStore[string])
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.calls) == 0
+	}
+	_, _, err := store.GetByKey("a")
+	assert.NoError(t, err)
+
+	clock.Step(30 * time.Second)
+	_, _, err = store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// A read past 80% of the ttl reloads the object in the background, so
+	// that reading it regularly never finds it expired
+	for i := 1; i <= 3; i++ {
+		clock.SetTime(start.Add(time.Duration(i) * 50 * time.Second))
+		item, exists, err := store.GetByKey("a")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, "a", item)
+		assert.Eventually(t, idle, time.Second, time.Millisecond)
+		assert.Equal(t, int32(1+i), calls.Load())
+	}
+
+	// An object left unread past its ttl is loaded again before returning
+	clock.Step(time.Minute)
+	_, _, err = store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), calls.Load())
+	assert.True(t, idle())
+}
+
+func TestLoadingStoreDirectWrites(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Now())
+	// Objects are "key@source"
+	versionKeyFunc := func(obj interface{}) (string, error) {
+		key, _, _ := strings.Cut(obj.(string), "@")
+		return key, nil
+	}
+	var calls atomic.Int32
+	store := NewLoadingStore(NewStore(versionKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		calls.Add(1)
+		return key + "@loaded", nil
+	}, WithStaleWhileRevalidate(time.Minute, time.Minute), WithLoadingClock(clock))
+	_, _, err := store.GetByKey("a")
+	assert.NoError(t, err)
+
+	// An object added directly does not inherit the load time of the
+	// deleted one
+	assert.NoError(t, store.Delete("a@loaded"))
+	clock.Step(2 * time.Minute)
+	assert.NoError(t, store.AddCtx(context.Background(), "a@direct"))
+	item, _, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a@direct", item)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Empty(t, store.(*loadingStore[string]).loaded)
+}
+
+func TestLoadingStoreEvictedLoadTimes(t *testing.T) {
+	backing := NewEvictionCache[any](testKeyFunc, eviction.NewLRU[string](2), Indexers[any]{})
+	store := NewLoadingStore(backing, func(ctx context.Context, key string) (interface{}, error) {
+		return key, nil
+	}, WithRefreshAhead(time.Minute, 0.8))
+
+	for i := 0; i < 100; i++ {
+		_, _, err := store.GetByKey(fmt.Sprint(i))
+		assert.NoError(t, err)
+	}
+
+	// The load times of the evicted objects are dropped
+	s := store.(*loadingStore[string])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.LessOrEqual(t, len(s.loaded), 2*backing.Size()+17)
+}
+
+func TestLoadingStoreRejectedLoad(t *testing.T) {
+	store := NewLoadingStore(NewStore(testKeyFunc, WithMaxEntries(1)), func(ctx context.Context, key string) (interface{}, error) {
+		return key, nil
+	}, WithStaleWhileRevalidate(time.Minute, time.Minute))
+
+	// The object the store rejects is returned, without a load time
+	for _, key := range []string{"a", "b"} {
+		item, _, err := store.GetByKey(key)
+		assert.NoError(t, err)
+		assert.Equal(t, key, item)
+	}
+	assert.Equal(t, []string{"a"}, store.ListKeys())
+	assert.Len(t, store.(*loadingStore[string]).loaded, 1)
+}
+
+func TestLoadingStorePanic(t *testing.T) {
+	var calls atomic.Int32
+	store := NewLoadingStore(NewStore(testKeyFunc), func(ctx context.Context, key string) (interface{}, error) {
+		if calls.Add(1) == 1 {
+			panic("database driver bug")
+		}
+		return key, nil
+	})
+
+	assert.Panics(t, func() { _, _, _ = store.GetByKey("a") })
+
+	// The call is not left in progress, so the key can be loaded again
+	item, exists, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "a", item)
+}
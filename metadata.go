@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+// EntryMeta is the history of the object stored under a key, as recorded by
+// stores created to track it.
+type EntryMeta struct {
+	CreatedAt   time.Time // When the key was stored, after being absent.
+	UpdatedAt   time.Time // When an object was last stored under the key.
+	LastAccess  time.Time // When the object was last read, zero if never.
+	AccessCount uint64    // How many times the object was read.
+}
+
+// metaTracker records the EntryMeta of every stored key. A nil tracker
+// records nothing, so stores call it unconditionally.
+type metaTracker[T comparable] struct {
+	mu      sync.Mutex
+	clock   eviction.Clock
+	entries map[T]*EntryMeta
+}
+
+// newMetaTracker creates a tracker reading the time from clock.
+func newMetaTracker[T comparable](clock eviction.Clock) *metaTracker[T] {
+	if clock == nil {
+		clock = eviction.RealClock{}
+	}
+	return &metaTracker[T]{
+		clock:   clock,
+		entries: make(map[T]*EntryMeta),
+	}
+}
+
+// stored records that an object was stored under key.
+func (m *metaTracker[T]) stored(key T) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(key, m.clock.Now())
+}
+
+// store records that an object was stored under key at now.
+// The caller must hold m.mu.
+func (m *metaTracker[T]) store(key T, now time.Time) {
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &EntryMeta{CreatedAt: now}
+		m.entries[key] = entry
+	}
+	entry.UpdatedAt = now
+}
+
+// accessed records a read of the object stored under key. Keys that are not
+// stored, because they were removed since they were read, are ignored.
+func (m *metaTracker[T]) accessed(key T) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.entries[key]; ok {
+		entry.LastAccess = m.clock.Now()
+		entry.AccessCount++
+	}
+}
+
+// forget drops the history of key after its object was removed.
+func (m *metaTracker[T]) forget(key T) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+}
+
+// replaced records that the objects of a store were replaced by items,
+// keeping the creation time of the keys that remain.
+func (m *metaTracker[T]) replaced(items map[T]interface{}) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if _, ok := items[key]; !ok {
+			delete(m.entries, key)
+		}
+	}
+	now := m.clock.Now()
+	for key := range items {
+		m.store(key, now)
+	}
+}
+
+// get returns the history of key.
+func (m *metaTracker[T]) get(key T) (EntryMeta, bool) {
+	if m == nil {
+		return EntryMeta{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return EntryMeta{}, false
+	}
+	return *entry, true
+}
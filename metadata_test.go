@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/liuxinbot/cache/eviction"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewStore(testKeyFunc, WithMetadata(clock))
+	assert.NoError(t, store.Add("a"))
+	clock.Step(time.Second)
+	assert.NoError(t, store.Update("a"))
+	clock.Step(time.Second)
+	_, _, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	store.GetMany([]string{"a", "missing"})
+	store.List()
+
+	meta, ok := store.GetMeta("a")
+	assert.True(t, ok)
+	assert.Equal(t, EntryMeta{
+		CreatedAt:   time.Unix(0, 0),
+		UpdatedAt:   time.Unix(1, 0),
+		LastAccess:  time.Unix(2, 0),
+		AccessCount: 2,
+	}, meta)
+
+	assert.NoError(t, store.Delete("a"))
+	_, ok = store.GetMeta("a")
+	assert.False(t, ok)
+}
+
+func TestMetadataReplace(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewStore(testKeyFunc, WithMetadata(clock))
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+	clock.Step(time.Second)
+
+	assert.NoError(t, store.Replace([]interface{}{"b", "c"}))
+
+	_, ok := store.GetMeta("a")
+	assert.False(t, ok)
+	meta, ok := store.GetMeta("b")
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(0, 0), meta.CreatedAt)
+	assert.Equal(t, time.Unix(1, 0), meta.UpdatedAt)
+	meta, ok = store.GetMeta("c")
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1, 0), meta.CreatedAt)
+}
+
+func TestMetadataDisabled(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.NoError(t, store.Add("a"))
+
+	_, ok := store.GetMeta("a")
+	assert.False(t, ok)
+}
+
+func TestEvictionCacheMetadata(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[int, int](eviction.NewFIFO[int](2)),
+		WithClock[int, int](clock),
+		WithEntryMetadata[int, int]())
+	assert.NoError(t, store.Add(1))
+	clock.Step(time.Second)
+	_, _, err := store.GetByKey(1)
+	assert.NoError(t, err)
+	store.Peek(1)
+
+	meta, ok := store.GetMeta(1)
+	assert.True(t, ok)
+	assert.Equal(t, EntryMeta{
+		CreatedAt:   time.Unix(0, 0),
+		UpdatedAt:   time.Unix(0, 0),
+		LastAccess:  time.Unix(1, 0),
+		AccessCount: 1,
+	}, meta)
+
+	// Evicted objects are forgotten
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+	_, ok = store.GetMeta(1)
+	assert.False(t, ok)
+	_, ok = store.GetMeta(3)
+	assert.True(t, ok)
+}
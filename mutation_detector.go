@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// MutationDetector is a Store keeping a copy of every object stored through
+// it, so that it can detect callers modifying the objects they share with the
+// store, which corrupts it without going through its methods.
+type MutationDetector[T comparable] interface {
+	Store[T]
+
+	// CompareObjects compares the stored objects with the copies taken when
+	// they were stored, and reports every object that differs to the mutation
+	// handler. Objects stored without going through the detector are skipped.
+	CompareObjects()
+
+	// Run calls CompareObjects every interval until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// MutationDetectorOption configures optional behaviour of a MutationDetector.
+type MutationDetectorOption[T comparable] func(*mutationDetector[T])
+
+// WithMutationHandler sets the function invoked with the key, stored object
+// and original copy of every mutated object, instead of panicking.
+func WithMutationHandler[T comparable](fn func(key T, obj, original interface{})) MutationDetectorOption[T] {
+	return func(d *mutationDetector[T]) {
+		d.onMutation = fn
+	}
+}
+
+// NewCacheMutationDetector creates a MutationDetector over store, taking the
+// copies of the stored objects with copier, which must copy them deeply.
+// Objects are compared with reflect.DeepEqual. By default a mutated object
+// makes CompareObjects panic, since the store can no longer be trusted. The
+// detector is meant for tests and debugging, as it doubles the memory held
+// by the objects.
+func NewCacheMutationDetector[T comparable](store Store[T], copier func(obj interface{}) interface{}, opts ...MutationDetectorOption[T]) MutationDetector[T] {
+	d := &mutationDetector[T]{
+		Store:     store,
+		copier:    copier,
+		originals: make(map[T]interface{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.onMutation == nil {
+		d.onMutation = func(key T, obj, original interface{}) {
+			panic(fmt.Sprintf("cached object %v was modified: %+v, was %+v", key, obj, original))
+		}
+	}
+	return d
+}
+
+// mutationDetector implements MutationDetector by copying the objects that
+// mutations leave stored.
+type mutationDetector[T comparable] struct {
+	Store[T]
+	copier     func(obj interface{}) interface{}
+	onMutation func(key T, obj, original interface{})
+	// mu is held by mutations and comparisons, so that the copies follow the
+	// store
+	mu        sync.Mutex
+	originals map[T]interface{}
+}
+
+var _ MutationDetector[string] = &mutationDetector[string]{}
+
+// Add inserts an item into the store.
+func (d *mutationDetector[T]) Add(obj interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.Store.Add(obj)
+	d.syncObjs(obj)
+	return err
+}
+
+// Update sets an item in the store to its updated state.
+func (d *mutationDetector[T]) Update(obj interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.Store.Update(obj)
+	d.syncObjs(obj)
+	return err
+}
+
+// Delete removes an item from the store.
+func (d *mutationDetector[T]) Delete(obj interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.Store.Delete(obj)
+	d.syncObjs(obj)
+	return err
+}
+
+// AddAll inserts items into the store in a single batch.
+func (d *mutationDetector[T]) AddAll(objs []interface{}) []error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	errs := d.Store.AddAll(objs)
+	d.syncObjs(objs...)
+	return errs
+}
+
+// UpdateAll sets items in the store to their updated state in a single batch.
+func (d *mutationDetector[T]) UpdateAll(objs []interface{}) []error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	errs := d.Store.UpdateAll(objs)
+	d.syncObjs(objs...)
+	return errs
+}
+
+// DeleteAll removes items from the store in a single batch.
+func (d *mutationDetector[T]) DeleteAll(objs []interface{}) []error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	errs := d.Store.DeleteAll(objs)
+	d.syncObjs(objs...)
+	return errs
+}
+
+// CompareAndSwap replaces old by new if old is the stored item.
+func (d *mutationDetector[T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	swapped, err := d.Store.CompareAndSwap(old, new)
+	d.syncObjs(new)
+	return swapped, err
+}
+
+// CompareAndDelete removes old if it is the stored item.
+func (d *mutationDetector[T]) CompareAndDelete(old interface{}) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deleted, err := d.Store.CompareAndDelete(old)
+	d.syncObjs(old)
+	return deleted, err
+}
+
+// Replace will delete the contents of the store, using instead the given list.
+func (d *mutationDetector[T]) Replace(list []interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.Store.Replace(list)
+	d.rebuild()
+	return err
+}
+
+// ReplaceWithDiff replaces the contents of the store and reports what changed.
+func (d *mutationDetector[T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	added, updated, removed, err := d.Store.ReplaceWithDiff(list)
+	d.rebuild()
+	return added, updated, removed, err
+}
+
+// Txn applies the changes buffered by fn atomically.
+func (d *mutationDetector[T]) Txn(fn func(tx Txn[T]) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var changed []interface{}
+	err := d.Store.Txn(func(tx Txn[T]) error {
+		return fn(recordingTxn[T]{tx, &changed})
+	})
+	d.syncObjs(changed...)
+	return err
+}
+
+// CompareObjects reports the stored items that differ from their copies.
+func (d *mutationDetector[T]) CompareObjects() {
+	type mutation struct {
+		key           T
+		obj, original interface{}
+	}
+	var mutations []mutation
+	d.mu.Lock()
+	seen := make(map[T]struct{}, len(d.originals))
+	d.Store.Range(func(key T, obj interface{}) bool {
+		original, ok := d.originals[key]
+		if !ok {
+			return true
+		}
+		seen[key] = struct{}{}
+		if !reflect.DeepEqual(obj, original) {
+			mutations = append(mutations, mutation{key, obj, original})
+		}
+		return true
+	})
+	// Forget the objects the store dropped by itself, such as evicted ones
+	for key := range d.originals {
+		if _, ok := seen[key]; !ok {
+			delete(d.originals, key)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, m := range mutations {
+		d.onMutation(m.key, m.obj, m.original)
+	}
+}
+
+// Run compares the items with their copies every interval until ctx is done.
+func (d *mutationDetector[T]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.CompareObjects()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// syncObjs copies the objects of objs if they are stored and forgets them
+// otherwise. The caller must hold d.mu.
+func (d *mutationDetector[T]) syncObjs(objs ...interface{}) {
+	for _, obj := range objs {
+		key, err := d.Store.KeyOf(obj)
+		if err != nil {
+			continue
+		}
+		if item, exists, _ := d.Store.GetByKey(key); exists {
+			d.originals[key] = d.copier(item)
+		} else {
+			delete(d.originals, key)
+		}
+	}
+}
+
+// rebuild copies all the stored objects. The caller must hold d.mu.
+func (d *mutationDetector[T]) rebuild() {
+	clear(d.originals)
+	d.Store.Range(func(key T, obj interface{}) bool {
+		d.originals[key] = d.copier(obj)
+		return true
+	})
+}
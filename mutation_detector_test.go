@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mutablePair struct {
+	name  string
+	value int
+}
+
+func mutablePairKeyFunc(obj interface{}) (string, error) {
+	return obj.(*mutablePair).name, nil
+}
+
+func copyMutablePair(obj interface{}) interface{} {
+	pair := *obj.(*mutablePair)
+	return &pair
+}
+
+func TestMutationDetector(t *testing.T) {
+	var mutated []string
+	detector := NewCacheMutationDetector(NewStore(mutablePairKeyFunc), copyMutablePair,
+		WithMutationHandler(func(key string, obj, original interface{}) {
+			assert.Equal(t, &mutablePair{key, 1}, original)
+			mutated = append(mutated, key)
+		}))
+	a, b := &mutablePair{"a", 1}, &mutablePair{"b", 1}
+	assert.Nil(t, detector.AddAll([]interface{}{a, b}))
+
+	detector.CompareObjects()
+	assert.Empty(t, mutated)
+
+	a.value = 2
+	detector.CompareObjects()
+	assert.Equal(t, []string{"a"}, mutated)
+
+	// Updating the object records a new copy, deleting it forgets it
+	assert.NoError(t, detector.Update(a))
+	b.value = 2
+	assert.NoError(t, detector.Delete(b))
+	mutated = nil
+	detector.CompareObjects()
+	assert.Empty(t, mutated)
+}
+
+func TestMutationDetectorPanics(t *testing.T) {
+	detector := NewCacheMutationDetector(NewStore(mutablePairKeyFunc), copyMutablePair)
+	a := &mutablePair{"a", 1}
+	assert.NoError(t, detector.Replace([]interface{}{a}))
+	detector.CompareObjects()
+
+	a.value = 2
+	assert.Panics(t, detector.CompareObjects)
+}
+
+func TestMutationDetectorRun(t *testing.T) {
+	mutated := make(chan string, 1)
+	detector := NewCacheMutationDetector(NewStore(mutablePairKeyFunc), copyMutablePair,
+		WithMutationHandler(func(key string, obj, original interface{}) {
+			select {
+			case mutated <- key:
+			default:
+			}
+		}))
+	a := &mutablePair{"a", 1}
+	assert.NoError(t, detector.Add(a))
+	a.value = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		detector.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	assert.Equal(t, "a", <-mutated)
+	cancel()
+	<-done
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// EventType describes a change made to an EvictionStore.
+type EventType int
+
+const (
+	// EventAdd means an object was stored under a new key.
+	EventAdd EventType = iota
+	// EventUpdate means an object replaced the one stored under its key.
+	EventUpdate
+	// EventDelete means an object was removed by Delete.
+	EventDelete
+	// EventEvict means an object was removed, or refused, for the reason
+	// given by the event.
+	EventEvict
+)
+
+// String returns a human-readable name of the EventType.
+func (t EventType) String() string {
+	switch t {
+	case EventAdd:
+		return "Add"
+	case EventUpdate:
+		return "Update"
+	case EventDelete:
+		return "Delete"
+	case EventEvict:
+		return "Evict"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event is a change made to an EvictionStore, as delivered to observers.
+type Event[T comparable] struct {
+	Type   EventType
+	Key    T
+	Obj    interface{}
+	Reason EvictionReason // Why the object was removed, for EventDelete and EventEvict.
+}
+
+// Observer is notified of the changes made to an EvictionStore. Events are
+// delivered synchronously, in order, after the cache lock is released, so the
+// observer may call back into the cache.
+type Observer[T comparable] interface {
+	Observe(event Event[T])
+}
+
+// ObserverFunc adapts a function to an Observer.
+type ObserverFunc[T comparable] func(event Event[T])
+
+// Observe calls f with the event.
+func (f ObserverFunc[T]) Observe(event Event[T]) {
+	f(event)
+}
+
+// ChannelObserver is an Observer delivering events on a buffered channel, so
+// that they can be consumed asynchronously. Events are dropped rather than
+// blocking the cache when the channel is full.
+type ChannelObserver[T comparable] struct {
+	events  chan Event[T]
+	dropped atomic.Uint64
+}
+
+// NewChannelObserver creates a new ChannelObserver buffering up to size events.
+func NewChannelObserver[T comparable](size int) *ChannelObserver[T] {
+	return &ChannelObserver[T]{events: make(chan Event[T], size)}
+}
+
+// Observe queues the event on the channel, or drops it if the channel is full.
+func (o *ChannelObserver[T]) Observe(event Event[T]) {
+	select {
+	case o.events <- event:
+	default:
+		o.dropped.Add(1)
+	}
+}
+
+// Events returns the channel the events are delivered on.
+func (o *ChannelObserver[T]) Events() <-chan Event[T] {
+	return o.events
+}
+
+// Dropped returns the number of events dropped because the channel was full.
+func (o *ChannelObserver[T]) Dropped() uint64 {
+	return o.dropped.Load()
+}
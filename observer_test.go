@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+func TestEvictionCacheObserver(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewFIFO[int](2), make(Indexers[int]))
+	var events []Event[int]
+	store.AddObserver(ObserverFunc[int](func(event Event[int]) {
+		// The observer may call back into the cache
+		store.Size()
+		events = append(events, event)
+	}))
+
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Update(2))
+	assert.NoError(t, store.Add(3))
+	assert.NoError(t, store.Delete(2))
+	assert.NoError(t, store.Evict())
+
+	assert.Equal(t, []Event[int]{
+		{Type: EventAdd, Key: 1, Obj: 1},
+		{Type: EventAdd, Key: 2, Obj: 2},
+		{Type: EventUpdate, Key: 2, Obj: 2},
+		{Type: EventEvict, Key: 1, Obj: 1, Reason: EvictionReasonCapacity},
+		{Type: EventAdd, Key: 3, Obj: 3},
+		{Type: EventDelete, Key: 2, Obj: 2, Reason: EvictionReasonDeleted},
+		{Type: EventEvict, Key: 3, Obj: 3, Reason: EvictionReasonManual},
+	}, events)
+}
+
+func TestChannelObserver(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](10), make(Indexers[int]))
+	observer := NewChannelObserver[int](2)
+	store.AddObserver(observer)
+
+	assert.NoError(t, store.Add(1))
+	assert.Equal(t, Event[int]{Type: EventAdd, Key: 1, Obj: 1}, <-observer.Events())
+	assert.NoError(t, store.Replace([]interface{}{2}))
+	assert.Equal(t, Event[int]{Type: EventEvict, Key: 1, Obj: 1, Reason: EvictionReasonReplaced}, <-observer.Events())
+	assert.Equal(t, Event[int]{Type: EventAdd, Key: 2, Obj: 2}, <-observer.Events())
+	assert.Zero(t, observer.Dropped())
+
+	// Events beyond the buffer are dropped
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, store.Add(i))
+	}
+	assert.Len(t, observer.Events(), 2)
+	assert.Equal(t, uint64(3), observer.Dropped())
+}
+
+func TestEventTypeString(t *testing.T) {
+	assert.Equal(t, "Add", EventAdd.String())
+	assert.Equal(t, "Evict", EventEvict.String())
+	assert.Equal(t, "EventType(9)", EventType(9).String())
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+// StoreOption configures optional behaviour of a store created by NewStore or NewIndexer.
+type StoreOption func(*storeOptions)
+
+// storeOptions holds the optional settings applied by StoreOption.
+type storeOptions struct {
+	maxEntries int
+	maxBytes   int64
+	sizer      func(obj interface{}) int64
+	onOverflow func(obj interface{}) error
+	copier     func(obj interface{}) interface{}
+	meta       bool
+	metaClock  eviction.Clock
+	version    VersionFunc
+	storeOpts  []ThreadSafeStoreOption
+}
+
+// WithMaxEntries limits the number of objects the store may hold.
+// Adding a new key to a full store fails with ErrStoreFull.
+func WithMaxEntries(n int) StoreOption {
+	return func(o *storeOptions) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxBytes limits the total estimated size of the objects in the store,
+// as reported by sizer. Adding an object that would exceed the limit fails
+// with ErrStoreFull.
+func WithMaxBytes(n int64, sizer func(obj interface{}) int64) StoreOption {
+	return func(o *storeOptions) {
+		o.maxBytes = n
+		o.sizer = sizer
+	}
+}
+
+// WithOverflowHandler sets a function invoked with the rejected object when
+// the store is full. Its return value is returned to the caller instead of
+// ErrStoreFull, so returning nil silently drops the object. Replace keeps the
+// first objects of its list that fit and rejects the following ones.
+func WithOverflowHandler(fn func(obj interface{}) error) StoreOption {
+	return func(o *storeOptions) {
+		o.onOverflow = fn
+	}
+}
+
+// WithCopyOnRead makes the store return the copies of its objects made by
+// copier, rather than the objects themselves, so that callers modifying what
+// they read do not corrupt the store. Since copies are never identical to the
+// stored objects, CompareAndSwap and CompareAndDelete must be given an object
+// obtained elsewhere.
+func WithCopyOnRead(copier func(obj interface{}) interface{}) StoreOption {
+	return func(o *storeOptions) {
+		o.copier = copier
+	}
+}
+
+// WithMetadata makes the store record the EntryMeta of every object, as
+// returned by GetMeta, reading the time from clock, or the real time if it is
+// nil. Reads by key count as accesses, listings do not.
+func WithMetadata(clock eviction.Clock) StoreOption {
+	return func(o *storeOptions) {
+		o.meta = true
+		o.metaClock = clock
+	}
+}
+
+// WithVersionFunc makes Add, Update, AddAll and UpdateAll fail with
+// ErrStaleVersion, leaving the store unchanged, when the version of the
+// object returned by fn is older than the version of the object stored under
+// its key, so that changes applied out of order do not overwrite newer ones.
+// Objects with the same version replace each other. CompareAndSwap, Txn and
+// Replace do not check versions.
+func WithVersionFunc(fn VersionFunc) StoreOption {
+	return func(o *storeOptions) {
+		o.version = fn
+	}
+}
+
+// WithThreadSafeStoreOptions applies opts to the ThreadSafeStore holding the
+// objects of the store, such as WithLazyIndexing.
+func WithThreadSafeStoreOptions(opts ...ThreadSafeStoreOption) StoreOption {
+	return func(o *storeOptions) {
+		o.storeOpts = append(o.storeOpts, opts...)
+	}
+}
+
+// EvictionOption configures optional behaviour of an EvictionStore created by
+// NewEvictionCache or NewEvictionCacheWithOptions.
+type EvictionOption[K, T comparable] func(*evictionOptions[K, T])
+
+// evictionOptions holds the optional settings applied by EvictionOption.
+type evictionOptions[K, T comparable] struct {
+	onEvicted OnEvictedFunc[T]
+	priority  func(obj interface{}) int
+	weigher   func(obj interface{}) int64
+	maxWeight int64
+	// noIndexTouch keeps ListKeysByIndex from recording accesses
+	noIndexTouch    bool
+	clock           eviction.Clock
+	janitorInterval time.Duration
+	policy          eviction.Policy[T]
+	indexers        Indexers[K]
+	ttl             time.Duration
+	admit           func(key T, obj interface{}) bool
+	victim          Store[T]
+	evictionCounts  bool
+	meta            bool
+}
+
+// WithOnEvicted sets a function invoked for every object removed from the
+// cache, whether to make room, by Evict and EvictN, by Replace or Delete, on
+// expiry or when refused admission, along with the reason. It is called after
+// the cache lock is released, so it may call back into the cache.
+func WithOnEvicted[K, T comparable](fn OnEvictedFunc[T]) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.onEvicted = fn
+	}
+}
+
+// WithPriority sets a function computing the priority level of every stored
+// object. It only takes effect when the eviction policy is an
+// eviction.PriorityPolicy, which then evicts objects of lower levels first.
+func WithPriority[K, T comparable](fn func(obj interface{}) int) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.priority = fn
+	}
+}
+
+// WithMaxWeight bounds the total weight of the stored objects, as computed by
+// weigher, in addition to the capacity of the eviction policy. Objects are
+// evicted in policy order until the total weight fits.
+func WithMaxWeight[K, T comparable](maxWeight int64, weigher func(obj interface{}) int64) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.maxWeight = maxWeight
+		o.weigher = weigher
+	}
+}
+
+// WithoutIndexTouch keeps ListKeysByIndex from recording an access to every
+// matching object in the eviction policy, so that index queries do not promote
+// the objects they return.
+func WithoutIndexTouch[K, T comparable]() EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.noIndexTouch = true
+	}
+}
+
+// WithClock sets the clock used to expire objects added with a TTL. By default
+// the cache uses the real time.
+func WithClock[K, T comparable](clock eviction.Clock) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.clock = clock
+	}
+}
+
+// WithJanitor starts a background goroutine removing the expired objects every
+// interval, until the cache is closed.
+func WithJanitor[K, T comparable](interval time.Duration) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.janitorInterval = interval
+	}
+}
+
+// WithPolicy sets the eviction policy of a cache created by
+// NewEvictionCacheWithOptions. By default the cache is unbounded.
+func WithPolicy[K, T comparable](policy eviction.Policy[T]) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.policy = policy
+	}
+}
+
+// WithIndexers sets the indexers of a cache created by
+// NewEvictionCacheWithOptions. By default the cache has no indexers.
+func WithIndexers[K, T comparable](indexers Indexers[K]) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.indexers = indexers
+	}
+}
+
+// WithTTL sets the time to live of the objects stored by Add and Update, which
+// otherwise never expire. AddWithTTL overrides it per object.
+func WithTTL[K, T comparable](ttl time.Duration) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.ttl = ttl
+	}
+}
+
+// WithAdmission sets a function consulted before storing an object whose key
+// is not in the cache yet. Objects it refuses are not stored and are reported
+// with EvictionReasonRejected, so that one-off objects do not displace
+// popular ones. Updates of stored objects are always admitted.
+func WithAdmission[K, T comparable](admit func(key T, obj interface{}) bool) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.admit = admit
+	}
+}
+
+// WithVictimCache demotes the objects evicted to make room for others into
+// victim, typically a smaller EvictionStore, instead of dropping them. A miss
+// in the cache then checks victim and moves the object found back into the
+// cache. Objects promoted this way get the default TTL.
+func WithVictimCache[K, T comparable](victim Store[T]) EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.victim = victim
+	}
+}
+
+// WithEvictionCounts makes the cache count the removed objects by reason,
+// as returned by EvictionCounts, to tell churn from capacity pressure.
+func WithEvictionCounts[K, T comparable]() EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.evictionCounts = true
+	}
+}
+
+// WithEntryMetadata makes the cache record the EntryMeta of every object, as
+// returned by GetMeta, reading the time from the clock of the cache. Reads by
+// key count as accesses, listings and Peek do not.
+func WithEntryMetadata[K, T comparable]() EvictionOption[K, T] {
+	return func(o *evictionOptions[K, T]) {
+		o.meta = true
+	}
+}
+
+// ThreadSafeStoreOption configures optional behaviour of a ThreadSafeStore
+// created by NewThreadSafeStore or NewShardedThreadSafeStore.
+type ThreadSafeStoreOption func(*threadSafeStoreOptions)
+
+// threadSafeStoreOptions holds the optional settings applied by
+// ThreadSafeStoreOption.
+type threadSafeStoreOptions struct {
+	strict bool
+	lazy   bool
+}
+
+// newThreadSafeStoreOptions applies opts.
+func newThreadSafeStoreOptions(opts []ThreadSafeStoreOption) threadSafeStoreOptions {
+	var o threadSafeStoreOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithStrictIndexing makes the store panic when an IndexFunc fails, rather
+// than return an IndexError, for callers treating a failing IndexFunc as a
+// programming error.
+func WithStrictIndexing() ThreadSafeStoreOption {
+	return func(o *threadSafeStoreOptions) {
+		o.strict = true
+	}
+}
+
+// WithLazyIndexing makes AddIndexer and AddIndexers only register their
+// indexers, which index the objects when their index is first queried, so
+// that the indices never queried cost nothing on writes. Such queries then
+// take the write lock while they index the objects, and return the error of
+// an IndexFunc failing meanwhile. The index then stays unbuilt, so that every
+// query fails again until the failing objects are changed or deleted, or the
+// indexer is removed. Indexers given to the constructor, unique and ordered
+// indexers, and those added by AddIndexersAsync, which indexes the objects in
+// the background, are built as usual.
+func WithLazyIndexing() ThreadSafeStoreOption {
+	return func(o *threadSafeStoreOptions) {
+		o.lazy = true
+	}
+}
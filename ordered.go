@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"cmp"
+	"strings"
+	"sync"
+)
+
+// OrderedStore is a Store that keeps its keys sorted, so that it can list
+// objects in key order and answer range queries.
+type OrderedStore[T cmp.Ordered] interface {
+	Store[T]
+
+	// ListRange returns the objects whose keys are at least from and less
+	// than to, in ascending key order.
+	ListRange(from, to T) []interface{}
+
+	// Ascend calls fn for every object in ascending key order until fn
+	// returns false. fn must not modify the store.
+	Ascend(fn func(key T, obj interface{}) bool)
+
+	// AscendFrom calls fn for the objects whose keys are at least from in
+	// ascending key order, like Ascend.
+	AscendFrom(from T, fn func(key T, obj interface{}) bool)
+
+	// Descend calls fn for every object in descending key order, like Ascend.
+	Descend(fn func(key T, obj interface{}) bool)
+}
+
+// NewOrderedStore creates a new OrderedStore. List and ListKeys return the
+// objects and keys of the store in ascending key order.
+func NewOrderedStore[T cmp.Ordered](keyFunc KeyFunc[T], opts ...StoreOption) OrderedStore[T] {
+	return &orderedStore[T]{
+		cache: newCache[any](keyFunc, opts),
+		keys:  newSkipList[T](),
+	}
+}
+
+// ListPrefix returns the objects of store whose keys start with prefix, in
+// ascending key order.
+func ListPrefix(store OrderedStore[string], prefix string) []interface{} {
+	var list []interface{}
+	store.AscendFrom(prefix, func(key string, obj interface{}) bool {
+		if !strings.HasPrefix(key, prefix) {
+			return false
+		}
+		list = append(list, obj)
+		return true
+	})
+	return list
+}
+
+// orderedStore implements OrderedStore by keeping the keys of a cache in a
+// skip list.
+type orderedStore[T cmp.Ordered] struct {
+	*cache[any, T]
+	// mu is held for writing by mutations, so that the cache and keys change
+	// together, and for reading by ordered traversals
+	mu   sync.RWMutex
+	keys *skipList[T]
+}
+
+var _ OrderedStore[string] = &orderedStore[string]{}
+
+// Add inserts an item into the store.
+func (s *orderedStore[T]) Add(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.cache.Add(obj)
+	s.syncObjs(obj)
+	return err
+}
+
+// Update sets an item in the store to its updated state.
+func (s *orderedStore[T]) Update(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.cache.Update(obj)
+	s.syncObjs(obj)
+	return err
+}
+
+// Delete removes an item from the store.
+func (s *orderedStore[T]) Delete(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.cache.Delete(obj)
+	s.syncObjs(obj)
+	return err
+}
+
+// AddAll inserts items into the store in a single batch.
+func (s *orderedStore[T]) AddAll(objs []interface{}) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := s.cache.AddAll(objs)
+	s.syncObjs(objs...)
+	return errs
+}
+
+// UpdateAll sets items in the store to their updated state in a single batch.
+func (s *orderedStore[T]) UpdateAll(objs []interface{}) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := s.cache.UpdateAll(objs)
+	s.syncObjs(objs...)
+	return errs
+}
+
+// DeleteAll removes items from the store in a single batch.
+func (s *orderedStore[T]) DeleteAll(objs []interface{}) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := s.cache.DeleteAll(objs)
+	s.syncObjs(objs...)
+	return errs
+}
+
+// CompareAndSwap replaces old by new if old is the stored item.
+func (s *orderedStore[T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swapped, err := s.cache.CompareAndSwap(old, new)
+	s.syncObjs(new)
+	return swapped, err
+}
+
+// CompareAndDelete removes old if it is the stored item.
+func (s *orderedStore[T]) CompareAndDelete(old interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted, err := s.cache.CompareAndDelete(old)
+	s.syncObjs(old)
+	return deleted, err
+}
+
+// Replace will delete the contents of the store, using instead the given list.
+func (s *orderedStore[T]) Replace(list []interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.cache.Replace(list)
+	s.rebuild()
+	return err
+}
+
+// ReplaceWithDiff replaces the contents of the store and reports what changed.
+func (s *orderedStore[T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	added, updated, removed, err := s.cache.ReplaceWithDiff(list)
+	s.rebuild()
+	return added, updated, removed, err
+}
+
+// Txn applies the changes buffered by fn atomically.
+func (s *orderedStore[T]) Txn(fn func(tx Txn[T]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changed []interface{}
+	err := s.cache.Txn(func(tx Txn[T]) error {
+		return fn(recordingTxn[T]{tx, &changed})
+	})
+	s.syncObjs(changed...)
+	return err
+}
+
+// List returns all the items in ascending key order.
+func (s *orderedStore[T]) List() []interface{} {
+	var list []interface{}
+	s.Ascend(func(key T, obj interface{}) bool {
+		list = append(list, obj)
+		return true
+	})
+	return list
+}
+
+// ListKeys returns all the keys in ascending order.
+func (s *orderedStore[T]) ListKeys() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]T, 0, s.keys.length)
+	for node := s.keys.first(); node != nil; node = node.next[0] {
+		keys = append(keys, node.key)
+	}
+	return keys
+}
+
+// ListRange returns the items whose keys are in [from, to) in ascending order.
+func (s *orderedStore[T]) ListRange(from, to T) []interface{} {
+	var list []interface{}
+	s.AscendFrom(from, func(key T, obj interface{}) bool {
+		if key >= to {
+			return false
+		}
+		list = append(list, obj)
+		return true
+	})
+	return list
+}
+
+// Ascend calls fn for every item in ascending key order.
+func (s *orderedStore[T]) Ascend(fn func(key T, obj interface{}) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.walk(s.keys.first(), true, fn)
+}
+
+// AscendFrom calls fn for the items whose keys are at least from in
+// ascending key order.
+func (s *orderedStore[T]) AscendFrom(from T, fn func(key T, obj interface{}) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.walk(s.keys.seek(from), true, fn)
+}
+
+// Descend calls fn for every item in descending key order.
+func (s *orderedStore[T]) Descend(fn func(key T, obj interface{}) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.walk(s.keys.last(), false, fn)
+}
+
+// walk calls fn for the items from node onwards, forwards or backwards,
+// until fn returns false. The caller must hold s.mu.
+func (s *orderedStore[T]) walk(node *skipListNode[T], forward bool, fn func(key T, obj interface{}) bool) {
+	for node != nil {
+		obj, _ := s.cache.store.Get(node.key)
+		if !fn(node.key, s.copy(obj)) {
+			return
+		}
+		if forward {
+			node = node.next[0]
+		} else {
+			node = node.prev
+		}
+	}
+}
+
+// syncObjs adds the keys of objs to the skip list if they are stored and
+// removes them otherwise. The caller must hold s.mu.
+func (s *orderedStore[T]) syncObjs(objs ...interface{}) {
+	for _, obj := range objs {
+		key, err := s.keyFunc(obj)
+		if err != nil {
+			continue
+		}
+		if _, exists := s.cache.store.Get(key); exists {
+			s.keys.insert(key)
+		} else {
+			s.keys.delete(key)
+		}
+	}
+}
+
+// rebuild refills the skip list with the stored keys. The caller must hold s.mu.
+func (s *orderedStore[T]) rebuild() {
+	s.keys = newSkipList[T]()
+	for _, key := range s.cache.store.ListKeys() {
+		s.keys.insert(key)
+	}
+}
+
+// recordingTxn is a Txn remembering the objects it changes.
+type recordingTxn[T comparable] struct {
+	Txn[T]
+	changed *[]interface{}
+}
+
+// Add buffers the insertion of obj.
+func (tx recordingTxn[T]) Add(obj interface{}) error {
+	*tx.changed = append(*tx.changed, obj)
+	return tx.Txn.Add(obj)
+}
+
+// Update buffers the modification of obj.
+func (tx recordingTxn[T]) Update(obj interface{}) error {
+	*tx.changed = append(*tx.changed, obj)
+	return tx.Txn.Update(obj)
+}
+
+// Delete buffers the removal of obj.
+func (tx recordingTxn[T]) Delete(obj interface{}) error {
+	*tx.changed = append(*tx.changed, obj)
+	return tx.Txn.Delete(obj)
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderedStore(t *testing.T) {
+	store := NewOrderedStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"d", "b", "a", "c"}))
+	assert.NoError(t, store.Delete("c"))
+
+	assert.Equal(t, []string{"a", "b", "d"}, store.ListKeys())
+	assert.Equal(t, []interface{}{"a", "b", "d"}, store.List())
+	assert.Equal(t, []interface{}{"b"}, store.ListRange("b", "d"))
+
+	var keys []string
+	store.Descend(func(key string, obj interface{}) bool {
+		keys = append(keys, key)
+		return key != "b"
+	})
+	assert.Equal(t, []string{"d", "b"}, keys)
+}
+
+func TestOrderedStoreListPrefix(t *testing.T) {
+	store := NewOrderedStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"app", "apple", "banana", "ap", "apricot"}))
+
+	assert.Equal(t, []interface{}{"app", "apple"}, ListPrefix(store, "app"))
+	assert.Empty(t, ListPrefix(store, "c"))
+}
+
+func TestOrderedStoreLimits(t *testing.T) {
+	store := NewOrderedStore(testKeyFunc, WithMaxEntries(1))
+	assert.NoError(t, store.Add("a"))
+
+	// Rejected objects are not listed
+	assert.ErrorIs(t, store.Add("b"), ErrStoreFull)
+	assert.Equal(t, []string{"a"}, store.ListKeys())
+}
+
+func TestOrderedStoreReplaceAndTxn(t *testing.T) {
+	store := NewOrderedStore(testKeyFunc)
+	assert.NoError(t, store.Replace([]interface{}{"c", "a"}))
+	assert.Equal(t, []string{"a", "c"}, store.ListKeys())
+
+	assert.NoError(t, store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Delete("a"))
+		return tx.Add("b")
+	}))
+	assert.Equal(t, []string{"b", "c"}, store.ListKeys())
+
+	err := store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Add("d"))
+		return errors.New("abort")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"b", "c"}, store.ListKeys())
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// pageKey is the position of a key in the order of pages. Keys of string
+// kinds are ordered by s and keys of integer, floating-point and boolean kinds
+// by n, both holding their value. Keys of other kinds have no order of their
+// own and are ordered by their string form as formatted by %v in s, then by
+// their hash in n, so that keys with the same string form stay distinct.
+type pageKey struct {
+	s string
+	n uint64
+}
+
+// compare returns -1, 0 or +1 as k comes before, with or after other.
+func (k pageKey) compare(other pageKey) int {
+	if c := strings.Compare(k.s, other.s); c != 0 {
+		return c
+	}
+	return cmp.Compare(k.n, other.n)
+}
+
+// pageSeed hashes the keys that have no order of their own.
+var pageSeed = maphash.MakeSeed()
+
+// pageKeyFunc returns the function computing the pageKey of keys of type T.
+func pageKeyFunc[T comparable]() func(key T) pageKey {
+	switch reflect.TypeFor[T]().Kind() {
+	case reflect.String:
+		return func(key T) pageKey {
+			return pageKey{s: reflect.ValueOf(key).String()}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(key T) pageKey {
+			// Flipping the sign bit orders negative values first
+			return pageKey{n: uint64(reflect.ValueOf(key).Int()) ^ 1<<63}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(key T) pageKey {
+			return pageKey{n: reflect.ValueOf(key).Uint()}
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(key T) pageKey {
+			// Flipping the sign bit of positive values and every bit of
+			// negative ones orders the bits like the values
+			bits := math.Float64bits(reflect.ValueOf(key).Float())
+			if bits>>63 == 1 {
+				return pageKey{n: ^bits}
+			}
+			return pageKey{n: bits | 1<<63}
+		}
+	case reflect.Bool:
+		return func(key T) pageKey {
+			if reflect.ValueOf(key).Bool() {
+				return pageKey{n: 1}
+			}
+			return pageKey{}
+		}
+	default:
+		return func(key T) pageKey {
+			return pageKey{s: fmt.Sprint(key), n: maphash.Comparable(pageSeed, key)}
+		}
+	}
+}
+
+// pageEntry is a key along with its position in the order of pages.
+type pageEntry[T comparable] struct {
+	key T
+	pos pageKey
+}
+
+// sortPageKeys returns the keys of items in the order of pages.
+func sortPageKeys[T comparable, V any](items map[T]V) []pageEntry[T] {
+	keyOf := pageKeyFunc[T]()
+	entries := make([]pageEntry[T], 0, len(items))
+	for key := range items {
+		entries = append(entries, pageEntry[T]{key, keyOf(key)})
+	}
+	slices.SortFunc(entries, func(a, b pageEntry[T]) int {
+		return a.pos.compare(b.pos)
+	})
+	return entries
+}
+
+// pager selects the keys of a page among keys sorted by sortPageKeys.
+//
+// A continue token encodes the position of the last key of the previous page,
+// so that paging stays consistent while the store changes: every key present
+// during the whole listing is returned exactly once. The hash ordering keys
+// without an order of their own is seeded per process, so their tokens are
+// only valid in the process that returned them.
+type pager struct {
+	limit     int
+	after     pageKey
+	continued bool
+}
+
+// newPager creates a pager for the page following continueToken, holding up
+// to limit keys, or every remaining key if limit is zero or less.
+func newPager(limit int, continueToken string) (pager, error) {
+	p := pager{limit: limit}
+	if continueToken != "" {
+		after, err := base64.RawURLEncoding.DecodeString(continueToken)
+		if err != nil {
+			return pager{}, fmt.Errorf("invalid continue token %q: %w", continueToken, err)
+		}
+		if len(after) < 8 {
+			return pager{}, fmt.Errorf("invalid continue token %q", continueToken)
+		}
+		p.after = pageKey{s: string(after[8:]), n: binary.BigEndian.Uint64(after)}
+		p.continued = true
+	}
+	return p, nil
+}
+
+// selectPage returns the entries of sorted following the continue token, up
+// to the limit of the pager and leaving out the keys for which skip returns
+// true if it is not nil, and whether other entries follow them.
+func selectPage[T comparable](p pager, sorted []pageEntry[T], skip func(key T) bool) ([]pageEntry[T], bool) {
+	start := 0
+	if p.continued {
+		var found bool
+		start, found = slices.BinarySearchFunc(sorted, p.after, func(e pageEntry[T], after pageKey) int {
+			return e.pos.compare(after)
+		})
+		if found {
+			start++
+		}
+	}
+	var entries []pageEntry[T]
+	for _, e := range sorted[start:] {
+		if skip != nil && skip(e.key) {
+			continue
+		}
+		if p.limit > 0 && len(entries) == p.limit {
+			return entries, true
+		}
+		entries = append(entries, e)
+	}
+	return entries, false
+}
+
+// nextToken returns the continue token of the page following entries, empty
+// if no other entries follow them.
+func nextToken[T comparable](entries []pageEntry[T], more bool) string {
+	if !more || len(entries) == 0 {
+		return ""
+	}
+	last := entries[len(entries)-1].pos
+	token := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(last.s)), last.n)
+	return base64.RawURLEncoding.EncodeToString(append(token, last.s...))
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+func TestListPage(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, store.Add(fmt.Sprintf("key-%02d", i)))
+	}
+
+	var pages [][]interface{}
+	token := ""
+	for {
+		items, next, err := store.ListPage(10, token)
+		assert.NoError(t, err)
+		pages = append(pages, items)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Len(t, pages, 3)
+	assert.Len(t, pages[0], 10)
+	assert.Equal(t, "key-00", pages[0][0])
+	assert.Equal(t, "key-10", pages[1][0])
+	assert.Equal(t, []interface{}{"key-20", "key-21", "key-22", "key-23", "key-24"}, pages[2])
+
+	// Without a limit all remaining objects are returned
+	items, next, err := store.ListPage(0, "")
+	assert.NoError(t, err)
+	assert.Len(t, items, 25)
+	assert.Empty(t, next)
+
+	_, _, err = store.ListPage(10, "not a token!")
+	assert.Error(t, err)
+}
+
+func TestListPageConcurrentChanges(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b", "c", "d"}))
+
+	items, next, err := store.ListPage(2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, items)
+
+	// Changes before the cursor do not shift the following pages
+	assert.NoError(t, store.Delete("a"))
+	assert.NoError(t, store.Add("aa"))
+	assert.NoError(t, store.Add("e"))
+	items, next, err = store.ListPage(2, next)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"c", "d"}, items)
+	items, next, err = store.ListPage(2, next)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"e"}, items)
+	assert.Empty(t, next)
+}
+
+func TestEvictionCacheListPage(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc, WithClock[int, int](clock))
+	assert.Nil(t, store.AddAll([]interface{}{1, 2, 3}))
+	assert.NoError(t, store.AddWithTTL(4, time.Second))
+	clock.Step(time.Second)
+
+	items, next, err := store.ListPage(2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1, 2}, items)
+	items, next, err = store.ListPage(2, next)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{3}, items)
+	assert.Empty(t, next)
+}
+
+func TestListPageNumericKeys(t *testing.T) {
+	store := NewStore(testIntKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{10, -3, 9, 100, 0}))
+
+	items, next, err := store.ListPage(3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{-3, 0, 9}, items)
+
+	// Keys added after the cursor are returned, before it are not
+	assert.NoError(t, store.Add(-5))
+	assert.NoError(t, store.Add(50))
+	items, next, err = store.ListPage(3, next)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{10, 50, 100}, items)
+	assert.Empty(t, next)
+}
+
+// pageTestKey is a key type without an order whose string forms collide.
+type pageTestKey struct {
+	id   int
+	name string
+}
+
+func (k pageTestKey) String() string {
+	return k.name
+}
+
+func TestListPageCollidingKeys(t *testing.T) {
+	keyFunc := func(obj interface{}) (pageTestKey, error) {
+		return obj.(pageTestKey), nil
+	}
+	store := NewStore(keyFunc)
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, store.Add(pageTestKey{i, "same"}))
+	}
+
+	var keys []interface{}
+	token := ""
+	for {
+		items, next, err := store.ListPage(4, token)
+		assert.NoError(t, err)
+		keys = append(keys, items...)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.ElementsMatch(t, store.List(), keys)
+}
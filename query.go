@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Query selects the objects of a store by the values of its indices and by
+// predicates, such as:
+//
+//	store.Query().Where("age", 20).And("sex", "woman").OrderBy(less).Limit(50).Do()
+//
+// The conditions on indices are looked up together, starting from the
+// smallest set of keys, and the predicates only run on the objects found.
+// Without such conditions, the query scans the whole store. A Query is built
+// by a single goroutine, and may be run several times.
+type Query[K, T comparable, V any] struct {
+	byIndexes func(queries map[string]K) ([]V, error)
+	scan      func(fn func(key T, obj V) bool)
+
+	conditions map[string]K
+	err        error
+	filters    []func(obj V) bool
+	less       func(a, b V) bool
+	limit      int
+}
+
+// newQuery returns a Query looking up the conditions on indices with
+// byIndexes, and scanning the objects with scan, such as the ByIndexes and
+// Range methods of a store.
+func newQuery[K, T comparable, V any](byIndexes func(queries map[string]K) ([]V, error), scan func(fn func(key T, obj V) bool)) *Query[K, T, V] {
+	return &Query[K, T, V]{byIndexes: byIndexes, scan: scan}
+}
+
+// Where restricts the query to the objects holding indexedValue in the
+// specified index. An index can only be restricted to a single value, Do
+// fails otherwise.
+func (q *Query[K, T, V]) Where(indexName string, indexedValue K) *Query[K, T, V] {
+	if q.conditions == nil {
+		q.conditions = make(map[string]K)
+	}
+	if previous, exists := q.conditions[indexName]; exists && previous != indexedValue && q.err == nil {
+		q.err = fmt.Errorf("index %s is queried for both %v and %v", indexName, previous, indexedValue)
+	}
+	q.conditions[indexName] = indexedValue
+	return q
+}
+
+// And restricts the query further, like Where.
+func (q *Query[K, T, V]) And(indexName string, indexedValue K) *Query[K, T, V] {
+	return q.Where(indexName, indexedValue)
+}
+
+// Filter restricts the query to the objects for which pred returns true. pred
+// may run while the store is locked, so it must not call back into it.
+func (q *Query[K, T, V]) Filter(pred func(obj V) bool) *Query[K, T, V] {
+	q.filters = append(q.filters, pred)
+	return q
+}
+
+// OrderBy sorts the objects found by less, keeping the order of those equal.
+// Otherwise they are in no particular order.
+func (q *Query[K, T, V]) OrderBy(less func(a, b V) bool) *Query[K, T, V] {
+	q.less = less
+	return q
+}
+
+// Limit returns at most n objects, the first ones by OrderBy if set. A query
+// without OrderBy stops looking for objects once it has found n of them. A
+// non-positive n sets no limit.
+func (q *Query[K, T, V]) Limit(n int) *Query[K, T, V] {
+	q.limit = n
+	return q
+}
+
+// Do runs the query and returns the objects found.
+func (q *Query[K, T, V]) Do() ([]V, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	var found []V
+	if len(q.conditions) > 0 {
+		candidates, err := q.byIndexes(q.conditions)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range candidates {
+			if q.match(obj) {
+				found = append(found, obj)
+				if q.full(found) {
+					break
+				}
+			}
+		}
+	} else {
+		q.scan(func(_ T, obj V) bool {
+			if q.match(obj) {
+				found = append(found, obj)
+			}
+			return !q.full(found)
+		})
+	}
+	if q.less != nil {
+		slices.SortStableFunc(found, func(a, b V) int {
+			switch {
+			case q.less(a, b):
+				return -1
+			case q.less(b, a):
+				return 1
+			default:
+				return 0
+			}
+		})
+		if q.limit > 0 && len(found) > q.limit {
+			found = found[:q.limit]
+		}
+	}
+	return found, nil
+}
+
+// match reports whether obj passes every filter.
+func (q *Query[K, T, V]) match(obj V) bool {
+	for _, pred := range q.filters {
+		if !pred(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+// full reports whether the query can stop looking for objects.
+func (q *Query[K, T, V]) full(found []V) bool {
+	return q.less == nil && q.limit > 0 && len(found) >= q.limit
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type queryUser struct {
+	name string
+	age  int
+	sex  string
+}
+
+func queryIndexers() ValueIndexers[any, queryUser] {
+	return ValueIndexers[any, queryUser]{
+		"age": func(u queryUser) ([]any, error) { return []any{u.age}, nil },
+		"sex": func(u queryUser) ([]any, error) { return []any{u.sex}, nil },
+	}
+}
+
+func TestQuery(t *testing.T) {
+	users := []queryUser{
+		{"alice", 20, "woman"}, {"bob", 20, "man"}, {"carol", 30, "woman"},
+		{"dave", 20, "woman"}, {"erin", 20, "woman"},
+	}
+	stores := map[string]ThreadSafeStore[any, string, queryUser]{
+		"map":     NewThreadSafeStore(queryIndexers(), Indexes[any, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, queryIndexers()),
+	}
+	byName := func(a, b queryUser) bool { return a.name < b.name }
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, u := range users {
+				assert.NoError(t, store.Add(u.name, u))
+			}
+
+			found, err := store.Query().Where("age", 20).And("sex", "woman").OrderBy(byName).Do()
+			assert.NoError(t, err)
+			assert.Equal(t, []queryUser{users[0], users[3], users[4]}, found)
+
+			// The limit applies after ordering
+			found, err = store.Query().Where("sex", "woman").OrderBy(byName).Limit(2).Do()
+			assert.NoError(t, err)
+			assert.Equal(t, []queryUser{users[0], users[2]}, found)
+
+			// Without ordering, the query stops once the limit is reached
+			var tested int
+			found, err = store.Query().Filter(func(u queryUser) bool {
+				tested++
+				return u.age == 20
+			}).Limit(2).Do()
+			assert.NoError(t, err)
+			assert.Len(t, found, 2)
+			assert.Less(t, tested, len(users))
+
+			// Filters run on the objects found by the indices only
+			tested = 0
+			found, err = store.Query().Where("age", 30).Filter(func(u queryUser) bool {
+				tested++
+				return true
+			}).Do()
+			assert.NoError(t, err)
+			assert.Equal(t, []queryUser{users[2]}, found)
+			assert.Equal(t, 1, tested)
+
+			// Without conditions, the whole store is scanned
+			found, err = store.Query().OrderBy(byName).Do()
+			assert.NoError(t, err)
+			assert.Equal(t, users, found)
+
+			found, err = store.Query().Where("age", 40).Do()
+			assert.NoError(t, err)
+			assert.Empty(t, found)
+
+			_, err = store.Query().Where("age", 20).And("age", 30).Do()
+			assert.Error(t, err)
+			_, err = store.Query().Where("missing", 1).Do()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestIndexedStoreQuery(t *testing.T) {
+	indexer := NewIndexer[int](testKeyFunc)
+	assert.NoError(t, indexer.AddIndexer("length", func(obj interface{}) ([]int, error) {
+		return []int{len(obj.(string))}, nil
+	}))
+	assert.Nil(t, indexer.AddAll([]interface{}{"apple", "melon", "kiwi", "peach"}))
+
+	found, err := indexer.Query().Where("length", 5).Filter(func(obj interface{}) bool {
+		return obj.(string) != "melon"
+	}).OrderBy(func(a, b interface{}) bool { return a.(string) < b.(string) }).Do()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"apple", "peach"}, found)
+
+	found, err = NewReadOnlyIndexer[int, string](indexer).Query().Where("length", 4).Do()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"kiwi"}, found)
+
+	typed := TypedIndexed[int, string, string](indexer)
+	names, err := typed.Query().Where("length", 4).Do()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kiwi"}, names)
+}
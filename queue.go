@@ -0,0 +1,226 @@
+package cache
+
+import "sync"
+
+// PopProcessFunc processes an object popped from a Queue.
+type PopProcessFunc func(obj interface{}) error
+
+// Queue is a FIFO queue of keyed objects. A key is queued once however many
+// times its object changes before it is popped, and Pop returns its latest
+// object, so consumers only see the latest state of every object.
+type Queue[T comparable] struct {
+	mu   sync.Mutex
+	cond sync.Cond
+	// items holds the latest object of the queued keys, and queue their
+	// order. Deleted keys stay in queue, and in queued, until Pop skips them.
+	items  map[T]interface{}
+	queue  []T
+	queued map[T]struct{}
+	// populated and initialPopulationCount track HasSynced like in DeltaFIFO
+	populated              bool
+	initialPopulationCount int
+	keyFunc                KeyFunc[T]
+	closed                 bool
+}
+
+// NewQueue creates a Queue computing keys with keyFunc.
+func NewQueue[T comparable](keyFunc KeyFunc[T]) *Queue[T] {
+	q := &Queue[T]{
+		items:   make(map[T]interface{}),
+		queued:  make(map[T]struct{}),
+		keyFunc: keyFunc,
+	}
+	q.cond.L = &q.mu
+	return q
+}
+
+// Add queues obj, replacing the object queued under its key.
+func (q *Queue[T]) Add(obj interface{}) error {
+	key, err := q.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.populated = true
+	q.put(key, obj)
+	return nil
+}
+
+// Update queues obj like Add.
+func (q *Queue[T]) Update(obj interface{}) error {
+	return q.Add(obj)
+}
+
+// AddIfNotPresent queues obj unless an object is queued under its key, so
+// that a failed object can be queued again without overwriting a newer one.
+func (q *Queue[T]) AddIfNotPresent(obj interface{}) error {
+	key, err := q.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.populated = true
+	q.addIfNotPresent(key, obj)
+	return nil
+}
+
+// Delete removes the object queued under the key of obj.
+func (q *Queue[T]) Delete(obj interface{}) error {
+	key, err := q.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.populated = true
+	delete(q.items, key)
+	return nil
+}
+
+// Replace empties the queue and queues the objects of list.
+func (q *Queue[T]) Replace(list []interface{}) error {
+	items := make(map[T]interface{}, len(list))
+	for _, obj := range list {
+		key, err := q.keyFunc(obj)
+		if err != nil {
+			return KeyError{obj, err}
+		}
+		items[key] = obj
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.populated {
+		q.populated = true
+		q.initialPopulationCount = len(items)
+	}
+	q.items = items
+	q.queue = q.queue[:0]
+	q.queued = make(map[T]struct{}, len(items))
+	for key := range items {
+		q.queue = append(q.queue, key)
+		q.queued[key] = struct{}{}
+	}
+	q.cond.Broadcast()
+	return nil
+}
+
+// put queues obj under key. The caller must hold q.mu.
+func (q *Queue[T]) put(key T, obj interface{}) {
+	if _, queued := q.queued[key]; !queued {
+		q.queue = append(q.queue, key)
+		q.queued[key] = struct{}{}
+	}
+	q.items[key] = obj
+	q.cond.Broadcast()
+}
+
+// addIfNotPresent queues obj under key unless an object is queued there.
+// The caller must hold q.mu.
+func (q *Queue[T]) addIfNotPresent(key T, obj interface{}) {
+	if _, queued := q.items[key]; !queued {
+		q.put(key, obj)
+	}
+}
+
+// Pop waits until an object is queued, removes it and calls process with it.
+// If process fails, the object is queued again unless a newer one was queued
+// meanwhile, and its error is returned. Pop returns ErrFIFOClosed once the
+// queue is closed and empty. process is called with the queue locked, so it
+// must not call back into the queue.
+func (q *Queue[T]) Pop(process PopProcessFunc) (interface{}, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		for len(q.queue) == 0 {
+			if q.closed {
+				return nil, ErrFIFOClosed
+			}
+			q.cond.Wait()
+		}
+		key := q.queue[0]
+		q.queue = q.queue[1:]
+		delete(q.queued, key)
+		if q.initialPopulationCount > 0 {
+			q.initialPopulationCount--
+		}
+		obj, ok := q.items[key]
+		if !ok {
+			// The object was deleted after being queued
+			continue
+		}
+		delete(q.items, key)
+		if err := process(obj); err != nil {
+			q.addIfNotPresent(key, obj)
+			return obj, err
+		}
+		return obj, nil
+	}
+}
+
+// Get returns the object queued under the key of obj.
+func (q *Queue[T]) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := q.keyFunc(obj)
+	if err != nil {
+		return nil, false, KeyError{obj, err}
+	}
+	return q.GetByKey(key)
+}
+
+// GetByKey returns the object queued under key.
+func (q *Queue[T]) GetByKey(key T) (interface{}, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item, exists := q.items[key]
+	return item, exists, nil
+}
+
+// List returns the queued objects in order.
+func (q *Queue[T]) List() []interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]interface{}, 0, len(q.items))
+	for _, key := range q.queue {
+		if item, ok := q.items[key]; ok {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// ListKeys returns the keys of the queued objects in order.
+func (q *Queue[T]) ListKeys() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	keys := make([]T, 0, len(q.items))
+	for _, key := range q.queue {
+		if _, ok := q.items[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of queued objects.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// HasSynced reports whether the objects queued by the first Replace have all
+// been popped. A queue changed before any Replace is synced right away.
+func (q *Queue[T]) HasSynced() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.populated && q.initialPopulationCount == 0
+}
+
+// Close makes Pop return ErrFIFOClosed once the queue is empty.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
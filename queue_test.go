@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPair is an object keyed by its name, with a value telling versions apart.
+type testPair struct {
+	name  string
+	value int
+}
+
+func testPairKeyFunc(obj interface{}) (string, error) {
+	return obj.(testPair).name, nil
+}
+
+func popObj(t *testing.T, q *Queue[string]) interface{} {
+	obj, err := q.Pop(func(interface{}) error { return nil })
+	assert.NoError(t, err)
+	return obj
+}
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(testPairKeyFunc)
+	assert.NoError(t, q.Add(testPair{"a", 1}))
+	assert.NoError(t, q.Add(testPair{"b", 1}))
+	assert.NoError(t, q.Update(testPair{"a", 2}))
+
+	// a is queued once, with its latest object
+	assert.Equal(t, []string{"a", "b"}, q.ListKeys())
+	assert.Equal(t, testPair{"a", 2}, popObj(t, q))
+	assert.Equal(t, testPair{"b", 1}, popObj(t, q))
+	assert.Zero(t, q.Len())
+}
+
+func TestQueueDelete(t *testing.T) {
+	q := NewQueue(testPairKeyFunc)
+	assert.NoError(t, q.Add(testPair{"a", 1}))
+	assert.NoError(t, q.Add(testPair{"b", 1}))
+	assert.NoError(t, q.Delete(testPair{"a", 1}))
+	assert.NoError(t, q.Add(testPair{"a", 2}))
+
+	// a keeps its place in the queue and is popped once
+	assert.Equal(t, []string{"a", "b"}, q.ListKeys())
+	assert.Equal(t, testPair{"a", 2}, popObj(t, q))
+	assert.Equal(t, testPair{"b", 1}, popObj(t, q))
+	assert.Zero(t, q.Len())
+}
+
+func TestQueueRequeue(t *testing.T) {
+	q := NewQueue(testPairKeyFunc)
+	assert.NoError(t, q.Add(testPair{"a", 1}))
+	errProcess := errors.New("not ready")
+
+	obj, err := q.Pop(func(obj interface{}) error {
+		// A newer object queued meanwhile is not overwritten
+		q.put("a", testPair{"a", 2})
+		return errProcess
+	})
+	assert.ErrorIs(t, err, errProcess)
+	assert.Equal(t, testPair{"a", 1}, obj)
+	assert.Equal(t, testPair{"a", 2}, popObj(t, q))
+
+	// Otherwise the failed object is queued again
+	assert.NoError(t, q.Add(testPair{"b", 1}))
+	_, err = q.Pop(func(obj interface{}) error {
+		return errProcess
+	})
+	assert.ErrorIs(t, err, errProcess)
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestQueueReplace(t *testing.T) {
+	q := NewQueue(testPairKeyFunc)
+	assert.False(t, q.HasSynced())
+	assert.NoError(t, q.Replace([]interface{}{testPair{"a", 1}, testPair{"b", 1}}))
+
+	assert.False(t, q.HasSynced())
+	popObj(t, q)
+	popObj(t, q)
+	assert.True(t, q.HasSynced())
+}
+
+func TestQueuePopBlocks(t *testing.T) {
+	q := NewQueue(testPairKeyFunc)
+	popped := make(chan interface{})
+	go func() {
+		popped <- popObj(t, q)
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("Pop returned from an empty queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.NoError(t, q.Add(testPair{"a", 1}))
+	assert.Equal(t, testPair{"a", 1}, <-popped)
+
+	q.Close()
+	_, err := q.Pop(func(interface{}) error { return nil })
+	assert.ErrorIs(t, err, ErrFIFOClosed)
+}
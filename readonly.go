@@ -0,0 +1,222 @@
+package cache
+
+import "iter"
+
+// ReadOnlyStore is the reading half of Store. Handing out a store as a
+// ReadOnlyStore lets the compiler keep its users from modifying it.
+type ReadOnlyStore[T comparable] interface {
+	// Get returns the object stored under the key of obj.
+	Get(obj interface{}) (interface{}, bool, error)
+
+	// GetByKey returns an object by its key.
+	GetByKey(key T) (interface{}, bool, error)
+
+	// GetMany returns the objects stored under keys by key, along with the
+	// keys that were not found.
+	GetMany(keys []T) (map[T]interface{}, []T)
+
+	// Contains reports whether an object is stored under key, see Store.Contains.
+	Contains(key T) bool
+
+	// GetMeta returns the history of the object stored under key, see Store.GetMeta.
+	GetMeta(key T) (EntryMeta, bool)
+
+	// List returns all objects.
+	List() []interface{}
+
+	// ListKeys returns all keys.
+	ListKeys() []T
+
+	// ListFiltered returns the objects for which pred returns true.
+	ListFiltered(pred func(obj interface{}) bool) []interface{}
+
+	// ListKeysFiltered returns the keys of the objects for which pred returns true.
+	ListKeysFiltered(pred func(obj interface{}) bool) []T
+
+	// Range calls fn for every object until fn returns false.
+	Range(fn func(key T, obj interface{}) bool)
+
+	// All returns an iterator over the keys and objects, like Range.
+	All() iter.Seq2[T, interface{}]
+
+	// ListPage returns a page of objects, see Store.ListPage.
+	ListPage(limit int, continueToken string) (items []interface{}, next string, err error)
+
+	// Size returns count of object.
+	Size() int
+}
+
+// ReadOnlyIndexedStore is the reading half of IndexedStore.
+type ReadOnlyIndexedStore[K, T comparable] interface {
+	ReadOnlyStore[T]
+
+	// ListKeysByIndex returns storage keys of objects whose indexed values for the specified index include the given indexed value.
+	ListKeysByIndex(indexName string, indexedValue K) ([]T, error)
+
+	// ListByIndex returns objects whose indexed values for the specified index include the given indexed value.
+	ListByIndex(indexName string, indexedValue K) ([]interface{}, error)
+
+	// CountByIndex returns the number of objects whose indexed values for the specified index include the given indexed value.
+	CountByIndex(indexName string, indexedValue K) (int, error)
+
+	// ListByIndexValues returns objects whose indexed values for the specified index include any of the given indexed values.
+	ListByIndexValues(indexName string, indexedValues ...K) ([]interface{}, error)
+
+	// ListByIndexes returns objects whose indexed values include the given indexed value for every index in queries.
+	ListByIndexes(queries map[string]K) ([]interface{}, error)
+
+	// Query returns a Query over the objects.
+	Query() *Query[K, T, interface{}]
+
+	// ListIndexValues returns the distinct indexed values for the specified index.
+	ListIndexValues(indexName string) ([]K, error)
+
+	// IndexStats returns the number of keys held by each value of the specified index, along with their totals.
+	IndexStats(indexName string) (IndexStats[K], error)
+
+	// GroupByIndex returns the storage keys of the objects held by each indexed value of the specified index.
+	GroupByIndex(indexName string) (map[K][]T, error)
+
+	// GroupCountsByIndex returns the number of objects held by each indexed value of the specified index.
+	GroupCountsByIndex(indexName string) (map[K]int, error)
+}
+
+// NewReadOnly returns a view of store that only reads from it. Unlike the
+// store itself, the view cannot be converted back to a Store.
+func NewReadOnly[T comparable](store Store[T]) ReadOnlyStore[T] {
+	return readOnlyStore[T]{store}
+}
+
+// NewReadOnlyIndexer returns a view of store that only reads from it,
+// including through its indices.
+func NewReadOnlyIndexer[K, T comparable](store IndexedStore[K, T]) ReadOnlyIndexedStore[K, T] {
+	return readOnlyIndexedStore[K, T]{readOnlyStore[T]{store}, store}
+}
+
+// readOnlyStore implements ReadOnlyStore by hiding the mutating methods of a Store.
+type readOnlyStore[T comparable] struct {
+	store Store[T]
+}
+
+// Get returns the object stored under the key of obj.
+func (s readOnlyStore[T]) Get(obj interface{}) (interface{}, bool, error) {
+	return s.store.Get(obj)
+}
+
+// GetByKey returns an object by its key.
+func (s readOnlyStore[T]) GetByKey(key T) (interface{}, bool, error) {
+	return s.store.GetByKey(key)
+}
+
+// GetMany returns the objects stored under keys.
+func (s readOnlyStore[T]) GetMany(keys []T) (map[T]interface{}, []T) {
+	return s.store.GetMany(keys)
+}
+
+// Contains reports whether an object is stored under key.
+func (s readOnlyStore[T]) Contains(key T) bool {
+	return s.store.Contains(key)
+}
+
+// GetMeta returns the history of the object stored under key.
+func (s readOnlyStore[T]) GetMeta(key T) (EntryMeta, bool) {
+	return s.store.GetMeta(key)
+}
+
+// List returns all objects.
+func (s readOnlyStore[T]) List() []interface{} {
+	return s.store.List()
+}
+
+// ListKeys returns all keys.
+func (s readOnlyStore[T]) ListKeys() []T {
+	return s.store.ListKeys()
+}
+
+// ListFiltered returns the objects for which pred returns true.
+func (s readOnlyStore[T]) ListFiltered(pred func(obj interface{}) bool) []interface{} {
+	return s.store.ListFiltered(pred)
+}
+
+// ListKeysFiltered returns the keys of the objects for which pred returns true.
+func (s readOnlyStore[T]) ListKeysFiltered(pred func(obj interface{}) bool) []T {
+	return s.store.ListKeysFiltered(pred)
+}
+
+// Range calls fn for every object until fn returns false.
+func (s readOnlyStore[T]) Range(fn func(key T, obj interface{}) bool) {
+	s.store.Range(fn)
+}
+
+// All returns an iterator over the keys and objects.
+func (s readOnlyStore[T]) All() iter.Seq2[T, interface{}] {
+	return s.store.All()
+}
+
+// ListPage returns a page of objects.
+func (s readOnlyStore[T]) ListPage(limit int, continueToken string) ([]interface{}, string, error) {
+	return s.store.ListPage(limit, continueToken)
+}
+
+// Size returns count of object.
+func (s readOnlyStore[T]) Size() int {
+	return s.store.Size()
+}
+
+// readOnlyIndexedStore implements ReadOnlyIndexedStore on top of an IndexedStore.
+type readOnlyIndexedStore[K, T comparable] struct {
+	readOnlyStore[T]
+	indexed IndexedStore[K, T]
+}
+
+// ListKeysByIndex returns the keys of the objects matching the indexed value.
+func (s readOnlyIndexedStore[K, T]) ListKeysByIndex(indexName string, indexedValue K) ([]T, error) {
+	return s.indexed.ListKeysByIndex(indexName, indexedValue)
+}
+
+// CountByIndex returns the number of objects matching the indexed value.
+func (s readOnlyIndexedStore[K, T]) CountByIndex(indexName string, indexedValue K) (int, error) {
+	return s.indexed.CountByIndex(indexName, indexedValue)
+}
+
+// ListByIndex returns the objects matching the indexed value.
+func (s readOnlyIndexedStore[K, T]) ListByIndex(indexName string, indexedValue K) ([]interface{}, error) {
+	return s.indexed.ListByIndex(indexName, indexedValue)
+}
+
+// ListByIndexValues returns the objects matching any of the indexed values.
+func (s readOnlyIndexedStore[K, T]) ListByIndexValues(indexName string, indexedValues ...K) ([]interface{}, error) {
+	return s.indexed.ListByIndexValues(indexName, indexedValues...)
+}
+
+// ListByIndexes returns the objects matching the indexed value of every index
+// in queries.
+func (s readOnlyIndexedStore[K, T]) ListByIndexes(queries map[string]K) ([]interface{}, error) {
+	return s.indexed.ListByIndexes(queries)
+}
+
+// Query returns a Query over the objects.
+func (s readOnlyIndexedStore[K, T]) Query() *Query[K, T, interface{}] {
+	return s.indexed.Query()
+}
+
+// ListIndexValues returns the distinct indexed values of the index.
+func (s readOnlyIndexedStore[K, T]) ListIndexValues(indexName string) ([]K, error) {
+	return s.indexed.ListIndexValues(indexName)
+}
+
+// IndexStats returns the number of keys held by each value of the index.
+func (s readOnlyIndexedStore[K, T]) IndexStats(indexName string) (IndexStats[K], error) {
+	return s.indexed.IndexStats(indexName)
+}
+
+// GroupByIndex returns the keys held by each value of the index.
+func (s readOnlyIndexedStore[K, T]) GroupByIndex(indexName string) (map[K][]T, error) {
+	return s.indexed.GroupByIndex(indexName)
+}
+
+// GroupCountsByIndex returns the number of keys held by each value of the
+// index.
+func (s readOnlyIndexedStore[K, T]) GroupCountsByIndex(indexName string) (map[K]int, error) {
+	return s.indexed.GroupCountsByIndex(indexName)
+}
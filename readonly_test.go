@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.NoError(t, store.Add("a"))
+	view := NewReadOnly(store)
+
+	item, exists, err := view.GetByKey("a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "a", item)
+
+	// The view follows the store
+	assert.NoError(t, store.Add("b"))
+	assert.Equal(t, 2, view.Size())
+	assert.ElementsMatch(t, []string{"a", "b"}, view.ListKeys())
+
+	_, isStore := view.(Store[string])
+	assert.False(t, isStore)
+}
+
+func TestReadOnlyIndexer(t *testing.T) {
+	store := NewIndexer[int](testKeyFunc)
+	assert.NoError(t, store.AddIndexer("length", func(obj interface{}) ([]int, error) {
+		return []int{len(obj.(string))}, nil
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"a", "bb"}))
+	view := NewReadOnlyIndexer(store)
+
+	items, err := view.ListByIndex("length", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"bb"}, items)
+	values, err := view.ListIndexValues("length")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{1, 2}, values)
+	assert.Equal(t, 2, view.Size())
+
+	_, isStore := view.(Store[string])
+	assert.False(t, isStore)
+}
@@ -1,6 +1,8 @@
 package sets
 
 import (
+	"iter"
+	"maps"
 	"reflect"
 	"sort"
 )
@@ -163,3 +165,8 @@ func (s Set[T]) PopAny() (T, bool) {
 func (s Set[T]) Len() int {
 	return len(s)
 }
+
+// Values returns an iterator over the elements of the set in random order.
+func (s Set[T]) Values() iter.Seq[T] {
+	return maps.Keys(s)
+}
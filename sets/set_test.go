@@ -344,3 +344,14 @@ func TestStringIntersection(t *testing.T) {
 		}
 	}
 }
+
+func TestSetValues(t *testing.T) {
+	set := NewSet(1, 2, 3)
+	sum := 0
+	for item := range set.Values() {
+		sum += item
+	}
+	if sum != 6 {
+		t.Errorf("Expected Values to yield 1, 2 and 3 but got a sum of %d", sum)
+	}
+}
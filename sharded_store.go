@@ -0,0 +1,989 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+
+	"github.com/liuxinbot/cache/sets"
+)
+
+// NewShardedThreadSafeStore creates a ThreadSafeStore partitioning its
+// objects and their indices across shards independently locked maps, the
+// shard of a key being hasher(key) modulo shards, so that operations on keys
+// of different shards do not contend. Operations on a single key lock its
+// shard only. UpdateAll and DeleteAll lock one shard at a time, so readers
+// may see part of their changes, while Apply, Replace, Swap, ListPage,
+// Snapshot and AddIndexers lock every shard involved at once. Index queries
+// visit the shards one after the other.
+func NewShardedThreadSafeStore[K, T comparable, V any](shards int, hasher func(key T) uint64, indexers ValueIndexers[K, V], opts ...ThreadSafeStoreOption) ThreadSafeStore[K, T, V] {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &shardedStore[K, T, V]{
+		shards: make([]*threadSafeMap[K, T, V], shards),
+		hasher: hasher,
+	}
+	o := newThreadSafeStoreOptions(opts)
+	for i := range s.shards {
+		s.shards[i] = newThreadSafeMap(maps.Clone(indexers), Indexes[K, T]{})
+		s.shards[i].index.strict = o.strict
+		s.shards[i].index.lazy = o.lazy
+	}
+	return s
+}
+
+// shardedStore implements ThreadSafeStore over shards accessed through
+// their unlocked methods under their own mutex, so that it counts operations
+// once for the whole store.
+type shardedStore[K, T comparable, V any] struct {
+	shards   []*threadSafeMap[K, T, V]
+	hasher   func(key T) uint64
+	counters storeCounters
+}
+
+var _ ThreadSafeStore[any, any, any] = &shardedStore[any, any, any]{}
+
+// shard returns the shard of key.
+func (s *shardedStore[K, T, V]) shard(key T) *threadSafeMap[K, T, V] {
+	return s.shards[s.hasher(key)%uint64(len(s.shards))]
+}
+
+// Add adds an object to the store.
+func (s *shardedStore[K, T, V]) Add(key T, obj V) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if err := shard.update(key, obj); err != nil {
+		return err
+	}
+	s.counters.adds.Add(1)
+	return nil
+}
+
+// Update updates an object in the store.
+func (s *shardedStore[K, T, V]) Update(key T, obj V) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if err := shard.update(key, obj); err != nil {
+		return err
+	}
+	s.counters.updates.Add(1)
+	return nil
+}
+
+// Delete deletes an object from the store.
+func (s *shardedStore[K, T, V]) Delete(key T) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.delete(key)
+	s.counters.deletes.Add(1)
+}
+
+// UpdateAll updates many objects in the store, one shard at a time.
+func (s *shardedStore[K, T, V]) UpdateAll(keys []T, objs []V) []error {
+	errs := make([]error, len(keys))
+	for i, positions := range s.partition(keys) {
+		shard := s.shards[i]
+		shard.mu.Lock()
+		for _, j := range positions {
+			if errs[j] = shard.update(keys[j], objs[j]); errs[j] == nil {
+				s.counters.updates.Add(1)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return batchErrors(errs)
+}
+
+// DeleteAll deletes many objects from the store, one shard at a time.
+func (s *shardedStore[K, T, V]) DeleteAll(keys []T) {
+	for i, positions := range s.partition(keys) {
+		shard := s.shards[i]
+		shard.mu.Lock()
+		for _, j := range positions {
+			shard.delete(keys[j])
+		}
+		shard.mu.Unlock()
+	}
+	s.counters.deletes.Add(uint64(len(keys)))
+}
+
+// Apply updates and deletes many objects at once, locking every shard they
+// belong to.
+func (s *shardedStore[K, T, V]) Apply(updates map[T]V, deletes []T) error {
+	involved := make([]bool, len(s.shards))
+	for key := range updates {
+		involved[s.hasher(key)%uint64(len(s.shards))] = true
+	}
+	for _, key := range deletes {
+		involved[s.hasher(key)%uint64(len(s.shards))] = true
+	}
+	// Shards are locked in order so that concurrent calls cannot deadlock
+	for i, ok := range involved {
+		if ok {
+			s.shards[i].mu.Lock()
+			defer s.shards[i].mu.Unlock()
+		}
+	}
+	shardUpdates := make([]map[T]V, len(s.shards))
+	shardDeletes := make([][]T, len(s.shards))
+	for key, obj := range updates {
+		i := s.hasher(key) % uint64(len(s.shards))
+		if shardUpdates[i] == nil {
+			shardUpdates[i] = make(map[T]V)
+		}
+		shardUpdates[i][key] = obj
+	}
+	for _, key := range deletes {
+		i := s.hasher(key) % uint64(len(s.shards))
+		shardDeletes[i] = append(shardDeletes[i], key)
+	}
+	// Every shard is checked before any is changed
+	batches := make([]map[T][]indexUpdate[K], len(s.shards))
+	var errs []error
+	for i, ok := range involved {
+		if !ok {
+			continue
+		}
+		var err error
+		if batches[i], err = s.shards[i].index.prepareBatch(shardUpdates[i], shardDeletes[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	for i, ok := range involved {
+		if ok {
+			s.shards[i].commit(shardUpdates[i], batches[i], shardDeletes[i], &s.counters)
+		}
+	}
+	return nil
+}
+
+// Compute updates or deletes the object stored under key under the lock of
+// its shard.
+func (s *shardedStore[K, T, V]) Compute(key T, fn func(old V, exists bool) (V, bool)) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.compute(key, fn, &s.counters)
+}
+
+// CompareAndSwap stores new under key if the stored object is old.
+func (s *shardedStore[K, T, V]) CompareAndSwap(key T, old, new V) (bool, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if current, exists := shard.items[key]; !exists || !sameObject(any(current), any(old)) {
+		return false, nil
+	}
+	if err := shard.update(key, new); err != nil {
+		return false, err
+	}
+	s.counters.updates.Add(1)
+	return true, nil
+}
+
+// CompareAndDelete deletes the object stored under key if it is old.
+func (s *shardedStore[K, T, V]) CompareAndDelete(key T, old V) bool {
+	return s.DeleteIf(key, func(current V) bool {
+		return sameObject(any(current), any(old))
+	})
+}
+
+// DeleteIf deletes the object stored under key if cond returns true for it.
+func (s *shardedStore[K, T, V]) DeleteIf(key T, cond func(obj V) bool) bool {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if current, exists := shard.items[key]; !exists || !cond(current) {
+		return false
+	}
+	shard.delete(key)
+	s.counters.deletes.Add(1)
+	return true
+}
+
+// Get retrieves an object from the store.
+func (s *shardedStore[K, T, V]) Get(key T) (item V, exists bool) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	item, exists = shard.get(key)
+	s.counters.get(exists)
+	return item, exists
+}
+
+// GetMany retrieves many objects from the store, under a single read lock
+// per shard.
+func (s *shardedStore[K, T, V]) GetMany(keys []T) map[T]V {
+	items := make(map[T]V, len(keys))
+	for i, positions := range s.partition(keys) {
+		shardKeys := make([]T, len(positions))
+		for n, j := range positions {
+			shardKeys[n] = keys[j]
+		}
+		shard := s.shards[i]
+		shard.mu.RLock()
+		maps.Copy(items, shard.getMany(shardKeys, &s.counters))
+		shard.mu.RUnlock()
+	}
+	return items
+}
+
+// Contains reports whether an object is stored under key.
+func (s *shardedStore[K, T, V]) Contains(key T) bool {
+	return s.shard(key).Contains(key)
+}
+
+// List lists all objects in the store.
+func (s *shardedStore[K, T, V]) List() []V {
+	list := make([]V, 0, s.Size())
+	s.Range(func(key T, obj V) bool {
+		list = append(list, obj)
+		return true
+	})
+	return list
+}
+
+// ListKeys lists all keys in the store one shard at a time, reusing the keys
+// each shard keeps until its next change.
+func (s *shardedStore[K, T, V]) ListKeys() []T {
+	list := make([]T, 0, s.Size())
+	for _, shard := range s.shards {
+		list = append(list, shard.cachedKeys()...)
+	}
+	return list
+}
+
+// RangeKeys calls fn for every key in the store until fn returns false, one
+// shard at a time, iterating over the keys each shard keeps like ListKeys.
+func (s *shardedStore[K, T, V]) RangeKeys(fn func(key T) bool) {
+	for _, shard := range s.shards {
+		for _, key := range shard.cachedKeys() {
+			if !fn(key) {
+				return
+			}
+		}
+	}
+}
+
+// ListFiltered lists the objects for which pred returns true.
+func (s *shardedStore[K, T, V]) ListFiltered(pred func(obj V) bool) []V {
+	var list []V
+	s.Range(func(key T, obj V) bool {
+		if pred(obj) {
+			list = append(list, obj)
+		}
+		return true
+	})
+	return list
+}
+
+// ListKeysFiltered lists the keys of the objects for which pred returns true.
+func (s *shardedStore[K, T, V]) ListKeysFiltered(pred func(obj V) bool) []T {
+	var list []T
+	s.Range(func(key T, obj V) bool {
+		if pred(obj) {
+			list = append(list, key)
+		}
+		return true
+	})
+	return list
+}
+
+// Range calls fn for every object in the store until fn returns false,
+// holding the read lock of one shard at a time.
+func (s *shardedStore[K, T, V]) Range(fn func(key T, obj V) bool) {
+	for _, shard := range s.shards {
+		stopped := false
+		shard.mu.RLock()
+		shard.rangeItems(func(key T, obj V) bool {
+			stopped = !fn(key, obj)
+			return !stopped
+		})
+		shard.mu.RUnlock()
+		if stopped {
+			return
+		}
+	}
+}
+
+// All returns an iterator over the keys and objects in the store.
+func (s *shardedStore[K, T, V]) All() iter.Seq2[T, V] {
+	return s.Range
+}
+
+// ListPage returns a page of objects and the continue token of the next one.
+func (s *shardedStore[K, T, V]) ListPage(limit int, continueToken string) ([]V, string, error) {
+	p, err := newPager(limit, continueToken)
+	if err != nil {
+		return nil, "", err
+	}
+	s.rlockAll()
+	defer s.runlockAll()
+	// The page is among the first limit keys of every shard
+	var entries []pageEntry[T]
+	var more bool
+	for _, shard := range s.shards {
+		page, shardMore := selectPage(p, shard.sortedKeys(), nil)
+		entries = append(entries, page...)
+		more = more || shardMore
+	}
+	slices.SortFunc(entries, func(a, b pageEntry[T]) int {
+		return a.pos.compare(b.pos)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries, more = entries[:limit], true
+	}
+	items := make([]V, len(entries))
+	for i, e := range entries {
+		items[i] = s.shard(e.key).items[e.key]
+	}
+	return items, nextToken(entries, more), nil
+}
+
+// Replace replaces all objects in the store.
+func (s *shardedStore[K, T, V]) Replace(items map[T]V) error {
+	_, err := s.Swap(items)
+	return err
+}
+
+// ReplaceIncremental replaces all objects in the store, updating the indices
+// of the changed ones only.
+func (s *shardedStore[K, T, V]) ReplaceIncremental(items map[T]V) error {
+	parts := s.split(items)
+	s.lockAll()
+	defer s.unlockAll()
+	var errs []error
+	for i, shard := range s.shards {
+		errs = append(errs, shard.replaceIncremental(parts[i]))
+	}
+	return errors.Join(errs...)
+}
+
+// Swap replaces all objects in the store and returns the previous ones.
+func (s *shardedStore[K, T, V]) Swap(items map[T]V) (map[T]V, error) {
+	parts := s.split(items)
+	s.lockAll()
+	defer s.unlockAll()
+	old := make(map[T]V)
+	var errs []error
+	for i, shard := range s.shards {
+		maps.Copy(old, shard.items)
+		errs = append(errs, shard.replace(parts[i]))
+	}
+	return old, errors.Join(errs...)
+}
+
+// Snapshot returns an independent copy of the store, sharded the same way.
+func (s *shardedStore[K, T, V]) Snapshot() ThreadSafeStore[K, T, V] {
+	s.rlockAll()
+	defer s.runlockAll()
+	copied := &shardedStore[K, T, V]{
+		shards: make([]*threadSafeMap[K, T, V], len(s.shards)),
+		hasher: s.hasher,
+	}
+	for i, shard := range s.shards {
+		copied.shards[i] = shard.snapshot(nil)
+	}
+	return copied
+}
+
+// Index retrieves objects by index from every shard.
+func (s *shardedStore[K, T, V]) Index(indexName string, obj V, lessFunc func(lhs, rhs T) bool) ([]V, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	_, items, err := s.query(func(shard *threadSafeMap[K, T, V]) (sets.Set[T], error) {
+		return shard.index.getKeysFromIndex(indexName, obj)
+	}, lessFunc)
+	return items, err
+}
+
+// ByIndex retrieves objects by indexed value from every shard.
+func (s *shardedStore[K, T, V]) ByIndex(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]V, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	_, items, err := s.query(func(shard *threadSafeMap[K, T, V]) (sets.Set[T], error) {
+		return shard.index.getKeysByIndex(indexName, indexedValue)
+	}, lessFunc)
+	return items, err
+}
+
+// IndexKeys retrieves keys by index from every shard.
+func (s *shardedStore[K, T, V]) IndexKeys(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]T, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	keys, _, err := s.query(func(shard *threadSafeMap[K, T, V]) (sets.Set[T], error) {
+		return shard.index.getKeysByIndex(indexName, indexedValue)
+	}, lessFunc)
+	return keys, err
+}
+
+// CountByIndex returns the number of objects holding the indexed value in
+// every shard.
+func (s *shardedStore[K, T, V]) CountByIndex(indexName string, indexedValue K) (int, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return 0, err
+	}
+	s.rlockAll()
+	defer s.runlockAll()
+	s.counters.indexQueries.Add(1)
+	var count int
+	for _, shard := range s.shards {
+		shardCount, err := shard.countByIndex(indexName, indexedValue)
+		if err != nil {
+			return 0, err
+		}
+		count += shardCount
+	}
+	return count, nil
+}
+
+// ByIndexValues retrieves the objects matching any of the indexed values from
+// every shard.
+func (s *shardedStore[K, T, V]) ByIndexValues(indexName string, indexedValues ...K) ([]V, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	_, items, err := s.query(func(shard *threadSafeMap[K, T, V]) (sets.Set[T], error) {
+		return shard.index.getKeysByIndexValues(indexName, indexedValues)
+	}, nil)
+	return items, err
+}
+
+// Query returns a Query over the objects of every shard.
+func (s *shardedStore[K, T, V]) Query() *Query[K, T, V] {
+	return newQuery(s.ByIndexes, s.Range)
+}
+
+// ByIndexes retrieves the objects matching the indexed value of every index in
+// queries from every shard. Since a key belongs to a single shard, the
+// intersection of every shard is computed on its own.
+func (s *shardedStore[K, T, V]) ByIndexes(queries map[string]K) ([]V, error) {
+	for indexName := range queries {
+		if err := s.buildIndex(indexName); err != nil {
+			return nil, err
+		}
+	}
+	_, items, err := s.query(func(shard *threadSafeMap[K, T, V]) (sets.Set[T], error) {
+		return shard.index.getKeysByIndexes(queries)
+	}, nil)
+	return items, err
+}
+
+// IndexValues retrieves the distinct indexed values of an index across all
+// shards.
+func (s *shardedStore[K, T, V]) IndexValues(indexName string) ([]K, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	s.rlockAll()
+	defer s.runlockAll()
+	s.counters.indexQueries.Add(1)
+	values := sets.NewSet[K]()
+	for _, shard := range s.shards {
+		shardValues, err := shard.index.listIndexValues(indexName)
+		if err != nil {
+			return nil, err
+		}
+		values.Insert(shardValues...)
+	}
+	return values.UnsortedList(), nil
+}
+
+// IndexStats returns the number of keys held by each value of an index,
+// summed across all shards.
+func (s *shardedStore[K, T, V]) IndexStats(indexName string) (IndexStats[K], error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return IndexStats[K]{}, err
+	}
+	s.rlockAll()
+	defer s.runlockAll()
+	counts := make(map[K]int)
+	for _, shard := range s.shards {
+		shardCounts, err := shard.index.valueCounts(indexName)
+		if err != nil {
+			return IndexStats[K]{}, err
+		}
+		for indexValue, count := range shardCounts {
+			counts[indexValue] += count
+		}
+	}
+	return newIndexStats(counts), nil
+}
+
+// GroupByIndex returns the keys held by each value of an index in every
+// shard.
+func (s *shardedStore[K, T, V]) GroupByIndex(indexName string) (map[K][]T, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	s.rlockAll()
+	defer s.runlockAll()
+	s.counters.indexQueries.Add(1)
+	groups := make(map[K][]T)
+	for _, shard := range s.shards {
+		shardGroups, err := shard.index.groupKeys(indexName)
+		if err != nil {
+			return nil, err
+		}
+		for indexValue, keys := range shardGroups {
+			groups[indexValue] = append(groups[indexValue], keys...)
+		}
+	}
+	return groups, nil
+}
+
+// GroupCountsByIndex returns the number of keys held by each value of an
+// index in every shard.
+func (s *shardedStore[K, T, V]) GroupCountsByIndex(indexName string) (map[K]int, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	s.rlockAll()
+	defer s.runlockAll()
+	s.counters.indexQueries.Add(1)
+	counts := make(map[K]int)
+	for _, shard := range s.shards {
+		shardCounts, err := shard.index.valueCounts(indexName)
+		if err != nil {
+			return nil, err
+		}
+		for indexValue, count := range shardCounts {
+			counts[indexValue] += count
+		}
+	}
+	return counts, nil
+}
+
+// AddIndexers adds new indexers to every shard.
+func (s *shardedStore[K, T, V]) AddIndexers(newIndexers ValueIndexers[K, V]) error {
+	s.lockAll()
+	defer s.unlockAll()
+	for i, shard := range s.shards {
+		if err := shard.addIndexers(newIndexers); err != nil {
+			// Remove the indexers from the shards that accepted them
+			names := slices.Collect(maps.Keys(newIndexers))
+			for _, done := range s.shards[:i] {
+				done.index.removeIndexers(names...)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// AddIndexersAsync adds new indexers to every shard, indexing the objects of
+// one shard chunk at a time in the background, and publishes them in all
+// shards at once.
+func (s *shardedStore[K, T, V]) AddIndexersAsync(newIndexers ValueIndexers[K, V], chunkSize int) <-chan error {
+	done := make(chan error, 1)
+	names := slices.Collect(maps.Keys(newIndexers))
+	keys := make([][]T, len(s.shards))
+	s.lockAll()
+	for i, shard := range s.shards {
+		if err := shard.index.addPending(newIndexers); err != nil {
+			for _, added := range s.shards[:i] {
+				added.index.dropPending(names)
+			}
+			s.unlockAll()
+			done <- err
+			close(done)
+			return done
+		}
+		keys[i] = shard.listKeys()
+	}
+	s.unlockAll()
+
+	// abandon drops the pending indices of every shard
+	abandon := func() {
+		s.lockAll()
+		defer s.unlockAll()
+		for _, shard := range s.shards {
+			shard.index.dropPending(names)
+		}
+	}
+	go func() {
+		defer close(done)
+		for i, shard := range s.shards {
+			for chunk := range slices.Chunk(keys[i], reindexChunk(chunkSize)) {
+				shard.mu.Lock()
+				err := shard.index.indexPending(names, chunk, shard.items)
+				shard.mu.Unlock()
+				if err != nil {
+					abandon()
+					done <- err
+					return
+				}
+			}
+		}
+		s.lockAll()
+		defer s.unlockAll()
+		for _, shard := range s.shards {
+			if err := shard.index.indexPending(names, nil, nil); err != nil {
+				for _, shard := range s.shards {
+					shard.index.dropPending(names)
+				}
+				done <- err
+				return
+			}
+		}
+		for _, shard := range s.shards {
+			shard.index.publish(names)
+		}
+		done <- nil
+	}()
+	return done
+}
+
+// AddIndexer adds new indexer to every shard.
+func (s *shardedStore[K, T, V]) AddIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
+	return s.AddIndexers(ValueIndexers[K, V]{indexName: indexFunc})
+}
+
+// RemoveIndexer removes an indexer and its index from every shard.
+func (s *shardedStore[K, T, V]) RemoveIndexer(indexName string) error {
+	s.lockAll()
+	defer s.unlockAll()
+	// The shards have the same indexers, so the first one decides
+	if err := s.shards[0].index.removeIndexer(indexName); err != nil {
+		return err
+	}
+	for _, shard := range s.shards[1:] {
+		shard.index.removeIndexers(indexName)
+	}
+	return nil
+}
+
+// AddUniqueIndexer fails with errors.ErrUnsupported: the shards are locked
+// separately, so none of them could tell whether another one holds a value.
+func (s *shardedStore[K, T, V]) AddUniqueIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
+	return fmt.Errorf("unique indexer %s across shards: %w", indexName, errors.ErrUnsupported)
+}
+
+// GetByUniqueIndex fails, since a sharded store has no unique index.
+func (s *shardedStore[K, T, V]) GetByUniqueIndex(indexName string, indexedValue K) (V, bool, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return s.shards[0].GetByUniqueIndex(indexName, indexedValue)
+}
+
+// AddOrderedIndexer adds new ordered indexer to every shard.
+func (s *shardedStore[K, T, V]) AddOrderedIndexer(indexName string, indexFunc ValueIndexFunc[K, V], compare func(a, b K) int) error {
+	s.lockAll()
+	defer s.unlockAll()
+	for i, shard := range s.shards {
+		if err := shard.addOrderedIndexer(indexName, indexFunc, compare); err != nil {
+			// Remove the indexer from the shards that accepted it
+			for _, done := range s.shards[:i] {
+				done.index.removeIndexers(indexName)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// SetIndexNormalizer makes normalize map the values of an index of every
+// shard and rebuilds them.
+func (s *shardedStore[K, T, V]) SetIndexNormalizer(indexName string, normalize func(K) K) error {
+	s.lockAll()
+	defer s.unlockAll()
+	// The shards have no unique index, so rebuilding them only fails if the
+	// index does not exist, which the first one decides
+	for _, shard := range s.shards {
+		if err := shard.setIndexNormalizer(indexName, normalize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnIndexChange registers hook for the changes of an index of every shard.
+// Each shard invokes it under its own lock, so that it may be invoked
+// concurrently for keys of different shards.
+func (s *shardedStore[K, T, V]) OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error {
+	if err := s.buildIndex(indexName); err != nil {
+		return err
+	}
+	s.lockAll()
+	defer s.unlockAll()
+	// The shards have the same indexers, so the first one decides whether
+	// the index exists
+	for _, shard := range s.shards {
+		if err := shard.index.addHook(indexName, hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reindex rebuilds an index of every shard from its objects. If that fails in
+// a shard, the shards rebuilt already get their previous index back.
+func (s *shardedStore[K, T, V]) Reindex(indexName string) error {
+	s.lockAll()
+	defer s.unlockAll()
+	previous := make([]builtIndex[K, T], len(s.shards))
+	for i, shard := range s.shards {
+		built, err := shard.rebuildExisting(indexName)
+		if err != nil {
+			for j, done := range s.shards[:i] {
+				done.index.restoreIndex(indexName, previous[j])
+			}
+			return err
+		}
+		previous[i] = built
+	}
+	for i, shard := range s.shards {
+		shard.index.notifyIndexRebuilt(indexName, previous[i].index)
+	}
+	return nil
+}
+
+// ByIndexRange retrieves the objects holding a value in [from, to) from every
+// shard.
+func (s *shardedStore[K, T, V]) ByIndexRange(indexName string, from, to K) ([]V, error) {
+	return s.byRange(indexName, indexRange[K]{from: &from, fromInclusive: true, to: &to})
+}
+
+// ByIndexGreaterThan retrieves the objects holding a value greater than
+// indexedValue from every shard.
+func (s *shardedStore[K, T, V]) ByIndexGreaterThan(indexName string, indexedValue K) ([]V, error) {
+	return s.byRange(indexName, indexRange[K]{from: &indexedValue})
+}
+
+// ByIndexLessThan retrieves the objects holding a value less than
+// indexedValue from every shard.
+func (s *shardedStore[K, T, V]) ByIndexLessThan(indexName string, indexedValue K) ([]V, error) {
+	return s.byRange(indexName, indexRange[K]{to: &indexedValue})
+}
+
+// GetIndexers returns a copy of the indexers, which all shards share.
+func (s *shardedStore[K, T, V]) GetIndexers() ValueIndexers[K, V] {
+	return s.shards[0].GetIndexers()
+}
+
+// LockKey locks the mutex of key, held by its shard.
+func (s *shardedStore[K, T, V]) LockKey(key T) {
+	s.shard(key).LockKey(key)
+}
+
+// UnlockKey unlocks the mutex of key.
+func (s *shardedStore[K, T, V]) UnlockKey(key T) {
+	s.shard(key).UnlockKey(key)
+}
+
+// Size get count of elements in the store.
+func (s *shardedStore[K, T, V]) Size() int {
+	size := 0
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		size += len(shard.items)
+		shard.mu.RUnlock()
+	}
+	return size
+}
+
+// Stats returns the counters of the store.
+func (s *shardedStore[K, T, V]) Stats() Stats {
+	return s.counters.stats(s.Size())
+}
+
+// EstimateBytes estimates the memory held by the objects and indices of
+// every shard, holding the read lock of one shard at a time.
+func (s *shardedStore[K, T, V]) EstimateBytes(sizer func(obj V) int64) int64 {
+	var total int64
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		total += shard.estimateBytes(sizer)
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+// partition returns the positions of keys by shard.
+func (s *shardedStore[K, T, V]) partition(keys []T) map[int][]int {
+	positions := make(map[int][]int)
+	for j, key := range keys {
+		i := int(s.hasher(key) % uint64(len(s.shards)))
+		positions[i] = append(positions[i], j)
+	}
+	return positions
+}
+
+// split returns items by shard.
+func (s *shardedStore[K, T, V]) split(items map[T]V) []map[T]V {
+	parts := make([]map[T]V, len(s.shards))
+	for i := range parts {
+		parts[i] = make(map[T]V)
+	}
+	for key, obj := range items {
+		parts[s.hasher(key)%uint64(len(s.shards))][key] = obj
+	}
+	return parts
+}
+
+// query collects the keys and objects matching an index query in every shard,
+// sorted by lessFunc if it is not nil.
+func (s *shardedStore[K, T, V]) query(keysOf func(shard *threadSafeMap[K, T, V]) (sets.Set[T], error), lessFunc func(lhs, rhs T) bool) ([]T, []V, error) {
+	s.counters.indexQueries.Add(1)
+	var keys []T
+	items := make(map[T]V)
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		keySet, err := keysOf(shard)
+		if err != nil {
+			shard.mu.RUnlock()
+			return nil, nil, err
+		}
+		for key := range keySet {
+			keys = append(keys, key)
+			items[key] = shard.items[key]
+		}
+		shard.mu.RUnlock()
+	}
+	if lessFunc != nil {
+		slices.SortFunc(keys, func(lhs, rhs T) int {
+			switch {
+			case lessFunc(lhs, rhs):
+				return -1
+			case lessFunc(rhs, lhs):
+				return 1
+			default:
+				return 0
+			}
+		})
+	}
+	list := make([]V, len(keys))
+	for i, key := range keys {
+		list[i] = items[key]
+	}
+	return keys, list, nil
+}
+
+// matchValues returns the objects of every shard holding any of
+// indexedValues in the named index, along with how many of them each holds,
+// querying the shards in turn under their read lock.
+func (s *shardedStore[K, T, V]) matchValues(indexName string, indexedValues []K) ([]TokenMatch[V], error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	s.counters.indexQueries.Add(1)
+	var matches []TokenMatch[V]
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		shardMatches, err := shard.matchIndexValues(indexName, indexedValues)
+		shard.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, shardMatches...)
+	}
+	return matches, nil
+}
+
+// byRange queries the ordered index of every shard in turn under its read
+// lock, and merges the objects found in ascending order of their values.
+func (s *shardedStore[K, T, V]) byRange(indexName string, r indexRange[K]) ([]V, error) {
+	if err := s.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	s.counters.indexQueries.Add(1)
+	type found struct {
+		value K
+		item  V
+	}
+	var all []found
+	var compare func(a, b K) int
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		keys, values, err := shard.index.getKeysByRange(indexName, r)
+		if err != nil {
+			shard.mu.RUnlock()
+			return nil, err
+		}
+		compare = shard.index.sorted[indexName].compare
+		for i, key := range keys {
+			all = append(all, found{values[i], shard.items[key]})
+		}
+		shard.mu.RUnlock()
+	}
+	slices.SortStableFunc(all, func(a, b found) int {
+		return compare(a.value, b.value)
+	})
+	list := make([]V, len(all))
+	for i, f := range all {
+		list[i] = f.item
+	}
+	return list, nil
+}
+
+// buildIndex indexes the objects of every shard with the named indexer if it
+// was registered lazily and its index has not been built yet, see
+// threadSafeMap.buildIndex. If the IndexFunc fails, the indexer stays unbuilt
+// in every shard.
+func (s *shardedStore[K, T, V]) buildIndex(indexName string) error {
+	// The shards have the same indexers, so the first one decides
+	first := s.shards[0]
+	if !first.index.lazy {
+		return nil
+	}
+	first.mu.RLock()
+	unbuilt := first.index.isUnbuilt(indexName)
+	first.mu.RUnlock()
+	if !unbuilt {
+		return nil
+	}
+	s.lockAll()
+	defer s.unlockAll()
+	indexFunc := first.index.unbuilt[indexName]
+	for i, shard := range s.shards {
+		if err := shard.build(indexName); err != nil {
+			for _, built := range s.shards[:i] {
+				built.index.unbuild(indexName, indexFunc)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// lockAll locks every shard for writing, in order.
+func (s *shardedStore[K, T, V]) lockAll() {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+	}
+}
+
+// unlockAll unlocks every shard locked by lockAll.
+func (s *shardedStore[K, T, V]) unlockAll() {
+	for _, shard := range s.shards {
+		shard.mu.Unlock()
+	}
+}
+
+// rlockAll locks every shard for reading, in order.
+func (s *shardedStore[K, T, V]) rlockAll() {
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+	}
+}
+
+// runlockAll unlocks every shard locked by rlockAll.
+func (s *shardedStore[K, T, V]) runlockAll() {
+	for _, shard := range s.shards {
+		shard.mu.RUnlock()
+	}
+}
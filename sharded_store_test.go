@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hashString(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func newTestShardedStore() ThreadSafeStore[string, string, interface{}] {
+	return NewShardedThreadSafeStore[string, string](4, hashString, Indexers[string]{
+		"parity": func(obj any) ([]string, error) {
+			if obj.(int)%2 == 0 {
+				return []string{"even"}, nil
+			}
+			return []string{"odd"}, nil
+		},
+	})
+}
+
+func TestShardedThreadSafeStore(t *testing.T) {
+	store := newTestShardedStore()
+	for i := 0; i < 10; i++ {
+		store.Add(strconv.Itoa(i), i)
+	}
+	store.Delete("9")
+	swapped, err := store.CompareAndSwap("8", 8, 10)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	assert.Equal(t, 9, store.Size())
+	item, exists := store.Get("8")
+	assert.True(t, exists)
+	assert.Equal(t, 10, item)
+	assert.ElementsMatch(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"}, store.ListKeys())
+
+	// Index queries merge every shard and sort the result
+	keys, err := store.IndexKeys("parity", "odd", func(lhs, rhs string) bool { return lhs < rhs })
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "3", "5", "7"}, keys)
+	items, err := store.ByIndex("parity", "even", func(lhs, rhs string) bool { return lhs > rhs })
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{10, 6, 4, 2, 0}, items)
+	_, err = store.IndexKeys("missing", "odd", nil)
+	assert.Error(t, err)
+
+	stats := store.Stats()
+	assert.Equal(t, uint64(10), stats.Adds)
+	assert.Equal(t, uint64(1), stats.Updates)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(3), stats.IndexQueries)
+}
+
+func TestShardedThreadSafeStoreBatch(t *testing.T) {
+	store := newTestShardedStore()
+	store.UpdateAll([]string{"a", "b", "c"}, []interface{}{1, 2, 3})
+	store.Apply(map[string]interface{}{"d": 4}, []string{"a"})
+	assert.ElementsMatch(t, []string{"b", "c", "d"}, store.ListKeys())
+
+	old, err := store.Swap(map[string]interface{}{"e": 5, "f": 6})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"b": 2, "c": 3, "d": 4}, old)
+	keys, err := store.IndexKeys("parity", "even", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f"}, keys)
+
+	snapshot := store.Snapshot()
+	store.DeleteAll([]string{"e", "f"})
+	assert.Equal(t, 0, store.Size())
+	assert.Equal(t, 2, snapshot.Size())
+}
+
+func TestShardedThreadSafeStoreApplyError(t *testing.T) {
+	store := NewShardedThreadSafeStore[int, string](4, hashString, Indexers[int]{"value": nonNegativeIndexFunc})
+	assert.NoError(t, store.Add("a", 1))
+
+	// A failed object leaves every shard unchanged
+	err := store.Apply(map[string]interface{}{"b": 2, "c": 3, "d": 4, "e": -5}, []string{"a"})
+	assert.ErrorAs(t, err, &IndexError{})
+	assert.Equal(t, []string{"a"}, store.ListKeys())
+}
+
+func TestShardedThreadSafeStoreCompareAndSwapUncomparable(t *testing.T) {
+	store := NewShardedThreadSafeStore[string, string](4, hashString, Indexers[string]{})
+	store.Add("slice", []int{1})
+
+	// Objects that cannot be compared are never the same, rather than panic
+	swapped, err := store.CompareAndSwap("slice", []int{1}, []int{2})
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	assert.False(t, store.CompareAndDelete("slice", []int{1}))
+	assert.Equal(t, 1, store.Size())
+}
+
+func TestShardedThreadSafeStoreListPage(t *testing.T) {
+	store := newTestShardedStore()
+	for i := 0; i < 5; i++ {
+		store.Add(fmt.Sprintf("k%d", i), i)
+	}
+
+	items, next, err := store.ListPage(3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{0, 1, 2}, items)
+	items, next, err = store.ListPage(3, next)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{3, 4}, items)
+	assert.Empty(t, next)
+}
+
+func TestShardedThreadSafeStoreConcurrent(t *testing.T) {
+	store := newTestShardedStore()
+	increment := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, false
+		}
+		return old.(int) + 1, false
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Compute(strconv.Itoa(j%10), increment)
+				store.List()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for j := 0; j < 10; j++ {
+		item, _ := store.Get(strconv.Itoa(j))
+		assert.Equal(t, 80, item)
+	}
+}
+
+func TestShardedThreadSafeStoreReplaceIncremental(t *testing.T) {
+	store := newTestShardedStore()
+	store.UpdateAll([]string{"a", "b", "c"}, []interface{}{1, 2, 3})
+	store.ReplaceIncremental(map[string]interface{}{"a": 1, "b": 3, "d": 4})
+
+	assert.ElementsMatch(t, []string{"a", "b", "d"}, store.ListKeys())
+	keys, err := store.IndexKeys("parity", "odd", func(lhs, rhs string) bool { return lhs < rhs })
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"cmp"
+	"math/rand/v2"
+)
+
+// skipListMaxLevel bounds the height of skip list nodes, enough for far more
+// keys than fit in memory with a branching factor of 4.
+const skipListMaxLevel = 24
+
+// skipListNode is a key linked into the levels of a skipList.
+type skipListNode[T any] struct {
+	key T
+	// prev links the nodes of the lowest level backwards, nil for the first one
+	prev *skipListNode[T]
+	next []*skipListNode[T]
+}
+
+// skipList is a sorted set of keys supporting ordered traversal in both
+// directions. It is not safe for concurrent use.
+type skipList[T any] struct {
+	head   *skipListNode[T]
+	tail   *skipListNode[T]
+	level  int
+	length int
+	// compare orders the keys like cmp.Compare
+	compare func(a, b T) int
+}
+
+// newSkipList creates an empty skipList of keys in their natural order.
+func newSkipList[T cmp.Ordered]() *skipList[T] {
+	return newSkipListFunc(cmp.Compare[T])
+}
+
+// newSkipListFunc creates an empty skipList of keys ordered by compare.
+func newSkipListFunc[T any](compare func(a, b T) int) *skipList[T] {
+	return &skipList[T]{
+		head:    &skipListNode[T]{next: make([]*skipListNode[T], skipListMaxLevel)},
+		level:   1,
+		compare: compare,
+	}
+}
+
+// insert adds key to the list and reports whether it was missing.
+func (l *skipList[T]) insert(key T) bool {
+	var update [skipListMaxLevel]*skipListNode[T]
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && l.compare(x.next[i].key, key) < 0 {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	if next := x.next[0]; next != nil && l.compare(next.key, key) == 0 {
+		return false
+	}
+
+	level := randomSkipListLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			update[i] = l.head
+		}
+		l.level = level
+	}
+	node := &skipListNode[T]{key: key, next: make([]*skipListNode[T], level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+	if update[0] != l.head {
+		node.prev = update[0]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node
+	} else {
+		l.tail = node
+	}
+	l.length++
+	return true
+}
+
+// delete removes key from the list and reports whether it was present.
+func (l *skipList[T]) delete(key T) bool {
+	var update [skipListMaxLevel]*skipListNode[T]
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && l.compare(x.next[i].key, key) < 0 {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	node := x.next[0]
+	if node == nil || l.compare(node.key, key) != 0 {
+		return false
+	}
+
+	for i := 0; i < len(node.next); i++ {
+		update[i].next[i] = node.next[i]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node.prev
+	} else {
+		l.tail = node.prev
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+// seek returns the node of the smallest key not less than key, or nil if
+// every key is less.
+func (l *skipList[T]) seek(key T) *skipListNode[T] {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && l.compare(x.next[i].key, key) < 0 {
+			x = x.next[i]
+		}
+	}
+	return x.next[0]
+}
+
+// first returns the node of the smallest key, or nil if the list is empty.
+func (l *skipList[T]) first() *skipListNode[T] {
+	return l.head.next[0]
+}
+
+// last returns the node of the largest key, or nil if the list is empty.
+func (l *skipList[T]) last() *skipListNode[T] {
+	return l.tail
+}
+
+// randomSkipListLevel returns the height of a new node, each level being
+// reached with a probability of 1/4.
+func randomSkipListLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Uint32()&3 == 0 {
+		level++
+	}
+	return level
+}
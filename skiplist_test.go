@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// skipListKeys returns the keys of l in ascending and descending order.
+func skipListKeys[T int | string](l *skipList[T]) ([]T, []T) {
+	var asc, desc []T
+	for n := l.first(); n != nil; n = n.next[0] {
+		asc = append(asc, n.key)
+	}
+	for n := l.last(); n != nil; n = n.prev {
+		desc = append(desc, n.key)
+	}
+	return asc, desc
+}
+
+func TestSkipList(t *testing.T) {
+	l := newSkipList[int]()
+	assert.True(t, l.insert(3))
+	assert.True(t, l.insert(1))
+	assert.True(t, l.insert(2))
+	assert.False(t, l.insert(2))
+
+	asc, desc := skipListKeys(l)
+	assert.Equal(t, []int{1, 2, 3}, asc)
+	assert.Equal(t, []int{3, 2, 1}, desc)
+	assert.Equal(t, 2, l.seek(2).key)
+	assert.Nil(t, l.seek(4))
+
+	assert.True(t, l.delete(3))
+	assert.False(t, l.delete(3))
+	asc, desc = skipListKeys(l)
+	assert.Equal(t, []int{1, 2}, asc)
+	assert.Equal(t, []int{2, 1}, desc)
+	assert.Equal(t, 2, l.length)
+}
+
+func TestSkipListRandom(t *testing.T) {
+	l := newSkipList[int]()
+	present := make(map[int]bool)
+	for i := 0; i < 10000; i++ {
+		key := rand.IntN(500)
+		if rand.IntN(3) == 0 {
+			assert.Equal(t, present[key], l.delete(key))
+			delete(present, key)
+		} else {
+			assert.Equal(t, !present[key], l.insert(key))
+			present[key] = true
+		}
+	}
+
+	var want []int
+	for key := range present {
+		want = append(want, key)
+	}
+	slices.Sort(want)
+	asc, desc := skipListKeys(l)
+	assert.Equal(t, want, asc)
+	slices.Reverse(want)
+	assert.Equal(t, want, desc)
+	assert.Equal(t, len(want), l.length)
+}
+
+func TestSkipListFunc(t *testing.T) {
+	l := newSkipListFunc(func(a, b int) int { return b - a })
+	for _, key := range []int{2, 3, 1} {
+		assert.True(t, l.insert(key))
+	}
+	assert.False(t, l.insert(3))
+
+	asc, _ := skipListKeys(l)
+	assert.Equal(t, []int{3, 2, 1}, asc)
+	assert.Equal(t, 1, l.seek(1).key)
+	assert.True(t, l.delete(2))
+	asc, _ = skipListKeys(l)
+	assert.Equal(t, []int{3, 1}, asc)
+}
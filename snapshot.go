@@ -0,0 +1,120 @@
+package cache
+
+// snapshot is a read-only IndexedStore holding a copy of another store.
+type snapshot[K, T comparable] struct {
+	*cache[K, T]
+}
+
+var _ IndexedStore[any, any] = snapshot[any, any]{}
+
+// newSnapshot creates a snapshot serving the objects of store.
+func newSnapshot[K, T comparable](keyFunc KeyFunc[T], store ThreadSafeStore[K, T, interface{}]) snapshot[K, T] {
+	return snapshot[K, T]{&cache[K, T]{store: store, keyFunc: keyFunc}}
+}
+
+// Add fails with ErrReadOnly.
+func (s snapshot[K, T]) Add(obj interface{}) error {
+	return ErrReadOnly
+}
+
+// Update fails with ErrReadOnly.
+func (s snapshot[K, T]) Update(obj interface{}) error {
+	return ErrReadOnly
+}
+
+// Delete fails with ErrReadOnly.
+func (s snapshot[K, T]) Delete(obj interface{}) error {
+	return ErrReadOnly
+}
+
+// AddAll fails with ErrReadOnly for every object.
+func (s snapshot[K, T]) AddAll(objs []interface{}) []error {
+	return readOnlyErrors(len(objs))
+}
+
+// UpdateAll fails with ErrReadOnly for every object.
+func (s snapshot[K, T]) UpdateAll(objs []interface{}) []error {
+	return readOnlyErrors(len(objs))
+}
+
+// DeleteAll fails with ErrReadOnly for every object.
+func (s snapshot[K, T]) DeleteAll(objs []interface{}) []error {
+	return readOnlyErrors(len(objs))
+}
+
+// CompareAndSwap fails with ErrReadOnly.
+func (s snapshot[K, T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	return false, ErrReadOnly
+}
+
+// CompareAndDelete fails with ErrReadOnly.
+func (s snapshot[K, T]) CompareAndDelete(old interface{}) (bool, error) {
+	return false, ErrReadOnly
+}
+
+// Replace fails with ErrReadOnly.
+func (s snapshot[K, T]) Replace(list []interface{}) error {
+	return ErrReadOnly
+}
+
+// ReplaceWithDiff fails with ErrReadOnly.
+func (s snapshot[K, T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	return nil, nil, nil, ErrReadOnly
+}
+
+// Txn fails with ErrReadOnly without calling fn.
+func (s snapshot[K, T]) Txn(fn func(tx Txn[T]) error) error {
+	return ErrReadOnly
+}
+
+// AddIndexer fails with ErrReadOnly.
+func (s snapshot[K, T]) AddIndexer(indexName string, indexFunc IndexFunc[K]) error {
+	return ErrReadOnly
+}
+
+// AddIndexers fails with ErrReadOnly.
+func (s snapshot[K, T]) AddIndexers(newIndexers Indexers[K]) error {
+	return ErrReadOnly
+}
+
+// AddUniqueIndexer fails with ErrReadOnly.
+func (s snapshot[K, T]) AddUniqueIndexer(indexName string, indexFunc IndexFunc[K]) error {
+	return ErrReadOnly
+}
+
+// AddOrderedIndexer fails with ErrReadOnly.
+func (s snapshot[K, T]) AddOrderedIndexer(indexName string, indexFunc IndexFunc[K], compare func(a, b K) int) error {
+	return ErrReadOnly
+}
+
+// Reindex fails with ErrReadOnly.
+func (s snapshot[K, T]) Reindex(indexName string) error {
+	return ErrReadOnly
+}
+
+// SetIndexNormalizer fails with ErrReadOnly.
+func (s snapshot[K, T]) SetIndexNormalizer(indexName string, normalize func(K) K) error {
+	return ErrReadOnly
+}
+
+// RemoveIndexer fails with ErrReadOnly.
+func (s snapshot[K, T]) RemoveIndexer(indexName string) error {
+	return ErrReadOnly
+}
+
+// Snapshot returns the snapshot itself, since it never changes.
+func (s snapshot[K, T]) Snapshot() Store[T] {
+	return s
+}
+
+// readOnlyErrors returns n ErrReadOnly errors for a batch operation.
+func readOnlyErrors(n int) []error {
+	if n == 0 {
+		return nil
+	}
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = ErrReadOnly
+	}
+	return errs
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/liuxinbot/cache/eviction"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	store := NewIndexer[int](testKeyFunc)
+	assert.NoError(t, store.AddIndexer("length", func(obj interface{}) ([]int, error) {
+		return []int{len(obj.(string))}, nil
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b", "cc"}))
+
+	snap := store.Snapshot()
+	assert.NoError(t, store.Delete("a"))
+	assert.NoError(t, store.Add("dd"))
+
+	assert.ElementsMatch(t, []string{"a", "b", "cc"}, snap.ListKeys())
+	indexed, ok := snap.(IndexedStore[int, string])
+	assert.True(t, ok)
+	keys, err := indexed.ListKeysByIndex("length", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cc"}, keys)
+	keys, err = store.ListKeysByIndex("length", 2)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"cc", "dd"}, keys)
+}
+
+func TestSnapshotReadOnly(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.NoError(t, store.Add("a"))
+	snap := store.Snapshot()
+
+	assert.ErrorIs(t, snap.Add("b"), ErrReadOnly)
+	assert.ErrorIs(t, snap.Update("a"), ErrReadOnly)
+	assert.ErrorIs(t, snap.Delete("a"), ErrReadOnly)
+	assert.ErrorIs(t, snap.Replace(nil), ErrReadOnly)
+	assert.Equal(t, []error{ErrReadOnly, ErrReadOnly}, snap.AddAll([]interface{}{"b", "c"}))
+	swapped, err := snap.CompareAndSwap("a", "a")
+	assert.False(t, swapped)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.Equal(t, []interface{}{"a"}, snap.List())
+	assert.Equal(t, snap, snap.Snapshot())
+}
+
+func TestEvictionCacheSnapshot(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithPolicy[int, int](eviction.NewLRU[int](3)),
+		WithClock[int, int](clock))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.AddWithTTL(3, time.Second))
+	clock.Step(time.Second)
+
+	snap := store.Snapshot()
+	assert.ElementsMatch(t, []int{1, 2}, snap.ListKeys())
+
+	// Taking a snapshot is not an access, so 1 is still the least recently used
+	assert.Zero(t, store.Stats().Hits)
+	assert.Equal(t, []int{1}, store.EvictN(1))
+	_, exists, err := snap.GetByKey(1)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
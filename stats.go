@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+// StatsProvider is implemented by the stores reporting usage counters, such
+// as those created by NewStore, NewIndexer, NewThreadSafeStore and
+// NewEvictionCache.
+type StatsProvider interface {
+	// Stats returns the counters collected since the store was created.
+	Stats() Stats
+}
+
+// Stats holds the usage counters of a store. The embedded eviction.Stats
+// reports the number of objects as Size, and lookups by Get, GetByKey and
+// GetMany that found their key or not as Hits and Misses. Stores with an
+// eviction policy report the counters of the policy instead, which also
+// count the accesses made by other methods, along with Puts, Evictions and
+// Capacity, which are zero otherwise.
+type Stats struct {
+	eviction.Stats
+
+	Adds         uint64 // Number of objects stored by Add, AddAll and similar methods.
+	Updates      uint64 // Number of objects stored by Update, UpdateAll, CompareAndSwap and Txn.
+	Deletes      uint64 // Number of objects removed by Delete, DeleteAll, CompareAndDelete and Txn.
+	Gets         uint64 // Number of keys looked up by Get, GetByKey and GetMany.
+	IndexQueries uint64 // Number of index lookups, such as ListByIndex.
+}
+
+// IndexStats describes how the keys of a store are spread over the values of
+// an index, such as to find a value held by most keys. It is computed on
+// demand by IndexStats, which is not counted as an index query.
+type IndexStats[K comparable] struct {
+	Values         int       // Number of distinct values.
+	Postings       int       // Number of keys held by the values, counting a key once per value.
+	MaxBucket      int       // Number of keys held by the value holding the most.
+	MaxBucketValue K         // The value holding MaxBucket keys, one of them if several do.
+	Counts         map[K]int // Number of keys held by each value.
+}
+
+// newIndexStats returns the IndexStats of an index whose values hold the
+// numbers of keys in counts.
+func newIndexStats[K comparable](counts map[K]int) IndexStats[K] {
+	stats := IndexStats[K]{Values: len(counts), Counts: counts}
+	for indexValue, count := range counts {
+		stats.Postings += count
+		if count > stats.MaxBucket {
+			stats.MaxBucket = count
+			stats.MaxBucketValue = indexValue
+		}
+	}
+	return stats
+}
+
+// mapEntryBytes is the estimated overhead of a map entry besides its key and
+// value, used by EstimateBytes.
+const mapEntryBytes = 16
+
+// valueBytes estimates the memory held by v, including the bytes of strings.
+func valueBytes[V comparable](v V) int64 {
+	size := int64(unsafe.Sizeof(v))
+	if s, ok := any(v).(string); ok {
+		size += int64(len(s))
+	}
+	return size
+}
+
+// storeCounters counts the operations of a store for its Stats. Replacements
+// are not counted.
+type storeCounters struct {
+	adds         atomic.Uint64
+	updates      atomic.Uint64
+	deletes      atomic.Uint64
+	gets         atomic.Uint64
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	indexQueries atomic.Uint64
+}
+
+// get counts a lookup that found its key if exists.
+func (c *storeCounters) get(exists bool) {
+	c.gets.Add(1)
+	if exists {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// stats returns the counters of a store holding size objects.
+func (c *storeCounters) stats(size int) Stats {
+	return Stats{
+		Stats: eviction.Stats{
+			Hits:   c.hits.Load(),
+			Misses: c.misses.Load(),
+			Size:   size,
+		},
+		Adds:         c.adds.Load(),
+		Updates:      c.updates.Load(),
+		Deletes:      c.deletes.Load(),
+		Gets:         c.gets.Load(),
+		IndexQueries: c.indexQueries.Load(),
+	}
+}
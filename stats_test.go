@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+func TestCacheStats(t *testing.T) {
+	store := NewIndexer[string](testKeyFunc)
+	assert.NoError(t, store.AddIndexer("length", func(obj interface{}) ([]string, error) {
+		return []string{strconv.Itoa(len(obj.(string)))}, nil
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+	assert.NoError(t, store.Update("a"))
+	assert.NoError(t, store.Delete("b"))
+	_, _, _ = store.GetByKey("a")
+	_, _ = store.GetMany([]string{"a", "b", "c"})
+	_, err := store.ListByIndex("length", "1")
+	assert.NoError(t, err)
+
+	stats := store.(StatsProvider).Stats()
+	assert.Equal(t, uint64(2), stats.Adds)
+	assert.Equal(t, uint64(1), stats.Updates)
+	assert.Equal(t, uint64(1), stats.Deletes)
+	assert.Equal(t, uint64(4), stats.Gets)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, 0.5, stats.HitRatio())
+	assert.Equal(t, uint64(1), stats.IndexQueries)
+	assert.Equal(t, 1, stats.Size)
+}
+
+func TestCacheStatsLimits(t *testing.T) {
+	store := NewStore(testKeyFunc, WithMaxEntries(1))
+	assert.NoError(t, store.Add("a"))
+	assert.ErrorIs(t, store.Add("b"), ErrStoreFull)
+
+	// Rejected objects are not counted
+	assert.Equal(t, uint64(1), store.(StatsProvider).Stats().Adds)
+}
+
+func TestThreadSafeStoreStats(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.Add("a", 1)
+	store.UpdateAll([]string{"a", "b"}, []interface{}{2, 3})
+	store.Delete("a")
+	store.Get("a")
+	store.Get("b")
+
+	stats := store.Stats()
+	assert.Equal(t, uint64(1), stats.Adds)
+	assert.Equal(t, uint64(2), stats.Updates)
+	assert.Equal(t, uint64(1), stats.Deletes)
+	assert.Equal(t, uint64(2), stats.Gets)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, 1, stats.Size)
+}
+
+func TestEvictionCacheStoreStats(t *testing.T) {
+	store := NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), make(Indexers[int]))
+	assert.NoError(t, store.Add(1))
+	assert.Nil(t, store.UpdateAll([]interface{}{1, 2, 3}))
+	assert.NoError(t, store.Delete(3))
+	_, _, _ = store.GetByKey(2)
+	_, _, _ = store.GetByKey(3)
+
+	stats := store.Stats()
+	assert.Equal(t, uint64(1), stats.Adds)
+	assert.Equal(t, uint64(3), stats.Updates)
+	assert.Equal(t, uint64(1), stats.Deletes)
+	assert.Equal(t, uint64(2), stats.Gets)
+	// Hits, misses and evictions come from the policy
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 1, stats.Size)
+}
+
+func TestThreadSafeStoreEstimateBytes(t *testing.T) {
+	sizer := func(obj interface{}) int64 { return 100 }
+	store := NewThreadSafeStore[int, string](Indexers[int]{}, Indexes[int, string]{})
+	assert.Equal(t, int64(0), store.EstimateBytes(sizer))
+
+	assert.NoError(t, store.Add("a", 1))
+	withObject := store.EstimateBytes(sizer)
+	assert.Greater(t, withObject, int64(100))
+
+	// Index entries are accounted for
+	assert.NoError(t, store.AddIndexer("value", nonNegativeIndexFunc))
+	assert.Greater(t, store.EstimateBytes(sizer), withObject)
+
+	// The sharded store sums up its shards
+	sharded := NewShardedThreadSafeStore(4, hashString, Indexers[int]{"value": nonNegativeIndexFunc})
+	assert.NoError(t, sharded.Add("a", 1))
+	assert.Equal(t, store.EstimateBytes(sizer), sharded.EstimateBytes(sizer))
+}
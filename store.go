@@ -1,6 +1,10 @@
 package cache
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"iter"
+)
 
 // Store defines a basic storage interface.
 type Store[T comparable] interface {
@@ -13,25 +17,114 @@ type Store[T comparable] interface {
 	// Delete removes an object.
 	Delete(obj interface{}) error
 
+	// AddAll inserts objects in a single batch. It returns nil if every object
+	// was stored, and otherwise the error of each object at its position.
+	AddAll(objs []interface{}) []error
+
+	// UpdateAll modifies objects in a single batch, returning errors like AddAll.
+	UpdateAll(objs []interface{}) []error
+
+	// DeleteAll removes objects in a single batch, returning errors like AddAll.
+	DeleteAll(objs []interface{}) []error
+
 	// List returns all objects.
 	List() []interface{}
 
 	// ListKeys returns all keys.
 	ListKeys() []T
 
+	// ListFiltered returns the objects for which pred returns true, selected
+	// in a single pass. pred must not modify the store.
+	ListFiltered(pred func(obj interface{}) bool) []interface{}
+
+	// ListKeysFiltered returns the keys of the objects for which pred returns
+	// true, like ListFiltered.
+	ListKeysFiltered(pred func(obj interface{}) bool) []T
+
+	// Range calls fn for every object, in no particular order, until fn
+	// returns false, without copying them into a slice. fn must not modify
+	// the store.
+	Range(fn func(key T, obj interface{}) bool)
+
+	// All returns an iterator over the keys and objects, like Range.
+	All() iter.Seq2[T, interface{}]
+
+	// ListPage returns up to limit objects following the page identified by
+	// continueToken, or the first page if it is empty, along with the token of
+	// the next page, empty after the last one. A limit of zero or less returns
+	// all remaining objects. Objects present during the whole listing are
+	// returned once. Pages are ordered by key if keys are strings, numbers or
+	// booleans, and otherwise by their string form as formatted by %v and then
+	// by a hash, in which case continue tokens are only valid in the process
+	// that returned them.
+	ListPage(limit int, continueToken string) (items []interface{}, next string, err error)
+
+	// KeyOf returns the key the store computes for obj, or a KeyError.
+	KeyOf(obj interface{}) (T, error)
+
 	// Get returns an object by its key.
 	Get(obj interface{}) (interface{}, bool, error)
 
 	// GetByKey returns an object by its key string.
 	GetByKey(key T) (interface{}, bool, error)
 
+	// GetMany returns the objects stored under keys by key, along with the
+	// keys that were not found.
+	GetMany(keys []T) (map[T]interface{}, []T)
+
+	// Contains reports whether an object is stored under key, without
+	// retrieving it or counting it as an access.
+	Contains(key T) bool
+
+	// GetMeta returns the history of the object stored under key, if the store
+	// was created to track it, such as with WithMetadata.
+	GetMeta(key T) (EntryMeta, bool)
+
+	// CompareAndSwap stores new in place of the object stored under its key if
+	// that object is old, and reports whether it did. Objects are compared with
+	// ==, those that cannot be compared, such as slices, never being the same
+	// as old.
+	CompareAndSwap(old, new interface{}) (bool, error)
+
+	// CompareAndDelete removes the object stored under the key of old if it is
+	// old itself, and reports whether it did.
+	CompareAndDelete(old interface{}) (bool, error)
+
 	// Replace replaces all objects with the given list.
 	Replace([]interface{}) error
 
+	// ReplaceWithDiff replaces all objects like Replace and reports the keys
+	// that were added, the keys that were present before and after, whose
+	// objects were replaced, and the keys that were removed.
+	ReplaceWithDiff(list []interface{}) (added, updated, removed []T, err error)
+
+	// Txn calls fn with a transaction buffering the changes it makes and, if
+	// fn returns nil, applies all of them at once, so that readers see either
+	// none or all of them. If fn returns an error, or if storing any of the
+	// objects fails like Add, nothing is applied and the error is returned.
+	// Stores that bound their size, such as those created by NewEvictionCache,
+	// may still reject the stored objects or evict them, along with others,
+	// as they would objects stored one by one.
+	Txn(fn func(tx Txn[T]) error) error
+
 	// Size returns count of object.
 	Size() int
+
+	// Snapshot returns a consistent copy of the objects that later changes to
+	// the store do not affect. The copy is read-only: its mutating methods
+	// fail with ErrReadOnly. Snapshots of an IndexedStore copy its indices as
+	// well and implement IndexedStore.
+	Snapshot() Store[T]
 }
 
+// ErrStoreFull is returned when adding an object would exceed the limits
+// configured on a store.
+var ErrStoreFull = errors.New("store is full")
+
+// ErrReadOnly is returned when modifying a store that cannot be modified,
+// such as a snapshot.
+var ErrReadOnly = errors.New("store is read-only")
+
 // KeyFunc generates a key from an object.
 type KeyFunc[T comparable] func(obj interface{}) (T, error)
 
@@ -50,3 +143,37 @@ func (k KeyError) Error() string {
 func (k KeyError) Unwrap() error {
 	return k.Err
 }
+
+// batchErrors returns errs if any of them is not nil, and nil otherwise.
+func batchErrors(errs []error) []error {
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
+}
+
+// replaceDiff compares the keys present before a replacement, oldKeys, with
+// the objects present after it, items, listed in order by keys. Keys missing
+// from items, such as objects that did not fit, are left out.
+func replaceDiff[T comparable](oldKeys, keys []T, items map[T]interface{}) (added, updated, removed []T) {
+	old := make(map[T]struct{}, len(oldKeys))
+	for _, key := range oldKeys {
+		old[key] = struct{}{}
+		if _, ok := items[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := items[key]; !ok {
+			continue
+		}
+		if _, ok := old[key]; ok {
+			updated = append(updated, key)
+		} else {
+			added = append(added, key)
+		}
+	}
+	return added, updated, removed
+}
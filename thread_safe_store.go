@@ -1,63 +1,299 @@
 package cache
 
 import (
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 // ThreadSafeStore defines an interface for a thread-safe store with indexing capabilities.
-type ThreadSafeStore[K, T comparable] interface {
-	// Add an object to the store.
-	Add(key T, obj interface{})
+// The objects are of type V, which is interface{} for the stores created with
+// Indexers rather than ValueIndexers.
+type ThreadSafeStore[K, T comparable, V any] interface {
+	// Add an object to the store. If an IndexFunc fails on obj, it returns an
+	// IndexError and leaves the store unchanged.
+	Add(key T, obj V) error
 
-	// Update an object in the store.
-	Update(key T, obj interface{})
+	// Update an object in the store, failing like Add.
+	Update(key T, obj V) error
 
 	// Delete an object from the store.
 	Delete(key T)
 
+	// UpdateAll stores each object of objs under the key at the same position
+	// in keys, under a single lock acquisition. It returns the error of every
+	// object at its position, or nil if all of them were stored.
+	UpdateAll(keys []T, objs []V) []error
+
+	// DeleteAll deletes the objects stored under keys under a single lock
+	// acquisition.
+	DeleteAll(keys []T)
+
+	// Apply stores the objects of updates under their keys and deletes the
+	// objects stored under deletes under a single lock acquisition, so that
+	// readers see either none or all of the changes. If any object fails like
+	// Add, including by conflicting with another object of updates in a unique
+	// index, nothing is changed and the errors are joined in the returned
+	// error.
+	Apply(updates map[T]V, deletes []T) error
+
+	// Compute replaces the object stored under key by the result of fn, or
+	// deletes it if fn returns true, atomically. fn receives the current
+	// object and whether it exists, and must not call back into the store.
+	// Storing the new object fails like Add.
+	Compute(key T, fn func(old V, exists bool) (new V, delete bool)) error
+
+	// CompareAndSwap stores new under key if the object stored there is old,
+	// and reports whether it did. Objects are compared with ==, those that
+	// cannot be compared, such as slices, never being the same as old.
+	// Storing new fails like Add.
+	CompareAndSwap(key T, old, new V) (bool, error)
+
+	// CompareAndDelete deletes the object stored under key if it is old, and
+	// reports whether it did.
+	CompareAndDelete(key T, old V) bool
+
+	// DeleteIf deletes the object stored under key if cond returns true for
+	// it, and reports whether it did. cond runs under the write lock, so it
+	// must not call back into the store.
+	DeleteIf(key T, cond func(obj V) bool) bool
+
 	// Get retrieve an object from the store.
-	Get(key T) (item interface{}, exists bool)
+	Get(key T) (item V, exists bool)
+
+	// GetMany returns the objects stored under keys by key, acquiring the
+	// read lock once. Missing keys are left out of the result.
+	GetMany(keys []T) map[T]V
+
+	// Contains reports whether an object is stored under key. Unlike Get, it
+	// is not counted in Stats.
+	Contains(key T) bool
 
 	// List all objects in the store.
-	List() []interface{}
+	List() []V
 
 	// ListKeys List all keys in the store.
 	ListKeys() []T
 
-	// Replace all objects in the store.
-	Replace(items map[T]interface{})
+	// RangeKeys calls fn for every key in the store, in no particular order,
+	// until fn returns false. Unlike Range, it does not hold the lock while fn
+	// runs, so fn may modify the store, but keys added meanwhile may be
+	// missed.
+	RangeKeys(fn func(key T) bool)
+
+	// ListFiltered lists the objects for which pred returns true under the
+	// read lock, so pred must not modify the store.
+	ListFiltered(pred func(obj V) bool) []V
+
+	// ListKeysFiltered lists the keys of the objects for which pred returns
+	// true, like ListFiltered.
+	ListKeysFiltered(pred func(obj V) bool) []T
+
+	// Range calls fn for every object in the store, in no particular order,
+	// until fn returns false. It holds the read lock meanwhile, so fn must not
+	// modify the store.
+	Range(fn func(key T, obj V) bool)
+
+	// All returns an iterator over the keys and objects, holding the read lock
+	// while it runs, like Range.
+	All() iter.Seq2[T, V]
+
+	// ListPage returns a page of objects ordered by key, see Store.ListPage.
+	ListPage(limit int, continueToken string) (items []V, next string, err error)
+
+	// Replace all objects in the store. The objects failing like Add are left
+	// out of the store, and their errors are joined in the returned error.
+	Replace(items map[T]V) error
+
+	// ReplaceIncremental replaces all objects in the store like Replace, but
+	// only updates the indices of the keys that were added, removed or whose
+	// object changed, rather than rebuilding them. Objects are unchanged if
+	// they are comparable and ==. Unlike Replace, it does not retain items.
+	ReplaceIncremental(items map[T]V) error
+
+	// Swap replaces all objects in the store like Replace and returns the
+	// previous ones.
+	Swap(items map[T]V) (map[T]V, error)
+
+	// Snapshot returns an independent copy of the objects and indices, taken
+	// atomically under the read lock.
+	Snapshot() ThreadSafeStore[K, T, V]
 
 	// Size get count of elements in the store.
 	Size() int
 
+	// Stats returns the usage counters of the store.
+	Stats() Stats
+
+	// EstimateBytes returns an estimate of the memory held by the store: the
+	// sizes of the objects as reported by sizer, plus that of the keys and
+	// index entries. It walks the whole store under the read lock.
+	EstimateBytes(sizer func(obj V) int64) int64
+
 	// Index retrieve objects by index.
-	Index(indexName string, obj interface{}, lessFunc func(lhs T, rhs T) bool) ([]interface{}, error)
+	Index(indexName string, obj V, lessFunc func(lhs T, rhs T) bool) ([]V, error)
 
 	// IndexKeys retrieve keys by index.
 	IndexKeys(indexName string, indexedValue K, lessFunc func(lhs T, rhs T) bool) ([]T, error)
 
+	// CountByIndex returns the number of objects holding indexedValue in an
+	// index, without listing them.
+	CountByIndex(indexName string, indexedValue K) (int, error)
+
 	// ByIndex retrieve objects by indexed value.
-	ByIndex(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]interface{}, error)
+	ByIndex(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]V, error)
+
+	// ByIndexes retrieve the objects whose indexed values include the value
+	// queried in every index of queries, intersecting the keys of the indices
+	// under the read lock. It returns no object if queries is empty.
+	ByIndexes(queries map[string]K) ([]V, error)
+
+	// Query returns a Query over the objects in the store, looking up its
+	// conditions with ByIndexes, or scanning the store with Range if it has
+	// none.
+	Query() *Query[K, T, V]
+
+	// IndexValues retrieve the distinct indexed values of an index, in no
+	// particular order.
+	IndexValues(indexName string) ([]K, error)
+
+	// ByIndexValues retrieve the objects whose indexed values include any of
+	// indexedValues, each of them once, in a single pass under the read lock.
+	ByIndexValues(indexName string, indexedValues ...K) ([]V, error)
+
+	// IndexStats returns the number of keys held by each value of an index,
+	// along with their totals.
+	IndexStats(indexName string) (IndexStats[K], error)
+
+	// GroupByIndex returns the keys held by each value of an index, in a
+	// single traversal of the index under the read lock. Keys are in no
+	// particular order.
+	GroupByIndex(indexName string) (map[K][]T, error)
+
+	// GroupCountsByIndex returns the number of keys held by each value of an
+	// index, like GroupByIndex, without listing them.
+	GroupCountsByIndex(indexName string) (map[K]int, error)
 
 	// AddIndexer add new indexer.
-	AddIndexer(indexName string, indexFunc IndexFunc[K]) error
+	AddIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error
 
 	// AddIndexers add new indexers.
-	AddIndexers(newIndexers Indexers[K]) error
+	AddIndexers(newIndexers ValueIndexers[K, V]) error
+
+	// RemoveIndexer removes the named indexer and its index, failing if there
+	// is no such indexer.
+	RemoveIndexer(indexName string) error
+
+	// GetIndexers returns a copy of the indexers, leaving out those still
+	// being built by AddIndexersAsync but including those registered lazily,
+	// see WithLazyIndexing.
+	GetIndexers() ValueIndexers[K, V]
+
+	// AddUniqueIndexer adds an indexer whose indexed values each identify at
+	// most one object. Storing an object with a value held under another key
+	// fails with an IndexError wrapping ErrUniqueConflict, as does adding the
+	// indexer if the stored objects already share a value.
+	AddUniqueIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error
+
+	// GetByUniqueIndex retrieve the object holding indexedValue in a unique
+	// index.
+	GetByUniqueIndex(indexName string, indexedValue K) (V, bool, error)
+
+	// AddOrderedIndexer adds an indexer whose values are kept ordered by
+	// compare, such as cmp.Compare, so that the objects can be queried by
+	// ranges of values.
+	AddOrderedIndexer(indexName string, indexFunc ValueIndexFunc[K, V], compare func(a, b K) int) error
+
+	// ByIndexRange retrieve the objects holding a value in [from, to) in an
+	// ordered index, in ascending order of their values. An object holding
+	// several values in the range is returned once.
+	ByIndexRange(indexName string, from, to K) ([]V, error)
+
+	// ByIndexGreaterThan retrieve the objects holding a value greater than
+	// indexedValue in an ordered index, like ByIndexRange.
+	ByIndexGreaterThan(indexName string, indexedValue K) ([]V, error)
+
+	// ByIndexLessThan retrieve the objects holding a value less than
+	// indexedValue in an ordered index, like ByIndexRange.
+	ByIndexLessThan(indexName string, indexedValue K) ([]V, error)
+
+	// SetIndexNormalizer makes normalize map the values of an index, both
+	// those of the stored objects and those queried, such as strings.ToLower
+	// for case-insensitive lookups. The index is rebuilt, and left as it was
+	// if that fails. A nil normalize stops normalizing the values.
+	SetIndexNormalizer(indexName string, normalize func(K) K) error
+
+	// OnIndexChange registers hook to be invoked whenever a key enters or
+	// leaves a value of an index, such as when an object moves from
+	// status=pending to status=ready. Hooks are invoked synchronously under
+	// the write lock, in the order they were registered, and must not call
+	// back into the store. Rebuilding an index, such as by Reindex or
+	// SetIndexNormalizer, reports the keys whose values it changed. Removing
+	// the index drops its hooks.
+	OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error
+
+	// Reindex rebuilds an index from the objects in the store, such as after
+	// fixing a bug of its IndexFunc, or if its entries are suspected stale. If
+	// the IndexFunc fails, the index is left as it was.
+	Reindex(indexName string) error
+
+	// LockKey locks the mutex of key, waiting until it is available, so that
+	// callers recomputing the object of a key serialize their work without
+	// blocking those of other keys. The mutex is independent of the lock of
+	// the store, so it does not block any of the other methods.
+	LockKey(key T)
+
+	// UnlockKey unlocks the mutex of key locked by LockKey.
+	UnlockKey(key T)
+
+	// AddIndexersAsync adds new indexers like AddIndexers, but indexes the
+	// existing objects in the background, chunkSize of them per lock
+	// acquisition or a default number if chunkSize is not positive, so that
+	// other operations proceed meanwhile. Queries do not see the new indices
+	// until they are complete, when they are all published at once. The
+	// returned channel receives nil once they are published, or the error
+	// that made them abandoned, and is then closed.
+	AddIndexersAsync(newIndexers ValueIndexers[K, V], chunkSize int) <-chan error
 }
 
 // threadSafeMap implements the ThreadSafeStore interface.
-type threadSafeMap[K, T comparable] struct {
+type threadSafeMap[K, T comparable, V any] struct {
 	mu    sync.RWMutex
-	items map[T]interface{}
-	index *storeIndex[K, T]
+	items map[T]V
+	index *storeIndex[K, T, V]
+	// counters are reported by Stats
+	counters storeCounters
+	// lists and keys hold the results of List and ListKeys since the last
+	// change, nil until they are needed again, so that repeated listings copy
+	// a slice rather than walk the map
+	lists atomic.Pointer[[]V]
+	keys  atomic.Pointer[[]T]
+	// sorted holds the keys in the order of ListPage until keys are added or
+	// removed, so that every page does not sort them again
+	sorted atomic.Pointer[[]pageEntry[T]]
+	// keyLocks are locked by LockKey
+	keyLocks keyMutex[T]
 }
 
 // NewThreadSafeStore creates a new instance of ThreadSafeStore.
-func NewThreadSafeStore[K, T comparable](indexers Indexers[K], indices Indexes[K, T]) ThreadSafeStore[K, T] {
-	return &threadSafeMap[K, T]{
-		items: make(map[T]interface{}),
-		index: &storeIndex[K, T]{
+func NewThreadSafeStore[K, T comparable, V any](indexers ValueIndexers[K, V], indices Indexes[K, T], opts ...ThreadSafeStoreOption) ThreadSafeStore[K, T, V] {
+	tsm := newThreadSafeMap(indexers, indices)
+	o := newThreadSafeStoreOptions(opts)
+	tsm.index.strict = o.strict
+	tsm.index.lazy = o.lazy
+	return tsm
+}
+
+// newThreadSafeMap creates a new threadSafeMap.
+func newThreadSafeMap[K, T comparable, V any](indexers ValueIndexers[K, V], indices Indexes[K, T]) *threadSafeMap[K, T, V] {
+	return &threadSafeMap[K, T, V]{
+		items: make(map[T]V),
+		index: &storeIndex[K, T, V]{
 			indexers: indexers,
 			indices:  indices,
 		},
@@ -65,76 +301,249 @@ func NewThreadSafeStore[K, T comparable](indexers Indexers[K], indices Indexes[K
 }
 
 // Add adds an object to the store.
-func (tsm *threadSafeMap[K, T]) Add(key T, obj interface{}) {
-	tsm.Update(key, obj)
+func (tsm *threadSafeMap[K, T, V]) Add(key T, obj V) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	if err := tsm.update(key, obj); err != nil {
+		return err
+	}
+	tsm.counters.adds.Add(1)
+	return nil
 }
 
 // Update updates an object in the store.
-func (tsm *threadSafeMap[K, T]) Update(key T, obj interface{}) {
+func (tsm *threadSafeMap[K, T, V]) Update(key T, obj V) error {
 	tsm.mu.Lock()
 	defer tsm.mu.Unlock()
-	oldObject := tsm.items[key]
-	tsm.items[key] = obj
-	tsm.index.updateIndices(oldObject, obj, key)
+	if err := tsm.update(key, obj); err != nil {
+		return err
+	}
+	tsm.counters.updates.Add(1)
+	return nil
 }
 
 // Delete deletes an object from the store.
-func (tsm *threadSafeMap[K, T]) Delete(key T) {
+func (tsm *threadSafeMap[K, T, V]) Delete(key T) {
 	tsm.mu.Lock()
 	defer tsm.mu.Unlock()
-	if obj, exists := tsm.items[key]; exists {
-		tsm.index.updateIndices(obj, nil, key)
-		delete(tsm.items, key)
+	tsm.delete(key)
+	tsm.counters.deletes.Add(1)
+}
+
+// UpdateAll updates many objects in the store.
+func (tsm *threadSafeMap[K, T, V]) UpdateAll(keys []T, objs []V) []error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.updateAll(keys, objs, &tsm.counters)
+}
+
+// DeleteAll deletes many objects from the store.
+func (tsm *threadSafeMap[K, T, V]) DeleteAll(keys []T) {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	for _, key := range keys {
+		tsm.delete(key)
+	}
+	tsm.counters.deletes.Add(uint64(len(keys)))
+}
+
+// Apply updates and deletes many objects at once.
+func (tsm *threadSafeMap[K, T, V]) Apply(updates map[T]V, deletes []T) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.apply(updates, deletes, &tsm.counters)
+}
+
+// Compute updates or deletes the object stored under key under the lock.
+func (tsm *threadSafeMap[K, T, V]) Compute(key T, fn func(old V, exists bool) (V, bool)) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.compute(key, fn, &tsm.counters)
+}
+
+// CompareAndSwap stores new under key if the stored object is old.
+func (tsm *threadSafeMap[K, T, V]) CompareAndSwap(key T, old, new V) (bool, error) {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	if current, exists := tsm.items[key]; !exists || !sameObject(any(current), any(old)) {
+		return false, nil
 	}
+	if err := tsm.update(key, new); err != nil {
+		return false, err
+	}
+	tsm.counters.updates.Add(1)
+	return true, nil
+}
+
+// CompareAndDelete deletes the object stored under key if it is old.
+func (tsm *threadSafeMap[K, T, V]) CompareAndDelete(key T, old V) bool {
+	return tsm.DeleteIf(key, func(current V) bool {
+		return sameObject(any(current), any(old))
+	})
+}
+
+// DeleteIf deletes the object stored under key if cond returns true for it.
+func (tsm *threadSafeMap[K, T, V]) DeleteIf(key T, cond func(obj V) bool) bool {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	if current, exists := tsm.items[key]; !exists || !cond(current) {
+		return false
+	}
+	tsm.delete(key)
+	tsm.counters.deletes.Add(1)
+	return true
 }
 
 // Get retrieves an object from the store.
-func (tsm *threadSafeMap[K, T]) Get(key T) (item interface{}, exists bool) {
+func (tsm *threadSafeMap[K, T, V]) Get(key T) (item V, exists bool) {
 	tsm.mu.RLock()
 	defer tsm.mu.RUnlock()
-	item, exists = tsm.items[key]
+	item, exists = tsm.get(key)
+	tsm.counters.get(exists)
 	return item, exists
 }
 
-// List lists all objects in the store.
-func (tsm *threadSafeMap[K, T]) List() []interface{} {
+// GetMany retrieves many objects from the store under a single read lock.
+func (tsm *threadSafeMap[K, T, V]) GetMany(keys []T) map[T]V {
 	tsm.mu.RLock()
 	defer tsm.mu.RUnlock()
-	list := make([]interface{}, 0, len(tsm.items))
+	return tsm.getMany(keys, &tsm.counters)
+}
+
+// Contains reports whether an object is stored under key.
+func (tsm *threadSafeMap[K, T, V]) Contains(key T) bool {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	_, exists := tsm.items[key]
+	return exists
+}
+
+// List lists all objects in the store. The list is kept until the next
+// change, so that listing an unchanged store only copies it.
+func (tsm *threadSafeMap[K, T, V]) List() []V {
+	if list := tsm.lists.Load(); list != nil {
+		return slices.Clone(*list)
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	list := tsm.list()
+	// Writers are excluded, so the list is still current
+	tsm.lists.Store(&list)
+	return slices.Clone(list)
+}
+
+// ListKeys lists all keys in the store, kept until the next change like List.
+func (tsm *threadSafeMap[K, T, V]) ListKeys() []T {
+	return slices.Clone(tsm.cachedKeys())
+}
+
+// RangeKeys calls fn for every key in the store until fn returns false,
+// iterating over the keys kept by ListKeys.
+func (tsm *threadSafeMap[K, T, V]) RangeKeys(fn func(key T) bool) {
+	for _, key := range tsm.cachedKeys() {
+		if !fn(key) {
+			return
+		}
+	}
+}
+
+// cachedKeys returns the keys kept until the next change, listing them if
+// needed. The slice is shared and must not be modified.
+func (tsm *threadSafeMap[K, T, V]) cachedKeys() []T {
+	if keys := tsm.keys.Load(); keys != nil {
+		return *keys
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	keys := tsm.listKeys()
+	// Writers are excluded, so the keys are still current
+	tsm.keys.Store(&keys)
+	return keys
+}
+
+// ListFiltered lists the objects for which pred returns true.
+func (tsm *threadSafeMap[K, T, V]) ListFiltered(pred func(obj V) bool) []V {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	var list []V
 	for _, item := range tsm.items {
-		list = append(list, item)
+		if pred(item) {
+			list = append(list, item)
+		}
 	}
 	return list
 }
 
-// ListKeys lists all keys in the store.
-func (tsm *threadSafeMap[K, T]) ListKeys() []T {
+// ListKeysFiltered lists the keys of the objects for which pred returns true.
+func (tsm *threadSafeMap[K, T, V]) ListKeysFiltered(pred func(obj V) bool) []T {
 	tsm.mu.RLock()
 	defer tsm.mu.RUnlock()
-	list := make([]T, 0, len(tsm.items))
-	for key := range tsm.items {
-		list = append(list, key)
+	var list []T
+	for key, item := range tsm.items {
+		if pred(item) {
+			list = append(list, key)
+		}
 	}
 	return list
 }
 
+// Range calls fn for every object in the store until fn returns false.
+func (tsm *threadSafeMap[K, T, V]) Range(fn func(key T, obj V) bool) {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.rangeItems(fn)
+}
+
+// All returns an iterator over the keys and objects in the store.
+func (tsm *threadSafeMap[K, T, V]) All() iter.Seq2[T, V] {
+	return tsm.Range
+}
+
+// ListPage returns a page of objects and the continue token of the next one.
+func (tsm *threadSafeMap[K, T, V]) ListPage(limit int, continueToken string) ([]V, string, error) {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	return tsm.listPage(limit, continueToken, nil)
+}
+
 // Replace replaces all objects in the store.
-func (tsm *threadSafeMap[K, T]) Replace(items map[T]interface{}) {
+func (tsm *threadSafeMap[K, T, V]) Replace(items map[T]V) error {
 	tsm.mu.Lock()
 	defer tsm.mu.Unlock()
-	tsm.items = items
+	return tsm.replace(items)
+}
 
-	// Rebuild any index
-	tsm.index.reset()
-	for key, item := range tsm.items {
-		tsm.index.updateIndices(nil, item, key)
-	}
+// ReplaceIncremental replaces all objects in the store, updating the indices
+// of the changed ones only.
+func (tsm *threadSafeMap[K, T, V]) ReplaceIncremental(items map[T]V) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.replaceIncremental(items)
+}
+
+// Swap replaces all objects in the store and returns the previous ones.
+func (tsm *threadSafeMap[K, T, V]) Swap(items map[T]V) (map[T]V, error) {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	old := tsm.items
+	return old, tsm.replace(items)
+}
+
+// Snapshot returns an independent copy of the store.
+func (tsm *threadSafeMap[K, T, V]) Snapshot() ThreadSafeStore[K, T, V] {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	return tsm.snapshot(nil)
 }
 
 // Index retrieves objects by index.
-func (tsm *threadSafeMap[K, T]) Index(indexName string, obj interface{}, lessFunc func(lhs, rhs T) bool) ([]interface{}, error) {
+func (tsm *threadSafeMap[K, T, V]) Index(indexName string, obj V, lessFunc func(lhs, rhs T) bool) ([]V, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
 	tsm.mu.RLock()
 	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
 
 	keySet, err := tsm.index.getKeysFromIndex(indexName, obj)
 	if err != nil {
@@ -148,7 +557,7 @@ func (tsm *threadSafeMap[K, T]) Index(indexName string, obj interface{}, lessFun
 		keys = keySet.List(lessFunc)
 	}
 
-	list := make([]interface{}, 0, len(keys))
+	list := make([]V, 0, len(keys))
 	for _, key := range keys {
 		list = append(list, tsm.items[key])
 	}
@@ -156,16 +565,574 @@ func (tsm *threadSafeMap[K, T]) Index(indexName string, obj interface{}, lessFun
 }
 
 // ByIndex retrieves objects by indexed value.
-func (tsm *threadSafeMap[K, T]) ByIndex(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]interface{}, error) {
+func (tsm *threadSafeMap[K, T, V]) ByIndex(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]V, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.byIndex(indexName, indexedValue, lessFunc)
+}
+
+// IndexKeys retrieves keys by index.
+func (tsm *threadSafeMap[K, T, V]) IndexKeys(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]T, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.indexKeys(indexName, indexedValue, lessFunc)
+}
+
+// CountByIndex returns the number of objects holding the indexed value.
+func (tsm *threadSafeMap[K, T, V]) CountByIndex(indexName string, indexedValue K) (int, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return 0, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.countByIndex(indexName, indexedValue)
+}
+
+// ByIndexValues retrieves the objects matching any of the indexed values.
+func (tsm *threadSafeMap[K, T, V]) ByIndexValues(indexName string, indexedValues ...K) ([]V, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.byIndexValues(indexName, indexedValues)
+}
+
+// Query returns a Query over the objects in the store.
+func (tsm *threadSafeMap[K, T, V]) Query() *Query[K, T, V] {
+	return newQuery(tsm.ByIndexes, tsm.Range)
+}
+
+// ByIndexes retrieves the objects matching the indexed value of every index in
+// queries.
+func (tsm *threadSafeMap[K, T, V]) ByIndexes(queries map[string]K) ([]V, error) {
+	for indexName := range queries {
+		if err := tsm.buildIndex(indexName); err != nil {
+			return nil, err
+		}
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.byIndexes(queries)
+}
+
+// IndexValues retrieves the distinct indexed values of an index.
+func (tsm *threadSafeMap[K, T, V]) IndexValues(indexName string) ([]K, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.index.listIndexValues(indexName)
+}
+
+// IndexStats returns the number of keys held by each value of an index.
+func (tsm *threadSafeMap[K, T, V]) IndexStats(indexName string) (IndexStats[K], error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return IndexStats[K]{}, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	counts, err := tsm.index.valueCounts(indexName)
+	if err != nil {
+		return IndexStats[K]{}, err
+	}
+	return newIndexStats(counts), nil
+}
+
+// GroupByIndex returns the keys held by each value of an index.
+func (tsm *threadSafeMap[K, T, V]) GroupByIndex(indexName string) (map[K][]T, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.index.groupKeys(indexName)
+}
+
+// GroupCountsByIndex returns the number of keys held by each value of an
+// index.
+func (tsm *threadSafeMap[K, T, V]) GroupCountsByIndex(indexName string) (map[K]int, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.index.valueCounts(indexName)
+}
+
+// AddIndexers adds new indexers to the store.
+func (tsm *threadSafeMap[K, T, V]) AddIndexers(newIndexers ValueIndexers[K, V]) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.addIndexers(newIndexers)
+}
+
+// AddIndexer adds new indexer to the store.
+func (tsm *threadSafeMap[K, T, V]) AddIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.addIndexer(indexName, indexFunc)
+}
+
+// RemoveIndexer removes an indexer and its index from the store.
+func (tsm *threadSafeMap[K, T, V]) RemoveIndexer(indexName string) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.index.removeIndexer(indexName)
+}
+
+// AddUniqueIndexer adds new unique indexer to the store.
+func (tsm *threadSafeMap[K, T, V]) AddUniqueIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.addUniqueIndexer(indexName, indexFunc)
+}
+
+// GetByUniqueIndex retrieves the object holding indexedValue in a unique index.
+func (tsm *threadSafeMap[K, T, V]) GetByUniqueIndex(indexName string, indexedValue K) (V, bool, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		var zero V
+		return zero, false, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.getByUniqueIndex(indexName, indexedValue)
+}
+
+// AddOrderedIndexer adds new ordered indexer to the store.
+func (tsm *threadSafeMap[K, T, V]) AddOrderedIndexer(indexName string, indexFunc ValueIndexFunc[K, V], compare func(a, b K) int) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.addOrderedIndexer(indexName, indexFunc, compare)
+}
+
+// SetIndexNormalizer makes normalize map the values of an index and rebuilds
+// it.
+func (tsm *threadSafeMap[K, T, V]) SetIndexNormalizer(indexName string, normalize func(K) K) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.setIndexNormalizer(indexName, normalize)
+}
+
+// OnIndexChange registers hook for the changes of an index, building it if
+// it was registered lazily.
+func (tsm *threadSafeMap[K, T, V]) OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return err
+	}
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	return tsm.index.addHook(indexName, hook)
+}
+
+// Reindex rebuilds an index from the objects in the store.
+func (tsm *threadSafeMap[K, T, V]) Reindex(indexName string) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	previous, err := tsm.rebuildExisting(indexName)
+	if err != nil {
+		return err
+	}
+	tsm.index.notifyIndexRebuilt(indexName, previous.index)
+	return nil
+}
+
+// ByIndexRange retrieves the objects holding a value in [from, to).
+func (tsm *threadSafeMap[K, T, V]) ByIndexRange(indexName string, from, to K) ([]V, error) {
+	return tsm.byRange(indexName, indexRange[K]{from: &from, fromInclusive: true, to: &to})
+}
+
+// ByIndexGreaterThan retrieves the objects holding a value greater than
+// indexedValue.
+func (tsm *threadSafeMap[K, T, V]) ByIndexGreaterThan(indexName string, indexedValue K) ([]V, error) {
+	return tsm.byRange(indexName, indexRange[K]{from: &indexedValue})
+}
+
+// ByIndexLessThan retrieves the objects holding a value less than
+// indexedValue.
+func (tsm *threadSafeMap[K, T, V]) ByIndexLessThan(indexName string, indexedValue K) ([]V, error) {
+	return tsm.byRange(indexName, indexRange[K]{to: &indexedValue})
+}
+
+// byRange retrieves the objects holding a value within r.
+func (tsm *threadSafeMap[K, T, V]) byRange(indexName string, r indexRange[K]) ([]V, error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.byIndexRange(indexName, r)
+}
+
+// GetIndexers returns a copy of the indexers of the store.
+func (tsm *threadSafeMap[K, T, V]) GetIndexers() ValueIndexers[K, V] {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	indexers := maps.Clone(tsm.index.indexers)
+	maps.Copy(indexers, tsm.index.unbuilt)
+	return indexers
+}
+
+// AddIndexersAsync adds new indexers, indexing the existing objects in the
+// background, chunkSize of them per lock acquisition.
+func (tsm *threadSafeMap[K, T, V]) AddIndexersAsync(newIndexers ValueIndexers[K, V], chunkSize int) <-chan error {
+	done := make(chan error, 1)
+	tsm.mu.Lock()
+	err := tsm.index.addPending(newIndexers)
+	keys := tsm.listKeys()
+	tsm.mu.Unlock()
+	if err != nil {
+		done <- err
+		close(done)
+		return done
+	}
+
+	names := slices.Collect(maps.Keys(newIndexers))
+	go func() {
+		defer close(done)
+		for chunk := range slices.Chunk(keys, reindexChunk(chunkSize)) {
+			tsm.mu.Lock()
+			err := tsm.index.indexPending(names, chunk, tsm.items)
+			if err != nil {
+				tsm.index.dropPending(names)
+			}
+			tsm.mu.Unlock()
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		tsm.mu.Lock()
+		defer tsm.mu.Unlock()
+		// Changes made after the last chunk may have failed to be indexed
+		if err := tsm.index.indexPending(names, nil, nil); err != nil {
+			tsm.index.dropPending(names)
+			done <- err
+			return
+		}
+		tsm.index.publish(names)
+		done <- nil
+	}()
+	return done
+}
+
+// LockKey locks the mutex of key.
+func (tsm *threadSafeMap[K, T, V]) LockKey(key T) {
+	tsm.keyLocks.lock(key)
+}
+
+// UnlockKey unlocks the mutex of key.
+func (tsm *threadSafeMap[K, T, V]) UnlockKey(key T) {
+	tsm.keyLocks.unlock(key)
+}
+
+// Size get count of elements in the store.
+func (tsm *threadSafeMap[K, T, V]) Size() int {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	return len(tsm.items)
+}
+
+// Stats returns the counters of the store.
+func (tsm *threadSafeMap[K, T, V]) Stats() Stats {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	return tsm.counters.stats(len(tsm.items))
+}
+
+// EstimateBytes estimates the memory held by the objects and indices.
+func (tsm *threadSafeMap[K, T, V]) EstimateBytes(sizer func(obj V) int64) int64 {
 	tsm.mu.RLock()
 	defer tsm.mu.RUnlock()
+	return tsm.estimateBytes(sizer)
+}
+
+// The methods below are the unlocked counterparts of the exported ones. The
+// caller must hold tsm.mu, or own the map exclusively like evictionCache does,
+// which guards it with its own mutex instead.
+
+// update stores obj under key and updates the indices, unless indexing obj
+// fails.
+func (tsm *threadSafeMap[K, T, V]) update(key T, obj V) error {
+	var oldObj *V
+	if old, exists := tsm.items[key]; exists {
+		oldObj = &old
+	}
+	if err := tsm.index.updateIndices(oldObj, &obj, key); err != nil {
+		return err
+	}
+	tsm.invalidate(oldObj == nil)
+	tsm.items[key] = obj
+	return nil
+}
+
+// updateAll stores many objects, counting the stored ones in counters.
+func (tsm *threadSafeMap[K, T, V]) updateAll(keys []T, objs []V, counters *storeCounters) []error {
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if errs[i] = tsm.update(key, objs[i]); errs[i] == nil {
+			counters.updates.Add(1)
+		}
+	}
+	return batchErrors(errs)
+}
+
+// apply stores and deletes many objects, counting them in counters. If any
+// object fails like Add, it changes nothing and returns the errors joined.
+func (tsm *threadSafeMap[K, T, V]) apply(updates map[T]V, deletes []T, counters *storeCounters) error {
+	batch, err := tsm.index.prepareBatch(updates, deletes)
+	if err != nil {
+		return err
+	}
+	tsm.commit(updates, batch, deletes, counters)
+	return nil
+}
+
+// commit stores and deletes many objects whose index values were computed
+// and checked by prepareBatch, counting them in counters.
+func (tsm *threadSafeMap[K, T, V]) commit(updates map[T]V, batch map[T][]indexUpdate[K], deletes []T, counters *storeCounters) {
+	for key, obj := range updates {
+		var oldObj *V
+		if old, exists := tsm.items[key]; exists {
+			oldObj = &old
+		}
+		tsm.index.applyIndexUpdates(batch[key], oldObj, &obj, key)
+		tsm.invalidate(oldObj == nil)
+		tsm.items[key] = obj
+	}
+	counters.updates.Add(uint64(len(updates)))
+	for _, key := range deletes {
+		tsm.delete(key)
+	}
+	counters.deletes.Add(uint64(len(deletes)))
+}
+
+// compute updates or deletes the object stored under key as fn decides,
+// counting the change in counters.
+func (tsm *threadSafeMap[K, T, V]) compute(key T, fn func(old V, exists bool) (V, bool), counters *storeCounters) error {
+	old, exists := tsm.items[key]
+	obj, del := fn(old, exists)
+	if del {
+		tsm.delete(key)
+		counters.deletes.Add(1)
+		return nil
+	}
+	if err := tsm.update(key, obj); err != nil {
+		return err
+	}
+	counters.updates.Add(1)
+	return nil
+}
+
+// delete removes the object stored under key and its index entries.
+func (tsm *threadSafeMap[K, T, V]) delete(key T) {
+	if obj, exists := tsm.items[key]; exists {
+		tsm.invalidate(true)
+		tsm.index.updateIndices(&obj, nil, key)
+		delete(tsm.items, key)
+	}
+}
+
+// invalidate drops the lists kept by List and ListKeys before a change, and
+// the keys sorted by ListPage if keysChanged, when keys are added or removed.
+func (tsm *threadSafeMap[K, T, V]) invalidate(keysChanged bool) {
+	tsm.lists.Store(nil)
+	tsm.keys.Store(nil)
+	if keysChanged {
+		tsm.sorted.Store(nil)
+	}
+}
+
+// get returns the object stored under key.
+func (tsm *threadSafeMap[K, T, V]) get(key T) (V, bool) {
+	item, exists := tsm.items[key]
+	return item, exists
+}
+
+// getMany returns the objects stored under keys, counting the lookups in
+// counters.
+func (tsm *threadSafeMap[K, T, V]) getMany(keys []T, counters *storeCounters) map[T]V {
+	items := make(map[T]V, len(keys))
+	for _, key := range keys {
+		item, exists := tsm.items[key]
+		if exists {
+			items[key] = item
+		}
+		counters.get(exists)
+	}
+	return items
+}
+
+// estimateBytes estimates the memory held by the objects and indices.
+func (tsm *threadSafeMap[K, T, V]) estimateBytes(sizer func(obj V) int64) int64 {
+	var total int64
+	for key, item := range tsm.items {
+		total += mapEntryBytes + valueBytes(key) + sizer(item)
+	}
+	for _, index := range tsm.index.indices {
+		for indexValue, keySet := range index {
+			total += mapEntryBytes + valueBytes(indexValue)
+			for key := range keySet {
+				total += mapEntryBytes + valueBytes(key)
+			}
+		}
+	}
+	return total
+}
+
+// list returns all objects.
+func (tsm *threadSafeMap[K, T, V]) list() []V {
+	list := make([]V, 0, len(tsm.items))
+	for _, item := range tsm.items {
+		list = append(list, item)
+	}
+	return list
+}
+
+// listKeys returns all keys.
+func (tsm *threadSafeMap[K, T, V]) listKeys() []T {
+	list := make([]T, 0, len(tsm.items))
+	for key := range tsm.items {
+		list = append(list, key)
+	}
+	return list
+}
+
+// rangeItems calls fn for every object until fn returns false.
+func (tsm *threadSafeMap[K, T, V]) rangeItems(fn func(key T, obj V) bool) {
+	for key, item := range tsm.items {
+		if !fn(key, item) {
+			return
+		}
+	}
+}
+
+// listPage returns a page of objects, leaving out the keys for which skip
+// returns true if it is not nil.
+func (tsm *threadSafeMap[K, T, V]) listPage(limit int, continueToken string, skip func(key T) bool) ([]V, string, error) {
+	p, err := newPager(limit, continueToken)
+	if err != nil {
+		return nil, "", err
+	}
+	entries, more := selectPage(p, tsm.sortedKeys(), skip)
+	items := make([]V, len(entries))
+	for i, e := range entries {
+		items[i] = tsm.items[e.key]
+	}
+	return items, nextToken(entries, more), nil
+}
+
+// sortedKeys returns the keys in the order of ListPage, sorting them if they
+// were added or removed since the last call. It must be called under the
+// lock, and the slice is shared and must not be modified.
+func (tsm *threadSafeMap[K, T, V]) sortedKeys() []pageEntry[T] {
+	if sorted := tsm.sorted.Load(); sorted != nil {
+		return *sorted
+	}
+	sorted := sortPageKeys(tsm.items)
+	// Writers are excluded, so the keys are still current
+	tsm.sorted.Store(&sorted)
+	return sorted
+}
+
+// snapshot returns a copy of the objects and indices, leaving out the keys for
+// which skip returns true if it is not nil.
+func (tsm *threadSafeMap[K, T, V]) snapshot(skip func(key T) bool) *threadSafeMap[K, T, V] {
+	copied := &threadSafeMap[K, T, V]{
+		items: make(map[T]V, len(tsm.items)),
+		index: tsm.index.clone(),
+	}
+	for key, item := range tsm.items {
+		if skip != nil && skip(key) {
+			copied.index.updateIndices(&item, nil, key)
+			continue
+		}
+		copied.items[key] = item
+	}
+	return copied
+}
+
+// replace swaps in items and rebuilds the indices, removing from items the
+// objects that fail to be indexed.
+func (tsm *threadSafeMap[K, T, V]) replace(items map[T]V) error {
+	tsm.invalidate(true)
+	tsm.items = items
+
+	// Rebuild any index, and report the changes to the hooks once rebuilt
+	// rather than every key as entering its values
+	previous, hooks := tsm.index.indices, tsm.index.hooks
+	tsm.index.hooks = nil
+	tsm.index.reset()
+	var errs []error
+	for key, item := range tsm.items {
+		if err := tsm.index.updateIndices(nil, &item, key); err != nil {
+			delete(tsm.items, key)
+			errs = append(errs, err)
+		}
+	}
+	tsm.index.hooks = hooks
+	tsm.index.notifyRebuilt(previous)
+	return errors.Join(errs...)
+}
 
-	keys, err := tsm.IndexKeys(indexName, indexedValue, lessFunc)
+// replaceIncremental deletes the objects missing from items and stores the
+// ones that differ from the stored objects, deleting those that fail to be
+// indexed.
+func (tsm *threadSafeMap[K, T, V]) replaceIncremental(items map[T]V) error {
+	for key := range tsm.items {
+		if _, exists := items[key]; !exists {
+			tsm.delete(key)
+		}
+	}
+	var errs []error
+	for key, obj := range items {
+		if old, exists := tsm.items[key]; exists && sameObject(any(old), any(obj)) {
+			continue
+		}
+		if err := tsm.update(key, obj); err != nil {
+			tsm.delete(key)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sameObject reports whether a and b are comparable and equal, without
+// panicking on objects that cannot be compared, including structs and arrays
+// holding such objects in interface fields.
+func sameObject(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Type() == vb.Type() && va.Comparable() && vb.Comparable() && a == b
+}
+
+// byIndex returns the objects whose index values include indexedValue.
+func (tsm *threadSafeMap[K, T, V]) byIndex(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]V, error) {
+	keys, err := tsm.indexKeys(indexName, indexedValue, lessFunc)
 	if err != nil {
 		return nil, err
 	}
 
-	list := make([]interface{}, 0, len(keys))
+	list := make([]V, 0, len(keys))
 	for _, key := range keys {
 		list = append(list, tsm.items[key])
 	}
@@ -173,11 +1140,62 @@ func (tsm *threadSafeMap[K, T]) ByIndex(indexName string, indexedValue K, lessFu
 	return list, nil
 }
 
-// IndexKeys retrieves keys by index.
-func (tsm *threadSafeMap[K, T]) IndexKeys(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]T, error) {
+// byIndexValues returns the objects whose index values include any of
+// indexedValues.
+func (tsm *threadSafeMap[K, T, V]) byIndexValues(indexName string, indexedValues []K) ([]V, error) {
+	keySet, err := tsm.index.getKeysByIndexValues(indexName, indexedValues)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]V, 0, len(keySet))
+	for key := range keySet {
+		list = append(list, tsm.items[key])
+	}
+	return list, nil
+}
+
+// matchValues returns the objects holding any of indexedValues in the named
+// index, along with how many of them each holds, under the read lock.
+func (tsm *threadSafeMap[K, T, V]) matchValues(indexName string, indexedValues []K) ([]TokenMatch[V], error) {
+	if err := tsm.buildIndex(indexName); err != nil {
+		return nil, err
+	}
 	tsm.mu.RLock()
 	defer tsm.mu.RUnlock()
+	tsm.counters.indexQueries.Add(1)
+	return tsm.matchIndexValues(indexName, indexedValues)
+}
+
+// matchIndexValues returns the objects holding any of indexedValues in the
+// named index, along with how many of them each holds.
+func (tsm *threadSafeMap[K, T, V]) matchIndexValues(indexName string, indexedValues []K) ([]TokenMatch[V], error) {
+	counts, err := tsm.index.countKeysByIndexValues(indexName, indexedValues)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]TokenMatch[V], 0, len(counts))
+	for key, count := range counts {
+		matches = append(matches, TokenMatch[V]{Object: tsm.items[key], Matches: count})
+	}
+	return matches, nil
+}
 
+// byIndexes returns the objects matching the indexed value of every index in
+// queries.
+func (tsm *threadSafeMap[K, T, V]) byIndexes(queries map[string]K) ([]V, error) {
+	keySet, err := tsm.index.getKeysByIndexes(queries)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]V, 0, len(keySet))
+	for key := range keySet {
+		list = append(list, tsm.items[key])
+	}
+	return list, nil
+}
+
+// indexKeys returns the keys whose index values include indexedValue.
+func (tsm *threadSafeMap[K, T, V]) indexKeys(indexName string, indexedValue K, lessFunc func(lhs, rhs T) bool) ([]T, error) {
 	keySet, err := tsm.index.getKeysByIndex(indexName, indexedValue)
 	if err != nil {
 		return nil, err
@@ -190,11 +1208,21 @@ func (tsm *threadSafeMap[K, T]) IndexKeys(indexName string, indexedValue K, less
 	return keySet.List(lessFunc), nil
 }
 
-// AddIndexers adds new indexers to the store.
-func (tsm *threadSafeMap[K, T]) AddIndexers(newIndexers Indexers[K]) error {
-	tsm.mu.Lock()
-	defer tsm.mu.Unlock()
+// countByIndex returns the number of keys whose index values include
+// indexedValue.
+func (tsm *threadSafeMap[K, T, V]) countByIndex(indexName string, indexedValue K) (int, error) {
+	keySet, err := tsm.index.getKeysByIndex(indexName, indexedValue)
+	if err != nil {
+		return 0, err
+	}
+	return len(keySet), nil
+}
 
+// addIndexers registers new indexers and indexes the existing objects with them.
+func (tsm *threadSafeMap[K, T, V]) addIndexers(newIndexers ValueIndexers[K, V]) error {
+	if tsm.index.lazy {
+		return tsm.index.addUnbuilt(newIndexers)
+	}
 	if err := tsm.index.addIndexers(newIndexers); err != nil {
 		return err
 	}
@@ -202,33 +1230,159 @@ func (tsm *threadSafeMap[K, T]) AddIndexers(newIndexers Indexers[K]) error {
 	// If there are already items, reindex them
 	for key, item := range tsm.items {
 		for name := range newIndexers {
-			tsm.index.updateSingleIndex(name, nil, item, key)
+			if err := tsm.index.updateSingleIndex(name, nil, &item, key); err != nil {
+				tsm.index.removeIndexers(slices.Collect(maps.Keys(newIndexers))...)
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
-// AddIndexer adds new indexer to the store.
-func (tsm *threadSafeMap[K, T]) AddIndexer(indexName string, indexFunc IndexFunc[K]) error {
-	tsm.mu.Lock()
-	defer tsm.mu.Unlock()
-
+// addIndexer registers a new indexer and indexes the existing objects with it.
+func (tsm *threadSafeMap[K, T, V]) addIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
+	if tsm.index.lazy {
+		return tsm.index.addUnbuilt(ValueIndexers[K, V]{indexName: indexFunc})
+	}
 	if err := tsm.index.addIndexer(indexName, indexFunc); err != nil {
 		return err
 	}
+	return tsm.reindex(indexName)
+}
 
-	// If there are already items, reindex them
-	for key, item := range tsm.items {
-		tsm.index.updateSingleIndex(indexName, nil, item, key)
+// addUniqueIndexer registers a new unique indexer and indexes the existing
+// objects with it.
+func (tsm *threadSafeMap[K, T, V]) addUniqueIndexer(indexName string, indexFunc ValueIndexFunc[K, V]) error {
+	if err := tsm.index.addUniqueIndexer(indexName, indexFunc); err != nil {
+		return err
+	}
+	return tsm.reindex(indexName)
+}
+
+// addOrderedIndexer registers a new ordered indexer and indexes the existing
+// objects with it.
+func (tsm *threadSafeMap[K, T, V]) addOrderedIndexer(indexName string, indexFunc ValueIndexFunc[K, V], compare func(a, b K) int) error {
+	if err := tsm.index.addOrderedIndexer(indexName, indexFunc, compare); err != nil {
+		return err
 	}
+	return tsm.reindex(indexName)
+}
 
+// setIndexNormalizer makes normalize map the values of the named index and
+// rebuilds it. If the normalized values conflict in a unique index, the
+// previous normalizer is restored along with the index.
+func (tsm *threadSafeMap[K, T, V]) setIndexNormalizer(indexName string, normalize func(K) K) error {
+	previous, err := tsm.index.setNormalizer(indexName, normalize)
+	if err != nil || tsm.index.isUnbuilt(indexName) {
+		// An unbuilt index is normalized when it is built
+		return err
+	}
+	built, err := tsm.rebuildIndex(indexName)
+	if err != nil {
+		tsm.index.setNormalizer(indexName, previous)
+		return err
+	}
+	tsm.index.notifyIndexRebuilt(indexName, built.index)
 	return nil
 }
 
-// Size get count of elements in the store.
-func (tsm *threadSafeMap[K, T]) Size() int {
+// buildIndex indexes the objects with the named indexer if it was registered
+// lazily and its index has not been built yet, under the write lock. It
+// returns the error of the IndexFunc if it fails, which removes the indexer.
+func (tsm *threadSafeMap[K, T, V]) buildIndex(indexName string) error {
+	// lazy never changes, so it can be read without the lock
+	if !tsm.index.lazy {
+		return nil
+	}
+	tsm.mu.RLock()
+	unbuilt := tsm.index.isUnbuilt(indexName)
+	tsm.mu.RUnlock()
+	if !unbuilt {
+		return nil
+	}
 	tsm.mu.Lock()
 	defer tsm.mu.Unlock()
-	return len(tsm.items)
+	return tsm.build(indexName)
+}
+
+// build indexes the objects with the named unbuilt indexer, unless it was
+// built or removed meanwhile. If the IndexFunc fails, the indexer stays
+// unbuilt.
+func (tsm *threadSafeMap[K, T, V]) build(indexName string) error {
+	indexFunc, unbuilt := tsm.index.unbuilt[indexName]
+	if !unbuilt {
+		return nil
+	}
+	delete(tsm.index.unbuilt, indexName)
+	tsm.index.indexers[indexName] = indexFunc
+	for key, item := range tsm.items {
+		if err := tsm.index.updateSingleIndex(indexName, nil, &item, key); err != nil {
+			tsm.index.unbuild(indexName, indexFunc)
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildIndex indexes the existing objects anew in the named index, and
+// returns the previous index. If that fails, it puts the previous index back.
+func (tsm *threadSafeMap[K, T, V]) rebuildIndex(indexName string) (builtIndex[K, T], error) {
+	previous := tsm.index.clearIndex(indexName)
+	for key, item := range tsm.items {
+		if err := tsm.index.updateSingleIndex(indexName, nil, &item, key); err != nil {
+			tsm.index.restoreIndex(indexName, previous)
+			return builtIndex[K, T]{}, err
+		}
+	}
+	return previous, nil
+}
+
+// rebuildExisting rebuilds the named index like rebuildIndex, failing if
+// there is no such indexer. Unbuilt indices are left alone, since they are
+// built from the objects of the store anyway.
+func (tsm *threadSafeMap[K, T, V]) rebuildExisting(indexName string) (builtIndex[K, T], error) {
+	if tsm.index.isUnbuilt(indexName) {
+		return builtIndex[K, T]{}, nil
+	}
+	if _, exists := tsm.index.indexers[indexName]; !exists {
+		return builtIndex[K, T]{}, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	return tsm.rebuildIndex(indexName)
+}
+
+// byIndexRange returns the objects holding a value within r in an ordered
+// index, see storeIndex.getKeysByRange.
+func (tsm *threadSafeMap[K, T, V]) byIndexRange(indexName string, r indexRange[K]) ([]V, error) {
+	keys, _, err := tsm.index.getKeysByRange(indexName, r)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]V, len(keys))
+	for i, key := range keys {
+		list[i] = tsm.items[key]
+	}
+	return list, nil
+}
+
+// reindex indexes the existing objects with the named indexer, removing it if
+// any of them fails to be indexed.
+func (tsm *threadSafeMap[K, T, V]) reindex(indexName string) error {
+	for key, item := range tsm.items {
+		if err := tsm.index.updateSingleIndex(indexName, nil, &item, key); err != nil {
+			tsm.index.removeIndexers(indexName)
+			return err
+		}
+	}
+	return nil
+}
+
+// getByUniqueIndex returns the object holding indexedValue in a unique index.
+func (tsm *threadSafeMap[K, T, V]) getByUniqueIndex(indexName string, indexedValue K) (V, bool, error) {
+	key, exists, err := tsm.index.getKeyByUniqueIndex(indexName, indexedValue)
+	if err != nil || !exists {
+		var zero V
+		return zero, false, err
+	}
+	return tsm.items[key], true, nil
 }
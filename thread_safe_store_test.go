@@ -1,9 +1,17 @@
 package cache
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"maps"
+	"math/rand/v2"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -195,3 +203,1154 @@ func TestThreadSafeStore(t *testing.T) {
 	indexedItems, err = store.ByIndex("suffix", "st", nil)
 	assert.ElementsMatch(t, indexedItems, []any{"suffixTest"})
 }
+
+func TestThreadSafeStoreCompute(t *testing.T) {
+	indexers := Indexers[string]{
+		"parity": func(obj any) ([]string, error) {
+			if obj.(int)%2 == 0 {
+				return []string{"even"}, nil
+			}
+			return []string{"odd"}, nil
+		},
+	}
+	store := NewThreadSafeStore[string, string](indexers, Indexes[string, string]{})
+	increment := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, false
+		}
+		return old.(int) + 1, false
+	}
+
+	// Concurrent increments are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Compute("counter", increment)
+		}()
+	}
+	wg.Wait()
+	item, exists := store.Get("counter")
+	assert.True(t, exists)
+	assert.Equal(t, 10, item)
+
+	// The indices follow the computed object
+	store.Compute("counter", increment)
+	keys, err := store.IndexKeys("parity", "odd", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"counter"}, keys)
+	keys, err = store.IndexKeys("parity", "even", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	store.Compute("counter", func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, true
+	})
+	_, exists = store.Get("counter")
+	assert.False(t, exists)
+	keys, err = store.IndexKeys("parity", "odd", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestThreadSafeStoreCompareAndSwap(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	v1, v2, v3 := new(int), new(int), new(int)
+	store.Add("key", v1)
+
+	swapped, err := store.CompareAndSwap("key", v2, v3)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	swapped, err = store.CompareAndSwap("missing", v1, v3)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	swapped, err = store.CompareAndSwap("key", v1, v2)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+	item, _ := store.Get("key")
+	assert.Same(t, v2, item)
+
+	assert.False(t, store.CompareAndDelete("key", v1))
+	assert.True(t, store.CompareAndDelete("key", v2))
+	_, exists := store.Get("key")
+	assert.False(t, exists)
+}
+
+func TestThreadSafeStoreCompareAndSwapUncomparable(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.Add("slice", []int{1})
+	store.Add("struct", struct{ v any }{[]int{1}})
+
+	// Objects that cannot be compared are never the same, rather than panic
+	swapped, err := store.CompareAndSwap("slice", []int{1}, []int{2})
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	swapped, err = store.CompareAndSwap("struct", struct{ v any }{[]int{1}}, nil)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	assert.False(t, store.CompareAndDelete("slice", []int{1}))
+	assert.Len(t, store.List(), 2)
+}
+
+func TestThreadSafeStoreBatch(t *testing.T) {
+	indexers := Indexers[string]{
+		"length": func(obj any) ([]string, error) {
+			return []string{strconv.Itoa(len(obj.(string)))}, nil
+		},
+	}
+	store := NewThreadSafeStore[string, string](indexers, Indexes[string, string]{})
+
+	store.UpdateAll([]string{"a", "b", "c"}, []interface{}{"x", "yy", "zz"})
+	assert.Equal(t, 3, store.Size())
+	keys, err := store.IndexKeys("length", "2", nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b", "c"}, keys)
+
+	store.DeleteAll([]string{"a", "b", "missing"})
+	assert.Equal(t, []string{"c"}, store.ListKeys())
+	keys, err = store.IndexKeys("length", "2", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, keys)
+}
+
+func TestThreadSafeStoreApply(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.UpdateAll([]string{"a", "b"}, []interface{}{1, 2})
+
+	store.Apply(map[string]interface{}{"b": 3, "c": 4}, []string{"a"})
+
+	assert.Equal(t, 2, store.Size())
+	item, _ := store.Get("b")
+	assert.Equal(t, 3, item)
+	item, _ = store.Get("c")
+	assert.Equal(t, 4, item)
+}
+
+func TestThreadSafeStoreApplyUnique(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	assert.NoError(t, store.AddUniqueIndexer("email", func(obj interface{}) ([]string, error) {
+		return []string{obj.(string)}, nil
+	}))
+	assert.NoError(t, store.Add("a", "x@"))
+	assert.NoError(t, store.Add("b", "y@"))
+
+	// A conflict with a stored object or within the batch changes nothing
+	assert.ErrorIs(t, store.Apply(map[string]interface{}{"c": "z@", "d": "x@"}, nil), ErrUniqueConflict)
+	assert.ErrorIs(t, store.Apply(map[string]interface{}{"c": "z@", "d": "z@"}, []string{"a"}), ErrUniqueConflict)
+	assert.ElementsMatch(t, []string{"a", "b"}, store.ListKeys())
+
+	// Values released by the batch can be taken in the same batch, in any
+	// order
+	assert.NoError(t, store.Apply(map[string]interface{}{"a": "y@", "b": "x@", "c": "z@"}, nil))
+	assert.NoError(t, store.Apply(map[string]interface{}{"d": "y@"}, []string{"a"}))
+	keys, err := store.IndexKeys("email", "y@", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, keys)
+	assert.ElementsMatch(t, []string{"b", "c", "d"}, store.ListKeys())
+}
+
+func TestThreadSafeStoreSwap(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.UpdateAll([]string{"a", "b"}, []interface{}{1, 2})
+
+	old, err := store.Swap(map[string]interface{}{"c": 3})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, old)
+	assert.Equal(t, []string{"c"}, store.ListKeys())
+}
+
+func TestThreadSafeStoreRange(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.UpdateAll([]string{"a", "b", "c"}, []interface{}{1, 2, 3})
+
+	sum := 0
+	store.Range(func(key string, obj interface{}) bool {
+		sum += obj.(int)
+		return true
+	})
+	assert.Equal(t, 6, sum)
+
+	// Returning false stops the iteration
+	visited := 0
+	store.Range(func(key string, obj interface{}) bool {
+		visited++
+		return false
+	})
+	assert.Equal(t, 1, visited)
+
+	keys := make([]string, 0, 3)
+	for key := range store.All() {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, keys)
+}
+
+func TestThreadSafeStoreListFiltered(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.UpdateAll([]string{"a", "b", "c", "d"}, []interface{}{1, 2, 3, 4})
+	even := func(obj interface{}) bool {
+		return obj.(int)%2 == 0
+	}
+
+	assert.ElementsMatch(t, []interface{}{2, 4}, store.ListFiltered(even))
+	assert.ElementsMatch(t, []string{"b", "d"}, store.ListKeysFiltered(even))
+	assert.Empty(t, store.ListFiltered(func(obj interface{}) bool { return false }))
+}
+
+func TestThreadSafeStoreListCached(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.UpdateAll([]string{"a", "b"}, []interface{}{1, 2})
+	assert.ElementsMatch(t, []interface{}{1, 2}, store.List())
+
+	// Modifying a returned list does not affect later ones
+	list := store.List()
+	list[0] = 3
+	keys := store.ListKeys()
+	keys[0] = "c"
+	assert.ElementsMatch(t, []interface{}{1, 2}, store.List())
+	assert.ElementsMatch(t, []string{"a", "b"}, store.ListKeys())
+
+	// Every change is listed
+	store.Update("c", 3)
+	assert.ElementsMatch(t, []interface{}{1, 2, 3}, store.List())
+	store.Delete("a")
+	assert.ElementsMatch(t, []string{"b", "c"}, store.ListKeys())
+	store.Replace(map[string]interface{}{"d": 4})
+	assert.Equal(t, []interface{}{4}, store.List())
+	assert.Equal(t, []string{"d"}, store.ListKeys())
+}
+
+func TestThreadSafeStoreListConcurrent(t *testing.T) {
+	store := NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Add(strconv.Itoa(i*100+j), j)
+				store.List()
+				store.ListKeys()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, store.List(), 400)
+	assert.Len(t, store.ListKeys(), 400)
+}
+
+func TestThreadSafeStoreReplaceIncremental(t *testing.T) {
+	indexed := 0
+	indexers := Indexers[string]{
+		"parity": func(obj any) ([]string, error) {
+			indexed++
+			if *obj.(*int)%2 == 0 {
+				return []string{"even"}, nil
+			}
+			return []string{"odd"}, nil
+		},
+	}
+	store := NewThreadSafeStore[string, string](indexers, Indexes[string, string]{})
+	one, two, three, four := 1, 2, 3, 4
+	store.UpdateAll([]string{"a", "b", "c"}, []interface{}{&one, &two, &three})
+
+	// Only the changed, added and removed objects are reindexed
+	indexed = 0
+	store.ReplaceIncremental(map[string]interface{}{"a": &one, "b": &four, "d": &three})
+	assert.Equal(t, 4, indexed)
+
+	assert.ElementsMatch(t, []string{"a", "b", "d"}, store.ListKeys())
+	keys, err := store.IndexKeys("parity", "odd", nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "d"}, keys)
+	keys, err = store.IndexKeys("parity", "even", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, keys)
+
+	// Objects that cannot be compared are always replaced
+	store = NewThreadSafeStore[string, string](Indexers[string]{}, Indexes[string, string]{})
+	store.ReplaceIncremental(map[string]interface{}{"e": []int{1}})
+	store.ReplaceIncremental(map[string]interface{}{"e": []int{2}})
+	item, _ := store.Get("e")
+	assert.Equal(t, []int{2}, item)
+}
+
+// nonNegativeIndexFunc indexes ints by value and fails on negative ones.
+func nonNegativeIndexFunc(obj interface{}) ([]int, error) {
+	if obj.(int) < 0 {
+		return nil, fmt.Errorf("negative value %d", obj)
+	}
+	return []int{obj.(int)}, nil
+}
+
+func TestThreadSafeStoreIndexErrors(t *testing.T) {
+	store := NewThreadSafeStore[int, string](Indexers[int]{"value": nonNegativeIndexFunc}, Indexes[int, string]{})
+	var indexErr IndexError
+	assert.ErrorAs(t, store.Add("a", -1), &indexErr)
+	assert.Equal(t, "value", indexErr.Name)
+	assert.Equal(t, "a", indexErr.Key)
+	assert.Equal(t, 0, store.Size())
+
+	// A failed update keeps the stored object and its index entries
+	assert.NoError(t, store.Add("a", 1))
+	assert.Error(t, store.Update("a", -2))
+	keys, _ := store.IndexKeys("value", 1, nil)
+	assert.Equal(t, []string{"a"}, keys)
+
+	errs := store.UpdateAll([]string{"b", "c"}, []interface{}{-1, 2})
+	assert.Len(t, errs, 2)
+	assert.Error(t, errs[0])
+	assert.NoError(t, errs[1])
+	swapped, err := store.CompareAndSwap("c", 2, -2)
+	assert.Error(t, err)
+	assert.False(t, swapped)
+	assert.Error(t, store.Compute("c", func(old interface{}, exists bool) (interface{}, bool) {
+		return -3, false
+	}))
+	// A failed object makes Apply change nothing
+	assert.Error(t, store.Apply(map[string]interface{}{"d": -1, "e": 5}, []string{"a"}))
+	assert.ElementsMatch(t, []string{"a", "c"}, store.ListKeys())
+	assert.Equal(t, uint64(1), store.Stats().Updates)
+
+	// Replacements leave the failing objects out
+	assert.Error(t, store.Replace(map[string]interface{}{"f": 6, "g": -7}))
+	assert.Equal(t, []string{"f"}, store.ListKeys())
+	assert.Error(t, store.ReplaceIncremental(map[string]interface{}{"f": -6, "h": 8}))
+	assert.Equal(t, []string{"h"}, store.ListKeys())
+	keys, _ = store.IndexKeys("value", 6, nil)
+	assert.Empty(t, keys)
+}
+
+func TestThreadSafeStoreAddIndexerError(t *testing.T) {
+	store := NewThreadSafeStore[int, string](Indexers[int]{}, Indexes[int, string]{})
+	assert.NoError(t, store.Add("a", -1))
+
+	// The indexer is not added if it fails on a stored object
+	assert.Error(t, store.AddIndexer("value", nonNegativeIndexFunc))
+	_, err := store.IndexKeys("value", 1, nil)
+	assert.Error(t, err)
+	assert.Error(t, store.AddIndexers(Indexers[int]{"value": nonNegativeIndexFunc}))
+
+	store.Delete("a")
+	assert.NoError(t, store.AddIndexer("value", nonNegativeIndexFunc))
+}
+
+func TestThreadSafeStoreStrictIndexing(t *testing.T) {
+	store := NewThreadSafeStore[int, string](Indexers[int]{"value": nonNegativeIndexFunc}, Indexes[int, string]{},
+		WithStrictIndexing())
+	assert.NoError(t, store.Add("a", 1))
+	assert.Panics(t, func() { _ = store.Add("b", -1) })
+}
+
+// TestThreadSafeStoreRandomIndexUpdates compares the indices of a store
+// changed at random by all of its methods with those rebuilt from scratch.
+func TestThreadSafeStoreRandomIndexUpdates(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	randomValues := func() []int {
+		values := make([]int, rng.IntN(4))
+		for j := range values {
+			values[j] = rng.IntN(8)
+		}
+		return values
+	}
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"values": valuesIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"values": valuesIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			objs := make(map[string]interface{})
+			for i := 0; i < 2000; i++ {
+				key := fmt.Sprintf("key-%d", rng.IntN(30))
+				switch op := rng.IntN(10); {
+				case op < 5:
+					values := randomValues()
+					assert.NoError(t, store.Update(key, values))
+					objs[key] = values
+				case op < 7:
+					store.Delete(key)
+					delete(objs, key)
+				case op < 8:
+					updates := map[string]interface{}{key: randomValues()}
+					deletes := []string{fmt.Sprintf("key-%d", rng.IntN(30))}
+					if _, ok := updates[deletes[0]]; ok {
+						deletes = nil
+					}
+					assert.NoError(t, store.Apply(updates, deletes))
+					for _, key := range deletes {
+						delete(objs, key)
+					}
+					maps.Copy(objs, updates)
+				default:
+					// Keep about half of the objects, changing some of them
+					items := make(map[string]interface{})
+					for key, obj := range objs {
+						switch rng.IntN(3) {
+						case 0:
+							items[key] = obj
+						case 1:
+							items[key] = randomValues()
+						}
+					}
+					assert.NoError(t, store.ReplaceIncremental(items))
+					objs = items
+				}
+			}
+			expected := bruteForceIndex(objs, valuesIndexFunc)
+			for value := 0; value < 8; value++ {
+				keys, err := store.IndexKeys("values", value, nil)
+				assert.NoError(t, err)
+				assert.ElementsMatch(t, expected[value].UnsortedList(), keys, "value %d", value)
+			}
+		})
+	}
+}
+
+func TestThreadSafeStoreAddIndexersAsync(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 1000; i++ {
+				assert.NoError(t, store.Add(strconv.Itoa(i), []int{i % 10, i % 7}))
+			}
+			done := store.AddIndexersAsync(Indexers[int]{"values": valuesIndexFunc}, 16)
+			// The name is taken as soon as the call returns
+			assert.Error(t, store.AddIndexer("values", valuesIndexFunc))
+
+			// Changes made meanwhile end up in the new index
+			var wg sync.WaitGroup
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := w; i < 1000; i += 4 {
+						key := strconv.Itoa(i)
+						if i%3 == 0 {
+							store.Delete(key)
+						} else {
+							assert.NoError(t, store.Update(key, []int{i % 5}))
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			assert.NoError(t, <-done)
+
+			objs := make(map[string]interface{})
+			store.Range(func(key string, obj interface{}) bool {
+				objs[key] = obj
+				return true
+			})
+			expected := bruteForceIndex(objs, valuesIndexFunc)
+			for value := 0; value < 10; value++ {
+				keys, err := store.IndexKeys("values", value, nil)
+				assert.NoError(t, err)
+				assert.ElementsMatch(t, expected[value].UnsortedList(), keys, "value %d", value)
+			}
+		})
+	}
+}
+
+func TestThreadSafeStoreAddIndexersAsyncError(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				assert.NoError(t, store.Add(strconv.Itoa(i), i))
+			}
+			assert.NoError(t, store.Add("bad", -1))
+
+			// The indexer is abandoned if it fails on an object
+			assert.ErrorAs(t, <-store.AddIndexersAsync(Indexers[int]{"value": nonNegativeIndexFunc}, 10), new(IndexError))
+			_, err := store.IndexKeys("value", 1, nil)
+			assert.Error(t, err)
+
+			store.Delete("bad")
+			assert.NoError(t, <-store.AddIndexersAsync(Indexers[int]{"value": nonNegativeIndexFunc}, 0))
+			keys, err := store.IndexKeys("value", 1, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"1"}, keys)
+		})
+	}
+}
+
+func TestThreadSafeStoreGetMany(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c"}, []interface{}{1, 2, 3}))
+			items := store.GetMany([]string{"a", "c", "missing"})
+			assert.Equal(t, map[string]interface{}{"a": 1, "c": 3}, items)
+
+			stats := store.Stats()
+			assert.Equal(t, uint64(3), stats.Gets)
+			assert.Equal(t, uint64(2), stats.Hits)
+			assert.Equal(t, uint64(1), stats.Misses)
+		})
+	}
+}
+
+func TestThreadSafeStoreContains(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.Add("a", 1))
+			assert.True(t, store.Contains("a"))
+			assert.False(t, store.Contains("b"))
+			assert.Zero(t, store.Stats().Gets)
+
+			store.Delete("a")
+			assert.False(t, store.Contains("a"))
+		})
+	}
+}
+
+func TestThreadSafeStoreRemoveIndexer(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"value": nonNegativeIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"value": nonNegativeIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b"}, []interface{}{1, 2}))
+			assert.NoError(t, store.RemoveIndexer("value"))
+			_, err := store.ByIndex("value", 1, nil)
+			assert.Error(t, err)
+			assert.Error(t, store.RemoveIndexer("value"))
+
+			// The indexer is no longer run, and its name may be reused
+			assert.NoError(t, store.Add("c", -1))
+			assert.NoError(t, store.AddIndexer("value", func(obj interface{}) ([]int, error) {
+				return []int{obj.(int) * 10}, nil
+			}))
+			keys, err := store.IndexKeys("value", 20, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"b"}, keys)
+		})
+	}
+}
+
+func TestThreadSafeStoreIndexValues(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"values": valuesIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"values": valuesIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			values, err := store.IndexValues("values")
+			assert.NoError(t, err)
+			assert.Empty(t, values)
+
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c"}, []interface{}{[]int{1, 2}, []int{2, 3}, []int{3}}))
+			values, err = store.IndexValues("values")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []int{1, 2, 3}, values)
+
+			// Values go away with the last object holding them
+			store.DeleteAll([]string{"b", "c"})
+			values, err = store.IndexValues("values")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []int{1, 2}, values)
+
+			_, err = store.IndexValues("missing")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreGetIndexers(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"value": nonNegativeIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"value": nonNegativeIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.AddIndexer("values", valuesIndexFunc))
+			indexers := store.GetIndexers()
+			assert.ElementsMatch(t, []string{"value", "values"}, slices.Collect(maps.Keys(indexers)))
+
+			// The result is a copy
+			delete(indexers, "value")
+			assert.Len(t, store.GetIndexers(), 2)
+		})
+	}
+}
+
+func TestThreadSafeStoreUniqueIndexer(t *testing.T) {
+	store := NewThreadSafeStore(Indexers[int]{"value": nonNegativeIndexFunc}, Indexes[int, string]{})
+	assert.NoError(t, store.Add("a", 1))
+	assert.NoError(t, store.Add("b", 2))
+	assert.NoError(t, store.AddUniqueIndexer("unique", nonNegativeIndexFunc))
+
+	// A value held under another key conflicts, while the same key may keep it
+	err := store.Add("c", 1)
+	assert.ErrorIs(t, err, ErrUniqueConflict)
+	var indexErr IndexError
+	assert.ErrorAs(t, err, &indexErr)
+	assert.Equal(t, "unique", indexErr.Name)
+	assert.Equal(t, "c", indexErr.Key)
+	assert.False(t, store.Contains("c"))
+	assert.NoError(t, store.Update("a", 1))
+	assert.NoError(t, store.Update("a", 3))
+	assert.NoError(t, store.Add("c", 1))
+
+	obj, exists, err := store.GetByUniqueIndex("unique", 3)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 3, obj)
+	_, exists, err = store.GetByUniqueIndex("unique", 4)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	_, _, err = store.GetByUniqueIndex("value", 3)
+	assert.Error(t, err)
+
+	// Objects already sharing a value, such as 1 and 3, keep the indexer from
+	// being added
+	assert.ErrorIs(t, store.AddUniqueIndexer("parity", func(obj interface{}) ([]int, error) {
+		return []int{obj.(int) & 1}, nil
+	}), ErrUniqueConflict)
+	assert.NotContains(t, store.GetIndexers(), "parity")
+}
+
+func TestThreadSafeStoreUniqueIndexerSharded(t *testing.T) {
+	store := NewShardedThreadSafeStore(4, hashString, Indexers[int]{})
+	assert.ErrorIs(t, store.AddUniqueIndexer("unique", nonNegativeIndexFunc), errors.ErrUnsupported)
+	_, _, err := store.GetByUniqueIndex("unique", 1)
+	assert.Error(t, err)
+}
+
+func TestThreadSafeStoreOrderedIndexer(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c", "d"}, []interface{}{4, 1, 3, 2}))
+			assert.NoError(t, store.AddOrderedIndexer("value", nonNegativeIndexFunc, cmp.Compare[int]))
+			assert.NoError(t, store.Add("e", 5))
+
+			items, err := store.ByIndexRange("value", 2, 4)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{2, 3}, items)
+			items, err = store.ByIndexGreaterThan("value", 3)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{4, 5}, items)
+			items, err = store.ByIndexLessThan("value", 3)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{1, 2}, items)
+
+			// Updates and deletes move the objects
+			assert.NoError(t, store.Update("b", 6))
+			store.Delete("a")
+			items, err = store.ByIndexGreaterThan("value", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{3, 5, 6}, items)
+
+			assert.NoError(t, store.AddIndexer("plain", nonNegativeIndexFunc))
+			_, err = store.ByIndexRange("plain", 0, 10)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreByIndexPrefix(t *testing.T) {
+	pathIndexFunc := func(obj interface{}) ([]string, error) { return []string{obj.(string)}, nil }
+	stores := map[string]ThreadSafeStore[string, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[string]{}, Indexes[string, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[string]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.AddOrderedIndexer("path", pathIndexFunc, cmp.Compare[string]))
+			assert.Nil(t, store.UpdateAll([]string{"1", "2", "3", "4"}, []interface{}{"a/c", "b/x", "a/b", "a"}))
+
+			items, err := ByIndexPrefix(store, "path", "a/")
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{"a/b", "a/c"}, items)
+			items, err = ByIndexPrefix(store, "path", "")
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{"a", "a/b", "a/c", "b/x"}, items)
+			items, err = ByIndexPrefix(store, "path", "c")
+			assert.NoError(t, err)
+			assert.Empty(t, items)
+
+			assert.NoError(t, store.AddIndexer("plain", pathIndexFunc))
+			_, err = ByIndexPrefix(store, "plain", "a")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreSetIndexNormalizer(t *testing.T) {
+	nameIndexFunc := func(obj interface{}) ([]string, error) { return []string{obj.(string)}, nil }
+	stores := map[string]ThreadSafeStore[string, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[string]{"name": nameIndexFunc}, Indexes[string, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[string]{"name": nameIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.AddOrderedIndexer("path", nameIndexFunc, cmp.Compare[string]))
+			assert.Nil(t, store.UpdateAll([]string{"1", "2"}, []interface{}{"Alice", "BOB"}))
+
+			// Existing objects are indexed anew
+			assert.NoError(t, store.SetIndexNormalizer("name", strings.ToLower))
+			assert.NoError(t, store.SetIndexNormalizer("path", strings.ToLower))
+			items, err := store.ByIndex("name", "ALICE", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{"Alice"}, items)
+			values, err := store.IndexValues("name")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"alice", "bob"}, values)
+
+			// Values are normalized on writes and range queries
+			assert.NoError(t, store.Add("3", "bEN"))
+			items, err = store.ByIndexRange("path", "B", "C")
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{"bEN", "BOB"}, items)
+			items, err = ByIndexPrefix(store, "path", "BE")
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{"bEN"}, items)
+			keys, err := store.IndexKeys("name", "Ben", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"3"}, keys)
+
+			assert.NoError(t, store.SetIndexNormalizer("name", nil))
+			items, err = store.ByIndex("name", "alice", nil)
+			assert.NoError(t, err)
+			assert.Empty(t, items)
+			assert.Error(t, store.SetIndexNormalizer("missing", strings.ToLower))
+		})
+	}
+}
+
+func TestThreadSafeStoreIndexStats(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"values": valuesIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"values": valuesIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			stats, err := store.IndexStats("values")
+			assert.NoError(t, err)
+			assert.Equal(t, IndexStats[int]{Counts: map[int]int{}}, stats)
+
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c", "d"}, []interface{}{
+				[]int{1, 2}, []int{1}, []int{1, 3}, []int{3},
+			}))
+			stats, err = store.IndexStats("values")
+			assert.NoError(t, err)
+			assert.Equal(t, IndexStats[int]{
+				Values:         3,
+				Postings:       6,
+				MaxBucket:      3,
+				MaxBucketValue: 1,
+				Counts:         map[int]int{1: 3, 2: 1, 3: 2},
+			}, stats)
+
+			store.Delete("a")
+			stats, err = store.IndexStats("values")
+			assert.NoError(t, err)
+			assert.Equal(t, map[int]int{1: 2, 3: 2}, stats.Counts)
+			assert.Equal(t, 4, stats.Postings)
+
+			_, err = store.IndexStats("missing")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreCountByIndex(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"values": valuesIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"values": valuesIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c", "d"}, []interface{}{
+				[]int{1, 2}, []int{1}, []int{1, 3}, []int{3},
+			}))
+			count, err := store.CountByIndex("values", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, count)
+
+			store.Delete("a")
+			count, err = store.CountByIndex("values", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, count)
+			count, err = store.CountByIndex("values", 2)
+			assert.NoError(t, err)
+			assert.Zero(t, count)
+
+			_, err = store.CountByIndex("missing", 1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreGroupByIndex(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"values": valuesIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"values": valuesIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			groups, err := store.GroupByIndex("values")
+			assert.NoError(t, err)
+			assert.Empty(t, groups)
+
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c", "d"}, []interface{}{
+				[]int{1, 2}, []int{1}, []int{1, 3}, []int{3},
+			}))
+			groups, err = store.GroupByIndex("values")
+			assert.NoError(t, err)
+			assert.Len(t, groups, 3)
+			assert.ElementsMatch(t, []string{"a", "b", "c"}, groups[1])
+			assert.Equal(t, []string{"a"}, groups[2])
+			assert.ElementsMatch(t, []string{"c", "d"}, groups[3])
+
+			store.Delete("a")
+			counts, err := store.GroupCountsByIndex("values")
+			assert.NoError(t, err)
+			assert.Equal(t, map[int]int{1: 2, 3: 2}, counts)
+
+			_, err = store.GroupByIndex("missing")
+			assert.Error(t, err)
+			_, err = store.GroupCountsByIndex("missing")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreLazyIndexing(t *testing.T) {
+	var calls atomic.Int32
+	countingIndexFunc := func(obj interface{}) ([]int, error) {
+		calls.Add(1)
+		return nonNegativeIndexFunc(obj)
+	}
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}, WithLazyIndexing()),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}, WithLazyIndexing()),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			calls.Store(0)
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c"}, []interface{}{1, 2, 1}))
+			assert.NoError(t, store.AddIndexer("value", countingIndexFunc))
+			assert.Error(t, store.AddIndexer("value", countingIndexFunc))
+			assert.Contains(t, store.GetIndexers(), "value")
+
+			// Writes skip the index until it is queried
+			assert.NoError(t, store.Add("d", 2))
+			assert.Zero(t, calls.Load())
+			keys, err := store.IndexKeys("value", 1, nil)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"a", "c"}, keys)
+			assert.EqualValues(t, 4, calls.Load())
+
+			// It is then kept up to date
+			assert.NoError(t, store.Update("a", 2))
+			keys, err = store.IndexKeys("value", 2, nil)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"a", "b", "d"}, keys)
+
+			// Building the index fails like adding it eagerly, and keeps
+			// failing until the failing object is deleted
+			assert.NoError(t, store.AddIndexer("failing", func(obj interface{}) ([]int, error) {
+				if obj == 1 {
+					return nil, errors.New("failing")
+				}
+				return []int{obj.(int)}, nil
+			}))
+			_, err = store.ByIndex("failing", 2, nil)
+			assert.Error(t, err)
+			assert.Contains(t, store.GetIndexers(), "failing")
+			_, err = store.ByIndex("failing", 2, nil)
+			assert.Error(t, err)
+			store.Delete("c")
+			keys, err = store.IndexKeys("failing", 2, nil)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"a", "b", "d"}, keys)
+
+			// Unbuilt indexers can be removed
+			assert.NoError(t, store.AddIndexer("unused", nonNegativeIndexFunc))
+			assert.NoError(t, store.RemoveIndexer("unused"))
+			_, err = store.IndexValues("unused")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreByIndexValues(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"values": valuesIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"values": valuesIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c"}, []interface{}{
+				[]int{1, 2}, []int{2, 3}, []int{4},
+			}))
+
+			// Objects holding several of the values are returned once
+			items, err := store.ByIndexValues("values", 1, 2, 5)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []interface{}{[]int{1, 2}, []int{2, 3}}, items)
+			items, err = store.ByIndexValues("values", 4)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{[]int{4}}, items)
+			items, err = store.ByIndexValues("values")
+			assert.NoError(t, err)
+			assert.Empty(t, items)
+
+			_, err = store.ByIndexValues("missing", 1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreByIndexes(t *testing.T) {
+	type user struct {
+		Age int
+		Sex string
+	}
+	indexers := Indexers[any]{
+		"age": func(obj any) ([]any, error) { return []any{obj.(user).Age}, nil },
+		"sex": func(obj any) ([]any, error) { return []any{obj.(user).Sex}, nil },
+	}
+	stores := map[string]ThreadSafeStore[any, string, interface{}]{
+		"map":     NewThreadSafeStore(indexers, Indexes[any, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, indexers),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c", "d"}, []interface{}{
+				user{20, "woman"}, user{20, "man"}, user{30, "woman"}, user{20, "woman"},
+			}))
+
+			items, err := store.ByIndexes(map[string]any{"sex": "woman", "age": 20})
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []interface{}{user{20, "woman"}, user{20, "woman"}}, items)
+			items, err = store.ByIndexes(map[string]any{"sex": "man", "age": 30})
+			assert.NoError(t, err)
+			assert.Empty(t, items)
+			items, err = store.ByIndexes(map[string]any{"age": 30})
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{user{30, "woman"}}, items)
+			items, err = store.ByIndexes(nil)
+			assert.NoError(t, err)
+			assert.Empty(t, items)
+
+			_, err = store.ByIndexes(map[string]any{"sex": "woman", "missing": 1})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestThreadSafeStoreReindex(t *testing.T) {
+	for _, name := range []string{"map", "sharded"} {
+		t.Run(name, func(t *testing.T) {
+			// The indexer has a bug, adding 10 to the values, until fixed
+			var fixed, failing bool
+			indexFunc := func(obj interface{}) ([]int, error) {
+				if failing {
+					return nil, errors.New("failing")
+				}
+				if fixed {
+					return []int{obj.(int)}, nil
+				}
+				return []int{obj.(int) + 10}, nil
+			}
+			store := NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{})
+			if name == "sharded" {
+				store = NewShardedThreadSafeStore(4, hashString, Indexers[int]{})
+			}
+			assert.NoError(t, store.AddOrderedIndexer("value", indexFunc, cmp.Compare[int]))
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c"}, []interface{}{1, 2, 3}))
+
+			fixed = true
+			assert.NoError(t, store.Reindex("value"))
+			items, err := store.ByIndexLessThan("value", 3)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{1, 2}, items)
+			keys, err := store.IndexKeys("value", 13, nil)
+			assert.NoError(t, err)
+			assert.Empty(t, keys)
+
+			// A failing rebuild leaves the index as it was
+			failing = true
+			assert.Error(t, store.Reindex("value"))
+			failing = false
+			items, err = store.ByIndexGreaterThan("value", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{2, 3}, items)
+
+			assert.Error(t, store.Reindex("missing"))
+		})
+	}
+}
+
+func TestThreadSafeStoreOnIndexChange(t *testing.T) {
+	type task struct{ status string }
+	statusIndexFunc := func(obj interface{}) ([]string, error) {
+		if obj.(task).status == "" {
+			return nil, nil
+		}
+		return []string{obj.(task).status}, nil
+	}
+	for _, name := range []string{"map", "sharded"} {
+		t.Run(name, func(t *testing.T) {
+			store := NewThreadSafeStore(Indexers[string]{"status": statusIndexFunc}, Indexes[string, string]{})
+			if name == "sharded" {
+				store = NewShardedThreadSafeStore(4, hashString, Indexers[string]{"status": statusIndexFunc})
+			}
+			var changes []IndexChange[string, string]
+			assert.NoError(t, store.OnIndexChange("status", func(change IndexChange[string, string]) {
+				changes = append(changes, change)
+			}))
+			assert.Error(t, store.OnIndexChange("missing", func(IndexChange[string, string]) {}))
+
+			assert.NoError(t, store.Add("a", task{"pending"}))
+			assert.NoError(t, store.Update("a", task{"ready"}))
+			assert.Equal(t, []IndexChange[string, string]{
+				{Index: "status", Key: "a", Value: "pending", Entered: true},
+				{Index: "status", Key: "a", Value: "pending"},
+				{Index: "status", Key: "a", Value: "ready", Entered: true},
+			}, changes)
+
+			// Keeping the value, or rebuilding the index, moves no key
+			changes = nil
+			assert.NoError(t, store.Update("a", task{"ready"}))
+			assert.NoError(t, store.Reindex("status"))
+			assert.Empty(t, changes)
+
+			// Normalizing the values moves the keys whose values change
+			assert.NoError(t, store.Update("a", task{"Ready"}))
+			changes = nil
+			assert.NoError(t, store.SetIndexNormalizer("status", strings.ToLower))
+			assert.Equal(t, []IndexChange[string, string]{
+				{Index: "status", Key: "a", Value: "Ready"},
+				{Index: "status", Key: "a", Value: "ready", Entered: true},
+			}, changes)
+
+			changes = nil
+			store.Delete("a")
+			assert.Equal(t, []IndexChange[string, string]{{Index: "status", Key: "a", Value: "ready"}}, changes)
+
+			// Replace reports the differences with the previous objects only
+			assert.Nil(t, store.UpdateAll([]string{"b", "c"}, []interface{}{task{"pending"}, task{"ready"}}))
+			changes = nil
+			assert.NoError(t, store.Replace(map[string]interface{}{"b": task{"pending"}, "c": task{"failed"}, "d": task{}}))
+			assert.ElementsMatch(t, []IndexChange[string, string]{
+				{Index: "status", Key: "c", Value: "ready"},
+				{Index: "status", Key: "c", Value: "failed", Entered: true},
+			}, changes)
+
+			// Removing the index drops its hooks
+			assert.NoError(t, store.RemoveIndexer("status"))
+			assert.NoError(t, store.AddIndexer("status", statusIndexFunc))
+			changes = nil
+			assert.NoError(t, store.Update("b", task{"ready"}))
+			assert.Empty(t, changes)
+		})
+	}
+}
+
+func TestThreadSafeStoreDeleteIf(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{"value": nonNegativeIndexFunc}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{"value": nonNegativeIndexFunc}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b"}, []interface{}{1, 2}))
+			isOne := func(obj interface{}) bool { return obj == 1 }
+
+			assert.False(t, store.DeleteIf("b", isOne))
+			assert.False(t, store.DeleteIf("missing", isOne))
+			assert.True(t, store.DeleteIf("a", isOne))
+			assert.Equal(t, []string{"b"}, store.ListKeys())
+			keys, _ := store.IndexKeys("value", 1, nil)
+			assert.Empty(t, keys)
+			assert.Equal(t, uint64(1), store.Stats().Deletes)
+		})
+	}
+}
+
+func TestThreadSafeStoreRangeKeys(t *testing.T) {
+	stores := map[string]ThreadSafeStore[int, string, interface{}]{
+		"map":     NewThreadSafeStore(Indexers[int]{}, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, Indexers[int]{}),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"a", "b", "c"}, []interface{}{1, 2, 3}))
+			var keys []string
+			store.RangeKeys(func(key string) bool {
+				keys = append(keys, key)
+				return true
+			})
+			assert.ElementsMatch(t, []string{"a", "b", "c"}, keys)
+
+			// fn may modify the store, and may stop early
+			count := 0
+			store.RangeKeys(func(key string) bool {
+				store.Delete(key)
+				count++
+				return count < 2
+			})
+			assert.Equal(t, 2, count)
+			assert.Equal(t, 1, store.Size())
+			assert.Len(t, store.ListKeys(), 1)
+		})
+	}
+}
+
+func TestThreadSafeStoreTypedValues(t *testing.T) {
+	indexers := ValueIndexers[int, *typedUser]{
+		"age": func(u *typedUser) ([]int, error) {
+			return []int{u.Age}, nil
+		},
+	}
+	stores := map[string]ThreadSafeStore[int, string, *typedUser]{
+		"map":     NewThreadSafeStore(indexers, Indexes[int, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, indexers),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			alice := &typedUser{"alice", 30}
+			assert.NoError(t, store.Add("alice", alice))
+			assert.NoError(t, store.Add("bob", &typedUser{"bob", 40}))
+
+			user, exists := store.Get("alice")
+			assert.True(t, exists)
+			assert.Equal(t, 30, user.Age)
+
+			users, err := store.ByIndex("age", 40, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []*typedUser{{"bob", 40}}, users)
+
+			swapped, err := store.CompareAndSwap("alice", alice, &typedUser{"alice", 31})
+			assert.NoError(t, err)
+			assert.True(t, swapped)
+			keys, err := store.IndexKeys("age", 31, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"alice"}, keys)
+			assert.Len(t, store.List(), 2)
+		})
+	}
+}
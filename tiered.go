@@ -0,0 +1,370 @@
+package cache
+
+import (
+	"hash/maphash"
+	"iter"
+	"sync"
+)
+
+// tieredStripes is the number of stripes the keys of a tiered store are
+// spread over to detect the writes racing with a copy into l1.
+const tieredStripes = 64
+
+// NewTieredStore creates a Store layering a small, fast l1 over a larger l2.
+// Reads check l1 first and fall back to l2, copying the objects found there
+// into l1. Writes go to l2 and then l1, so that l2 holds every object and l1
+// the recently used ones; listings therefore read l2 only. The tiers are
+// updated one after the other, so readers may briefly see them disagree, but
+// an object read from l2 is not copied into l1 if its key may have been
+// written while it was read, so that l1 never keeps an outdated object.
+func NewTieredStore[K, T comparable](l1 EvictionStore[K, T], l2 Store[T]) Store[T] {
+	return &tieredStore[K, T]{l1: l1, l2: l2, seed: maphash.MakeSeed()}
+}
+
+// tieredStore implements the Store returned by NewTieredStore.
+type tieredStore[K, T comparable] struct {
+	l1 EvictionStore[K, T]
+	l2 Store[T]
+
+	// mu orders the copies into l1 with the starts and ends of the writes,
+	// and guards writes and inFlight
+	mu sync.Mutex
+	// writes counts the writes started on the keys of each stripe, and
+	// inFlight those not finished yet
+	writes   [tieredStripes]uint64
+	inFlight [tieredStripes]int
+	seed     maphash.Seed
+}
+
+// Add inserts an object into both tiers.
+func (s *tieredStore[K, T]) Add(obj interface{}) error {
+	defer s.finishWrites(s.startWrites(obj))
+	if err := s.l2.Add(obj); err != nil {
+		return err
+	}
+	return s.l1.Add(obj)
+}
+
+// Update modifies an object in both tiers.
+func (s *tieredStore[K, T]) Update(obj interface{}) error {
+	defer s.finishWrites(s.startWrites(obj))
+	if err := s.l2.Update(obj); err != nil {
+		return err
+	}
+	return s.l1.Update(obj)
+}
+
+// Delete removes an object from both tiers.
+func (s *tieredStore[K, T]) Delete(obj interface{}) error {
+	defer s.finishWrites(s.startWrites(obj))
+	if err := s.l2.Delete(obj); err != nil {
+		return err
+	}
+	return s.l1.Delete(obj)
+}
+
+// AddAll inserts objects into both tiers in a batch each.
+func (s *tieredStore[K, T]) AddAll(objs []interface{}) []error {
+	return s.batch(objs, s.l2.AddAll, s.l1.AddAll)
+}
+
+// UpdateAll modifies objects in both tiers in a batch each.
+func (s *tieredStore[K, T]) UpdateAll(objs []interface{}) []error {
+	return s.batch(objs, s.l2.UpdateAll, s.l1.UpdateAll)
+}
+
+// DeleteAll removes objects from both tiers in a batch each.
+func (s *tieredStore[K, T]) DeleteAll(objs []interface{}) []error {
+	return s.batch(objs, s.l2.DeleteAll, s.l1.DeleteAll)
+}
+
+// batch applies a batch operation to l2 and then to the objects it accepted
+// in l1, returning the first error of every object.
+func (s *tieredStore[K, T]) batch(objs []interface{}, l2Op, l1Op func(objs []interface{}) []error) []error {
+	defer s.finishWrites(s.startWrites(objs...))
+	errs := l2Op(objs)
+	accepted := objs
+	var positions []int
+	if errs != nil {
+		accepted = make([]interface{}, 0, len(objs))
+		for i, obj := range objs {
+			if errs[i] == nil {
+				accepted = append(accepted, obj)
+				positions = append(positions, i)
+			}
+		}
+	}
+	l1Errs := l1Op(accepted)
+	if l1Errs == nil {
+		return errs
+	}
+	if errs == nil {
+		return l1Errs
+	}
+	for i, err := range l1Errs {
+		errs[positions[i]] = err
+	}
+	return errs
+}
+
+// List returns all objects of l2.
+func (s *tieredStore[K, T]) List() []interface{} {
+	return s.l2.List()
+}
+
+// ListKeys returns all keys of l2.
+func (s *tieredStore[K, T]) ListKeys() []T {
+	return s.l2.ListKeys()
+}
+
+// ListFiltered returns the objects of l2 for which pred returns true.
+func (s *tieredStore[K, T]) ListFiltered(pred func(obj interface{}) bool) []interface{} {
+	return s.l2.ListFiltered(pred)
+}
+
+// ListKeysFiltered returns the keys of the objects of l2 for which pred returns true.
+func (s *tieredStore[K, T]) ListKeysFiltered(pred func(obj interface{}) bool) []T {
+	return s.l2.ListKeysFiltered(pred)
+}
+
+// Range calls fn for every object of l2 until fn returns false.
+func (s *tieredStore[K, T]) Range(fn func(key T, obj interface{}) bool) {
+	s.l2.Range(fn)
+}
+
+// All returns an iterator over the keys and objects of l2.
+func (s *tieredStore[K, T]) All() iter.Seq2[T, interface{}] {
+	return s.l2.All()
+}
+
+// ListPage returns a page of the objects of l2.
+func (s *tieredStore[K, T]) ListPage(limit int, continueToken string) ([]interface{}, string, error) {
+	return s.l2.ListPage(limit, continueToken)
+}
+
+// KeyOf returns the key of obj in l2.
+func (s *tieredStore[K, T]) KeyOf(obj interface{}) (T, error) {
+	return s.l2.KeyOf(obj)
+}
+
+// Get returns the object stored under the key of obj.
+func (s *tieredStore[K, T]) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := s.l2.KeyOf(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.GetByKey(key)
+}
+
+// GetByKey returns the object stored under key.
+func (s *tieredStore[K, T]) GetByKey(key T) (interface{}, bool, error) {
+	if item, exists, err := s.l1.GetByKey(key); err != nil || exists {
+		return item, exists, err
+	}
+	started, idle := s.startedWrites(key)
+	item, exists, err := s.l2.GetByKey(key)
+	if err != nil || !exists {
+		return item, exists, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idle && s.unchanged(key, started) {
+		if err := s.l1.Add(item); err != nil {
+			return nil, false, err
+		}
+	}
+	return item, true, nil
+}
+
+// GetMany returns the objects stored under keys, looking the keys missing
+// from l1 up in l2 and copying the objects found there into l1, like GetByKey.
+func (s *tieredStore[K, T]) GetMany(keys []T) (map[T]interface{}, []T) {
+	items, missing := s.l1.GetMany(keys)
+	if len(missing) == 0 {
+		return items, missing
+	}
+	started := make([]uint64, len(missing))
+	idle := make([]bool, len(missing))
+	for i, key := range missing {
+		started[i], idle[i] = s.startedWrites(key)
+	}
+	found, stillMissing := s.l2.GetMany(missing)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	promoted := make([]interface{}, 0, len(found))
+	for i, key := range missing {
+		item, ok := found[key]
+		if !ok {
+			continue
+		}
+		items[key] = item
+		if idle[i] && s.unchanged(key, started[i]) {
+			promoted = append(promoted, item)
+		}
+	}
+	s.l1.AddAll(promoted)
+	return items, stillMissing
+}
+
+// stripe returns the stripe of key in s.writes.
+func (s *tieredStore[K, T]) stripe(key T) int {
+	return int(maphash.Comparable(s.seed, key) % tieredStripes)
+}
+
+// startedWrites returns the number of writes started on the stripe of key,
+// to be compared by unchanged once an object is read from l2, and whether
+// none of them is in flight. An object read while a write is in flight may
+// be outdated by the time the write finishes, so it must not be copied.
+func (s *tieredStore[K, T]) startedWrites(key T) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stripe := s.stripe(key)
+	return s.writes[stripe], s.inFlight[stripe] == 0
+}
+
+// unchanged reports whether no write started on the stripe of key since
+// startedWrites returned started, and none is in flight.
+// The caller must hold s.mu.
+func (s *tieredStore[K, T]) unchanged(key T, started uint64) bool {
+	stripe := s.stripe(key)
+	return s.writes[stripe] == started && s.inFlight[stripe] == 0
+}
+
+// startWrites counts a write started on the keys of objs, before any tier
+// changes, and returns their stripes to pass to finishWrites once both tiers
+// changed. Objects without a key are left to fail in l2.
+func (s *tieredStore[K, T]) startWrites(objs ...interface{}) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stripes := make([]int, 0, len(objs))
+	for _, obj := range objs {
+		if key, err := s.l2.KeyOf(obj); err == nil {
+			stripe := s.stripe(key)
+			s.writes[stripe]++
+			s.inFlight[stripe]++
+			stripes = append(stripes, stripe)
+		}
+	}
+	return stripes
+}
+
+// startWritesAll counts a write started on every key, such as by Replace,
+// and returns every stripe to pass to finishWrites.
+func (s *tieredStore[K, T]) startWritesAll() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stripes := make([]int, tieredStripes)
+	for i := range s.writes {
+		s.writes[i]++
+		s.inFlight[i]++
+		stripes[i] = i
+	}
+	return stripes
+}
+
+// finishWrites counts the writes started by startWrites on stripes as
+// finished.
+func (s *tieredStore[K, T]) finishWrites(stripes []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stripe := range stripes {
+		s.inFlight[stripe]--
+	}
+}
+
+// Contains reports whether l1 or l2 stores an object under key, without
+// copying it into l1.
+func (s *tieredStore[K, T]) Contains(key T) bool {
+	return s.l1.Contains(key) || s.l2.Contains(key)
+}
+
+// GetMeta returns the history recorded by l1 for key, or else by l2.
+func (s *tieredStore[K, T]) GetMeta(key T) (EntryMeta, bool) {
+	if meta, ok := s.l1.GetMeta(key); ok {
+		return meta, true
+	}
+	return s.l2.GetMeta(key)
+}
+
+// CompareAndSwap replaces old by new in l2 if old is the object stored
+// there, and then updates l1.
+func (s *tieredStore[K, T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	defer s.finishWrites(s.startWrites(new))
+	swapped, err := s.l2.CompareAndSwap(old, new)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	return true, s.l1.Update(new)
+}
+
+// CompareAndDelete removes old from l2 if it is the object stored there, and
+// then from l1.
+func (s *tieredStore[K, T]) CompareAndDelete(old interface{}) (bool, error) {
+	defer s.finishWrites(s.startWrites(old))
+	deleted, err := s.l2.CompareAndDelete(old)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	return true, s.l1.Delete(old)
+}
+
+// Replace replaces the objects of l2 and empties l1, which fills up again
+// as objects are read.
+func (s *tieredStore[K, T]) Replace(list []interface{}) error {
+	defer s.finishWrites(s.startWritesAll())
+	if err := s.l2.Replace(list); err != nil {
+		return err
+	}
+	return s.l1.Replace(nil)
+}
+
+// ReplaceWithDiff replaces the objects like Replace and reports what changed in l2.
+func (s *tieredStore[K, T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	defer s.finishWrites(s.startWritesAll())
+	added, updated, removed, err := s.l2.ReplaceWithDiff(list)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return added, updated, removed, s.l1.Replace(nil)
+}
+
+// Txn applies the changes buffered by fn atomically to l2, and then brings
+// the changed objects of l1 up to date.
+func (s *tieredStore[K, T]) Txn(fn func(tx Txn[T]) error) error {
+	var changed []interface{}
+	var stripes []int
+	defer func() { s.finishWrites(stripes) }()
+	err := s.l2.Txn(func(tx Txn[T]) error {
+		if err := fn(recordingTxn[T]{tx, &changed}); err != nil {
+			return err
+		}
+		// The changes are applied once fn returns
+		stripes = s.startWrites(changed...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, obj := range changed {
+		item, exists, err := s.l2.Get(obj)
+		if err == nil && exists {
+			err = s.l1.Update(item)
+		} else if err == nil {
+			err = s.l1.Delete(obj)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot returns a read-only copy of l2.
+func (s *tieredStore[K, T]) Snapshot() Store[T] {
+	return s.l2.Snapshot()
+}
+
+// Size returns the number of objects in l2.
+func (s *tieredStore[K, T]) Size() int {
+	return s.l2.Size()
+}
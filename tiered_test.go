@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/liuxinbot/cache/eviction"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTiers() (EvictionStore[any, string], Store[string], Store[string]) {
+	l1 := NewEvictionCache[any](testKeyFunc, eviction.NewLRU[string](2), Indexers[any]{})
+	l2 := NewStore(testKeyFunc)
+	return l1, l2, NewTieredStore(l1, l2)
+}
+
+func TestTieredStore(t *testing.T) {
+	l1, l2, store := newTestTiers()
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b", "c"}))
+
+	// l1 only keeps the most recent objects, l2 all of them
+	assert.ElementsMatch(t, []string{"b", "c"}, l1.ListKeys())
+	assert.Equal(t, 3, l2.Size())
+	assert.Equal(t, 3, store.Size())
+
+	// A miss in l1 is served by l2 and promoted
+	item, exists, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "a", item)
+	assert.ElementsMatch(t, []string{"a", "c"}, l1.ListKeys())
+
+	assert.NoError(t, store.Delete("a"))
+	_, exists, err = store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 2, l2.Size())
+}
+
+// pausingStore is a Store whose GetByKey waits for resume after reading, so
+// that writes can race with the copy of the object read into l1.
+type pausingStore struct {
+	Store[string]
+	paused, resume chan struct{}
+}
+
+func (s pausingStore) GetByKey(key string) (interface{}, bool, error) {
+	item, exists, err := s.Store.GetByKey(key)
+	s.paused <- struct{}{}
+	<-s.resume
+	return item, exists, err
+}
+
+func TestTieredStoreRacingWrites(t *testing.T) {
+	// Objects are "key@version"
+	versionKeyFunc := func(obj interface{}) (string, error) {
+		key, _, _ := strings.Cut(obj.(string), "@")
+		return key, nil
+	}
+	for name, write := range map[string]func(store Store[string]) error{
+		"update": func(store Store[string]) error { return store.Update("a@2") },
+		"delete": func(store Store[string]) error { return store.Delete("a@1") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			l1 := NewEvictionCache[any](versionKeyFunc, eviction.NewLRU[string](2), Indexers[any]{})
+			l2 := pausingStore{NewStore(versionKeyFunc), make(chan struct{}), make(chan struct{})}
+			store := NewTieredStore(l1, l2)
+			assert.NoError(t, store.Add("a@1"))
+			assert.NoError(t, l1.Delete("a@1"))
+
+			read := make(chan interface{})
+			go func() {
+				item, _, _ := store.GetByKey("a")
+				read <- item
+			}()
+			<-l2.paused
+			assert.NoError(t, write(store))
+			close(l2.resume)
+			assert.Equal(t, "a@1", <-read)
+
+			// The object read before the write is not copied into l1
+			want, _, err := l2.Store.GetByKey("a")
+			assert.NoError(t, err)
+			item, _, err := l1.GetByKey("a")
+			assert.NoError(t, err)
+			assert.Equal(t, want, item)
+		})
+	}
+}
+
+// blockingStore is a pausingStore whose GetMany pauses too, and whose
+// Update and Delete wait for write before writing, so that a read can race
+// with a write already started.
+type blockingStore struct {
+	pausingStore
+	entered, write chan struct{}
+}
+
+func (s blockingStore) GetMany(keys []string) (map[string]interface{}, []string) {
+	items, missing := s.Store.GetMany(keys)
+	s.paused <- struct{}{}
+	<-s.resume
+	return items, missing
+}
+
+func (s blockingStore) Update(obj interface{}) error {
+	s.entered <- struct{}{}
+	<-s.write
+	return s.Store.Update(obj)
+}
+
+func (s blockingStore) Delete(obj interface{}) error {
+	s.entered <- struct{}{}
+	<-s.write
+	return s.Store.Delete(obj)
+}
+
+func TestTieredStoreReadDuringWrite(t *testing.T) {
+	// Objects are "key@version"
+	versionKeyFunc := func(obj interface{}) (string, error) {
+		key, _, _ := strings.Cut(obj.(string), "@")
+		return key, nil
+	}
+	writes := map[string]func(store Store[string]) error{
+		"update": func(store Store[string]) error { return store.Update("a@2") },
+		"delete": func(store Store[string]) error { return store.Delete("a@1") },
+	}
+	reads := map[string]func(store Store[string]) interface{}{
+		"get": func(store Store[string]) interface{} {
+			item, _, _ := store.GetByKey("a")
+			return item
+		},
+		"get many": func(store Store[string]) interface{} {
+			items, _ := store.(interface {
+				GetMany(keys []string) (map[string]interface{}, []string)
+			}).GetMany([]string{"a"})
+			return items["a"]
+		},
+	}
+	for writeName, write := range writes {
+		for readName, read := range reads {
+			t.Run(writeName+" "+readName, func(t *testing.T) {
+				l1 := NewEvictionCache[any](versionKeyFunc, eviction.NewLRU[string](2), Indexers[any]{})
+				l2 := blockingStore{
+					pausingStore{NewStore(versionKeyFunc), make(chan struct{}), make(chan struct{})},
+					make(chan struct{}), make(chan struct{}),
+				}
+				store := NewTieredStore(l1, l2)
+				assert.NoError(t, store.Add("a@1"))
+				assert.NoError(t, l1.Delete("a@1"))
+
+				// The write starts before the read, which reads l2 before the
+				// write changes it and copies the object once it finished
+				written := make(chan error)
+				go func() {
+					written <- write(store)
+				}()
+				<-l2.entered
+				readItem := make(chan interface{})
+				go func() {
+					readItem <- read(store)
+				}()
+				<-l2.paused
+				close(l2.write)
+				assert.NoError(t, <-written)
+				close(l2.resume)
+				assert.Equal(t, "a@1", <-readItem)
+
+				// The object read before the write is not copied into l1
+				want, _, err := l2.Store.GetByKey("a")
+				assert.NoError(t, err)
+				item, _, err := l1.GetByKey("a")
+				assert.NoError(t, err)
+				assert.Equal(t, want, item)
+			})
+		}
+	}
+}
+
+func TestTieredStoreGetMany(t *testing.T) {
+	l1, _, store := newTestTiers()
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b", "c"}))
+
+	items, missing := store.GetMany([]string{"a", "c", "d"})
+
+	assert.Equal(t, map[string]interface{}{"a": "a", "c": "c"}, items)
+	assert.Equal(t, []string{"d"}, missing)
+	assert.ElementsMatch(t, []string{"a", "c"}, l1.ListKeys())
+}
+
+func TestTieredStoreContains(t *testing.T) {
+	l1, _, store := newTestTiers()
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b", "c"}))
+
+	assert.True(t, store.Contains("a"))
+	assert.False(t, store.Contains("d"))
+	// Objects found in l2 are not promoted
+	assert.ElementsMatch(t, []string{"b", "c"}, l1.ListKeys())
+}
+
+func TestTieredStoreReplaceAndTxn(t *testing.T) {
+	l1, l2, store := newTestTiers()
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+
+	assert.NoError(t, store.Replace([]interface{}{"c"}))
+	assert.Zero(t, l1.Size())
+	assert.Equal(t, []string{"c"}, l2.ListKeys())
+
+	_, _, err := store.GetByKey("c")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Delete("c"))
+		return tx.Add("d")
+	}))
+	assert.Equal(t, []string{"d"}, l1.ListKeys())
+	assert.Equal(t, []string{"d"}, l2.ListKeys())
+}
+
+func TestTieredStoreBatchErrors(t *testing.T) {
+	l1 := NewEvictionCache[any](testKeyFunc, eviction.NewLRU[string](2), Indexers[any]{})
+	l2 := NewStore(testKeyFunc, WithMaxEntries(1))
+	store := NewTieredStore(l1, l2)
+
+	errs := store.AddAll([]interface{}{"a", "b"})
+
+	assert.Equal(t, []error{nil, ErrStoreFull}, errs)
+	assert.Equal(t, []string{"a"}, l1.ListKeys())
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/liuxinbot/cache/sets"
+)
+
+// Tokenizer splits texts into the tokens indexed by the IndexFunc made by
+// TokenIndexFunc and looked up by SearchByTokens, so that both split them
+// alike.
+type Tokenizer struct {
+	split     func(text string) []string
+	lower     bool
+	stopWords sets.Set[string]
+}
+
+// TokenizerOption configures optional behaviour of a Tokenizer created by
+// NewTokenizer.
+type TokenizerOption func(*Tokenizer)
+
+// NewTokenizer creates a Tokenizer splitting texts around whitespace, unless
+// configured otherwise by opts.
+func NewTokenizer(opts ...TokenizerOption) *Tokenizer {
+	t := &Tokenizer{split: strings.Fields}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.lower && t.stopWords != nil {
+		lowered := sets.NewSet[string]()
+		for word := range t.stopWords {
+			lowered.Insert(strings.ToLower(word))
+		}
+		t.stopWords = lowered
+	}
+	return t
+}
+
+// WithSplitFunc makes the Tokenizer split texts into tokens with split, such
+// as strings.FieldsFunc with a custom separator.
+func WithSplitFunc(split func(text string) []string) TokenizerOption {
+	return func(t *Tokenizer) {
+		t.split = split
+	}
+}
+
+// WithLowercase makes the Tokenizer lowercase the tokens, so that searches are
+// case-insensitive.
+func WithLowercase() TokenizerOption {
+	return func(t *Tokenizer) {
+		t.lower = true
+	}
+}
+
+// WithStopWords makes the Tokenizer drop words, such as "a" or "the", which
+// are too common to be worth indexing.
+func WithStopWords(words ...string) TokenizerOption {
+	return func(t *Tokenizer) {
+		if t.stopWords == nil {
+			t.stopWords = sets.NewSet[string]()
+		}
+		t.stopWords.Insert(words...)
+	}
+}
+
+// Tokens returns the distinct tokens of text, in the order they first appear,
+// leaving out the empty tokens and the stop words.
+func (t *Tokenizer) Tokens(text string) []string {
+	var tokens []string
+	seen := sets.NewSet[string]()
+	for _, token := range t.split(text) {
+		if t.lower {
+			token = strings.ToLower(token)
+		}
+		if token == "" || t.stopWords.Has(token) || seen.Has(token) {
+			continue
+		}
+		seen.Insert(token)
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// TokenIndexFunc returns an IndexFunc indexing every object under the tokens
+// of the text returned by text, as split by tokenizer, for SearchByTokens.
+func TokenIndexFunc[V any](tokenizer *Tokenizer, text func(obj V) string) ValueIndexFunc[string, V] {
+	return func(obj V) ([]string, error) {
+		return tokenizer.Tokens(text(obj)), nil
+	}
+}
+
+// TokenMatch is an object found by SearchByTokens, along with the number of
+// distinct tokens of the query it holds.
+type TokenMatch[V any] struct {
+	Object  V
+	Matches int
+}
+
+// valueMatcher is implemented by the stores of this package counting the
+// values of an index held by their objects.
+type valueMatcher[K comparable, V any] interface {
+	matchValues(indexName string, indexedValues []K) ([]TokenMatch[V], error)
+}
+
+// SearchByTokens returns the objects of store holding any token of query in
+// the specified index, built with TokenIndexFunc and tokenizer, ranked by the
+// number of tokens they hold, in descending order. Objects holding as many
+// tokens are in no particular order.
+func SearchByTokens[T comparable, V any](store ThreadSafeStore[string, T, V], indexName string, tokenizer *Tokenizer, query string) ([]TokenMatch[V], error) {
+	return searchByTokens[V](store, indexName, tokenizer, query)
+}
+
+// ListSearchByTokens returns the objects of store holding any token of query
+// in the specified index, like SearchByTokens.
+func ListSearchByTokens[T comparable](store IndexedStore[string, T], indexName string, tokenizer *Tokenizer, query string) ([]TokenMatch[interface{}], error) {
+	return searchByTokens[interface{}](store, indexName, tokenizer, query)
+}
+
+// searchByTokens matches the tokens of query in a single pass over the index,
+// and ranks the objects found.
+func searchByTokens[V any](store interface{}, indexName string, tokenizer *Tokenizer, query string) ([]TokenMatch[V], error) {
+	m, ok := store.(valueMatcher[string, V])
+	if !ok {
+		return nil, fmt.Errorf("token searches on %T: %w", store, errors.ErrUnsupported)
+	}
+	matches, err := m.matchValues(indexName, tokenizer.Tokens(query))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(matches, func(a, b TokenMatch[V]) int {
+		return cmp.Compare(b.Matches, a.Matches)
+	})
+	return matches, nil
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+func TestTokenizer(t *testing.T) {
+	assert.Equal(t, []string{"The", "quick", "fox", "the"}, NewTokenizer().Tokens(" The quick  fox the fox "))
+
+	tokenizer := NewTokenizer(WithLowercase(), WithStopWords("The", "a"))
+	assert.Equal(t, []string{"quick", "fox"}, tokenizer.Tokens("The quick Fox a FOX"))
+
+	tokenizer = NewTokenizer(WithSplitFunc(func(text string) []string {
+		return strings.FieldsFunc(text, func(r rune) bool { return r == ',' })
+	}))
+	assert.Equal(t, []string{"red", "green blue"}, tokenizer.Tokens("red,,green blue,red"))
+	assert.Empty(t, tokenizer.Tokens(""))
+}
+
+func TestSearchByTokens(t *testing.T) {
+	tokenizer := NewTokenizer(WithLowercase(), WithStopWords("the"))
+	indexers := Indexers[string]{"title": TokenIndexFunc(tokenizer, func(obj interface{}) string {
+		return obj.(string)
+	})}
+	stores := map[string]ThreadSafeStore[string, string, interface{}]{
+		"map":     NewThreadSafeStore(indexers, Indexes[string, string]{}),
+		"sharded": NewShardedThreadSafeStore(4, hashString, indexers),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.UpdateAll([]string{"1", "2", "3", "4"}, []interface{}{
+				"The Quick Brown Fox", "The Lazy Dog", "Quick Lazy Fox", "Brown Bear",
+			}))
+
+			// Repeated tokens and stop words of the query do not count
+			matches, err := SearchByTokens(store, "title", tokenizer, "lazy FOX the fox quick")
+			assert.NoError(t, err)
+			assert.Equal(t, []TokenMatch[interface{}]{{"Quick Lazy Fox", 3}}, matches[:1])
+			assert.ElementsMatch(t, []TokenMatch[interface{}]{
+				{"The Quick Brown Fox", 2}, {"The Lazy Dog", 1},
+			}, matches[1:])
+
+			matches, err = SearchByTokens(store, "title", tokenizer, "the unicorn")
+			assert.NoError(t, err)
+			assert.Empty(t, matches)
+
+			_, err = SearchByTokens(store, "missing", tokenizer, "fox")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestListSearchByTokens(t *testing.T) {
+	tokenizer := NewTokenizer(WithLowercase())
+	indexFunc := TokenIndexFunc(tokenizer, func(obj interface{}) string { return obj.(string) })
+
+	indexer := NewIndexer[string](testKeyFunc)
+	assert.NoError(t, indexer.AddIndexer("text", indexFunc))
+	evicting := NewEvictionCache(testKeyFunc, eviction.NewLRU[string](2), Indexers[string]{"text": indexFunc})
+	for name, store := range map[string]IndexedStore[string, string]{"indexer": indexer, "eviction": evicting} {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.AddAll([]interface{}{"red apple", "green apple", "red cherry"}))
+
+			matches, err := ListSearchByTokens(store, "text", tokenizer, "Red Apple")
+			assert.NoError(t, err)
+			if name == "eviction" {
+				// red apple was evicted
+				assert.ElementsMatch(t, []TokenMatch[interface{}]{{"green apple", 1}, {"red cherry", 1}}, matches)
+				return
+			}
+			assert.Equal(t, TokenMatch[interface{}]{"red apple", 2}, matches[0])
+			assert.Len(t, matches, 3)
+		})
+	}
+
+	// Stores implemented elsewhere are not supported
+	wrapped := struct{ IndexedStore[string, string] }{indexer}
+	_, err := ListSearchByTokens[string](wrapped, "text", tokenizer, "red")
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
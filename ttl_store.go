@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+// NewTTLStore creates a new Store whose objects expire once ttl has elapsed
+// since they were last added or updated, like the ExpirationCache of
+// client-go. Expired objects are removed as soon as a read would see them,
+// so they are never returned, listed or counted. A nil clock uses the real
+// time.
+func NewTTLStore[T comparable](keyFunc KeyFunc[T], ttl time.Duration, clock eviction.Clock) Store[T] {
+	opts := []EvictionOption[any, T]{WithTTL[any, T](ttl)}
+	if clock != nil {
+		opts = append(opts, WithClock[any, T](clock))
+	}
+	return ttlStore[T]{NewEvictionCacheWithOptions(keyFunc, opts...)}
+}
+
+// ttlStore implements the store of NewTTLStore over an eviction cache, which
+// already hides expired objects from lookups, ranges and pages, by deleting
+// them before the reads that would include them.
+type ttlStore[T comparable] struct {
+	EvictionStore[any, T]
+}
+
+// List returns all the unexpired items.
+func (s ttlStore[T]) List() []interface{} {
+	s.DeleteExpired()
+	return s.EvictionStore.List()
+}
+
+// ListKeys returns the keys of all the unexpired items.
+func (s ttlStore[T]) ListKeys() []T {
+	s.DeleteExpired()
+	return s.EvictionStore.ListKeys()
+}
+
+// Size returns the number of unexpired items.
+func (s ttlStore[T]) Size() int {
+	s.DeleteExpired()
+	return s.EvictionStore.Size()
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+func TestTTLStore(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Unix(0, 0))
+	store := NewTTLStore(testKeyFunc, time.Minute, clock)
+	assert.NoError(t, store.Add("a"))
+	clock.Step(30 * time.Second)
+	assert.NoError(t, store.Add("b"))
+
+	// Updating an object restarts its time to live
+	clock.Step(30 * time.Second)
+	assert.NoError(t, store.Update("b"))
+	_, exists, err := store.GetByKey("a")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, []string{"b"}, store.ListKeys())
+	assert.Equal(t, 1, store.Size())
+
+	clock.Step(59 * time.Second)
+	item, exists, _ := store.Get("b")
+	assert.True(t, exists)
+	assert.Equal(t, "b", item)
+
+	clock.Step(time.Second)
+	assert.Empty(t, store.List())
+	assert.Equal(t, 0, store.Size())
+
+	// Expired objects can be added again
+	assert.NoError(t, store.Add("a"))
+	assert.Equal(t, []string{"a"}, store.ListKeys())
+}
@@ -0,0 +1,364 @@
+// Package ttlcache provides an indexed cache whose objects expire once their
+// time to live has elapsed. Expired objects are removed from the store, and so
+// from its indices, before any read would see them, as well as periodically
+// by a janitor goroutine.
+package ttlcache
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/liuxinbot/cache"
+	"github.com/liuxinbot/cache/eviction"
+)
+
+// DefaultJanitorInterval is the interval at which the janitor of a Cache
+// removes the expired objects, unless set by WithJanitorInterval.
+const DefaultJanitorInterval = time.Minute
+
+// Expiration tells whether reading an object extends its time to live.
+type Expiration int
+
+const (
+	// Absolute makes objects expire once their time to live has elapsed since
+	// they were stored, such as cached content.
+	Absolute Expiration = iota
+	// Sliding makes objects expire once their time to live has elapsed since
+	// they were stored or last returned by Get, such as sessions.
+	Sliding
+)
+
+// Option configures optional behaviour of a Cache created by New.
+type Option func(*options)
+
+// options holds the optional settings applied by Option.
+type options struct {
+	clock           eviction.Clock
+	janitorInterval time.Duration
+	indexers        cache.Indexers[any]
+	expiration      Expiration
+}
+
+// WithClock sets the clock the objects expire by. By default the cache uses
+// the real time.
+func WithClock(clock eviction.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithJanitorInterval sets the interval at which the janitor removes the
+// expired objects. An interval of zero or less starts no janitor, leaving the
+// expired objects to be removed by the reads that would see them.
+func WithJanitorInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.janitorInterval = interval
+	}
+}
+
+// WithExpiration sets the Expiration of the objects stored by Set and
+// SetWithTTL. By default it is Absolute.
+func WithExpiration(expiration Expiration) Option {
+	return func(o *options) {
+		o.expiration = expiration
+	}
+}
+
+// WithIndexers sets the indexers of the cache. By default it has none.
+func WithIndexers(indexers cache.Indexers[any]) Option {
+	return func(o *options) {
+		o.indexers = indexers
+	}
+}
+
+// Cache is a thread-safe cache of objects stored under keys of type T, which
+// expire once their time to live has elapsed. It keeps its objects in a
+// cache.ThreadSafeStore, whose indices never hold expired objects once read.
+type Cache[T comparable] struct {
+	store      cache.ThreadSafeStore[any, T, interface{}]
+	defaultTTL time.Duration
+	expiration Expiration
+	clock      eviction.Clock
+
+	// mu is held for writing by the changes of the store, so that the store
+	// and the expiries change together, and for reading by the reads
+	mu sync.RWMutex
+	// expiries holds the expiry of the objects that expire by key
+	expiries map[T]expiry
+	// deadlines orders the scheduled expiries, including stale ones which no
+	// longer match expiries and are skipped
+	deadlines deadlineHeap[T]
+
+	stopJanitor chan struct{}
+	closeOnce   sync.Once
+}
+
+// New creates a new Cache whose objects expire after defaultTTL unless stored
+// by SetWithTTL. A defaultTTL of zero or less makes them never expire. Unless
+// configured otherwise by opts, a janitor removes the expired objects every
+// DefaultJanitorInterval until the cache is closed.
+func New[T comparable](defaultTTL time.Duration, opts ...Option) *Cache[T] {
+	o := options{janitorInterval: DefaultJanitorInterval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	indexers := o.indexers
+	if indexers == nil {
+		indexers = cache.Indexers[any]{}
+	}
+	c := &Cache[T]{
+		store:      cache.NewThreadSafeStore(indexers, cache.Indexes[any, T]{}),
+		defaultTTL: defaultTTL,
+		expiration: o.expiration,
+		clock:      o.clock,
+		expiries:   make(map[T]expiry),
+	}
+	if c.clock == nil {
+		c.clock = eviction.RealClock{}
+	}
+	if o.janitorInterval > 0 {
+		c.stopJanitor = make(chan struct{})
+		go c.runJanitor(o.janitorInterval, c.stopJanitor)
+	}
+	return c
+}
+
+// Set stores obj under key, expiring after the default time to live.
+func (c *Cache[T]) Set(key T, obj interface{}) error {
+	return c.SetWithTTL(key, obj, c.defaultTTL)
+}
+
+// SetWithTTL stores obj under key, expiring once ttl has elapsed. A ttl of
+// zero or less makes it never expire. If an indexer fails on obj, it returns
+// the error and leaves the object stored under key, if any, unchanged.
+func (c *Cache[T]) SetWithTTL(key T, obj interface{}, ttl time.Duration) error {
+	return c.SetWithExpiration(key, obj, ttl, c.expiration)
+}
+
+// SetWithExpiration stores obj under key like SetWithTTL, overriding the
+// Expiration of the cache for this object.
+func (c *Cache[T]) SetWithExpiration(key T, obj interface{}, ttl time.Duration, expiration Expiration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.store.Update(key, obj); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		delete(c.expiries, key)
+		return nil
+	}
+	at := c.clock.Now().Add(ttl)
+	c.expiries[key] = expiry{at: at, scheduled: at, ttl: ttl, sliding: expiration == Sliding}
+	heap.Push(&c.deadlines, deadline[T]{key, at})
+	c.compact()
+	return nil
+}
+
+// Get returns the object stored under key, unless it expired, in which case
+// it is removed. Getting an object with a Sliding expiration extends its time
+// to live, which then elapses from now on.
+func (c *Cache[T]) Get(key T) (interface{}, bool) {
+	obj, _, exists := c.GetWithExpiration(key)
+	return obj, exists
+}
+
+// GetWithExpiration returns the object stored under key like Get, along with
+// when it expires, such as to derive the max-age of an HTTP response. The
+// expiry is the zero time if the object never expires.
+func (c *Cache[T]) GetWithExpiration(key T) (obj interface{}, expiresAt time.Time, ok bool) {
+	now := c.clock.Now()
+	c.mu.RLock()
+	obj, exists := c.store.Get(key)
+	e, expires := c.expiries[key]
+	c.mu.RUnlock()
+	if !exists || !expires || (!e.sliding && now.Before(e.at)) {
+		return obj, e.at, exists
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The object may have been stored or removed again meanwhile
+	obj, exists = c.store.Get(key)
+	e, expires = c.expiries[key]
+	switch {
+	case !exists || !expires:
+	case !now.Before(e.at):
+		c.remove(key)
+		return nil, time.Time{}, false
+	case e.sliding:
+		// The deadline scheduled in the heap is left as is, and moved to the
+		// new expiry once due
+		e.at = now.Add(e.ttl)
+		c.expiries[key] = e
+	}
+	return obj, e.at, exists
+}
+
+// Delete removes the object stored under key, if any.
+func (c *Cache[T]) Delete(key T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+}
+
+// Len returns the number of unexpired objects.
+func (c *Cache[T]) Len() int {
+	c.DeleteExpired()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store.Size()
+}
+
+// List returns the unexpired objects, in no particular order.
+func (c *Cache[T]) List() []interface{} {
+	c.DeleteExpired()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store.List()
+}
+
+// ByIndex returns the unexpired objects holding indexedValue in the specified
+// index.
+func (c *Cache[T]) ByIndex(indexName string, indexedValue any) ([]interface{}, error) {
+	c.DeleteExpired()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store.ByIndex(indexName, indexedValue, nil)
+}
+
+// IndexKeys returns the keys of the unexpired objects holding indexedValue in
+// the specified index.
+func (c *Cache[T]) IndexKeys(indexName string, indexedValue any) ([]T, error) {
+	c.DeleteExpired()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store.IndexKeys(indexName, indexedValue, nil)
+}
+
+// AddIndexer adds an indexer, indexing the stored objects with it.
+func (c *Cache[T]) AddIndexer(indexName string, indexFunc cache.IndexFunc[any]) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.AddIndexer(indexName, indexFunc)
+}
+
+// DeleteExpired removes the expired objects, along with their index entries,
+// and returns their keys.
+func (c *Cache[T]) DeleteExpired() []T {
+	now := c.clock.Now()
+	c.mu.RLock()
+	due := len(c.deadlines) > 0 && !now.Before(c.deadlines[0].expiry)
+	c.mu.RUnlock()
+	if !due {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expiredKeys []T
+	for len(c.deadlines) > 0 && !now.Before(c.deadlines[0].expiry) {
+		d := heap.Pop(&c.deadlines).(deadline[T])
+		e, ok := c.expiries[d.key]
+		if !ok || !e.scheduled.Equal(d.expiry) {
+			continue
+		}
+		if now.Before(e.at) {
+			// A sliding expiry was extended since it was scheduled
+			e.scheduled = e.at
+			c.expiries[d.key] = e
+			heap.Push(&c.deadlines, deadline[T]{d.key, e.at})
+			continue
+		}
+		c.remove(d.key)
+		expiredKeys = append(expiredKeys, d.key)
+	}
+	return expiredKeys
+}
+
+// Close stops the janitor, if any.
+func (c *Cache[T]) Close() {
+	if c.stopJanitor != nil {
+		c.closeOnce.Do(func() { close(c.stopJanitor) })
+	}
+}
+
+// runJanitor calls DeleteExpired every interval until stop is closed.
+func (c *Cache[T]) runJanitor(interval time.Duration, stop chan struct{}) {
+	timer := c.clock.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			c.DeleteExpired()
+			timer.Reset(interval)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// remove deletes the object stored under key from the store and its indices.
+// Its deadline is left in the heap, where it is stale from then on.
+// The caller must hold c.mu for writing.
+func (c *Cache[T]) remove(key T) {
+	c.store.Delete(key)
+	delete(c.expiries, key)
+}
+
+// compact drops the stale deadlines once they outnumber the live ones, so
+// that storing the same keys again and again does not grow the heap.
+// The caller must hold c.mu for writing.
+func (c *Cache[T]) compact() {
+	if len(c.deadlines) <= 2*len(c.expiries)+16 {
+		return
+	}
+	c.deadlines = c.deadlines[:0]
+	for key, e := range c.expiries {
+		c.deadlines = append(c.deadlines, deadline[T]{key, e.scheduled})
+	}
+	heap.Init(&c.deadlines)
+}
+
+// expiry is when an object expires.
+type expiry struct {
+	at time.Time
+	// scheduled is the deadline of the object in the heap, which may precede
+	// at if a sliding expiry was extended since
+	scheduled time.Time
+	ttl       time.Duration
+	sliding   bool
+}
+
+// deadline is the scheduled expiry of the object stored under key.
+type deadline[T comparable] struct {
+	key    T
+	expiry time.Time
+}
+
+// deadlineHeap implements heap.Interface over deadlines, the earliest first.
+type deadlineHeap[T comparable] []deadline[T]
+
+func (h deadlineHeap[T]) Len() int {
+	return len(h)
+}
+
+func (h deadlineHeap[T]) Less(i, j int) bool {
+	return h[i].expiry.Before(h[j].expiry)
+}
+
+func (h deadlineHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *deadlineHeap[T]) Push(x interface{}) {
+	*h = append(*h, x.(deadline[T]))
+}
+
+func (h *deadlineHeap[T]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	d := old[n-1]
+	*h = old[:n-1]
+	return d
+}
@@ -0,0 +1,157 @@
+package ttlcache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache"
+	"github.com/liuxinbot/cache/eviction"
+)
+
+func firstLetterIndexFunc(obj interface{}) ([]any, error) {
+	s, ok := obj.(string)
+	if !ok || s == "" {
+		return nil, errors.New("not a word")
+	}
+	return []any{s[:1]}, nil
+}
+
+func TestCache(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Now())
+	c := New[int](time.Minute, WithClock(clock), WithJanitorInterval(0),
+		WithIndexers(cache.Indexers[any]{"first": firstLetterIndexFunc}))
+
+	assert.NoError(t, c.Set(1, "apple"))
+	assert.NoError(t, c.SetWithTTL(2, "avocado", 2*time.Minute))
+	assert.NoError(t, c.SetWithTTL(3, "apricot", 0))
+	assert.Error(t, c.Set(4, 4))
+	assert.Equal(t, 3, c.Len())
+
+	obj, exists := c.Get(1)
+	assert.True(t, exists)
+	assert.Equal(t, "apple", obj)
+
+	// 1 expires, and leaves the index even before any cleanup
+	clock.Step(time.Minute)
+	_, exists = c.Get(1)
+	assert.False(t, exists)
+	keys, err := c.IndexKeys("first", "a")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{2, 3}, keys)
+
+	// Storing 2 again renews its time to live
+	assert.NoError(t, c.SetWithTTL(2, "avocado", 2*time.Minute))
+	clock.Step(time.Minute)
+	objs, err := c.ByIndex("first", "a")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []interface{}{"avocado", "apricot"}, objs)
+
+	clock.Step(time.Minute)
+	assert.Equal(t, []int{2}, c.DeleteExpired())
+	assert.Equal(t, []interface{}{"apricot"}, c.List())
+	assert.Empty(t, c.DeleteExpired())
+
+	c.Delete(3)
+	assert.Zero(t, c.Len())
+}
+
+func TestCacheSlidingExpiration(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Now())
+	c := New[string](time.Minute, WithClock(clock), WithJanitorInterval(0), WithExpiration(Sliding))
+	assert.NoError(t, c.Set("session", "alice"))
+	assert.NoError(t, c.SetWithExpiration("content", "page", time.Minute, Absolute))
+
+	// Reading the session keeps it alive, unlike the content
+	for i := 0; i < 3; i++ {
+		clock.Step(40 * time.Second)
+		_, exists := c.Get("session")
+		assert.True(t, exists)
+	}
+	_, exists := c.Get("content")
+	assert.False(t, exists)
+
+	// Listing does not extend the time to live
+	clock.Step(40 * time.Second)
+	assert.Len(t, c.List(), 1)
+	clock.Step(20 * time.Second)
+	assert.Equal(t, []string{"session"}, c.DeleteExpired())
+	assert.Zero(t, c.Len())
+}
+
+func TestCacheGetWithExpiration(t *testing.T) {
+	now := time.Now()
+	clock := eviction.NewFakeClock(now)
+	c := New[string](time.Minute, WithClock(clock), WithJanitorInterval(0))
+	assert.NoError(t, c.Set("content", "page"))
+	assert.NoError(t, c.SetWithExpiration("session", "alice", time.Minute, Sliding))
+	assert.NoError(t, c.SetWithTTL("static", "logo", 0))
+
+	obj, expiresAt, exists := c.GetWithExpiration("content")
+	assert.True(t, exists)
+	assert.Equal(t, "page", obj)
+	assert.Equal(t, now.Add(time.Minute), expiresAt)
+
+	// The expiry returned for a sliding object is the extended one
+	clock.Step(30 * time.Second)
+	_, expiresAt, exists = c.GetWithExpiration("session")
+	assert.True(t, exists)
+	assert.Equal(t, now.Add(90*time.Second), expiresAt)
+
+	_, expiresAt, exists = c.GetWithExpiration("static")
+	assert.True(t, exists)
+	assert.True(t, expiresAt.IsZero())
+
+	clock.Step(30 * time.Second)
+	_, expiresAt, exists = c.GetWithExpiration("content")
+	assert.False(t, exists)
+	assert.True(t, expiresAt.IsZero())
+}
+
+func TestCacheAddIndexer(t *testing.T) {
+	c := New[string](0, WithJanitorInterval(0))
+	assert.NoError(t, c.Set("a", "apple"))
+	assert.NoError(t, c.AddIndexer("upper", func(obj interface{}) ([]any, error) {
+		return []any{strings.ToUpper(obj.(string))}, nil
+	}))
+	objs, err := c.ByIndex("upper", "APPLE")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"apple"}, objs)
+
+	_, err = c.ByIndex("missing", "APPLE")
+	assert.Error(t, err)
+}
+
+func TestCacheJanitor(t *testing.T) {
+	clock := eviction.NewFakeClock(time.Now())
+	c := New[int](time.Second, WithClock(clock), WithJanitorInterval(time.Minute),
+		WithIndexers(cache.Indexers[any]{"first": firstLetterIndexFunc}))
+	defer c.Close()
+	assert.NoError(t, c.Set(1, "apple"))
+
+	assert.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+	clock.Step(time.Minute)
+	assert.Eventually(t, func() bool {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		keys, err := c.store.IndexKeys("first", "a", nil)
+		return err == nil && len(keys) == 0
+	}, time.Second, time.Millisecond)
+
+	c.Close()
+	c.Close()
+}
+
+func TestCacheCompact(t *testing.T) {
+	c := New[int](time.Hour, WithJanitorInterval(0))
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, c.Set(1, i))
+	}
+	assert.LessOrEqual(t, len(c.deadlines), 2*len(c.expiries)+17)
+	obj, exists := c.Get(1)
+	assert.True(t, exists)
+	assert.Equal(t, 99, obj)
+}
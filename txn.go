@@ -0,0 +1,79 @@
+package cache
+
+// Txn buffers changes to a store until they are applied together by
+// Store.Txn. Later changes to a key replace earlier ones.
+type Txn[T comparable] interface {
+	// Add buffers the insertion of an object.
+	Add(obj interface{}) error
+
+	// Update buffers the modification of an object.
+	Update(obj interface{}) error
+
+	// Delete buffers the removal of an object.
+	Delete(obj interface{}) error
+}
+
+// txnChange is the last change buffered for a key.
+type txnChange struct {
+	obj     interface{}
+	deleted bool
+}
+
+// txn implements Txn, recording the last change of every key.
+type txn[T comparable] struct {
+	keyFunc KeyFunc[T]
+	// keys lists the changed keys in the order they were first changed
+	keys    []T
+	changes map[T]txnChange
+}
+
+// newTxn creates an empty transaction computing keys with keyFunc.
+func newTxn[T comparable](keyFunc KeyFunc[T]) *txn[T] {
+	return &txn[T]{
+		keyFunc: keyFunc,
+		changes: make(map[T]txnChange),
+	}
+}
+
+// Add buffers the insertion of obj.
+func (tx *txn[T]) Add(obj interface{}) error {
+	return tx.record(obj, false)
+}
+
+// Update buffers the modification of obj.
+func (tx *txn[T]) Update(obj interface{}) error {
+	return tx.record(obj, false)
+}
+
+// Delete buffers the removal of obj.
+func (tx *txn[T]) Delete(obj interface{}) error {
+	return tx.record(obj, true)
+}
+
+// record buffers a change of obj, replacing the previous change of its key.
+func (tx *txn[T]) record(obj interface{}, deleted bool) error {
+	key, err := tx.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+	if _, exists := tx.changes[key]; !exists {
+		tx.keys = append(tx.keys, key)
+	}
+	tx.changes[key] = txnChange{obj: obj, deleted: deleted}
+	return nil
+}
+
+// split returns the objects to store by key and the keys to delete.
+func (tx *txn[T]) split() (map[T]interface{}, []T) {
+	updates := make(map[T]interface{}, len(tx.changes))
+	var deletes []T
+	for _, key := range tx.keys {
+		change := tx.changes[key]
+		if change.deleted {
+			deletes = append(deletes, key)
+		} else {
+			updates[key] = change.obj
+		}
+	}
+	return updates, deletes
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxn(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+
+	err := store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Add("c"))
+		assert.NoError(t, tx.Delete("a"))
+		assert.NoError(t, tx.Add("d"))
+		assert.NoError(t, tx.Delete("d"))
+		// Nothing is applied before fn returns
+		assert.Equal(t, 2, store.Size())
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b", "c"}, store.ListKeys())
+}
+
+func TestTxnAbort(t *testing.T) {
+	store := NewStore(testKeyFunc)
+	assert.NoError(t, store.Add("a"))
+	errAbort := errors.New("abort")
+
+	err := store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Add("b"))
+		assert.NoError(t, tx.Delete("a"))
+		return errAbort
+	})
+
+	assert.ErrorIs(t, err, errAbort)
+	assert.Equal(t, []string{"a"}, store.ListKeys())
+}
+
+func TestTxnKeyError(t *testing.T) {
+	store := NewStore(func(obj interface{}) (string, error) {
+		return "", errors.New("no key")
+	})
+
+	err := store.Txn(func(tx Txn[string]) error {
+		return tx.Add("a")
+	})
+
+	assert.ErrorAs(t, err, &KeyError{})
+	assert.Zero(t, store.Size())
+}
+
+func TestTxnIndexError(t *testing.T) {
+	// Objects are "key:email"
+	store := NewIndexer[string, string](func(obj interface{}) (string, error) {
+		key, _, _ := strings.Cut(obj.(string), ":")
+		return key, nil
+	})
+	assert.NoError(t, store.AddUniqueIndexer("email", func(obj interface{}) ([]string, error) {
+		_, email, _ := strings.Cut(obj.(string), ":")
+		return []string{email}, nil
+	}))
+	assert.NoError(t, store.Add("a:x@"))
+
+	err := store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Add("b:y@"))
+		assert.NoError(t, tx.Delete("a:x@"))
+		return tx.Add("c:y@")
+	})
+
+	assert.ErrorIs(t, err, ErrUniqueConflict)
+	assert.Equal(t, []string{"a"}, store.ListKeys())
+}
+
+func TestTxnLimits(t *testing.T) {
+	store := NewStore(testKeyFunc, WithMaxEntries(2))
+	assert.Nil(t, store.AddAll([]interface{}{"a", "b"}))
+
+	// Deleting a key makes room for another in the same transaction
+	assert.NoError(t, store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Delete("a"))
+		return tx.Add("c")
+	}))
+	assert.ElementsMatch(t, []string{"b", "c"}, store.ListKeys())
+
+	err := store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Update("b"))
+		return tx.Add("d")
+	})
+	assert.ErrorIs(t, err, ErrStoreFull)
+	assert.ElementsMatch(t, []string{"b", "c"}, store.ListKeys())
+}
+
+func TestEvictionCacheTxn(t *testing.T) {
+	var evicted []int
+	store := NewEvictionCacheWithOptions(testIntKeyFunc,
+		WithOnEvicted[int, int](func(key int, obj interface{}, reason EvictionReason) {
+			evicted = append(evicted, key)
+		}))
+	assert.Nil(t, store.AddAll([]interface{}{1, 2}))
+
+	assert.NoError(t, store.Txn(func(tx Txn[int]) error {
+		assert.NoError(t, tx.Delete(1))
+		return tx.Add(3)
+	}))
+
+	assert.ElementsMatch(t, []int{2, 3}, store.ListKeys())
+	assert.Equal(t, []int{1}, evicted)
+}
+
+func TestSnapshotTxn(t *testing.T) {
+	called := false
+	err := NewStore(testKeyFunc).Snapshot().Txn(func(tx Txn[string]) error {
+		called = true
+		return nil
+	})
+
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.False(t, called)
+}
+
+func TestEvictionCacheTxnIndexError(t *testing.T) {
+	// Objects are "key:email"
+	store := NewEvictionCacheWithOptions[string, string](func(obj interface{}) (string, error) {
+		key, _, _ := strings.Cut(obj.(string), ":")
+		return key, nil
+	})
+	assert.NoError(t, store.AddUniqueIndexer("email", func(obj interface{}) ([]string, error) {
+		_, email, _ := strings.Cut(obj.(string), ":")
+		return []string{email}, nil
+	}))
+	assert.Nil(t, store.AddAll([]interface{}{"a:x@", "b:y@"}))
+
+	// Objects may swap their unique values in a transaction
+	assert.NoError(t, store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Update("a:y@"))
+		return tx.Update("b:x@")
+	}))
+	assert.ElementsMatch(t, []interface{}{"a:y@", "b:x@"}, store.List())
+
+	err := store.Txn(func(tx Txn[string]) error {
+		assert.NoError(t, tx.Delete("a:y@"))
+		assert.NoError(t, tx.Add("c:z@"))
+		return tx.Add("d:x@")
+	})
+	assert.ErrorIs(t, err, ErrUniqueConflict)
+	assert.ElementsMatch(t, []interface{}{"a:y@", "b:x@"}, store.List())
+}
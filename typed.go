@@ -0,0 +1,409 @@
+package cache
+
+import (
+	"fmt"
+	"iter"
+)
+
+// TypedStore is a Store whose objects all have the value type V, so that
+// they are passed and returned without type assertions.
+type TypedStore[T comparable, V any] interface {
+	// Add inserts an object.
+	Add(obj V) error
+
+	// Update modifies an existing object.
+	Update(obj V) error
+
+	// Delete removes an object.
+	Delete(obj V) error
+
+	// List returns all objects.
+	List() []V
+
+	// ListKeys returns all keys.
+	ListKeys() []T
+
+	// ListFiltered returns the objects for which pred returns true.
+	ListFiltered(pred func(obj V) bool) []V
+
+	// ListKeysFiltered returns the keys of the objects for which pred returns true.
+	ListKeysFiltered(pred func(obj V) bool) []T
+
+	// Range calls fn for every object until fn returns false.
+	Range(fn func(key T, obj V) bool)
+
+	// All returns an iterator over the keys and objects, like Range.
+	All() iter.Seq2[T, V]
+
+	// ListPage returns a page of objects, see Store.ListPage.
+	ListPage(limit int, continueToken string) (items []V, next string, err error)
+
+	// Get returns the object stored under the key of obj.
+	Get(obj V) (V, bool, error)
+
+	// GetByKey returns an object by its key.
+	GetByKey(key T) (V, bool, error)
+
+	// GetMany returns the objects stored under keys by key, along with the
+	// keys that were not found.
+	GetMany(keys []T) (map[T]V, []T)
+
+	// Contains reports whether an object is stored under key, see Store.Contains.
+	Contains(key T) bool
+
+	// GetMeta returns the history of the object stored under key, see Store.GetMeta.
+	GetMeta(key T) (EntryMeta, bool)
+
+	// Replace replaces all objects with the given list.
+	Replace(list []V) error
+
+	// Size returns count of object.
+	Size() int
+
+	// Snapshot returns a read-only copy of the objects, see Store.Snapshot.
+	Snapshot() TypedStore[T, V]
+
+	// Untyped returns the underlying Store.
+	Untyped() Store[T]
+}
+
+// TypedIndexedStore is an IndexedStore whose objects all have the value type V.
+type TypedIndexedStore[K, T comparable, V any] interface {
+	TypedStore[T, V]
+
+	// ListKeysByIndex returns storage keys of objects whose indexed values for the specified index include the given indexed value.
+	ListKeysByIndex(indexName string, indexedValue K) ([]T, error)
+
+	// ListByIndex returns objects whose indexed values for the specified index include the given indexed value.
+	ListByIndex(indexName string, indexedValue K) ([]V, error)
+
+	// CountByIndex returns the number of objects whose indexed values for the specified index include the given indexed value.
+	CountByIndex(indexName string, indexedValue K) (int, error)
+
+	// ListByIndexValues returns objects whose indexed values for the specified index include any of the given indexed values.
+	ListByIndexValues(indexName string, indexedValues ...K) ([]V, error)
+
+	// ListByIndexes returns objects whose indexed values include the given indexed value for every index in queries.
+	ListByIndexes(queries map[string]K) ([]V, error)
+
+	// Query returns a Query over the stored objects.
+	Query() *Query[K, T, V]
+
+	// ListIndexValues returns the distinct indexed values for the specified index.
+	ListIndexValues(indexName string) ([]K, error)
+
+	// IndexStats returns the number of keys held by each value of the specified index, along with their totals.
+	IndexStats(indexName string) (IndexStats[K], error)
+
+	// GroupByIndex returns the storage keys of the objects held by each indexed value of the specified index.
+	GroupByIndex(indexName string) (map[K][]T, error)
+
+	// GroupCountsByIndex returns the number of objects held by each indexed value of the specified index.
+	GroupCountsByIndex(indexName string) (map[K]int, error)
+
+	// AddIndexer add new indexer.
+	AddIndexer(indexName string, indexFunc func(obj V) ([]K, error)) error
+
+	// RemoveIndexer removes the named indexer along with its index.
+	RemoveIndexer(indexName string) error
+
+	// SetIndexNormalizer makes normalize map the values of the specified index.
+	SetIndexNormalizer(indexName string, normalize func(K) K) error
+
+	// Reindex rebuilds the specified index from the stored objects.
+	Reindex(indexName string) error
+
+	// OnIndexChange registers hook to be invoked whenever a key enters or leaves a value of the specified index.
+	OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error
+}
+
+// NewTypedStore creates a new TypedStore computing keys with keyFunc.
+func NewTypedStore[T comparable, V any](keyFunc func(obj V) (T, error), opts ...StoreOption) TypedStore[T, V] {
+	return Typed[T, V](NewStore(untypedKeyFunc(keyFunc), opts...))
+}
+
+// NewTypedIndexer creates a new TypedIndexedStore computing keys with keyFunc.
+func NewTypedIndexer[K, T comparable, V any](keyFunc func(obj V) (T, error), opts ...StoreOption) TypedIndexedStore[K, T, V] {
+	return TypedIndexed[K, T, V](NewIndexer[K](untypedKeyFunc(keyFunc), opts...))
+}
+
+// Typed wraps store, which must only hold objects of type V, in a TypedStore.
+// It works with any Store, including an EvictionStore.
+func Typed[T comparable, V any](store Store[T]) TypedStore[T, V] {
+	return &typedStore[T, V]{store: store}
+}
+
+// TypedIndexed wraps store, which must only hold objects of type V, in a
+// TypedIndexedStore.
+func TypedIndexed[K, T comparable, V any](store IndexedStore[K, T]) TypedIndexedStore[K, T, V] {
+	return &typedIndexedStore[K, T, V]{typedStore[T, V]{store: store}, store}
+}
+
+// untypedKeyFunc adapts a key function on V to a KeyFunc.
+func untypedKeyFunc[T comparable, V any](keyFunc func(obj V) (T, error)) KeyFunc[T] {
+	return func(obj interface{}) (T, error) {
+		v, ok := obj.(V)
+		if !ok {
+			var zero T
+			return zero, fmt.Errorf("object is %T, not %T", obj, v)
+		}
+		return keyFunc(v)
+	}
+}
+
+// typedStore implements TypedStore on top of a Store.
+type typedStore[T comparable, V any] struct {
+	store Store[T]
+}
+
+// Add inserts an object.
+func (s *typedStore[T, V]) Add(obj V) error {
+	return s.store.Add(obj)
+}
+
+// Update modifies an existing object.
+func (s *typedStore[T, V]) Update(obj V) error {
+	return s.store.Update(obj)
+}
+
+// Delete removes an object.
+func (s *typedStore[T, V]) Delete(obj V) error {
+	return s.store.Delete(obj)
+}
+
+// List returns all objects. Objects of another type, which can only be added
+// through the underlying Store, are skipped.
+func (s *typedStore[T, V]) List() []V {
+	return typedList[V](s.store.List())
+}
+
+// ListKeys returns all keys.
+func (s *typedStore[T, V]) ListKeys() []T {
+	return s.store.ListKeys()
+}
+
+// ListFiltered returns the objects for which pred returns true.
+func (s *typedStore[T, V]) ListFiltered(pred func(obj V) bool) []V {
+	return typedList[V](s.store.ListFiltered(func(obj interface{}) bool {
+		v, ok := obj.(V)
+		return ok && pred(v)
+	}))
+}
+
+// ListKeysFiltered returns the keys of the objects for which pred returns true.
+func (s *typedStore[T, V]) ListKeysFiltered(pred func(obj V) bool) []T {
+	return s.store.ListKeysFiltered(func(obj interface{}) bool {
+		v, ok := obj.(V)
+		return ok && pred(v)
+	})
+}
+
+// Range calls fn for every object until fn returns false, skipping objects
+// of another type.
+func (s *typedStore[T, V]) Range(fn func(key T, obj V) bool) {
+	s.store.Range(func(key T, obj interface{}) bool {
+		v, ok := obj.(V)
+		return !ok || fn(key, v)
+	})
+}
+
+// All returns an iterator over the keys and objects.
+func (s *typedStore[T, V]) All() iter.Seq2[T, V] {
+	return s.Range
+}
+
+// ListPage returns a page of objects, skipping objects of another type.
+func (s *typedStore[T, V]) ListPage(limit int, continueToken string) ([]V, string, error) {
+	list, next, err := s.store.ListPage(limit, continueToken)
+	if err != nil {
+		return nil, "", err
+	}
+	return typedList[V](list), next, nil
+}
+
+// Get returns the object stored under the key of obj.
+func (s *typedStore[T, V]) Get(obj V) (V, bool, error) {
+	return typedGet[V](s.store.Get(obj))
+}
+
+// GetByKey returns an object by its key.
+func (s *typedStore[T, V]) GetByKey(key T) (V, bool, error) {
+	return typedGet[V](s.store.GetByKey(key))
+}
+
+// GetMany returns the objects stored under keys and the keys not found.
+func (s *typedStore[T, V]) GetMany(keys []T) (map[T]V, []T) {
+	items, missing := s.store.GetMany(keys)
+	typed := make(map[T]V, len(items))
+	for key, item := range items {
+		if v, ok := item.(V); ok {
+			typed[key] = v
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return typed, missing
+}
+
+// Contains reports whether an object is stored under key.
+func (s *typedStore[T, V]) Contains(key T) bool {
+	return s.store.Contains(key)
+}
+
+// GetMeta returns the history of the object stored under key.
+func (s *typedStore[T, V]) GetMeta(key T) (EntryMeta, bool) {
+	return s.store.GetMeta(key)
+}
+
+// Replace replaces all objects with the given list.
+func (s *typedStore[T, V]) Replace(list []V) error {
+	untyped := make([]interface{}, len(list))
+	for i, obj := range list {
+		untyped[i] = obj
+	}
+	return s.store.Replace(untyped)
+}
+
+// Size returns count of object.
+func (s *typedStore[T, V]) Size() int {
+	return s.store.Size()
+}
+
+// Snapshot returns a read-only copy of the objects.
+func (s *typedStore[T, V]) Snapshot() TypedStore[T, V] {
+	return Typed[T, V](s.store.Snapshot())
+}
+
+// Untyped returns the underlying Store.
+func (s *typedStore[T, V]) Untyped() Store[T] {
+	return s.store
+}
+
+// typedIndexedStore implements TypedIndexedStore on top of an IndexedStore.
+type typedIndexedStore[K, T comparable, V any] struct {
+	typedStore[T, V]
+	indexed IndexedStore[K, T]
+}
+
+// ListKeysByIndex returns storage keys of objects whose indexed values include indexedValue.
+func (s *typedIndexedStore[K, T, V]) ListKeysByIndex(indexName string, indexedValue K) ([]T, error) {
+	return s.indexed.ListKeysByIndex(indexName, indexedValue)
+}
+
+// CountByIndex returns the number of objects whose indexed values include
+// indexedValue, counting those of another type too.
+func (s *typedIndexedStore[K, T, V]) CountByIndex(indexName string, indexedValue K) (int, error) {
+	return s.indexed.CountByIndex(indexName, indexedValue)
+}
+
+// ListByIndex returns objects whose indexed values include indexedValue.
+func (s *typedIndexedStore[K, T, V]) ListByIndex(indexName string, indexedValue K) ([]V, error) {
+	list, err := s.indexed.ListByIndex(indexName, indexedValue)
+	if err != nil {
+		return nil, err
+	}
+	return typedList[V](list), nil
+}
+
+// ListByIndexValues returns objects whose indexed values include any of
+// indexedValues.
+func (s *typedIndexedStore[K, T, V]) ListByIndexValues(indexName string, indexedValues ...K) ([]V, error) {
+	list, err := s.indexed.ListByIndexValues(indexName, indexedValues...)
+	if err != nil {
+		return nil, err
+	}
+	return typedList[V](list), nil
+}
+
+// ListByIndexes returns objects matching the indexed value of every index in
+// queries.
+func (s *typedIndexedStore[K, T, V]) ListByIndexes(queries map[string]K) ([]V, error) {
+	list, err := s.indexed.ListByIndexes(queries)
+	if err != nil {
+		return nil, err
+	}
+	return typedList[V](list), nil
+}
+
+// Query returns a Query over the stored objects, skipping objects of another
+// type.
+func (s *typedIndexedStore[K, T, V]) Query() *Query[K, T, V] {
+	return newQuery(s.ListByIndexes, s.Range)
+}
+
+// ListIndexValues returns the distinct indexed values for the specified index.
+func (s *typedIndexedStore[K, T, V]) ListIndexValues(indexName string) ([]K, error) {
+	return s.indexed.ListIndexValues(indexName)
+}
+
+// IndexStats returns the number of keys held by each value of the specified index.
+func (s *typedIndexedStore[K, T, V]) IndexStats(indexName string) (IndexStats[K], error) {
+	return s.indexed.IndexStats(indexName)
+}
+
+// GroupByIndex returns the storage keys of the objects held by each indexed
+// value of the specified index.
+func (s *typedIndexedStore[K, T, V]) GroupByIndex(indexName string) (map[K][]T, error) {
+	return s.indexed.GroupByIndex(indexName)
+}
+
+// GroupCountsByIndex returns the number of objects held by each indexed value
+// of the specified index.
+func (s *typedIndexedStore[K, T, V]) GroupCountsByIndex(indexName string) (map[K]int, error) {
+	return s.indexed.GroupCountsByIndex(indexName)
+}
+
+// AddIndexer add new indexer computing the indexed values of objects of type V.
+func (s *typedIndexedStore[K, T, V]) AddIndexer(indexName string, indexFunc func(obj V) ([]K, error)) error {
+	return s.indexed.AddIndexer(indexName, func(obj interface{}) ([]K, error) {
+		v, ok := obj.(V)
+		if !ok {
+			return nil, fmt.Errorf("object is %T, not %T", obj, v)
+		}
+		return indexFunc(v)
+	})
+}
+
+// RemoveIndexer removes the named indexer along with its index.
+func (s *typedIndexedStore[K, T, V]) RemoveIndexer(indexName string) error {
+	return s.indexed.RemoveIndexer(indexName)
+}
+
+// SetIndexNormalizer makes normalize map the values of the specified index.
+func (s *typedIndexedStore[K, T, V]) SetIndexNormalizer(indexName string, normalize func(K) K) error {
+	return s.indexed.SetIndexNormalizer(indexName, normalize)
+}
+
+// OnIndexChange registers hook for the changes of the specified index.
+func (s *typedIndexedStore[K, T, V]) OnIndexChange(indexName string, hook IndexChangeFunc[K, T]) error {
+	return s.indexed.OnIndexChange(indexName, hook)
+}
+
+// Reindex rebuilds the specified index from the stored objects.
+func (s *typedIndexedStore[K, T, V]) Reindex(indexName string) error {
+	return s.indexed.Reindex(indexName)
+}
+
+// typedGet converts the result of Store.Get or Store.GetByKey to V.
+func typedGet[V any](item interface{}, exists bool, err error) (V, bool, error) {
+	var zero V
+	if err != nil || !exists {
+		return zero, exists, err
+	}
+	v, ok := item.(V)
+	if !ok {
+		return zero, false, fmt.Errorf("object is %T, not %T", item, zero)
+	}
+	return v, true, nil
+}
+
+// typedList converts a list of objects to V, skipping objects of other types.
+func typedList[V any](list []interface{}) []V {
+	typed := make([]V, 0, len(list))
+	for _, item := range list {
+		if v, ok := item.(V); ok {
+			typed = append(typed, v)
+		}
+	}
+	return typed
+}
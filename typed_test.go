@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuxinbot/cache/eviction"
+)
+
+type typedUser struct {
+	Name string
+	Age  int
+}
+
+func typedUserKey(u *typedUser) (string, error) {
+	return u.Name, nil
+}
+
+func TestTypedStore(t *testing.T) {
+	store := NewTypedStore(typedUserKey)
+	alice := &typedUser{"alice", 30}
+	bob := &typedUser{"bob", 40}
+	assert.NoError(t, store.Add(alice))
+	assert.NoError(t, store.Add(bob))
+
+	user, exists, err := store.GetByKey("alice")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 30, user.Age)
+
+	user, exists, err = store.Get(&typedUser{Name: "bob"})
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Same(t, bob, user)
+
+	user, exists, err = store.GetByKey("carol")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Nil(t, user)
+
+	assert.ElementsMatch(t, []*typedUser{alice, bob}, store.List())
+	ages := 0
+	store.Range(func(key string, u *typedUser) bool {
+		ages += u.Age
+		return true
+	})
+	assert.Equal(t, 70, ages)
+	assert.Equal(t, []*typedUser{bob}, store.ListFiltered(func(u *typedUser) bool { return u.Age > 35 }))
+	assert.Equal(t, []string{"alice"}, store.ListKeysFiltered(func(u *typedUser) bool { return u.Age < 35 }))
+	for name, u := range store.All() {
+		assert.Equal(t, name, u.Name)
+	}
+	users, missing := store.GetMany([]string{"alice", "carol"})
+	assert.Equal(t, map[string]*typedUser{"alice": alice}, users)
+	assert.Equal(t, []string{"carol"}, missing)
+
+	assert.NoError(t, store.Replace([]*typedUser{bob}))
+	assert.Equal(t, []string{"bob"}, store.ListKeys())
+	assert.NoError(t, store.Delete(bob))
+	assert.Equal(t, 0, store.Size())
+}
+
+func TestTypedStoreWrongType(t *testing.T) {
+	store := NewTypedStore(typedUserKey)
+
+	// Objects of another type can only come through the untyped store
+	assert.Error(t, store.Untyped().Add("alice"))
+
+	untyped := NewStore(testKeyFunc)
+	assert.NoError(t, untyped.Add("alice"))
+	typed := Typed[string, *typedUser](untyped)
+	_, exists, err := typed.GetByKey("alice")
+	assert.Error(t, err)
+	assert.False(t, exists)
+	assert.Empty(t, typed.List())
+}
+
+func TestTypedIndexer(t *testing.T) {
+	store := NewTypedIndexer[int](typedUserKey)
+	assert.NoError(t, store.AddIndexer("age", func(u *typedUser) ([]int, error) {
+		return []int{u.Age}, nil
+	}))
+	assert.NoError(t, store.Add(&typedUser{"alice", 30}))
+	assert.NoError(t, store.Add(&typedUser{"bob", 40}))
+
+	users, err := store.ListByIndex("age", 30)
+	assert.NoError(t, err)
+	assert.Equal(t, []*typedUser{{"alice", 30}}, users)
+	keys, err := store.ListKeysByIndex("age", 40)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, keys)
+
+	assert.NoError(t, store.RemoveIndexer("age"))
+	_, err = store.ListByIndex("age", 30)
+	assert.Error(t, err)
+}
+
+func TestTypedIndexerListByIndexValues(t *testing.T) {
+	store := NewTypedIndexer[int](typedUserKey)
+	assert.NoError(t, store.AddIndexer("age", func(u *typedUser) ([]int, error) {
+		return []int{u.Age}, nil
+	}))
+	alice, bob := &typedUser{"alice", 30}, &typedUser{"bob", 40}
+	assert.NoError(t, store.Add(alice))
+	assert.NoError(t, store.Add(bob))
+	assert.NoError(t, store.Add(&typedUser{"carol", 50}))
+
+	users, err := store.ListByIndexValues("age", 30, 40, 60)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []*typedUser{alice, bob}, users)
+}
+
+func TestTypedEvictionStore(t *testing.T) {
+	store := TypedIndexed[int, int, int](NewEvictionCache(testIntKeyFunc, eviction.NewLRU[int](2), make(Indexers[int])))
+	assert.NoError(t, store.Add(1))
+	assert.NoError(t, store.Add(2))
+	assert.NoError(t, store.Add(3))
+
+	value, exists, err := store.GetByKey(3)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 3, value)
+	assert.ElementsMatch(t, []int{2, 3}, store.List())
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStaleVersion is returned when storing an object whose version is older
+// than the version of the object stored under its key, for stores created
+// WithVersionFunc.
+var ErrStaleVersion = errors.New("object version is older than the stored one")
+
+// VersionFunc returns the version of an object, such as its resourceVersion.
+// Versions increase with every change of the object.
+type VersionFunc func(obj interface{}) (uint64, error)
+
+// versionOf returns the version of obj, or an error describing why it has none.
+// The cache must have been created WithVersionFunc.
+func (c *cache[K, T]) versionOf(obj interface{}) (uint64, error) {
+	version, err := c.version(obj)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get version of object %+v: %w", obj, err)
+	}
+	return version, nil
+}
+
+// checkVersion returns ErrStaleVersion if current, the object stored under
+// key if exists, has a newer version than version.
+func (c *cache[K, T]) checkVersion(key T, version uint64, current interface{}, exists bool) error {
+	if !exists {
+		return nil
+	}
+	stored, err := c.version(current)
+	if err == nil && stored > version {
+		return fmt.Errorf("%w: %v has version %d, got %d", ErrStaleVersion, key, stored, version)
+	}
+	return nil
+}
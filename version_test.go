@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPairVersion(obj interface{}) (uint64, error) {
+	pair, ok := obj.(testPair)
+	if !ok {
+		return 0, errors.New("object is not a testPair")
+	}
+	return uint64(pair.value), nil
+}
+
+func TestStoreVersionFunc(t *testing.T) {
+	store := NewStore(testPairKeyFunc, WithVersionFunc(testPairVersion))
+	assert.NoError(t, store.Add(testPair{"a", 2}))
+	assert.ErrorIs(t, store.Update(testPair{"a", 1}), ErrStaleVersion)
+	assert.NoError(t, store.Update(testPair{"a", 2}))
+	assert.NoError(t, store.Update(testPair{"a", 3}))
+
+	errs := store.UpdateAll([]interface{}{testPair{"a", 1}, testPair{"b", 1}})
+	assert.ErrorIs(t, errs[0], ErrStaleVersion)
+	assert.NoError(t, errs[1])
+
+	item, _, _ := store.GetByKey("a")
+	assert.Equal(t, testPair{"a", 3}, item)
+	assert.Equal(t, 2, store.Size())
+
+	// Deleted objects can be added again with any version
+	assert.NoError(t, store.Delete(testPair{"a", 0}))
+	assert.NoError(t, store.Add(testPair{"a", 1}))
+}
+
+func TestStoreVersionFuncLimits(t *testing.T) {
+	store := NewStore(testPairKeyFunc, WithVersionFunc(testPairVersion), WithMaxEntries(1))
+	assert.NoError(t, store.Add(testPair{"a", 2}))
+	assert.ErrorIs(t, store.Update(testPair{"a", 1}), ErrStaleVersion)
+	assert.ErrorIs(t, store.Add(testPair{"b", 1}), ErrStoreFull)
+	assert.NoError(t, store.Update(testPair{"a", 3}))
+}
+
+func TestStoreVersionFuncConcurrent(t *testing.T) {
+	store := NewStore(testPairKeyFunc, WithVersionFunc(testPairVersion))
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = store.Update(testPair{"a", i})
+		}()
+	}
+	wg.Wait()
+
+	// The newest version wins whatever the order of the updates
+	item, _, _ := store.GetByKey("a")
+	assert.Equal(t, testPair{"a", 100}, item)
+}
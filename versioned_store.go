@@ -0,0 +1,235 @@
+package cache
+
+import "sync"
+
+// VersionedStore is a Store remembering the last values stored under every
+// key, so that successive versions of an object can be compared.
+type VersionedStore[T comparable] interface {
+	Store[T]
+
+	// GetVersion returns the value stored under key n changes ago, 0 being
+	// the current one, and false if key is not stored or fewer values are
+	// retained.
+	GetVersion(key T, n int) (interface{}, bool)
+
+	// History returns the retained values of key from the oldest to the
+	// current one, or nil if key is not stored.
+	History(key T) []interface{}
+}
+
+// NewVersionedStore creates a VersionedStore over store retaining up to n
+// values per key, including the current one. Every Add, Update or
+// replacement of an object records a version, even if its value did not
+// change. Deleting an object forgets its history, as does the store dropping
+// it by itself, such as an eviction cache evicting it, which is noticed the
+// next time the key changes.
+func NewVersionedStore[T comparable](store Store[T], n int) VersionedStore[T] {
+	if n < 1 {
+		n = 1
+	}
+	return &versionedStore[T]{
+		Store:   store,
+		limit:   n,
+		history: make(map[T][]interface{}),
+	}
+}
+
+// versionedStore implements VersionedStore by recording the objects that
+// mutations leave stored.
+type versionedStore[T comparable] struct {
+	Store[T]
+	limit int
+	// mu is held for writing by mutations, so that the history follows the
+	// store, and for reading by lookups of the history
+	mu      sync.RWMutex
+	history map[T][]interface{}
+}
+
+var _ VersionedStore[string] = &versionedStore[string]{}
+
+// Add inserts an item into the store.
+func (s *versionedStore[T]) Add(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.Store.Add(obj)
+	if err == nil {
+		s.syncObjs(obj)
+	}
+	return err
+}
+
+// Update sets an item in the store to its updated state.
+func (s *versionedStore[T]) Update(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.Store.Update(obj)
+	if err == nil {
+		s.syncObjs(obj)
+	}
+	return err
+}
+
+// Delete removes an item from the store.
+func (s *versionedStore[T]) Delete(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.Store.Delete(obj)
+	s.syncObjs(obj)
+	return err
+}
+
+// AddAll inserts items into the store in a single batch.
+func (s *versionedStore[T]) AddAll(objs []interface{}) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := s.Store.AddAll(objs)
+	s.syncObjs(succeededObjs(objs, errs)...)
+	return errs
+}
+
+// UpdateAll sets items in the store to their updated state in a single batch.
+func (s *versionedStore[T]) UpdateAll(objs []interface{}) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := s.Store.UpdateAll(objs)
+	s.syncObjs(succeededObjs(objs, errs)...)
+	return errs
+}
+
+// DeleteAll removes items from the store in a single batch.
+func (s *versionedStore[T]) DeleteAll(objs []interface{}) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := s.Store.DeleteAll(objs)
+	s.syncObjs(objs...)
+	return errs
+}
+
+// CompareAndSwap replaces old by new if old is the stored item.
+func (s *versionedStore[T]) CompareAndSwap(old, new interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swapped, err := s.Store.CompareAndSwap(old, new)
+	if swapped {
+		s.syncObjs(new)
+	}
+	return swapped, err
+}
+
+// CompareAndDelete removes old if it is the stored item.
+func (s *versionedStore[T]) CompareAndDelete(old interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted, err := s.Store.CompareAndDelete(old)
+	if deleted {
+		s.syncObjs(old)
+	}
+	return deleted, err
+}
+
+// Replace will delete the contents of the store, using instead the given list.
+func (s *versionedStore[T]) Replace(list []interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.Store.Replace(list)
+	s.rebuild()
+	return err
+}
+
+// ReplaceWithDiff replaces the contents of the store and reports what changed.
+func (s *versionedStore[T]) ReplaceWithDiff(list []interface{}) ([]T, []T, []T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	added, updated, removed, err := s.Store.ReplaceWithDiff(list)
+	s.rebuild()
+	return added, updated, removed, err
+}
+
+// Txn applies the changes buffered by fn atomically.
+func (s *versionedStore[T]) Txn(fn func(tx Txn[T]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changed []interface{}
+	err := s.Store.Txn(func(tx Txn[T]) error {
+		return fn(recordingTxn[T]{tx, &changed})
+	})
+	if err == nil {
+		s.syncObjs(changed...)
+	}
+	return err
+}
+
+// GetVersion returns the value of key n changes ago.
+func (s *versionedStore[T]) GetVersion(key T, n int) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := s.history[key]
+	if n < 0 || n >= len(versions) {
+		return nil, false
+	}
+	return versions[len(versions)-1-n], true
+}
+
+// History returns the retained values of key from the oldest to the current one.
+func (s *versionedStore[T]) History(key T) []interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := s.history[key]
+	if versions == nil {
+		return nil
+	}
+	return append([]interface{}(nil), versions...)
+}
+
+// syncObjs records the stored values of the keys of objs as their current
+// version, or forgets the keys that are no longer stored. The caller must
+// hold s.mu.
+func (s *versionedStore[T]) syncObjs(objs ...interface{}) {
+	for _, obj := range objs {
+		key, err := s.Store.KeyOf(obj)
+		if err != nil {
+			continue
+		}
+		if item, exists, _ := s.Store.GetByKey(key); exists {
+			s.record(key, item)
+		} else {
+			delete(s.history, key)
+		}
+	}
+}
+
+// succeededObjs returns the objects of a batch whose errors, errs, are nil.
+func succeededObjs(objs []interface{}, errs []error) []interface{} {
+	if errs == nil {
+		return objs
+	}
+	var list []interface{}
+	for i, obj := range objs {
+		if errs[i] == nil {
+			list = append(list, obj)
+		}
+	}
+	return list
+}
+
+// rebuild records the stored values of all keys, forgetting the keys that are
+// no longer stored. The caller must hold s.mu.
+func (s *versionedStore[T]) rebuild() {
+	old := s.history
+	s.history = make(map[T][]interface{}, len(old))
+	s.Store.Range(func(key T, obj interface{}) bool {
+		s.history[key] = old[key]
+		s.record(key, obj)
+		return true
+	})
+}
+
+// record appends obj to the versions of key, dropping the oldest ones beyond
+// the limit. The caller must hold s.mu.
+func (s *versionedStore[T]) record(key T, obj interface{}) {
+	versions := append(s.history[key], obj)
+	if len(versions) > s.limit {
+		versions = append(versions[:0:0], versions[len(versions)-s.limit:]...)
+	}
+	s.history[key] = versions
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedStore(t *testing.T) {
+	store := NewVersionedStore(NewStore(testPairKeyFunc), 2)
+	assert.NoError(t, store.Add(testPair{"a", 1}))
+	assert.Equal(t, []interface{}{testPair{"a", 1}}, store.History("a"))
+	assert.NoError(t, store.Update(testPair{"a", 2}))
+	assert.NoError(t, store.Update(testPair{"a", 3}))
+
+	// Only the last two versions are retained
+	assert.Equal(t, []interface{}{testPair{"a", 2}, testPair{"a", 3}}, store.History("a"))
+	obj, ok := store.GetVersion("a", 0)
+	assert.True(t, ok)
+	assert.Equal(t, testPair{"a", 3}, obj)
+	obj, ok = store.GetVersion("a", 1)
+	assert.True(t, ok)
+	assert.Equal(t, testPair{"a", 2}, obj)
+	_, ok = store.GetVersion("a", 2)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Delete(testPair{"a", 3}))
+	assert.Nil(t, store.History("a"))
+	_, ok = store.GetVersion("a", 0)
+	assert.False(t, ok)
+}
+
+func TestVersionedStoreReplace(t *testing.T) {
+	store := NewVersionedStore(NewStore(testPairKeyFunc), 3)
+	assert.Nil(t, store.AddAll([]interface{}{testPair{"a", 1}, testPair{"b", 1}}))
+	assert.NoError(t, store.Replace([]interface{}{testPair{"a", 2}, testPair{"c", 1}}))
+
+	assert.Equal(t, []interface{}{testPair{"a", 1}, testPair{"a", 2}}, store.History("a"))
+	assert.Nil(t, store.History("b"))
+	assert.Equal(t, []interface{}{testPair{"c", 1}}, store.History("c"))
+}
+
+func TestVersionedStoreRejected(t *testing.T) {
+	store := NewVersionedStore(NewStore(testPairKeyFunc, WithMaxEntries(1)), 3)
+	assert.NoError(t, store.Add(testPair{"a", 1}))
+	errs := store.AddAll([]interface{}{testPair{"a", 2}, testPair{"b", 1}})
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], ErrStoreFull)
+
+	// Rejected objects are not recorded
+	assert.Equal(t, []interface{}{testPair{"a", 1}, testPair{"a", 2}}, store.History("a"))
+	assert.Nil(t, store.History("b"))
+}